@@ -0,0 +1,133 @@
+// Command keyctl manages the access token signing key ring.
+//
+// Rotation procedure:
+//
+//	keyctl generate -alg ES256   # publish a new key (verify-only)
+//	# wait for JWT_KEY_REFRESH_INTERVAL plus the JWKS cache lifetime
+//	keyctl promote <kid>         # start signing with it
+//	# wait for JWT_ACCESS_EXPIRY
+//	keyctl retire                # drop previous keys
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/config"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const usage = `Usage: keyctl <command> [arguments]
+
+Commands:
+  list                  list signing keys
+  generate [-alg ALG]   generate a verify-only key (HS256, RS256, ES256, EdDSA)
+  promote <kid>         make a key the active signing key
+  retire                delete previous keys older than JWT_ACCESS_EXPIRY
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "keyctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, command string, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return err
+	}
+
+	cipher, err := auth.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		return err
+	}
+
+	keyService := service.NewSigningKeyService(
+		repository.NewSigningKeyRepository(db),
+		nil, // the ring is only used by Reload, which keyctl never calls
+		cipher,
+		cfg.JWTAccessExpiry,
+	)
+
+	switch command {
+	case "list":
+		keys, err := keyService.List(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KID\tALG\tSTATUS\tCREATED\tACTIVATED\tDEACTIVATED")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				k.ID, k.Algorithm, k.Status,
+				formatTime(&k.CreatedAt), formatTime(k.ActivatedAt), formatTime(k.DeactivatedAt))
+		}
+		return w.Flush()
+
+	case "generate":
+		fs := flag.NewFlagSet("generate", flag.ExitOnError)
+		alg := fs.String("alg", cfg.JWTSigningAlg, "signing algorithm")
+		_ = fs.Parse(args)
+
+		key, err := keyService.Generate(ctx, *alg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Generated %s key %s\n", key.Algorithm, key.ID)
+		fmt.Printf("Promote it after %s (key reload) plus the JWKS cache lifetime.\n", cfg.JWTKeyRefreshInterval)
+		return nil
+
+	case "promote":
+		if len(args) != 1 {
+			return errors.New("promote requires a key ID")
+		}
+		if err := keyService.Promote(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Promoted %s. Previous keys can be retired after %s.\n", args[0], cfg.JWTAccessExpiry)
+		return nil
+
+	case "retire":
+		n, err := keyService.Retire(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Retired %d key(s)\n", n)
+		return nil
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,171 @@
+// Command oauthctl manages the OAuth clients that may use this server as
+// their authorization server.
+//
+//	oauthctl create -name "Example" -redirect-uri https://app.example.com/callback
+//	oauthctl create -name "Worker" -grant client_credentials -scope reports:read
+//
+// Client secrets are printed once and only their hashes are stored.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/config"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const usage = `Usage: oauthctl <command> [arguments]
+
+Commands:
+  list                        list clients
+  create -name NAME [flags]   register a client (-h for flags)
+  delete <client_id>          delete a client, its consents and tokens
+  rotate-secret <client_id>   replace a confidential client's secret
+`
+
+// listFlag collects a repeatable or comma separated flag
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*f = append(*f, v)
+		}
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "oauthctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, command string, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return err
+	}
+
+	clientService := service.NewOAuthClientService(
+		repository.NewOAuthClientRepository(db),
+		auth.NewTokenHasher(cfg.TokenHashKey),
+	)
+
+	switch command {
+	case "list":
+		clients, err := clientService.List(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CLIENT_ID\tNAME\tTYPE\tGRANTS\tSCOPES\tREDIRECT_URIS")
+		for _, c := range clients {
+			clientType := "confidential"
+			if c.IsPublic() {
+				clientType = "public"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				c.ClientID, c.Name, clientType,
+				strings.Join(c.GrantTypes, ","), strings.Join(c.Scopes, ","), strings.Join(c.RedirectURIs, ","))
+		}
+		return w.Flush()
+
+	case "create":
+		var redirectURIs, grants, scopes listFlag
+		fs := flag.NewFlagSet("create", flag.ExitOnError)
+		name := fs.String("name", "", "client name shown on the consent page")
+		fs.Var(&redirectURIs, "redirect-uri", "allowed redirect URI (repeatable)")
+		fs.Var(&grants, "grant", "allowed grant type (default authorization_code,refresh_token)")
+		fs.Var(&scopes, "scope", "allowed scope (default openid,profile,email)")
+		public := fs.Bool("public", false, "public client without a secret (SPA, native app)")
+		skipConsent := fs.Bool("skip-consent", false, "trusted first-party client; users are not asked to consent")
+		_ = fs.Parse(args)
+
+		if len(grants) == 0 {
+			grants = listFlag{model.GrantAuthorizationCode, model.GrantRefreshToken}
+		}
+		if len(scopes) == 0 {
+			scopes = listFlag{service.ScopeOpenID, service.ScopeProfile, service.ScopeEmail}
+		}
+
+		client, secret, err := clientService.Create(ctx, service.OAuthClientRegistration{
+			Name:         *name,
+			RedirectURIs: redirectURIs,
+			GrantTypes:   grants,
+			Scopes:       scopes,
+			Public:       *public,
+			SkipConsent:  *skipConsent,
+		})
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidClientMetadata) {
+				return errors.New("invalid client: a name is required, authorization_code needs a redirect URI, and public clients cannot use client_credentials")
+			}
+			return err
+		}
+		fmt.Printf("Client ID:     %s\n", client.ClientID)
+		if secret != "" {
+			fmt.Printf("Client secret: %s\n", secret)
+			fmt.Println("Store the secret now; it cannot be shown again.")
+		}
+		return nil
+
+	case "delete":
+		if len(args) != 1 {
+			return errors.New("delete requires a client ID")
+		}
+		if err := clientService.Delete(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", args[0])
+		return nil
+
+	case "rotate-secret":
+		if len(args) != 1 {
+			return errors.New("rotate-secret requires a client ID")
+		}
+		secret, err := clientService.RotateSecret(ctx, args[0])
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidClientMetadata) {
+				return errors.New("public clients have no secret")
+			}
+			return err
+		}
+		fmt.Printf("Client secret: %s\n", secret)
+		fmt.Println("The previous secret no longer works.")
+		return nil
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
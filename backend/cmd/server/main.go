@@ -1,16 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/ablaze/gonexttemp-backend/internal/auth"
 	"github.com/ablaze/gonexttemp-backend/internal/config"
 	"github.com/ablaze/gonexttemp-backend/internal/handler"
+	"github.com/ablaze/gonexttemp-backend/internal/mail"
 	"github.com/ablaze/gonexttemp-backend/internal/middleware"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/oidc"
+	"github.com/ablaze/gonexttemp-backend/internal/pagination"
+	"github.com/ablaze/gonexttemp-backend/internal/passwordpolicy"
+	"github.com/ablaze/gonexttemp-backend/internal/ratelimit"
 	"github.com/ablaze/gonexttemp-backend/internal/repository"
 	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/internal/webauthn"
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -35,26 +47,301 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Load configured signing key (used to bootstrap the key ring)
+	signingKey, err := loadSigningKey(cfg)
+	if err != nil {
+		slog.Error("Failed to load signing key", "error", err)
+		os.Exit(1)
+	}
+
+	cipher, err := auth.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		slog.Error("Failed to initialize cipher", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize JWT manager
+	keyRing := auth.NewKeyRing(signingKey)
 	jwtManager := auth.NewJWTManager(
-		cfg.JWTSecret,
+		keyRing,
 		cfg.JWTAccessExpiry,
 		cfg.JWTRefreshExpiry,
 	)
+	tokenHasher := auth.NewTokenHasher(cfg.TokenHashKey)
+
+	mailer, err := loadMailer(cfg)
+	if err != nil {
+		slog.Error("Failed to initialize mailer", "error", err)
+		os.Exit(1)
+	}
+	mailer = mail.NewQueueMailer(mailer, mailQueueSize)
+
+	passwordPolicy, err := loadPasswordPolicy(cfg)
+	if err != nil {
+		slog.Error("Failed to initialize password policy", "error", err)
+		os.Exit(1)
+	}
+
+	oauthProviders, err := loadOAuthProviders(cfg)
+	if err != nil {
+		slog.Error("Failed to initialize identity providers", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	tokenRepo := repository.NewTokenRepository(db)
+	signingKeyRepo := repository.NewSigningKeyRepository(db)
+	revokedTokenRepo := repository.NewRevokedTokenRepository(db)
+	actionTokenRepo := repository.NewActionTokenRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	webauthnRepo := repository.NewWebAuthnRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	oauthClientRepo := repository.NewOAuthClientRepository(db)
+	oauthGrantRepo := repository.NewOAuthGrantRepository(db)
+	patRepo := repository.NewPersonalAccessTokenRepository(db)
+	serviceAccountRepo := repository.NewServiceAccountRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	adminActionRepo := repository.NewAdminActionRepository(db)
+	rateLimitRepo := repository.NewRateLimitRepository(db)
+	loginAttemptRepo := loadLoginAttemptRepository(cfg, db)
+
+	ctx := context.Background()
+
+	// Migration 000021 leaves non-ASCII addresses to userctl; until it has
+	// run, such users could not sign in or could share an address
+	stale, err := userRepo.CountStaleCanonicalEmails(ctx)
+	if err != nil {
+		slog.Error("Failed to check canonical email addresses", "error", err)
+		os.Exit(1)
+	}
+	if stale > 0 {
+		slog.Error("Users have email addresses that are not canonicalized; run `userctl canonicalize-emails` first", "users", stale)
+		os.Exit(1)
+	}
+
+	// Load the key ring and keep it in sync with other replicas
+	signingKeyService := service.NewSigningKeyService(signingKeyRepo, keyRing, cipher, cfg.JWTAccessExpiry)
+	if err := signingKeyService.Bootstrap(ctx, signingKey); err != nil {
+		slog.Error("Failed to bootstrap signing keys", "error", err)
+		os.Exit(1)
+	}
+	if err := signingKeyService.Reload(ctx); err != nil {
+		slog.Error("Failed to load signing keys", "error", err)
+		os.Exit(1)
+	}
+	go signingKeyService.Watch(ctx, cfg.JWTKeyRefreshInterval)
+	if keyRing.Active().IsSymmetric() {
+		slog.Warn("ID tokens are signed with HS256; OAuth clients cannot verify them without JWT_SECRET. Use an asymmetric JWT_SIGNING_ALG when acting as an identity provider.")
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, tokenRepo, jwtManager)
+	revocationService := service.NewRevocationService(
+		revokedTokenRepo,
+		tokenRepo,
+		cfg.JWTAccessExpiry,
+		cfg.RevocationCacheSize,
+		cfg.RevocationCacheTTL,
+	)
+	mfaService := service.NewMFAService(
+		mfaRepo,
+		userRepo,
+		cipher,
+		tokenHasher,
+		service.MFAOptions{
+			Issuer:                       cfg.MFAIssuer,
+			ChallengeExpiry:              cfg.MFAChallengeExpiry,
+			ChallengeAttempts:            cfg.MFAChallengeAttempts,
+			RecoveryCodeCount:            cfg.MFARecoveryCodes,
+			RecoveryCodeWarningThreshold: cfg.MFARecoveryCodesWarn,
+		},
+	)
+	passkeyService := service.NewPasskeyService(
+		webauthnRepo,
+		userRepo,
+		webauthn.New(webauthn.Config{
+			RPID:    cfg.WebAuthnRPID,
+			RPName:  cfg.WebAuthnRPName,
+			Origins: strings.Split(cfg.WebAuthnOrigins, ","),
+			Timeout: cfg.WebAuthnTimeout,
+		}),
+		tokenHasher,
+		service.PasskeyOptions{ChallengeExpiry: cfg.WebAuthnTimeout},
+	)
+	passwordHasher := auth.NewPasswordHasher(auth.PasswordOptions{
+		Argon2: auth.Argon2Params{
+			Memory:      cfg.PasswordArgon2Memory,
+			Iterations:  cfg.PasswordArgon2Iterations,
+			Parallelism: cfg.PasswordArgon2Parallelism,
+			SaltLength:  auth.DefaultArgon2Params.SaltLength,
+			KeyLength:   auth.DefaultArgon2Params.KeyLength,
+		},
+		Pepper:          cfg.PasswordPepper,
+		PepperID:        cfg.PasswordPepperID,
+		PreviousPeppers: cfg.PasswordPreviousPeppers,
+	})
+	loginThrottle := service.NewLoginThrottleService(
+		loginAttemptRepo,
+		service.LoginThrottleOptions{
+			FreeAttempts:       cfg.LoginFreeAttempts,
+			BackoffBase:        cfg.LoginBackoffBase,
+			BackoffMax:         cfg.LoginBackoffMax,
+			LockoutThreshold:   cfg.LoginLockoutThreshold,
+			LockoutDuration:    cfg.LoginLockoutDuration,
+			IPLockoutThreshold: cfg.LoginIPLockoutThreshold,
+			Window:             cfg.LoginAttemptWindow,
+		},
+	)
+	roleService := service.NewRoleService(roleRepo, userRepo, cfg.AuthzCacheSize, cfg.AuthzCacheTTL)
+	authService := service.NewAuthService(
+		userRepo,
+		tokenRepo,
+		actionTokenRepo,
+		revocationService,
+		mfaService,
+		passkeyService,
+		loginThrottle,
+		roleService,
+		jwtManager,
+		tokenHasher,
+		passwordHasher,
+		passwordPolicy,
+		mailer,
+		service.AuthOptions{
+			AppURL:                  cfg.AppURL,
+			PasswordResetExpiry:     cfg.PasswordResetExpiry,
+			EmailVerificationExpiry: cfg.EmailVerificationExpiry,
+			RequireVerifiedEmail:    cfg.EmailVerificationRequired == config.EmailVerificationLogin,
+			DeletedEmailHold:        deletedEmailHold(cfg),
+		},
+	)
+	sessionService := service.NewSessionService(tokenRepo, revocationService)
+	userService := service.NewUserService(
+		userRepo,
+		actionTokenRepo,
+		sessionService,
+		revocationService,
+		passwordHasher,
+		passwordPolicy,
+	)
+	emailChangeService := service.NewEmailChangeService(
+		userRepo,
+		actionTokenRepo,
+		sessionService,
+		passwordHasher,
+		tokenHasher,
+		mailer,
+		service.EmailChangeOptions{
+			AppURL:           cfg.AppURL,
+			Expiry:           cfg.EmailChangeExpiry,
+			RevertExpiry:     cfg.EmailChangeRevertExpiry,
+			DeletedEmailHold: deletedEmailHold(cfg),
+		},
+	)
+	adminUserService := service.NewAdminUserService(
+		userRepo,
+		roleRepo,
+		adminActionRepo,
+		actionTokenRepo,
+		sessionService,
+		revocationService,
+		authService,
+		loginThrottle,
+		pagination.NewSigner(cfg.TokenHashKey),
+		service.AdminUserOptions{
+			DeletedEmailHold: deletedEmailHold(cfg),
+		},
+	)
+	magicLinkService := service.NewMagicLinkService(
+		userRepo,
+		actionTokenRepo,
+		tokenHasher,
+		mailer,
+		loadRateLimiter(cfg, rateLimitRepo, cfg.MagicLinkEmailLimit, cfg.MagicLinkRateWindow),
+		loadRateLimiter(cfg, rateLimitRepo, cfg.MagicLinkIPLimit, cfg.MagicLinkRateWindow),
+		service.MagicLinkOptions{
+			AppURL:      cfg.AppURL,
+			Expiry:      cfg.MagicLinkExpiry,
+			BindBrowser: cfg.MagicLinkBindBrowser,
+		},
+	)
+	oauthService := service.NewOAuthService(
+		identityRepo,
+		userRepo,
+		oauthProviders,
+		tokenHasher,
+		service.OAuthOptions{
+			StateExpiry:       handler.OAuthStateMaxAge,
+			LinkVerifiedEmail: cfg.OAuthLinkPolicy == config.OAuthLinkVerifiedEmail,
+			DeletedEmailHold:  deletedEmailHold(cfg),
+		},
+	)
+	oauthClientService := service.NewOAuthClientService(oauthClientRepo, tokenHasher)
+	oauthServerService := service.NewOAuthServerService(
+		oauthClientService,
+		oauthClientRepo,
+		oauthGrantRepo,
+		userRepo,
+		revocationService,
+		jwtManager,
+		tokenHasher,
+		service.OAuthServerOptions{
+			Issuer:        cfg.APIURL,
+			ConsentURL:    cfg.AppURL + "/oauth/consent",
+			RequestExpiry: cfg.OAuthServerRequestExpiry,
+			CodeExpiry:    cfg.OAuthServerCodeExpiry,
+		},
+	)
+	patService := service.NewPersonalAccessTokenService(
+		patRepo,
+		userRepo,
+		tokenHasher,
+		service.PersonalAccessTokenOptions{MaxLifetime: cfg.PATMaxLifetime},
+	)
+	serviceAccountService := service.NewServiceAccountService(serviceAccountRepo, jwtManager, tokenHasher)
+
+	// Delete expired rows in the background; every replica may do so
+	if cfg.PurgeInterval > 0 {
+		purgeService := service.NewPurgeService(
+			tokenRepo,
+			revokedTokenRepo,
+			actionTokenRepo,
+			mfaRepo,
+			webauthnRepo,
+			oauthGrantRepo,
+			identityRepo,
+			rateLimitRepo,
+			loginAttemptRepo,
+			service.PurgeOptions{
+				RateLimitWindow:    cfg.MagicLinkRateWindow,
+				LoginAttemptWindow: cfg.LoginAttemptWindow,
+			},
+		)
+		go purgeService.Run(ctx, cfg.PurgeInterval)
+	}
 
 	// Initialize handlers
 	healthHandler := handler.NewHealthHandler()
+	jwksHandler := handler.NewJWKSHandler(jwtManager)
 	authHandler := handler.NewAuthHandler(authService)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	userHandler := handler.NewUserHandler(userService, emailChangeService)
+	mfaHandler := handler.NewMFAHandler(mfaService)
+	passkeyHandler := handler.NewPasskeyHandler(passkeyService, authService)
+	magicLinkHandler := handler.NewMagicLinkHandler(magicLinkService, authService, cfg.MagicLinkExpiry)
+	oauthHandler := handler.NewOAuthHandler(oauthService, authService, cfg.AppURL)
+	oauthServerHandler := handler.NewOAuthServerHandler(oauthServerService)
+	patHandler := handler.NewPersonalAccessTokenHandler(patService)
+	serviceAccountHandler := handler.NewServiceAccountHandler(serviceAccountService)
+	roleHandler := handler.NewRoleHandler(roleService)
+	adminUserHandler := handler.NewAdminUserHandler(adminUserService)
 
 	// Setup router
-	router := setupRouter(cfg, jwtManager, healthHandler, authHandler)
+	router, err := setupRouter(cfg, jwtManager, revocationService, patService, roleService, healthHandler, jwksHandler, authHandler, sessionHandler, userHandler, mfaHandler, passkeyHandler, magicLinkHandler, oauthHandler, oauthServerHandler, patHandler, serviceAccountHandler, roleHandler, adminUserHandler)
+	if err != nil {
+		slog.Error("Failed to set up router", "error", err)
+		os.Exit(1)
+	}
 
 	// Start server
 	addr := fmt.Sprintf(":%s", cfg.Port)
@@ -65,9 +352,122 @@ func main() {
 	}
 }
 
+func loadSigningKey(cfg *config.Config) (*auth.SigningKey, error) {
+	if cfg.JWTSigningAlg == auth.AlgHS256 {
+		return auth.NewHMACKey(cfg.JWTSecret), nil
+	}
+
+	pemData := []byte(cfg.JWTPrivateKey)
+	if cfg.JWTPrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		pemData = data
+	}
+
+	return auth.ParsePrivateKeyPEM(cfg.JWTSigningAlg, pemData, cfg.JWTKeyID)
+}
+
+// mailQueueSize bounds the messages waiting to be sent
+const mailQueueSize = 1000
+
+func loadMailer(cfg *config.Config) (mail.Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, errors.New("SMTP_HOST is required for the smtp mail driver")
+		}
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "file":
+		return mail.NewFileMailer(cfg.MailFileDir, cfg.MailFrom), nil
+	case "memory":
+		return mail.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+	}
+}
+
+// deletedEmailHold turns DELETED_EMAIL_POLICY into a hold duration, where
+// a negative hold keeps addresses for good
+func deletedEmailHold(cfg *config.Config) time.Duration {
+	switch cfg.DeletedEmailPolicy {
+	case config.DeletedEmailHold:
+		return cfg.DeletedEmailHold
+	case config.DeletedEmailNever:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func loadLoginAttemptRepository(cfg *config.Config, db *gorm.DB) repository.LoginAttemptRepository {
+	if cfg.LoginAttemptStore == "memory" {
+		return repository.NewMemoryLoginAttemptRepository()
+	}
+	return repository.NewLoginAttemptRepository(db)
+}
+
+// loadRateLimiter counts magic link requests in the database unless
+// MAGIC_LINK_RATE_STORE=memory
+func loadRateLimiter(cfg *config.Config, repo repository.RateLimitRepository, limit int, period time.Duration) ratelimit.Limiter {
+	if cfg.MagicLinkRateStore == "memory" {
+		return ratelimit.NewMemory(limit, period)
+	}
+	return ratelimit.NewStore(repo, "magic_link:", limit, period)
+}
+
+func loadPasswordPolicy(cfg *config.Config) (*passwordpolicy.Policy, error) {
+	opts := passwordpolicy.Options{
+		MinLength: cfg.PasswordMinLength,
+		MaxLength: cfg.PasswordMaxLength,
+	}
+	if cfg.PasswordDenylistFile != "" {
+		denylist, err := passwordpolicy.LoadDenylist(cfg.PasswordDenylistFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.Denylist = denylist
+	}
+	if cfg.PasswordBreachDir != "" {
+		if _, err := os.Stat(cfg.PasswordBreachDir); err != nil {
+			return nil, err
+		}
+		opts.Breaches = passwordpolicy.NewRangeDir(cfg.PasswordBreachDir, cfg.PasswordBreachMinCount)
+	}
+	return passwordpolicy.New(opts), nil
+}
+
+func loadOAuthProviders(cfg *config.Config) ([]*oidc.Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	providers := make([]*oidc.Provider, 0, len(cfg.OAuthProviders))
+	for _, p := range cfg.OAuthProviders {
+		var scopes []string
+		if p.Scopes != "" {
+			scopes = strings.Split(p.Scopes, ",")
+		}
+		provider, err := oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			Kind:         p.Kind,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			Scopes:       scopes,
+			RedirectURL:  cfg.APIURL + "/api/v1/auth/oauth/" + p.Name + "/callback",
+		}, client)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
 func connectDB(databaseURL string) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// Report unique violations as gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, err
@@ -87,17 +487,53 @@ func connectDB(databaseURL string) (*gorm.DB, error) {
 func setupRouter(
 	cfg *config.Config,
 	jwtManager *auth.JWTManager,
+	revocations middleware.RevocationChecker,
+	pats middleware.PersonalAccessTokenAuthenticator,
+	permissions middleware.PermissionResolver,
 	healthHandler *handler.HealthHandler,
+	jwksHandler *handler.JWKSHandler,
 	authHandler *handler.AuthHandler,
-) *gin.Engine {
+	sessionHandler *handler.SessionHandler,
+	userHandler *handler.UserHandler,
+	mfaHandler *handler.MFAHandler,
+	passkeyHandler *handler.PasskeyHandler,
+	magicLinkHandler *handler.MagicLinkHandler,
+	oauthHandler *handler.OAuthHandler,
+	oauthServerHandler *handler.OAuthServerHandler,
+	patHandler *handler.PersonalAccessTokenHandler,
+	serviceAccountHandler *handler.ServiceAccountHandler,
+	roleHandler *handler.RoleHandler,
+	adminUserHandler *handler.AdminUserHandler,
+) (*gin.Engine, error) {
 	router := gin.Default()
 
+	// Client IPs: X-Forwarded-For is only believed from configured proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+	switch cfg.TrustedPlatform {
+	case "fly":
+		router.TrustedPlatform = gin.PlatformFlyIO
+	case "cloudflare":
+		router.TrustedPlatform = gin.PlatformCloudflare
+	}
+
 	// Middleware
 	router.Use(middleware.CORSMiddleware(cfg.CORSOrigins))
 
 	// Health check
 	router.GET("/health", healthHandler.Health)
 
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+
+	// OAuth 2.0 / OpenID Connect provider endpoints for registered clients
+	router.GET("/.well-known/openid-configuration", oauthServerHandler.Configuration)
+	router.GET("/oauth/authorize", oauthServerHandler.Authorize)
+	router.POST("/oauth/token", oauthServerHandler.Token)
+	router.GET("/userinfo", oauthServerHandler.UserInfo)
+	router.POST("/userinfo", oauthServerHandler.UserInfo)
+
 	// API v1
 	v1 := router.Group("/api/v1")
 	{
@@ -106,17 +542,94 @@ func setupRouter(
 		{
 			authGroup.POST("/register", authHandler.Register)
 			authGroup.POST("/login", authHandler.Login)
+			authGroup.POST("/mfa/verify", authHandler.VerifyMFA)
+			authGroup.POST("/passkeys/login/options", passkeyHandler.LoginOptions)
+			authGroup.POST("/passkeys/login", passkeyHandler.Login)
+			authGroup.POST("/magic-link", magicLinkHandler.Send)
+			authGroup.POST("/magic-link/consume", magicLinkHandler.Consume)
+			authGroup.GET("/oauth/providers", oauthHandler.Providers)
+			authGroup.GET("/oauth/:provider/start", oauthHandler.Start)
+			authGroup.GET("/oauth/:provider/callback", oauthHandler.Callback)
+			authGroup.POST("/token", serviceAccountHandler.Token)
 			authGroup.POST("/refresh", authHandler.Refresh)
 			authGroup.POST("/logout", authHandler.Logout)
+			authGroup.POST("/password/forgot", authHandler.ForgotPassword)
+			authGroup.POST("/password/reset", authHandler.ResetPassword)
+			authGroup.POST("/verify-email", authHandler.VerifyEmail)
+			authGroup.POST("/verify-email/resend", authHandler.ResendVerification)
+			authGroup.POST("/email-change/confirm", userHandler.ConfirmEmailChange)
+			authGroup.POST("/email-change/revert", userHandler.RevertEmailChange)
 		}
 
-		// Protected routes
+		// Protected routes (JWTs, personal access tokens and service accounts)
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(jwtManager))
+		protected.Use(middleware.AuthMiddleware(jwtManager, revocations, pats, permissions))
+		{
+			protected.GET("/auth/me", middleware.RequireScope(auth.ScopeUserRead), authHandler.Me)
+		}
+
+		// Account security settings, which personal access tokens cannot reach
+		account := protected.Group("")
+		account.Use(middleware.RequireSession())
+		{
+			account.GET("/auth/sessions", sessionHandler.List)
+			account.DELETE("/auth/sessions/:id", sessionHandler.Revoke)
+			account.POST("/auth/logout-all", sessionHandler.LogoutAll)
+			account.PATCH("/users/me", userHandler.UpdateProfile)
+			account.POST("/users/me/password", userHandler.ChangePassword)
+			account.POST("/users/me/email", userHandler.RequestEmailChange)
+			account.DELETE("/users/me", userHandler.Delete)
+			account.POST("/auth/mfa/totp/enroll", mfaHandler.EnrollTOTP)
+			account.POST("/auth/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+			account.POST("/auth/mfa/totp/disable", mfaHandler.DisableTOTP)
+			account.POST("/auth/mfa/recovery-codes/regenerate", mfaHandler.RegenerateRecoveryCodes)
+			account.GET("/auth/passkeys", passkeyHandler.List)
+			account.POST("/auth/passkeys/register/options", passkeyHandler.RegistrationOptions)
+			account.POST("/auth/passkeys", passkeyHandler.Register)
+			account.DELETE("/auth/passkeys/:id", passkeyHandler.Delete)
+			account.POST("/auth/oauth/:provider/link", oauthHandler.Link)
+			account.GET("/auth/identities", oauthHandler.ListIdentities)
+			account.DELETE("/auth/identities/:id", oauthHandler.Unlink)
+			account.GET("/auth/tokens", patHandler.List)
+			account.DELETE("/auth/tokens/:id", patHandler.Revoke)
+			account.GET("/oauth/requests/:id", oauthServerHandler.GetRequest)
+			account.POST("/oauth/requests/:id/deny", oauthServerHandler.Deny)
+			account.GET("/oauth/consents", oauthServerHandler.ListConsents)
+			account.DELETE("/oauth/consents/:client_id", oauthServerHandler.RevokeConsent)
+			account.GET("/roles", middleware.RequirePermission(model.PermissionRolesRead), roleHandler.List)
+			account.GET("/users/:id/roles", middleware.RequirePermission(model.PermissionRolesRead), roleHandler.ListUserRoles)
+			account.PUT("/users/:id/roles/:role", middleware.RequirePermission(model.PermissionRolesAssign), roleHandler.Assign)
+			account.DELETE("/users/:id/roles/:role", middleware.RequirePermission(model.PermissionRolesAssign), roleHandler.Unassign)
+		}
+
+		// User administration. Reading needs users:read, changes users:write.
+		admin := account.Group("/admin/users")
+		admin.Use(middleware.RequirePermission(model.PermissionUsersRead))
+		{
+			write := middleware.RequirePermission(model.PermissionUsersWrite)
+			admin.GET("", adminUserHandler.List)
+			admin.GET("/:id", adminUserHandler.Get)
+			admin.POST("/:id/disable", write, adminUserHandler.Disable)
+			admin.POST("/:id/enable", write, adminUserHandler.Enable)
+			admin.POST("/:id/password-reset", write, adminUserHandler.ForcePasswordReset)
+			admin.DELETE("/:id/sessions", write, adminUserHandler.RevokeSessions)
+			admin.DELETE("/:id", write, adminUserHandler.Delete)
+			admin.POST("/:id/restore", write, adminUserHandler.Restore)
+			admin.POST("/:id/unlock", write, adminUserHandler.Unlock)
+		}
+
+		// Account routes that also require a verified email address when
+		// EMAIL_VERIFICATION_REQUIRED=routes. Unverified users can still
+		// sign in and manage their own security.
+		verified := account.Group("")
+		if cfg.EmailVerificationRequired == config.EmailVerificationRoutes {
+			verified.Use(middleware.RequireVerifiedEmail())
+		}
 		{
-			protected.GET("/auth/me", authHandler.Me)
+			verified.POST("/auth/tokens", patHandler.Create)
+			verified.POST("/oauth/requests/:id/approve", oauthServerHandler.Approve)
 		}
 	}
 
-	return router
+	return router, nil
 }
@@ -0,0 +1,164 @@
+// Command svcctl manages service accounts, the machine-to-machine
+// principals that obtain access tokens from POST /api/v1/auth/token.
+//
+//	svcctl create -name "Billing worker" -scope reports:read
+//	svcctl rotate-secret -overlap 1h <client_id>
+//
+// Client secrets are printed once and only their hashes are stored.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/config"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const usage = `Usage: svcctl <command> [arguments]
+
+Commands:
+  list                                   list service accounts and their secrets
+  create -name NAME -scope SCOPE         create a service account (-h for flags)
+  delete <client_id>                     delete a service account
+  rotate-secret [-overlap D] <client_id> issue a new secret; old ones expire after D
+`
+
+// listFlag collects a repeatable or comma separated flag
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*f = append(*f, v)
+		}
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "svcctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, command string, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only the client credentials grant signs tokens, which svcctl never
+	// does, so the key ring is left empty
+	accountService := service.NewServiceAccountService(
+		repository.NewServiceAccountRepository(db),
+		nil,
+		auth.NewTokenHasher(cfg.TokenHashKey),
+	)
+
+	switch command {
+	case "list":
+		accounts, err := accountService.List(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CLIENT_ID\tNAME\tSCOPES\tSECRETS")
+		for _, a := range accounts {
+			secrets := make([]string, 0, len(a.Secrets))
+			for _, s := range a.Secrets {
+				switch {
+				case s.IsExpired():
+					continue
+				case s.ExpiresAt != nil:
+					secrets = append(secrets, fmt.Sprintf("…%s (expires %s)", s.Hint, s.ExpiresAt.Format(time.RFC3339)))
+				default:
+					secrets = append(secrets, "…"+s.Hint)
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				a.ClientID, a.Name, strings.Join(a.Scopes, ","), strings.Join(secrets, ", "))
+		}
+		return w.Flush()
+
+	case "create":
+		var scopes listFlag
+		fs := flag.NewFlagSet("create", flag.ExitOnError)
+		name := fs.String("name", "", "service account name")
+		fs.Var(&scopes, "scope", "allowed scope (repeatable)")
+		_ = fs.Parse(args)
+
+		account, secret, err := accountService.Create(ctx, *name, scopes)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidServiceAccount) {
+				return errors.New("invalid service account: a name and at least one scope are required")
+			}
+			return err
+		}
+		fmt.Printf("Client ID:     %s\n", account.ClientID)
+		fmt.Printf("Client secret: %s\n", secret)
+		fmt.Println("Store the secret now; it cannot be shown again.")
+		return nil
+
+	case "delete":
+		if len(args) != 1 {
+			return errors.New("delete requires a client ID")
+		}
+		if err := accountService.Delete(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", args[0])
+		return nil
+
+	case "rotate-secret":
+		fs := flag.NewFlagSet("rotate-secret", flag.ExitOnError)
+		overlap := fs.Duration("overlap", cfg.ServiceAccountSecretOverlap, "how long the previous secrets keep working")
+		_ = fs.Parse(args)
+		if fs.NArg() != 1 {
+			return errors.New("rotate-secret requires a client ID")
+		}
+		secret, err := accountService.RotateSecret(ctx, fs.Arg(0), *overlap)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Client secret: %s\n", secret)
+		if *overlap > 0 {
+			fmt.Printf("The previous secrets keep working until %s.\n", time.Now().Add(*overlap).Format(time.RFC3339))
+		} else {
+			fmt.Println("The previous secrets no longer work.")
+		}
+		return nil
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
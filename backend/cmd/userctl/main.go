@@ -0,0 +1,199 @@
+// Command userctl performs administrative actions on user accounts.
+//
+//	userctl unlock alice@example.com
+//	userctl unlock-ip 192.0.2.1
+//	userctl canonicalize-emails
+//	userctl grant-role alice@example.com admin
+//	userctl revoke-role alice@example.com admin
+//
+// Unlocking clears the failed login count kept in the database. With
+// LOGIN_ATTEMPT_STORE=memory the counts live inside the server process and
+// userctl cannot reach them; restart the server instead.
+//
+// canonicalize-emails recomputes the canonical email address of every user,
+// which the migration adding it can only approximate for non-ASCII
+// addresses. It changes nothing if two active users would collide.
+//
+// grant-role gives the first admin their role, after which admins can
+// manage roles through the API. Role changes reach running servers within
+// AUTHZ_CACHE_TTL.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/config"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const usage = `Usage: userctl <command> [arguments]
+
+Commands:
+  unlock <email>   clear failed logins and any lockout on an account
+  unlock-ip <ip>   clear failed logins and any block on a client IP
+  canonicalize-emails
+                   recompute the canonical email address of every user
+  grant-role <email> <role>
+                   assign a role to a user
+  revoke-role <email> <role>
+                   remove a role from a user
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "userctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, command string, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return err
+	}
+
+	throttle := service.NewLoginThrottleService(
+		repository.NewLoginAttemptRepository(db),
+		service.LoginThrottleOptions{Window: cfg.LoginAttemptWindow},
+	)
+
+	if command == "unlock" || command == "unlock-ip" {
+		if cfg.LoginAttemptStore != "postgres" {
+			return errors.New("failed logins are only kept in the database with LOGIN_ATTEMPT_STORE=postgres")
+		}
+	}
+
+	switch command {
+	case "unlock":
+		if len(args) != 1 {
+			return errors.New("unlock requires an email address")
+		}
+		if err := throttle.Unlock(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Unlocked %s\n", args[0])
+		return nil
+
+	case "unlock-ip":
+		if len(args) != 1 {
+			return errors.New("unlock-ip requires an IP address")
+		}
+		if err := throttle.UnlockIP(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Unblocked %s\n", args[0])
+		return nil
+
+	case "canonicalize-emails":
+		return canonicalizeEmails(ctx, db)
+
+	case "grant-role", "revoke-role":
+		if len(args) != 2 {
+			return fmt.Errorf("%s requires an email address and a role", command)
+		}
+		userRepo := repository.NewUserRepository(db)
+		user, err := userRepo.FindByEmail(ctx, args[0])
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no user with email %s", args[0])
+			}
+			return err
+		}
+		roles := service.NewRoleService(repository.NewRoleRepository(db), userRepo, 1, 0)
+		if command == "grant-role" {
+			if err := roles.Assign(ctx, uuid.Nil, user.ID, args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Granted %s to %s\n", args[1], user.Email)
+			return nil
+		}
+		if err := roles.Unassign(ctx, uuid.Nil, user.ID, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked %s from %s\n", args[1], user.Email)
+		return nil
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// canonicalizeEmails brings users.email_canonical in line with
+// auth.CanonicalEmail. Collisions between active users are listed and
+// nothing is written until they are resolved.
+func canonicalizeEmails(ctx context.Context, db *gorm.DB) error {
+	var users []model.User
+	if err := db.WithContext(ctx).Unscoped().Order("created_at").Find(&users).Error; err != nil {
+		return err
+	}
+
+	active := make(map[string][]model.User)
+	var changed []model.User
+	for _, user := range users {
+		canonical := auth.CanonicalEmail(user.Email)
+		if !user.DeletedAt.Valid {
+			active[canonical] = append(active[canonical], user)
+		}
+		if canonical != user.EmailCanonical {
+			user.EmailCanonical = canonical
+			changed = append(changed, user)
+		}
+	}
+
+	var collisions []string
+	for canonical, owners := range active {
+		if len(owners) < 2 {
+			continue
+		}
+		accounts := make([]string, len(owners))
+		for i, owner := range owners {
+			accounts[i] = fmt.Sprintf("%s (id %s)", owner.Email, owner.ID)
+		}
+		collisions = append(collisions, fmt.Sprintf("  %s: %s", canonical, strings.Join(accounts, ", ")))
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		fmt.Fprintln(os.Stderr, "Active users share an email address once case and normalization are ignored:")
+		fmt.Fprintln(os.Stderr, strings.Join(collisions, "\n"))
+		return errors.New("keep one account per address (change or delete the others), then run again")
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, user := range changed {
+			if err := tx.Unscoped().Model(&model.User{}).Where("id = ?", user.ID).
+				Update("email_canonical", user.EmailCanonical).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Updated %d of %d users\n", len(changed), len(users))
+	return nil
+}
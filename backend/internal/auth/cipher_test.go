@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+const testEncryptionKey = "0123456789abcdef0123456789abcdef"
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := c.Encrypt([]byte("totp secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Encrypt([]byte("totp secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("encrypting twice gives the same ciphertext, want a fresh nonce each time")
+	}
+	plaintext, err := c.Decrypt(a)
+	if err != nil || string(plaintext) != "totp secret" {
+		t.Errorf("Decrypt = %q, %v; want the plaintext", plaintext, err)
+	}
+}
+
+func TestCipherRejects(t *testing.T) {
+	c, err := NewCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Encrypt([]byte("totp secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+	other, err := NewCipher("fedcba9876543210fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		cipher     *Cipher
+		ciphertext string
+	}{
+		{"tampered", c, base64.StdEncoding.EncodeToString(raw)},
+		{"other key", other, sealed},
+		{"truncated", c, base64.StdEncoding.EncodeToString(raw[:4])},
+		{"not base64", c, "!!"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.cipher.Decrypt(tt.ciphertext); !errors.Is(err, ErrDecryptionFailed) {
+			t.Errorf("%s: err = %v, want ErrDecryptionFailed", tt.name, err)
+		}
+	}
+
+	for _, key := range []string{"", "too short", testEncryptionKey + "x"} {
+		if _, err := NewCipher(key); err == nil {
+			t.Errorf("NewCipher with a %d byte key: err = nil, want an error", len(key))
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CanonicalEmail returns the form of an email address that identifies an
+// account: trimmed, NFC-normalized and lowercased, with an internationalized
+// domain in its ASCII (punycode) form. Addresses with the same canonical
+// form belong to the same account.
+func CanonicalEmail(email string) string {
+	email = strings.ToLower(norm.NFC.String(strings.TrimSpace(email)))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	local, domain := email[:at], email[at+1:]
+	// Domains IDNA rejects cannot be delivered to; lowercasing is enough
+	// to compare them
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+	return local + "@" + domain
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto"
+	"testing"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 7638 section 3.1
+	jwk := JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+		Alg: "RS256",
+		Kid: "2011-04-29",
+	}
+	got, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("Thumbprint = %s, want %s", got, want)
+	}
+}
+
+func TestPublicJWKRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgES256, AlgEdDSA} {
+		key, err := GenerateSigningKey(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jwk, ok := key.PublicJWK()
+		if !ok {
+			t.Errorf("%s: PublicJWK = false, want true", alg)
+			continue
+		}
+		if jwk.Alg != alg || jwk.Kid != key.ID || jwk.Use != "sig" {
+			t.Errorf("%s: JWK = %+v, want alg, kid and use sig", alg, jwk)
+		}
+		// Generated keys are named by their thumbprint
+		if thumbprint, _ := jwk.Thumbprint(); thumbprint != key.ID {
+			t.Errorf("%s: kid = %s, want the thumbprint %s", alg, key.ID, thumbprint)
+		}
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			t.Errorf("%s: PublicKey: %v", alg, err)
+			continue
+		}
+		if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(key.Public()) {
+			t.Errorf("%s: decoded key differs from the signing key", alg)
+		}
+	}
+
+	if _, ok := NewHMACKey("secret").PublicJWK(); ok {
+		t.Error("HS256 key: PublicJWK = true, want the secret kept private")
+	}
+}
+
+func TestJWKPublicKeyRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+	}{
+		{"unknown type", JWK{Kty: "oct"}},
+		{"short RSA key", JWK{Kty: "RSA", N: "AQAB", E: "AQAB"}},
+		{"unsupported curve", JWK{Kty: "EC", Crv: "P-384"}},
+		// x = y = 1 is not on P-256
+		{"point off the curve", JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAE",
+			Y:   "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAE",
+		}},
+		{"short Ed25519 key", JWK{Kty: "OKP", Crv: "Ed25519", X: "AAAA"}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.jwk.PublicKey(); err == nil {
+			t.Errorf("%s: err = nil, want an error", tt.name)
+		}
+	}
+}
@@ -13,45 +13,100 @@ var (
 	ErrExpiredToken = errors.New("token has expired")
 )
 
+// Kinds of principal an access token can be issued to
+const (
+	PrincipalUser    = "user"
+	PrincipalService = "service"
+)
+
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	SessionID     string `json:"sid,omitempty"`
+
+	// PrincipalType tells users from service accounts; tokens issued before
+	// it was introduced carry none and are user tokens. Service account
+	// tokens carry ServiceAccountID and Scope instead of a user.
+	PrincipalType    string `json:"principal_type,omitempty"`
+	ServiceAccountID string `json:"service_account_id,omitempty"`
+
+	// ClientID is set on tokens issued to OAuth clients, which are scoped to
+	// Scope (space separated) and are not accepted by this API
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+
+	// Permissions granted by the user's roles when the token was issued.
+	// They hold while AuthzVersion is the user's current authorization
+	// version; tokens without them carry version 0.
+	Permissions  []string `json:"perms,omitempty"`
+	AuthzVersion int      `json:"authz_ver,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Principal returns the kind of principal the token was issued to
+func (c *Claims) Principal() string {
+	if c.PrincipalType == "" {
+		return PrincipalUser
+	}
+	return c.PrincipalType
+}
+
 type JWTManager struct {
-	secretKey     []byte
+	keys          *KeyRing
 	accessExpiry  time.Duration
 	refreshExpiry time.Duration
 }
 
-func NewJWTManager(secret string, accessExpiry, refreshExpiry time.Duration) *JWTManager {
+func NewJWTManager(keys *KeyRing, accessExpiry, refreshExpiry time.Duration) *JWTManager {
 	return &JWTManager{
-		secretKey:     []byte(secret),
+		keys:          keys,
 		accessExpiry:  accessExpiry,
 		refreshExpiry: refreshExpiry,
 	}
 }
 
-// GenerateAccessToken creates a new access token
-func (m *JWTManager) GenerateAccessToken(userID uuid.UUID, email string) (string, error) {
-	claims := Claims{
-		UserID: userID.String(),
-		Email:  email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+// GenerateAccessToken creates a new access token carrying the given
+// private claims; the registered claims are filled in here. It also
+// returns the token's unique ID (jti) so it can be revoked later.
+func (m *JWTManager) GenerateAccessToken(claims Claims) (string, string, error) {
+	jti := uuid.New().String()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessExpiry)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(m.secretKey)
+	signed, err := m.Sign(claims)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, jti, nil
 }
 
-// GenerateRefreshToken creates a new refresh token string
-func (m *JWTManager) GenerateRefreshToken() string {
-	return uuid.New().String()
+// Sign signs arbitrary claims (such as OpenID Connect ID tokens) with the
+// active key
+func (m *JWTManager) Sign(claims jwt.Claims) (string, error) {
+	key := m.keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// SigningAlgorithm returns the algorithm of the active key
+func (m *JWTManager) SigningAlgorithm() string {
+	return m.keys.Active().Method.Alg()
+}
+
+// GenerateRefreshToken creates a new opaque refresh token string
+func (m *JWTManager) GenerateRefreshToken() (string, error) {
+	return GenerateOpaqueToken()
+}
+
+// GetAccessExpiry returns the access token expiry duration
+func (m *JWTManager) GetAccessExpiry() time.Duration {
+	return m.accessExpiry
 }
 
 // GetRefreshExpiry returns the refresh token expiry duration
@@ -62,10 +117,19 @@ func (m *JWTManager) GetRefreshExpiry() time.Duration {
 // ValidateAccessToken validates an access token and returns claims
 func (m *JWTManager) ValidateAccessToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// Tokens issued before kid was introduced carry no kid header
+		key := m.keys.Active()
+		if kid, ok := token.Header["kid"]; ok {
+			kidStr, _ := kid.(string)
+			if key, ok = m.keys.Lookup(kidStr); !ok {
+				return nil, ErrInvalidToken
+			}
+		}
+		// The algorithm is pinned by the key, never chosen by the token
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, ErrInvalidToken
 		}
-		return m.secretKey, nil
+		return key.verifyKey, nil
 	})
 
 	if err != nil {
@@ -75,10 +139,23 @@ func (m *JWTManager) ValidateAccessToken(tokenString string) (*Claims, error) {
 		return nil, ErrInvalidToken
 	}
 
+	// Tokens without a jti cannot be revoked, so they are not accepted
 	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
+	if !ok || !token.Valid || claims.ID == "" {
 		return nil, ErrInvalidToken
 	}
 
 	return claims, nil
 }
+
+// JWKS returns the public keys that verify access tokens. Symmetric keys
+// are never included.
+func (m *JWTManager) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range m.keys.Keys() {
+		if jwk, ok := key.PublicJWK(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func generateKey(t *testing.T, alg string) *SigningKey {
+	t.Helper()
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA} {
+		key := generateKey(t, alg)
+		m := NewJWTManager(NewKeyRing(key), time.Minute, time.Hour)
+
+		signed, jti, err := m.GenerateAccessToken(Claims{UserID: "user-1", SessionID: "session-1"})
+		if err != nil {
+			t.Fatalf("%s: GenerateAccessToken: %v", alg, err)
+		}
+		token, _, err := jwt.NewParser().ParseUnverified(signed, &Claims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Header["alg"] != alg || token.Header["kid"] != key.ID {
+			t.Errorf("%s: header = %v, want alg %s and kid %s", alg, token.Header, alg, key.ID)
+		}
+
+		claims, err := m.ValidateAccessToken(signed)
+		if err != nil {
+			t.Errorf("%s: ValidateAccessToken: %v", alg, err)
+			continue
+		}
+		if claims.UserID != "user-1" || claims.SessionID != "session-1" || claims.ID != jti || jti == "" {
+			t.Errorf("%s: claims = %+v, jti %q", alg, claims, jti)
+		}
+	}
+}
+
+func TestValidateAccessTokenLooksUpKid(t *testing.T) {
+	previous := generateKey(t, AlgES256)
+	active := generateKey(t, AlgEdDSA)
+	unknown := generateKey(t, AlgES256)
+	ring := NewKeyRing(active, previous)
+	m := NewJWTManager(ring, time.Minute, time.Hour)
+
+	sign := func(key *SigningKey, header map[string]any, claims jwt.Claims) string {
+		t.Helper()
+		token := jwt.NewWithClaims(key.Method, claims)
+		for name, value := range header {
+			token.Header[name] = value
+		}
+		signed, err := token.SignedString(key.signKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	valid := func() *Claims {
+		return &Claims{RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		}}
+	}
+	expired := valid()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noJTI := valid()
+	noJTI.ID = ""
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"active key", sign(active, map[string]any{"kid": active.ID}, valid()), nil},
+		{"previous key", sign(previous, map[string]any{"kid": previous.ID}, valid()), nil},
+		{"unknown kid", sign(unknown, map[string]any{"kid": unknown.ID}, valid()), ErrInvalidToken},
+		{"kid of another key", sign(unknown, map[string]any{"kid": previous.ID}, valid()), ErrInvalidToken},
+		// Without a kid only the active key is tried
+		{"no kid, active key", sign(active, nil, valid()), nil},
+		{"no kid, previous key", sign(previous, nil, valid()), ErrInvalidToken},
+		{"expired", sign(active, map[string]any{"kid": active.ID}, expired), ErrExpiredToken},
+		{"no jti", sign(active, map[string]any{"kid": active.ID}, noJTI), ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		if _, err := m.ValidateAccessToken(tt.token); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestValidateAccessTokenPinsAlgorithm(t *testing.T) {
+	key := generateKey(t, AlgRS256)
+	m := NewJWTManager(NewKeyRing(key), time.Minute, time.Hour)
+	claims := &Claims{RegisteredClaims: jwt.RegisteredClaims{
+		ID:        "jti-1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}}
+
+	// HS256 keyed with the published RSA public key
+	jwk, _ := key.PublicJWK()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.ID
+	confused, err := token.SignedString([]byte(jwk.N))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// alg none
+	token = jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	token.Header["kid"] = key.ID
+	none, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, signed := range map[string]string{"HS256": confused, "none": none} {
+		if _, err := m.ValidateAccessToken(signed); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s token: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestJWKSOmitsSymmetricKeys(t *testing.T) {
+	asymmetric := generateKey(t, AlgEdDSA)
+	m := NewJWTManager(NewKeyRing(NewHMACKey("test-secret"), asymmetric), time.Minute, time.Hour)
+	jwks := m.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != asymmetric.ID {
+		t.Errorf("JWKS = %+v, want only the EdDSA key", jwks)
+	}
+}
+
+func TestParseSigningKeyRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA} {
+		key := generateKey(t, alg)
+		material, err := key.MarshalPrivate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseSigningKey(alg, material, key.ID)
+		if err != nil {
+			t.Errorf("%s: ParseSigningKey: %v", alg, err)
+			continue
+		}
+		signed, _, err := NewJWTManager(NewKeyRing(key), time.Minute, time.Hour).GenerateAccessToken(Claims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewJWTManager(NewKeyRing(parsed), time.Minute, time.Hour).ValidateAccessToken(signed); err != nil {
+			t.Errorf("%s: the parsed key does not verify the original's token: %v", alg, err)
+		}
+	}
+
+	// The key must fit the algorithm
+	ec, _ := generateKey(t, AlgES256).MarshalPrivate()
+	if _, err := ParseSigningKey(AlgRS256, ec, ""); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("RS256 with a P-256 key: err = %v, want ErrInvalidKey", err)
+	}
+	if _, err := GenerateSigningKey("PS512"); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("PS512: err = %v, want ErrUnsupportedAlgorithm", err)
+	}
+}
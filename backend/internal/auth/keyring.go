@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+)
+
+// KeyRing holds the active signing key and any verify-only keys (previous
+// keys still inside the access token TTL, and pre-published next keys).
+// It is safe for concurrent use and can be swapped at runtime.
+type KeyRing struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewKeyRing(active *SigningKey, verifyOnly ...*SigningKey) *KeyRing {
+	r := &KeyRing{}
+	r.Replace(active, verifyOnly...)
+	return r
+}
+
+// Replace atomically swaps the contents of the ring
+func (r *KeyRing) Replace(active *SigningKey, verifyOnly ...*SigningKey) {
+	keys := make(map[string]*SigningKey, len(verifyOnly)+1)
+	for _, k := range verifyOnly {
+		keys[k.ID] = k
+	}
+	keys[active.ID] = active
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = active
+	r.keys = keys
+}
+
+// Active returns the key new tokens are signed with
+func (r *KeyRing) Active() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// Lookup returns the key with the given kid
+func (r *KeyRing) Lookup(kid string) (*SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	k, ok := r.keys[kid]
+	return k, ok
+}
+
+// Keys returns every key in the ring, active key first
+func (r *KeyRing) Keys() []*SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*SigningKey, 0, len(r.keys))
+	for _, k := range r.keys {
+		if k != r.active {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return append([]*SigningKey{r.active}, keys...)
+}
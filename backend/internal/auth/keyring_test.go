@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyRotation(t *testing.T) {
+	old := generateKey(t, AlgES256)
+	next := generateKey(t, AlgES256)
+	ring := NewKeyRing(old)
+	m := NewJWTManager(ring, time.Minute, time.Hour)
+	kids := func() string {
+		var ids []string
+		for _, jwk := range m.JWKS().Keys {
+			ids = append(ids, jwk.Kid)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	issuedBefore, _, err := m.GenerateAccessToken(Claims{UserID: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next key is published before it signs anything
+	ring.Replace(old, next)
+	if got, want := kids(), old.ID+","+next.ID; got != want {
+		t.Errorf("JWKS kids = %s, want the active key first: %s", got, want)
+	}
+
+	// Promoted, it signs; the old key still verifies what it signed
+	ring.Replace(next, old)
+	issuedAfter, _, err := m.GenerateAccessToken(Claims{UserID: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, signed := range map[string]string{"before": issuedBefore, "after": issuedAfter} {
+		if _, err := m.ValidateAccessToken(signed); err != nil {
+			t.Errorf("token issued %s the rotation: %v", name, err)
+		}
+	}
+
+	// Retired, the old key's tokens are refused and it is unpublished
+	ring.Replace(next)
+	if _, err := m.ValidateAccessToken(issuedBefore); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of a retired key: err = %v, want ErrInvalidToken", err)
+	}
+	if got := kids(); got != next.ID {
+		t.Errorf("JWKS kids = %s, want only %s", got, next.ID)
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+const minRSAKeyBits = 2048
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrInvalidKey           = errors.New("invalid signing key")
+)
+
+// SigningKey is a key used to sign and verify access tokens, identified by
+// the kid header of the tokens it signs
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(secret string) *SigningKey {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("kid"))
+	return &SigningKey{
+		ID:        base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12]),
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// ParsePrivateKeyPEM loads an asymmetric signing key for alg from PEM data
+// (PKCS#8, PKCS#1 or SEC 1). An empty kid defaults to the RFC 7638
+// thumbprint of the public key.
+func ParsePrivateKeyPEM(alg string, data []byte, kid string) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrInvalidKey)
+	}
+
+	privateKey, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	key := &SigningKey{ID: kid, signKey: privateKey}
+	switch alg {
+	case AlgRS256:
+		k, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires an RSA key", ErrInvalidKey, alg)
+		}
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("%w: RSA key must be at least %d bits", ErrInvalidKey, minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.verifyKey = &k.PublicKey
+	case AlgES256:
+		k, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: %s requires a P-256 key", ErrInvalidKey, alg)
+		}
+		key.Method = jwt.SigningMethodES256
+		key.verifyKey = &k.PublicKey
+	case AlgEdDSA:
+		k, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires an Ed25519 key", ErrInvalidKey, alg)
+		}
+		key.Method = jwt.SigningMethodEdDSA
+		key.verifyKey = k.Public()
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+
+	if key.ID == "" {
+		jwk, _ := key.PublicJWK()
+		key.ID, err = jwk.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// ParseSigningKey loads a key of any supported algorithm from the form
+// produced by MarshalPrivate
+func ParseSigningKey(alg string, data []byte, kid string) (*SigningKey, error) {
+	if alg != AlgHS256 {
+		return ParsePrivateKeyPEM(alg, data, kid)
+	}
+	key := NewHMACKey(string(data))
+	if kid != "" {
+		key.ID = kid
+	}
+	return key, nil
+}
+
+// GenerateSigningKey creates a new random key for alg
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var privateKey interface{}
+	var err error
+	switch alg {
+	case AlgHS256:
+		secret, err := GenerateOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+		return NewHMACKey(secret), nil
+	case AlgRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+	case AlgES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKeyPEM(alg, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "")
+}
+
+// MarshalPrivate encodes the signing material: the raw secret for HMAC
+// keys, PKCS#8 PEM otherwise
+func (k *SigningKey) MarshalPrivate() ([]byte, error) {
+	if secret, ok := k.signKey.([]byte); ok {
+		return secret, nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.signKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// IsSymmetric reports whether the key is a shared secret that must not be
+// published
+func (k *SigningKey) IsSymmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// Public returns the verification key of an asymmetric key
+func (k *SigningKey) Public() crypto.PublicKey {
+	if k.IsSymmetric() {
+		return nil
+	}
+	return k.verifyKey
+}
+
+func parsePrivateKey(der []byte) (interface{}, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key format")
+}
@@ -1,22 +1,200 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-const bcryptCost = 12
+var (
+	ErrUnsupportedPasswordHash = errors.New("unsupported password hash")
+	ErrUnknownPepper           = errors.New("password hash uses an unknown pepper")
+)
 
-// HashPassword hashes a password using bcrypt
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
-	if err != nil {
+// PasswordHasher hashes user passwords into self-describing PHC strings
+// ($argon2id$v=19$m=...,t=...,p=...$salt$hash), so the algorithm and its
+// parameters can change without invalidating stored hashes
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded, and whether encoded
+	// is outdated and should be replaced by a fresh Hash of the password.
+	// An empty hash (an account without a password) never matches.
+	Verify(password, encoded string) (ok bool, rehash bool, err error)
+}
+
+// Argon2Params are the argon2id cost parameters; Memory is in KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation with extra memory
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// PasswordOptions configures NewPasswordHasher
+type PasswordOptions struct {
+	Argon2 Argon2Params
+	// Pepper is an optional server secret mixed into every new hash, so a
+	// database dump alone is not enough to guess passwords offline.
+	// PepperID names it inside the hash.
+	Pepper   string
+	PepperID string
+	// PreviousPeppers maps the IDs of retired peppers to their values, so
+	// hashes made with them still verify and are rehashed with the current
+	// pepper. Hashes naming any other ID fail with ErrUnknownPepper.
+	PreviousPeppers map[string]string
+}
+
+type argon2idHasher struct {
+	opts PasswordOptions
+}
+
+// NewPasswordHasher returns a hasher that writes argon2id hashes and still
+// verifies legacy bcrypt hashes, reporting them for rehashing
+func NewPasswordHasher(opts PasswordOptions) PasswordHasher {
+	return &argon2idHasher{opts: opts}
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	p := h.opts.Argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return string(bytes), nil
+	key := argon2.IDKey(h.input(password, h.opts.Pepper), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+	if h.opts.Pepper != "" {
+		params += ",keyid=" + h.opts.PepperID
+	}
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s",
+		argon2.Version,
+		params,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
-// CheckPassword compares a password with a hash
-func CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+func (h *argon2idHasher) Verify(password, encoded string) (bool, bool, error) {
+	switch {
+	case encoded == "":
+		return false, false, nil
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return h.verifyArgon2id(password, encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		// bcrypt ignores everything past 72 bytes, so such hashes are
+		// always replaced once the password is known
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		return err == nil, true, nil
+	default:
+		return false, false, ErrUnsupportedPasswordHash
+	}
+}
+
+func (h *argon2idHasher) verifyArgon2id(password, encoded string) (bool, bool, error) {
+	// "", "argon2id", "v=19", params, salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return false, false, ErrUnsupportedPasswordHash
+	}
+
+	var p Argon2Params
+	var keyID string
+	for _, param := range strings.Split(parts[3], ",") {
+		name, value, _ := strings.Cut(param, "=")
+		if name == "keyid" {
+			keyID = value
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return false, false, ErrUnsupportedPasswordHash
+		}
+		switch name {
+		case "m":
+			p.Memory = uint32(n)
+		case "t":
+			p.Iterations = uint32(n)
+		case "p":
+			if n > 255 {
+				return false, false, ErrUnsupportedPasswordHash
+			}
+			p.Parallelism = uint8(n)
+		}
+	}
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return false, false, ErrUnsupportedPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnsupportedPasswordHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false, false, ErrUnsupportedPasswordHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(want))
+
+	pepper, ok := h.pepper(keyID)
+	if !ok {
+		return false, false, ErrUnknownPepper
+	}
+
+	got := argon2.IDKey(h.input(password, pepper), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return false, false, nil
+	}
+	rehash := p != h.opts.Argon2 || keyID != h.currentKeyID()
+	return true, rehash, nil
+}
+
+// pepper returns the pepper a hash names by keyID; "" with no keyID
+func (h *argon2idHasher) pepper(keyID string) (string, bool) {
+	switch {
+	case keyID == "":
+		return "", true
+	case h.opts.Pepper != "" && keyID == h.opts.PepperID:
+		return h.opts.Pepper, true
+	}
+	pepper, ok := h.opts.PreviousPeppers[keyID]
+	return pepper, ok && pepper != ""
+}
+
+// currentKeyID is the keyid new hashes get, "" without a pepper
+func (h *argon2idHasher) currentKeyID() string {
+	if h.opts.Pepper == "" {
+		return ""
+	}
+	return h.opts.PepperID
+}
+
+// input is what argon2 actually hashes: the password itself, or its
+// HMAC-SHA256 under the pepper
+func (h *argon2idHasher) input(password, pepper string) []byte {
+	if pepper == "" {
+		return []byte(password)
+	}
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
 }
@@ -0,0 +1,31 @@
+package auth
+
+import "strings"
+
+// PersonalAccessTokenPrefix starts every personal access token, so that
+// secret scanners can recognize leaked tokens and the auth middleware can
+// tell them from JWTs
+const PersonalAccessTokenPrefix = "gnt_pat_"
+
+// Scopes that can be granted to personal access tokens
+const (
+	ScopeUserRead = "user:read"
+)
+
+// PersonalAccessTokenScopes lists every grantable scope
+var PersonalAccessTokenScopes = []string{ScopeUserRead}
+
+// GeneratePersonalAccessToken creates a new prefixed token
+func GeneratePersonalAccessToken() (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal
+// access token rather than a JWT
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptCost applies to recovery codes; passwords use argon2id
+const bcryptCost = 12
+
+// Recovery codes are 10 characters from an alphabet without look-alike
+// characters, shown as two groups of five (about 50 bits each)
+const (
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeLength   = 10
+)
+
+// GenerateRecoveryCode creates a random one-time MFA recovery code in the
+// form "xxxxx-xxxxx"
+func GenerateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, recoveryCodeLength+1)
+	for i, v := range b {
+		if i == recoveryCodeLength/2 {
+			code = append(code, '-')
+		}
+		// 256 is not a multiple of the alphabet size, but the bias is
+		// negligible next to the entropy of the whole code
+		code = append(code, recoveryCodeAlphabet[int(v)%len(recoveryCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// NormalizeRecoveryCode strips separators and case so codes typed as
+// "ABCDE FGHJK" or "abcde-fghjk" match what was hashed
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// IsRecoveryCode reports whether code has the shape of a recovery code
+// rather than a TOTP code
+func IsRecoveryCode(code string) bool {
+	return len(NormalizeRecoveryCode(code)) == recoveryCodeLength
+}
+
+// HashRecoveryCode hashes an MFA recovery code with bcrypt. Recovery codes
+// are short and random, so they are not affected by its 72 byte limit.
+func HashRecoveryCode(code string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(code), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// CheckRecoveryCode compares a recovery code with a hash
+func CheckRecoveryCode(code, hash string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(code))
+	return err == nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+)
+
+var recoveryCodeShape = regexp.MustCompile(`^[` + recoveryCodeAlphabet + `]{5}-[` + recoveryCodeAlphabet + `]{5}$`)
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 20 {
+		code, err := GenerateRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !recoveryCodeShape.MatchString(code) {
+			t.Errorf("code %q is not xxxxx-xxxxx from the alphabet", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRecoveryCodeInput(t *testing.T) {
+	tests := []struct {
+		input      string
+		normalized string
+		isRecovery bool
+	}{
+		{"abcde-fghjk", "abcdefghjk", true},
+		{"ABCDE FGHJK", "abcdefghjk", true},
+		{"abcdefghjk", "abcdefghjk", true},
+		{"123456", "123456", false},
+		{"abcde-fghj", "abcdefghj", false},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.input); got != tt.normalized {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.input, got, tt.normalized)
+		}
+		if got := IsRecoveryCode(tt.input); got != tt.isRecovery {
+			t.Errorf("IsRecoveryCode(%q) = %v, want %v", tt.input, got, tt.isRecovery)
+		}
+	}
+}
+
+func TestCheckRecoveryCode(t *testing.T) {
+	hash, err := HashRecoveryCode("abcdefghjk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		code string
+		ok   bool
+	}{
+		{"abcdefghjk", true},
+		{"abcdefghjm", false},
+		// Callers normalize before checking
+		{"abcde-fghjk", false},
+	}
+	for _, tt := range tests {
+		if got := CheckRecoveryCode(tt.code, hash); got != tt.ok {
+			t.Errorf("CheckRecoveryCode(%q) = %v, want %v", tt.code, got, tt.ok)
+		}
+	}
+}
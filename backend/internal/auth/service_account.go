@@ -0,0 +1,14 @@
+package auth
+
+// ServiceAccountSecretPrefix starts every service account client secret,
+// so that secret scanners can recognize leaked secrets
+const ServiceAccountSecretPrefix = "gnt_sas_"
+
+// GenerateServiceAccountSecret creates a new prefixed client secret
+func GenerateServiceAccountSecret() (string, error) {
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return ServiceAccountSecretPrefix + secret, nil
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// opaqueTokenBytes is the amount of randomness in an opaque token (256 bits)
+const opaqueTokenBytes = 32
+
+// TokenHasher derives the at-rest form of opaque tokens with a server key,
+// so that a database dump alone cannot be used to impersonate anyone
+type TokenHasher struct {
+	key []byte
+}
+
+func NewTokenHasher(key string) *TokenHasher {
+	return &TokenHasher{key: []byte(key)}
+}
+
+// Hash returns the hex encoded HMAC-SHA256 of a token
+func (h *TokenHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateOpaqueToken creates a random URL-safe token string
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
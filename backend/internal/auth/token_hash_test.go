@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestTokenHasher(t *testing.T) {
+	tests := []struct {
+		name, key, token, hash string
+	}{
+		// RFC 4231 test cases 1 and 2
+		{"case 1", strings.Repeat("\x0b", 20), "Hi There", "b0344c61d8db38535ca8afceaf0bf12b881dc200c9833da726e9376c2e32cff7"},
+		{"case 2", "Jefe", "what do ya want for nothing?", "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+	}
+	for _, tt := range tests {
+		if got := NewTokenHasher(tt.key).Hash(tt.token); got != tt.hash {
+			t.Errorf("%s: Hash = %s, want %s", tt.name, got, tt.hash)
+		}
+	}
+
+	// The stored form depends on the server key, not only on the token
+	token := "a-refresh-token"
+	if NewTokenHasher("key-one").Hash(token) == NewTokenHasher("key-two").Hash(token) {
+		t.Error("Hash is the same under different keys")
+	}
+}
+
+func TestGenerateOpaqueToken(t *testing.T) {
+	a, err := GenerateOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("two tokens are equal")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(a)
+	if err != nil || len(raw) != opaqueTokenBytes {
+		t.Errorf("token %q decodes to %d bytes (%v), want %d", a, len(raw), err, opaqueTokenBytes)
+	}
+}
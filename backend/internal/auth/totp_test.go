@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPMatchesRFC6238(t *testing.T) {
+	// RFC 6238 appendix B lists 8 digit codes; 6 digit codes are their
+	// last six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		at := time.Unix(tt.unix, 0)
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, at)
+		if !ok {
+			t.Errorf("ValidateTOTP(%s at %d) = false, want true", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / totpPeriod; step != want {
+			t.Errorf("ValidateTOTP(%s at %d): step = %d, want %d", tt.code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestTOTPSkew(t *testing.T) {
+	// 081804 is the code of step 37037036
+	at := time.Unix(1111111109, 0)
+	tests := []struct {
+		name  string
+		shift time.Duration
+		ok    bool
+	}{
+		{"one step early", -totpPeriod * time.Second, true},
+		{"one step late", totpPeriod * time.Second, true},
+		{"two steps early", -2 * totpPeriod * time.Second, false},
+		{"two steps late", 2 * totpPeriod * time.Second, false},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfc6238Secret, "081804", at.Add(tt.shift))
+		if ok != tt.ok {
+			t.Errorf("%s: ValidateTOTP = %v, want %v", tt.name, ok, tt.ok)
+		}
+		// The step is that of the code, not of the clock
+		if ok && step != 37037036 {
+			t.Errorf("%s: step = %d, want 37037036", tt.name, step)
+		}
+	}
+}
+
+func TestTOTPRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"short code", rfc6238Secret, "28708"},
+		{"8 digit code", rfc6238Secret, "94287082"},
+		{"wrong code", rfc6238Secret, "287083"},
+		{"invalid secret", "not base32!", "287082"},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, at); ok {
+			t.Errorf("%s: ValidateTOTP = true, want false", tt.name)
+		}
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != totpSecretBytes {
+		t.Fatalf("secret %q decodes to %d bytes (%v), want %d", secret, len(key), err, totpSecretBytes)
+	}
+
+	now := time.Now()
+	if _, ok := ValidateTOTP(secret, totpCode(key, now.Unix()/totpPeriod), now); !ok {
+		t.Error("the current code of a new secret is rejected")
+	}
+
+	uri := TOTPURI("goNexttemp", "alice@example.com", secret)
+	want := "otpauth://totp/goNexttemp:alice@example.com?algorithm=SHA1&digits=6&issuer=goNexttemp&period=30&secret=" + secret
+	if uri != want {
+		t.Errorf("TOTPURI = %s, want %s", uri, want)
+	}
+	if strings.Contains(secret, "=") {
+		t.Errorf("secret %q is padded", secret)
+	}
+}
@@ -1,11 +1,60 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
 
+// Values of EMAIL_VERIFICATION_REQUIRED
+const (
+	EmailVerificationNone   = "none"
+	EmailVerificationLogin  = "login"
+	EmailVerificationRoutes = "routes"
+)
+
+// Values of DELETED_EMAIL_POLICY
+const (
+	// DeletedEmailRelease frees the email of a deleted account at once
+	DeletedEmailRelease = "release"
+	// DeletedEmailHold keeps it from new registrations for DELETED_EMAIL_HOLD
+	DeletedEmailHold = "hold"
+	// DeletedEmailNever never lets it be registered again
+	DeletedEmailNever = "never"
+)
+
+// Values of OAUTH_LINK_POLICY
+const (
+	// OAuthLinkExplicit refuses provider logins whose email belongs to an
+	// existing account; users link providers from their account instead
+	OAuthLinkExplicit = "explicit"
+	// OAuthLinkVerifiedEmail links such logins when both the provider and
+	// the account have verified the email
+	OAuthLinkVerifiedEmail = "verified_email"
+)
+
+var (
+	oauthProviderName = regexp.MustCompile(`^[a-z0-9]+$`)
+	passwordPepperID  = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+)
+
+// OAuthProvider is read from OAUTH_<NAME>_* variables
+type OAuthProvider struct {
+	Name string `ignored:"true"`
+	// Kind is "oidc" or "github"; "google" and "github" need only client
+	// credentials
+	Kind         string `envconfig:"KIND"`
+	Issuer       string `envconfig:"ISSUER"`
+	ClientID     string `envconfig:"CLIENT_ID" required:"true"`
+	ClientSecret string `envconfig:"CLIENT_SECRET" required:"true"`
+	Scopes       string `envconfig:"SCOPES"`
+}
+
 type Config struct {
 	// Server
 	Port string `envconfig:"BACKEND_PORT" default:"8080"`
@@ -14,12 +63,169 @@ type Config struct {
 	DatabaseURL string `envconfig:"DATABASE_URL" required:"true"`
 
 	// JWT
-	JWTSecret        string        `envconfig:"JWT_SECRET" required:"true"`
-	JWTAccessExpiry  time.Duration `envconfig:"JWT_ACCESS_EXPIRY" default:"15m"`
-	JWTRefreshExpiry time.Duration `envconfig:"JWT_REFRESH_EXPIRY" default:"168h"`
+	// HS256 signs with JWT_SECRET; RS256, ES256 and EdDSA sign with the PEM
+	// private key given inline (JWT_PRIVATE_KEY) or as a file path
+	JWTSigningAlg     string        `envconfig:"JWT_SIGNING_ALG" default:"HS256"`
+	JWTSecret         string        `envconfig:"JWT_SECRET"`
+	JWTPrivateKey     string        `envconfig:"JWT_PRIVATE_KEY"`
+	JWTPrivateKeyFile string        `envconfig:"JWT_PRIVATE_KEY_FILE"`
+	JWTKeyID          string        `envconfig:"JWT_KEY_ID"`
+	JWTAccessExpiry   time.Duration `envconfig:"JWT_ACCESS_EXPIRY" default:"15m"`
+	JWTRefreshExpiry  time.Duration `envconfig:"JWT_REFRESH_EXPIRY" default:"168h"`
+
+	// The configured key only seeds the database key ring on first start;
+	// rotation is done with cmd/keyctl and picked up on the next reload
+	JWTKeyRefreshInterval time.Duration `envconfig:"JWT_KEY_REFRESH_INTERVAL" default:"1m"`
+
+	// Opaque tokens (refresh tokens etc.) are stored as HMAC-SHA256 with this
+	// key of at least 32 bytes
+	TokenHashKey string `envconfig:"TOKEN_HASH_KEY" required:"true"`
+
+	// Passwords are hashed with argon2id (memory in KiB). Changed parameters
+	// apply to new hashes, and older ones are rehashed at the next login.
+	// The optional pepper is a server secret mixed into every hash; it is
+	// named by PASSWORD_PEPPER_ID in the hash. To rotate it, move the old
+	// one to PASSWORD_PREVIOUS_PEPPERS ("id:pepper,...") until every hash
+	// naming it has been replaced at login.
+	PasswordArgon2Memory      uint32            `envconfig:"PASSWORD_ARGON2_MEMORY" default:"65536"`
+	PasswordArgon2Iterations  uint32            `envconfig:"PASSWORD_ARGON2_ITERATIONS" default:"3"`
+	PasswordArgon2Parallelism uint8             `envconfig:"PASSWORD_ARGON2_PARALLELISM" default:"2"`
+	PasswordPepper            string            `envconfig:"PASSWORD_PEPPER"`
+	PasswordPepperID          string            `envconfig:"PASSWORD_PEPPER_ID" default:"1"`
+	PasswordPreviousPeppers   map[string]string `envconfig:"PASSWORD_PREVIOUS_PEPPERS"`
+
+	// Password policy for new passwords. The denylist file adds to the
+	// built-in list of common passwords, one per line. PASSWORD_BREACH_DIR
+	// points to a local copy of the Have I Been Pwned range files; hashes
+	// seen fewer than PASSWORD_BREACH_MIN_COUNT times are allowed.
+	PasswordMinLength      int    `envconfig:"PASSWORD_MIN_LENGTH" default:"8"`
+	PasswordMaxLength      int    `envconfig:"PASSWORD_MAX_LENGTH" default:"128"`
+	PasswordDenylistFile   string `envconfig:"PASSWORD_DENYLIST_FILE"`
+	PasswordBreachDir      string `envconfig:"PASSWORD_BREACH_DIR"`
+	PasswordBreachMinCount int    `envconfig:"PASSWORD_BREACH_MIN_COUNT" default:"1"`
+
+	// Secrets stored in the database (signing keys etc.) are encrypted with
+	// AES-256-GCM under this key of exactly 32 bytes
+	EncryptionKey string `envconfig:"ENCRYPTION_KEY" required:"true"`
+
+	// Access token revocation: a local LRU caches denylist lookups, and a
+	// "not revoked" answer is trusted for RevocationCacheTTL, which bounds how
+	// long a revocation made on another replica takes to apply here
+	RevocationCacheSize int           `envconfig:"REVOCATION_CACHE_SIZE" default:"10000"`
+	RevocationCacheTTL  time.Duration `envconfig:"REVOCATION_CACHE_TTL" default:"5s"`
+
+	// Role-based access control: access tokens carry the user's permissions
+	// with an authorization version, which a local LRU caches for
+	// AuthzCacheTTL; this bounds how long a role change made on another
+	// replica takes to apply here
+	AuthzCacheSize int           `envconfig:"AUTHZ_CACHE_SIZE" default:"10000"`
+	AuthzCacheTTL  time.Duration `envconfig:"AUTHZ_CACHE_TTL" default:"5s"`
+
+	// Frontend base URL, used for links in emails
+	AppURL string `envconfig:"APP_URL" default:"http://localhost:3000"`
+
+	PasswordResetExpiry time.Duration `envconfig:"PASSWORD_RESET_EXPIRY" default:"1h"`
+
+	// Email verification: "none" (default), "login" (Login rejects
+	// unverified users) or "routes" (verified route groups reject them)
+	EmailVerificationRequired string        `envconfig:"EMAIL_VERIFICATION_REQUIRED" default:"none"`
+	EmailVerificationExpiry   time.Duration `envconfig:"EMAIL_VERIFICATION_EXPIRY" default:"48h"`
+
+	// Account deletion soft-deletes the user; DELETED_EMAIL_POLICY decides
+	// when its email address can be registered again
+	DeletedEmailPolicy string        `envconfig:"DELETED_EMAIL_POLICY" default:"release"`
+	DeletedEmailHold   time.Duration `envconfig:"DELETED_EMAIL_HOLD" default:"720h"`
+
+	// Email change: the new address confirms within EMAIL_CHANGE_EXPIRY,
+	// and the previous one can revert the change for EMAIL_CHANGE_REVERT_EXPIRY
+	EmailChangeExpiry       time.Duration `envconfig:"EMAIL_CHANGE_EXPIRY" default:"24h"`
+	EmailChangeRevertExpiry time.Duration `envconfig:"EMAIL_CHANGE_REVERT_EXPIRY" default:"168h"`
+
+	// Magic links: passwordless login through a link sent by email. Each
+	// address and each client IP may request a limited number of links per
+	// window. The "postgres" store shares the counts between replicas;
+	// "memory" is for a single node. With binding, a link works only in the
+	// browser it was requested from.
+	MagicLinkRateStore   string        `envconfig:"MAGIC_LINK_RATE_STORE" default:"postgres"`
+	MagicLinkExpiry      time.Duration `envconfig:"MAGIC_LINK_EXPIRY" default:"15m"`
+	MagicLinkBindBrowser bool          `envconfig:"MAGIC_LINK_BIND_BROWSER" default:"false"`
+	MagicLinkRateWindow  time.Duration `envconfig:"MAGIC_LINK_RATE_WINDOW" default:"15m"`
+	MagicLinkEmailLimit  int           `envconfig:"MAGIC_LINK_EMAIL_LIMIT" default:"3"`
+	MagicLinkIPLimit     int           `envconfig:"MAGIC_LINK_IP_LIMIT" default:"10"`
+
+	// Login throttling: after LOGIN_FREE_ATTEMPTS failures each further
+	// failure doubles the wait before the next attempt, and reaching the
+	// lockout threshold locks the account (or blocks the IP) for
+	// LOGIN_LOCKOUT_DURATION. Failures are forgotten LOGIN_ATTEMPT_WINDOW
+	// after the last one. The "postgres" store shares counts between
+	// replicas; "memory" is for a single node.
+	LoginAttemptStore       string        `envconfig:"LOGIN_ATTEMPT_STORE" default:"postgres"`
+	LoginFreeAttempts       int           `envconfig:"LOGIN_FREE_ATTEMPTS" default:"3"`
+	LoginBackoffBase        time.Duration `envconfig:"LOGIN_BACKOFF_BASE" default:"1s"`
+	LoginBackoffMax         time.Duration `envconfig:"LOGIN_BACKOFF_MAX" default:"1m"`
+	LoginLockoutThreshold   int           `envconfig:"LOGIN_LOCKOUT_THRESHOLD" default:"10"`
+	LoginIPLockoutThreshold int           `envconfig:"LOGIN_IP_LOCKOUT_THRESHOLD" default:"100"`
+	LoginLockoutDuration    time.Duration `envconfig:"LOGIN_LOCKOUT_DURATION" default:"15m"`
+	LoginAttemptWindow      time.Duration `envconfig:"LOGIN_ATTEMPT_WINDOW" default:"24h"`
+
+	// Expired tokens, challenges, login attempts and rate limit windows are
+	// deleted every PURGE_INTERVAL; 0 turns this off
+	PurgeInterval time.Duration `envconfig:"PURGE_INTERVAL" default:"1h"`
+
+	// Two-factor authentication
+	MFAIssuer            string        `envconfig:"MFA_ISSUER" default:"goNexttemp"`
+	MFAChallengeExpiry   time.Duration `envconfig:"MFA_CHALLENGE_EXPIRY" default:"5m"`
+	MFAChallengeAttempts int           `envconfig:"MFA_CHALLENGE_ATTEMPTS" default:"5"`
+	MFARecoveryCodes     int           `envconfig:"MFA_RECOVERY_CODES" default:"10"`
+	MFARecoveryCodesWarn int           `envconfig:"MFA_RECOVERY_CODES_WARN" default:"3"`
+
+	// Passkeys (WebAuthn). The RP ID is the domain passkeys are bound to and
+	// WEBAUTHN_ORIGINS lists the frontend origins, comma separated.
+	WebAuthnRPID    string        `envconfig:"WEBAUTHN_RP_ID" default:"localhost"`
+	WebAuthnRPName  string        `envconfig:"WEBAUTHN_RP_NAME" default:"goNexttemp"`
+	WebAuthnOrigins string        `envconfig:"WEBAUTHN_ORIGINS" default:"http://localhost:3000"`
+	WebAuthnTimeout time.Duration `envconfig:"WEBAUTHN_TIMEOUT" default:"5m"`
+
+	// Personal access tokens: a non-zero maximum lifetime makes an expiry
+	// mandatory
+	PATMaxLifetime time.Duration `envconfig:"PAT_MAX_LIFETIME" default:"0"`
+
+	// Service accounts (see cmd/svcctl): how long previous client secrets
+	// keep working after a rotation
+	ServiceAccountSecretOverlap time.Duration `envconfig:"SERVICE_ACCOUNT_SECRET_OVERLAP" default:"24h"`
+
+	// Mail: "smtp", "file" (writes .eml files to MAIL_FILE_DIR) or "memory"
+	MailDriver   string `envconfig:"MAIL_DRIVER" default:"file"`
+	MailFrom     string `envconfig:"MAIL_FROM" default:"no-reply@localhost"`
+	MailFileDir  string `envconfig:"MAIL_FILE_DIR" default:"tmp/mail"`
+	SMTPHost     string `envconfig:"SMTP_HOST"`
+	SMTPPort     string `envconfig:"SMTP_PORT" default:"587"`
+	SMTPUsername string `envconfig:"SMTP_USERNAME"`
+	SMTPPassword string `envconfig:"SMTP_PASSWORD"`
+
+	// External identity providers: OAUTH_PROVIDERS is a comma separated list
+	// of names, each configured through OAUTH_<NAME>_CLIENT_ID etc. Provider
+	// callbacks go to API_URL, the public URL of this server.
+	APIURL             string          `envconfig:"API_URL" default:"http://localhost:8080"`
+	OAuthProviderNames string          `envconfig:"OAUTH_PROVIDERS"`
+	OAuthLinkPolicy    string          `envconfig:"OAUTH_LINK_POLICY" default:"explicit"`
+	OAuthProviders     []OAuthProvider `ignored:"true"`
+
+	// Authorization server for registered clients (see cmd/oauthctl). The
+	// request expiry covers the user logging in and consenting; the code
+	// expiry covers the client redeeming the code.
+	OAuthServerRequestExpiry time.Duration `envconfig:"OAUTH_SERVER_REQUEST_EXPIRY" default:"10m"`
+	OAuthServerCodeExpiry    time.Duration `envconfig:"OAUTH_SERVER_CODE_EXPIRY" default:"1m"`
 
 	// CORS
 	CORSOrigins string `envconfig:"CORS_ORIGINS" default:"http://localhost:3000"`
+
+	// Client IPs, used by login throttling and session records. By default
+	// the peer address is used and X-Forwarded-For is ignored; list the IPs
+	// or CIDRs of reverse proxies whose X-Forwarded-For may be believed, or
+	// name a platform (fly, cloudflare) whose client IP header to trust.
+	TrustedProxies  []string `envconfig:"TRUSTED_PROXIES"`
+	TrustedPlatform string   `envconfig:"TRUSTED_PLATFORM"`
 }
 
 func Load() (*Config, error) {
@@ -27,5 +233,104 @@ func Load() (*Config, error) {
 	if err := envconfig.Process("", &cfg); err != nil {
 		return nil, err
 	}
+
+	if len(cfg.TokenHashKey) < 32 {
+		return nil, errors.New("TOKEN_HASH_KEY must be at least 32 bytes")
+	}
+	if len(cfg.EncryptionKey) != 32 {
+		return nil, errors.New("ENCRYPTION_KEY must be exactly 32 bytes, an AES-256 key")
+	}
+
+	if cfg.JWTSigningAlg == "HS256" {
+		if cfg.JWTSecret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+	} else if cfg.JWTPrivateKey == "" && cfg.JWTPrivateKeyFile == "" {
+		return nil, errors.New("JWT_PRIVATE_KEY or JWT_PRIVATE_KEY_FILE is required for " + cfg.JWTSigningAlg)
+	}
+
+	if cfg.PasswordArgon2Memory < 8*uint32(cfg.PasswordArgon2Parallelism) || cfg.PasswordArgon2Iterations == 0 || cfg.PasswordArgon2Parallelism == 0 {
+		return nil, errors.New("PASSWORD_ARGON2_* must be positive, with at least 8 KiB of memory per lane")
+	}
+	if cfg.PasswordMinLength < 1 || cfg.PasswordMaxLength < cfg.PasswordMinLength {
+		return nil, errors.New("PASSWORD_MAX_LENGTH must be at least PASSWORD_MIN_LENGTH, which must be positive")
+	}
+	if !passwordPepperID.MatchString(cfg.PasswordPepperID) {
+		return nil, errors.New("PASSWORD_PEPPER_ID must be alphanumeric")
+	}
+	for id, pepper := range cfg.PasswordPreviousPeppers {
+		if !passwordPepperID.MatchString(id) || pepper == "" {
+			return nil, errors.New("PASSWORD_PREVIOUS_PEPPERS must map alphanumeric IDs to non-empty peppers")
+		}
+		if cfg.PasswordPepper != "" && id == cfg.PasswordPepperID {
+			return nil, errors.New("PASSWORD_PREVIOUS_PEPPERS must not reuse PASSWORD_PEPPER_ID")
+		}
+	}
+
+	switch cfg.EmailVerificationRequired {
+	case EmailVerificationNone, EmailVerificationLogin, EmailVerificationRoutes:
+	default:
+		return nil, errors.New("EMAIL_VERIFICATION_REQUIRED must be none, login or routes")
+	}
+
+	switch cfg.MagicLinkRateStore {
+	case "postgres", "memory":
+	default:
+		return nil, errors.New("MAGIC_LINK_RATE_STORE must be postgres or memory")
+	}
+
+	switch cfg.LoginAttemptStore {
+	case "postgres", "memory":
+	default:
+		return nil, errors.New("LOGIN_ATTEMPT_STORE must be postgres or memory")
+	}
+	if cfg.LoginAttemptWindow < cfg.LoginLockoutDuration {
+		return nil, errors.New("LOGIN_ATTEMPT_WINDOW must not be shorter than LOGIN_LOCKOUT_DURATION")
+	}
+
+	switch cfg.DeletedEmailPolicy {
+	case DeletedEmailRelease, DeletedEmailHold, DeletedEmailNever:
+	default:
+		return nil, errors.New("DELETED_EMAIL_POLICY must be release, hold or never")
+	}
+
+	switch cfg.OAuthLinkPolicy {
+	case OAuthLinkExplicit, OAuthLinkVerifiedEmail:
+	default:
+		return nil, errors.New("OAUTH_LINK_POLICY must be explicit or verified_email")
+	}
+
+	if cfg.PurgeInterval < 0 {
+		return nil, errors.New("PURGE_INTERVAL must not be negative")
+	}
+
+	for _, proxy := range cfg.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", proxy)
+			}
+		}
+	}
+	switch cfg.TrustedPlatform {
+	case "", "fly", "cloudflare":
+	default:
+		return nil, errors.New("TRUSTED_PLATFORM must be fly or cloudflare")
+	}
+
+	for _, name := range strings.Split(cfg.OAuthProviderNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !oauthProviderName.MatchString(name) {
+			return nil, fmt.Errorf("invalid OAuth provider name %q", name)
+		}
+		provider := OAuthProvider{Name: name}
+		if err := envconfig.Process("OAUTH_"+strings.ToUpper(name), &provider); err != nil {
+			return nil, err
+		}
+		cfg.OAuthProviders = append(cfg.OAuthProviders, provider)
+	}
+
 	return &cfg, nil
 }
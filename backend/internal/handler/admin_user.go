@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminUserHandler serves /admin/users. Actions are attributed to the
+// admin whose access token made the request.
+type AdminUserHandler struct {
+	adminUserService service.AdminUserService
+}
+
+func NewAdminUserHandler(adminUserService service.AdminUserService) *AdminUserHandler {
+	return &AdminUserHandler{adminUserService: adminUserService}
+}
+
+func (h *AdminUserHandler) List(c *gin.Context) {
+	page, err := h.adminUserService.List(c.Request.Context(), c.Request.URL.Query())
+	if err != nil {
+		if listQueryRejected(c, err) {
+			return
+		}
+		h.handleError(c, err, "Failed to list users")
+		return
+	}
+
+	respondPage(c, page)
+}
+
+func (h *AdminUserHandler) Get(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminUserService.Get(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err, "Failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(user))
+}
+
+func (h *AdminUserHandler) Disable(c *gin.Context) {
+	h.act(c, h.adminUserService.Disable, "User disabled successfully", "Failed to disable user")
+}
+
+func (h *AdminUserHandler) Enable(c *gin.Context) {
+	h.act(c, h.adminUserService.Enable, "User enabled successfully", "Failed to enable user")
+}
+
+func (h *AdminUserHandler) ForcePasswordReset(c *gin.Context) {
+	h.act(c, h.adminUserService.ForcePasswordReset, "Password reset required and reset link sent", "Failed to force password reset")
+}
+
+func (h *AdminUserHandler) RevokeSessions(c *gin.Context) {
+	h.act(c, h.adminUserService.RevokeSessions, "Sessions revoked successfully", "Failed to revoke sessions")
+}
+
+func (h *AdminUserHandler) Delete(c *gin.Context) {
+	h.act(c, h.adminUserService.Delete, "User deleted successfully", "Failed to delete user")
+}
+
+func (h *AdminUserHandler) Restore(c *gin.Context) {
+	h.act(c, h.adminUserService.Restore, "User restored successfully", "Failed to restore user")
+}
+
+func (h *AdminUserHandler) Unlock(c *gin.Context) {
+	h.act(c, h.adminUserService.Unlock, "User unlocked successfully", "Failed to unlock user")
+}
+
+// act runs an action of the current admin on the user in the path
+func (h *AdminUserHandler) act(
+	c *gin.Context,
+	action func(ctx context.Context, actorID, userID uuid.UUID) error,
+	message, failure string,
+) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := action(c.Request.Context(), actorID, userID); err != nil {
+		h.handleError(c, err, failure)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": message,
+	}))
+}
+
+func (h *AdminUserHandler) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, response.Error(
+			response.CodeNotFound,
+			"User not found",
+		))
+	case errors.Is(err, service.ErrSelfAction):
+		c.JSON(http.StatusForbidden, response.Error(
+			response.CodeForbidden,
+			"Admins cannot do this to their own account",
+		))
+	case errors.Is(err, service.ErrUserNotDeleted):
+		c.JSON(http.StatusConflict, response.Error(
+			response.CodeConflict,
+			"User is not deleted",
+		))
+	case errors.Is(err, service.ErrEmailTaken):
+		c.JSON(http.StatusConflict, response.Error(
+			response.CodeConflict,
+			"Another account uses the email address",
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			message,
+		))
+	}
+}
@@ -3,18 +3,11 @@ package handler
 import (
 	"errors"
 	"net/http"
-	"time"
 
-	"github.com/ablaze/gonexttemp-backend/internal/middleware"
 	"github.com/ablaze/gonexttemp-backend/internal/service"
 	"github.com/ablaze/gonexttemp-backend/pkg/response"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/google/uuid"
-)
-
-const (
-	RefreshTokenCookie = "refresh_token"
 )
 
 type AuthHandler struct {
@@ -47,8 +40,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	authRes, err := h.authService.Register(c.Request.Context(), req)
+	authRes, err := h.authService.Register(c.Request.Context(), req, clientInfo(c))
 	if err != nil {
+		if passwordRejected(c, "password", err) {
+			return
+		}
 		if errors.Is(err, service.ErrUserAlreadyExists) {
 			c.JSON(http.StatusConflict, response.Error(
 				response.CodeConflict,
@@ -63,7 +59,15 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	h.setRefreshTokenCookie(c, authRes.RefreshToken)
+	if authRes.EmailVerificationRequired {
+		c.JSON(http.StatusCreated, response.Success(gin.H{
+			"user":                        authRes.User,
+			"email_verification_required": true,
+		}))
+		return
+	}
+
+	setRefreshTokenCookie(c, authRes.RefreshToken)
 	c.JSON(http.StatusCreated, response.Success(gin.H{
 		"user":         authRes.User,
 		"access_token": authRes.AccessToken,
@@ -89,8 +93,18 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	authRes, err := h.authService.Login(c.Request.Context(), req)
+	authRes, err := h.authService.Login(c.Request.Context(), req, clientInfo(c))
 	if err != nil {
+		var lockedErr *service.AccountLockedError
+		if errors.As(err, &lockedErr) {
+			accountLocked(c, lockedErr.RetryAfter)
+			return
+		}
+		var rateErr *service.RateLimitError
+		if errors.As(err, &rateErr) {
+			rateLimited(c, rateErr.RetryAfter)
+			return
+		}
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			c.JSON(http.StatusUnauthorized, response.Error(
 				response.CodeInvalidCredentials,
@@ -98,6 +112,24 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			))
 			return
 		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, response.Error(
+				response.CodeEmailNotVerified,
+				"Email address has not been verified",
+			))
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			accountDisabled(c)
+			return
+		}
+		if errors.Is(err, service.ErrPasswordResetRequired) {
+			c.JSON(http.StatusForbidden, response.Error(
+				response.CodePasswordResetRequired,
+				"Password must be reset; check your email for a reset link",
+			))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.Error(
 			response.CodeInternalError,
 			"Failed to login",
@@ -105,7 +137,78 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	h.setRefreshTokenCookie(c, authRes.RefreshToken)
+	if authRes.MFAToken != "" {
+		c.JSON(http.StatusOK, response.Success(gin.H{
+			"mfa_required": true,
+			"mfa_token":    authRes.MFAToken,
+		}))
+		return
+	}
+
+	setRefreshTokenCookie(c, authRes.RefreshToken)
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"user":         authRes.User,
+		"access_token": authRes.AccessToken,
+		"expires_in":   authRes.ExpiresIn,
+	}))
+}
+
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req service.VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return
+	}
+
+	authRes, err := h.authService.VerifyMFA(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		var lockedErr *service.AccountLockedError
+		if errors.As(err, &lockedErr) {
+			accountLocked(c, lockedErr.RetryAfter)
+			return
+		}
+		var rateErr *service.RateLimitError
+		if errors.As(err, &rateErr) {
+			rateLimited(c, rateErr.RetryAfter)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidMFACode) {
+			c.JSON(http.StatusUnauthorized, response.Error(
+				response.CodeMFAInvalidCode,
+				"Invalid two-factor authentication code",
+			))
+			return
+		}
+		if errors.Is(err, service.ErrInvalidMFAChallenge) || errors.Is(err, service.ErrMFANotEnabled) {
+			c.JSON(http.StatusUnauthorized, response.Error(
+				response.CodeTokenInvalid,
+				"Invalid or expired MFA token",
+			))
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			accountDisabled(c)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to verify two-factor authentication",
+		))
+		return
+	}
+
+	setRefreshTokenCookie(c, authRes.RefreshToken)
 	c.JSON(http.StatusOK, response.Success(gin.H{
 		"user":         authRes.User,
 		"access_token": authRes.AccessToken,
@@ -123,10 +226,18 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		return
 	}
 
-	authRes, err := h.authService.Refresh(c.Request.Context(), refreshToken)
+	authRes, err := h.authService.Refresh(c.Request.Context(), refreshToken, clientInfo(c))
 	if err != nil {
+		if errors.Is(err, service.ErrTokenReused) {
+			clearRefreshTokenCookie(c)
+			c.JSON(http.StatusUnauthorized, response.Error(
+				response.CodeTokenReused,
+				"Refresh token has already been used",
+			))
+			return
+		}
 		if errors.Is(err, service.ErrInvalidToken) {
-			h.clearRefreshTokenCookie(c)
+			clearRefreshTokenCookie(c)
 			c.JSON(http.StatusUnauthorized, response.Error(
 				response.CodeTokenInvalid,
 				"Invalid or expired refresh token",
@@ -140,7 +251,7 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		return
 	}
 
-	h.setRefreshTokenCookie(c, authRes.RefreshToken)
+	setRefreshTokenCookie(c, authRes.RefreshToken)
 	c.JSON(http.StatusOK, response.Success(gin.H{
 		"user":         authRes.User,
 		"access_token": authRes.AccessToken,
@@ -154,31 +265,160 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		_ = h.authService.Logout(c.Request.Context(), refreshToken)
 	}
 
-	h.clearRefreshTokenCookie(c)
+	clearRefreshTokenCookie(c)
 	c.JSON(http.StatusOK, response.Success(gin.H{
 		"message": "Logged out successfully",
 	}))
 }
 
-func (h *AuthHandler) Me(c *gin.Context) {
-	userIDStr, exists := c.Get(middleware.ContextUserID)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, response.Error(
-			response.CodeUnauthorized,
-			"User not authenticated",
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req service.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
 		))
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
+	if err := h.validate.Struct(req); err != nil {
 		c.JSON(http.StatusBadRequest, response.Error(
 			response.CodeValidationError,
-			"Invalid user ID",
+			err.Error(),
 		))
 		return
 	}
 
+	if err := h.authService.ForgotPassword(c.Request.Context(), req); err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to request password reset",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "If an account exists for this email, a password reset link has been sent",
+	}))
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req service.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), req); err != nil {
+		if passwordRejected(c, "password", err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, response.Error(
+				response.CodeTokenInvalid,
+				"Invalid or expired password reset token",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to reset password",
+		))
+		return
+	}
+
+	clearRefreshTokenCookie(c)
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Password has been reset successfully",
+	}))
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req service.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c.Request.Context(), req); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, response.Error(
+				response.CodeTokenInvalid,
+				"Invalid or expired verification token",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to verify email",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Email verified successfully",
+	}))
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req service.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.authService.ResendVerification(c.Request.Context(), req); err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to resend verification email",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "If an unverified account exists for this email, a verification link has been sent",
+	}))
+}
+
+func (h *AuthHandler) Me(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	user, err := h.authService.GetCurrentUser(c.Request.Context(), userID)
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) {
@@ -198,28 +438,10 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	c.JSON(http.StatusOK, response.Success(user))
 }
 
-func (h *AuthHandler) setRefreshTokenCookie(c *gin.Context, token string) {
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie(
-		RefreshTokenCookie,
-		token,
-		int(7*24*time.Hour.Seconds()), // 7 days
-		"/",
-		"",
-		false, // secure: set to true in production with HTTPS
-		true,  // httpOnly
-	)
-}
-
-func (h *AuthHandler) clearRefreshTokenCookie(c *gin.Context) {
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie(
-		RefreshTokenCookie,
-		"",
-		-1,
-		"/",
-		"",
-		false,
-		true,
-	)
+// accountDisabled answers 403 for an account an admin has disabled
+func accountDisabled(c *gin.Context) {
+	c.JSON(http.StatusForbidden, response.Error(
+		response.CodeAccountDisabled,
+		"Account has been disabled",
+	))
 }
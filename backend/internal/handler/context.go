@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/middleware"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// currentUserID returns the authenticated user's ID. It writes an error
+// response and returns false when the ID is missing or malformed.
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDStr, exists := c.Get(middleware.ContextUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, response.Error(
+			response.CodeUnauthorized,
+			"User not authenticated",
+		))
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid user ID",
+		))
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// userIDParam parses the :id path parameter of /users/:id routes. It writes
+// an error response and returns false when the ID is malformed.
+func userIDParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid user ID",
+		))
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// currentSessionID returns the session the access token was issued for,
+// or uuid.Nil if the token carries none
+func currentSessionID(c *gin.Context) uuid.UUID {
+	sessionID, err := uuid.Parse(c.GetString(middleware.ContextSessionID))
+	if err != nil {
+		return uuid.Nil
+	}
+	return sessionID
+}
+
+// clientInfo collects the request details recorded on a session
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RefreshTokenCookie = "refresh_token"
+	OAuthStateCookie   = "oauth_state"
+	MagicLinkCookie    = "magic_link_binding"
+
+	// OAuthStateMaxAge bounds how long the user may take at the provider
+	OAuthStateMaxAge = 10 * time.Minute
+)
+
+func setRefreshTokenCookie(c *gin.Context, token string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		RefreshTokenCookie,
+		token,
+		int(7*24*time.Hour.Seconds()), // 7 days
+		"/",
+		"",
+		false, // secure: set to true in production with HTTPS
+		true,  // httpOnly
+	)
+}
+
+func clearRefreshTokenCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		RefreshTokenCookie,
+		"",
+		-1,
+		"/",
+		"",
+		false,
+		true,
+	)
+}
+
+// setOAuthStateCookie binds an authorization request to the browser that
+// started it. It must be Lax so it is sent on the provider's redirect back.
+func setOAuthStateCookie(c *gin.Context, state string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		OAuthStateCookie,
+		state,
+		int(OAuthStateMaxAge.Seconds()),
+		"/",
+		"",
+		false,
+		true,
+	)
+}
+
+func clearOAuthStateCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		OAuthStateCookie,
+		"",
+		-1,
+		"/",
+		"",
+		false,
+		true,
+	)
+}
+
+// setMagicLinkCookie binds a requested magic link to this browser
+func setMagicLinkCookie(c *gin.Context, binding string, maxAge time.Duration) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		MagicLinkCookie,
+		binding,
+		int(maxAge.Seconds()),
+		"/",
+		"",
+		false,
+		true,
+	)
+}
+
+func clearMagicLinkCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		MagicLinkCookie,
+		"",
+		-1,
+		"/",
+		"",
+		false,
+		true,
+	)
+}
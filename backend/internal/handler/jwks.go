@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	jwt *auth.JWTManager
+}
+
+func NewJWKSHandler(jwt *auth.JWTManager) *JWKSHandler {
+	return &JWKSHandler{jwt: jwt}
+}
+
+// JWKS serves the key set as a bare JSON document (not wrapped in the API
+// envelope) so that standard JOSE libraries can consume it
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwt.JWKS())
+}
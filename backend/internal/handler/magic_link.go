@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type MagicLinkHandler struct {
+	magicLinks  service.MagicLinkService
+	authService service.AuthService
+	linkExpiry  time.Duration
+	validate    *validator.Validate
+}
+
+// NewMagicLinkHandler creates the handler. linkExpiry is how long the
+// browser binding cookie is kept.
+func NewMagicLinkHandler(magicLinks service.MagicLinkService, authService service.AuthService, linkExpiry time.Duration) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		magicLinks:  magicLinks,
+		authService: authService,
+		linkExpiry:  linkExpiry,
+		validate:    validator.New(),
+	}
+}
+
+func (h *MagicLinkHandler) Send(c *gin.Context) {
+	var req service.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return
+	}
+
+	binding, err := h.magicLinks.Send(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		var rateErr *service.RateLimitError
+		if errors.As(err, &rateErr) {
+			rateLimited(c, rateErr.RetryAfter)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to send sign-in link",
+		))
+		return
+	}
+
+	if binding != "" {
+		setMagicLinkCookie(c, binding, h.linkExpiry)
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "If an account exists for this email, a sign-in link has been sent",
+	}))
+}
+
+// Consume exchanges a link for the same response and refresh token cookie
+// as a password login
+func (h *MagicLinkHandler) Consume(c *gin.Context) {
+	var req service.ConsumeMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return
+	}
+
+	binding, _ := c.Cookie(MagicLinkCookie)
+	userID, err := h.magicLinks.Consume(c.Request.Context(), req, binding)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMagicLink) {
+			c.JSON(http.StatusUnauthorized, response.Error(
+				response.CodeTokenInvalid,
+				"Invalid or expired sign-in link",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to sign in",
+		))
+		return
+	}
+	if binding != "" {
+		clearMagicLinkCookie(c)
+	}
+
+	authRes, err := h.authService.LoginExternal(c.Request.Context(), userID, clientInfo(c))
+	if err != nil {
+		var lockedErr *service.AccountLockedError
+		if errors.As(err, &lockedErr) {
+			accountLocked(c, lockedErr.RetryAfter)
+			return
+		}
+		var rateErr *service.RateLimitError
+		if errors.As(err, &rateErr) {
+			rateLimited(c, rateErr.RetryAfter)
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusUnauthorized, response.Error(
+				response.CodeTokenInvalid,
+				"Invalid or expired sign-in link",
+			))
+		case errors.Is(err, service.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, response.Error(
+				response.CodeEmailNotVerified,
+				"Email address has not been verified",
+			))
+		case errors.Is(err, service.ErrAccountDisabled):
+			accountDisabled(c)
+		default:
+			c.JSON(http.StatusInternalServerError, response.Error(
+				response.CodeInternalError,
+				"Failed to sign in",
+			))
+		}
+		return
+	}
+
+	if authRes.MFAToken != "" {
+		c.JSON(http.StatusOK, response.Success(gin.H{
+			"mfa_required": true,
+			"mfa_token":    authRes.MFAToken,
+		}))
+		return
+	}
+
+	setRefreshTokenCookie(c, authRes.RefreshToken)
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"user":         authRes.User,
+		"access_token": authRes.AccessToken,
+		"expires_in":   authRes.ExpiresIn,
+	}))
+}
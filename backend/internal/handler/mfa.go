@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type MFAHandler struct {
+	mfaService service.MFAService
+	validate   *validator.Validate
+}
+
+func NewMFAHandler(mfaService service.MFAService) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+		validate:   validator.New(),
+	}
+}
+
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.mfaService.EnrollTOTP(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, response.Error(
+				response.CodeConflict,
+				"Two-factor authentication is already enabled",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to start two-factor authentication enrollment",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(enrollment))
+}
+
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmTOTP(c.Request.Context(), userID, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(codes))
+}
+
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.mfaService.DisableTOTP(c.Request.Context(), userID, req); err != nil {
+		h.handleError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Two-factor authentication disabled successfully",
+	}))
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(codes))
+}
+
+func (h *MFAHandler) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return false
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return false
+	}
+	return true
+}
+
+func (h *MFAHandler) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeMFAInvalidCode,
+			"Invalid two-factor authentication code",
+		))
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, response.Error(
+			response.CodeConflict,
+			"Two-factor authentication is already enabled",
+		))
+	case errors.Is(err, service.ErrMFANotEnrolled), errors.Is(err, service.ErrMFANotEnabled):
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Two-factor authentication is not enabled",
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			message,
+		))
+	}
+}
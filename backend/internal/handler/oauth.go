@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// oauthCallbackPath is the frontend page the provider callback redirects
+// to. On success the frontend obtains an access token from /auth/refresh.
+const oauthCallbackPath = "/auth/callback"
+
+// Error values passed to the frontend callback page
+const (
+	oauthErrorInvalidState     = "invalid_state"
+	oauthErrorAccessDenied     = "access_denied"
+	oauthErrorLoginFailed      = "login_failed"
+	oauthErrorEmailRequired    = "email_required"
+	oauthErrorAccountExists    = "account_exists"
+	oauthErrorAlreadyLinked    = "already_linked"
+	oauthErrorEmailNotVerified = "email_not_verified"
+	oauthErrorAccountDisabled  = "account_disabled"
+	oauthErrorAccountLocked    = "account_locked"
+)
+
+type OAuthHandler struct {
+	oauthService service.OAuthService
+	authService  service.AuthService
+	appURL       string
+}
+
+func NewOAuthHandler(oauthService service.OAuthService, authService service.AuthService, appURL string) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		authService:  authService,
+		appURL:       appURL,
+	}
+}
+
+func (h *OAuthHandler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"providers": h.oauthService.Providers(),
+	}))
+}
+
+// Start redirects the browser to the identity provider
+func (h *OAuthHandler) Start(c *gin.Context) {
+	start, err := h.oauthService.Start(c.Request.Context(), c.Param("provider"), nil)
+	if err != nil {
+		h.handleStartError(c, err)
+		return
+	}
+
+	setOAuthStateCookie(c, start.State)
+	c.Redirect(http.StatusFound, start.URL)
+}
+
+// Link starts linking a provider to the logged in user. It returns the
+// authorization URL for the frontend to navigate to, since the access token
+// cannot travel with a browser redirect.
+func (h *OAuthHandler) Link(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	start, err := h.oauthService.Start(c.Request.Context(), c.Param("provider"), &userID)
+	if err != nil {
+		h.handleStartError(c, err)
+		return
+	}
+
+	setOAuthStateCookie(c, start.State)
+	c.JSON(http.StatusOK, response.Success(start))
+}
+
+// Callback finishes the flow and redirects back to the frontend, with the
+// refresh token cookie set on success
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	provider := c.Param("provider")
+	state := c.Query("state")
+
+	cookieState, _ := c.Cookie(OAuthStateCookie)
+	clearOAuthStateCookie(c)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		h.redirect(c, url.Values{"error": {oauthErrorInvalidState}})
+		return
+	}
+	if c.Query("error") != "" {
+		h.redirect(c, url.Values{"error": {oauthErrorAccessDenied}})
+		return
+	}
+
+	result, err := h.oauthService.Complete(c.Request.Context(), provider, state, c.Query("code"))
+	if err != nil {
+		h.redirectError(c, err)
+		return
+	}
+	if result.Linked {
+		h.redirect(c, url.Values{"linked": {provider}})
+		return
+	}
+
+	authRes, err := h.authService.LoginExternal(c.Request.Context(), result.UserID, clientInfo(c))
+	if err != nil {
+		h.redirectError(c, err)
+		return
+	}
+	if authRes.MFAToken != "" {
+		// The fragment keeps the challenge out of server logs and Referer
+		c.Redirect(http.StatusFound, h.appURL+oauthCallbackPath+"#"+url.Values{
+			"mfa_token": {authRes.MFAToken},
+		}.Encode())
+		return
+	}
+
+	setRefreshTokenCookie(c, authRes.RefreshToken)
+	h.redirect(c, nil)
+}
+
+func (h *OAuthHandler) ListIdentities(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	identities, err := h.oauthService.ListIdentities(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to list identities",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(identities))
+}
+
+func (h *OAuthHandler) Unlink(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid identity ID",
+		))
+		return
+	}
+
+	if err := h.oauthService.Unlink(c.Request.Context(), userID, id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrIdentityNotFound), errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, response.Error(
+				response.CodeNotFound,
+				"Identity not found",
+			))
+		case errors.Is(err, service.ErrLastLoginMethod):
+			c.JSON(http.StatusConflict, response.Error(
+				response.CodeConflict,
+				"Set a password or link another provider before unlinking this one",
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, response.Error(
+				response.CodeInternalError,
+				"Failed to unlink identity",
+			))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Identity unlinked successfully",
+	}))
+}
+
+func (h *OAuthHandler) handleStartError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, response.Error(
+			response.CodeNotFound,
+			"Unknown identity provider",
+		))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, response.Error(
+		response.CodeInternalError,
+		"Failed to start login with identity provider",
+	))
+}
+
+func (h *OAuthHandler) redirectError(c *gin.Context, err error) {
+	code := oauthErrorLoginFailed
+	switch {
+	case errors.Is(err, service.ErrInvalidOAuthState), errors.Is(err, service.ErrUnknownProvider):
+		code = oauthErrorInvalidState
+	case errors.Is(err, service.ErrOAuthEmailRequired):
+		code = oauthErrorEmailRequired
+	case errors.Is(err, service.ErrOAuthAccountExists):
+		code = oauthErrorAccountExists
+	case errors.Is(err, service.ErrIdentityAlreadyLinked):
+		code = oauthErrorAlreadyLinked
+	case errors.Is(err, service.ErrEmailNotVerified):
+		code = oauthErrorEmailNotVerified
+	case errors.Is(err, service.ErrAccountDisabled):
+		code = oauthErrorAccountDisabled
+	}
+	var lockedErr *service.AccountLockedError
+	if errors.As(err, &lockedErr) {
+		code = oauthErrorAccountLocked
+	}
+	h.redirect(c, url.Values{"error": {code}})
+}
+
+func (h *OAuthHandler) redirect(c *gin.Context, params url.Values) {
+	target := h.appURL + oauthCallbackPath
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+	c.Redirect(http.StatusFound, target)
+}
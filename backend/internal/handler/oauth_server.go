@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ablaze/gonexttemp-backend/internal/middleware"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OAuthServerHandler serves the endpoints OAuth clients talk to directly
+// (which speak RFC 6749 JSON rather than the API envelope) and the API the
+// frontend consent page uses
+type OAuthServerHandler struct {
+	oauthServer service.OAuthServerService
+}
+
+func NewOAuthServerHandler(oauthServer service.OAuthServerService) *OAuthServerHandler {
+	return &OAuthServerHandler{oauthServer: oauthServer}
+}
+
+// Configuration serves the OpenID Provider metadata
+func (h *OAuthServerHandler) Configuration(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.oauthServer.Configuration())
+}
+
+// Authorize validates the request and redirects the browser to the consent
+// page. Requests whose client or redirect URI cannot be trusted are
+// answered here instead of being redirected.
+func (h *OAuthServerHandler) Authorize(c *gin.Context) {
+	target, err := h.oauthServer.Authorize(c.Request.Context(), service.AuthorizeRequest{
+		ResponseType:        c.Query("response_type"),
+		ClientID:            c.Query("client_id"),
+		RedirectURI:         c.Query("redirect_uri"),
+		Scope:               c.Query("scope"),
+		State:               c.Query("state"),
+		Nonce:               c.Query("nonce"),
+		CodeChallenge:       c.Query("code_challenge"),
+		CodeChallengeMethod: c.Query("code_challenge_method"),
+	})
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			c.JSON(http.StatusBadRequest, oauthErr)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, &service.OAuthError{Code: service.OAuthErrServerError})
+		return
+	}
+
+	c.Redirect(http.StatusFound, target)
+}
+
+// Token serves the token endpoint. Clients authenticate with HTTP Basic
+// auth or client_id/client_secret in the form; public clients send only
+// client_id.
+func (h *OAuthServerHandler) Token(c *gin.Context) {
+	req, basic, ok := bindTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.oauthServer.Token(c.Request.Context(), req)
+	if err != nil {
+		writeTokenError(c, err, basic)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// bindTokenRequest reads a token endpoint request, taking the client
+// credentials from HTTP Basic auth or the form. It reports whether Basic
+// auth was used, and writes an error response and returns false when the
+// request is malformed.
+func bindTokenRequest(c *gin.Context) (service.TokenRequest, bool, bool) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	req := service.TokenRequest{
+		GrantType:    c.PostForm("grant_type"),
+		ClientID:     c.PostForm("client_id"),
+		ClientSecret: c.PostForm("client_secret"),
+		Code:         c.PostForm("code"),
+		RedirectURI:  c.PostForm("redirect_uri"),
+		CodeVerifier: c.PostForm("code_verifier"),
+		RefreshToken: c.PostForm("refresh_token"),
+		Scope:        c.PostForm("scope"),
+	}
+
+	basicID, basicSecret, basic := c.Request.BasicAuth()
+	if basic {
+		if req.ClientSecret != "" {
+			c.JSON(http.StatusBadRequest, &service.OAuthError{
+				Code:        service.OAuthErrInvalidRequest,
+				Description: "use only one client authentication method",
+			})
+			return req, basic, false
+		}
+		// RFC 6749 §2.3.1 form-encodes the credentials before Basic encoding
+		id, err1 := url.QueryUnescape(basicID)
+		secret, err2 := url.QueryUnescape(basicSecret)
+		if err1 != nil || err2 != nil || (req.ClientID != "" && req.ClientID != id) {
+			c.JSON(http.StatusBadRequest, &service.OAuthError{Code: service.OAuthErrInvalidRequest})
+			return req, basic, false
+		}
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	return req, basic, true
+}
+
+// writeTokenError answers a failed token request with the RFC 6749 error
+func writeTokenError(c *gin.Context, err error, basic bool) {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, &service.OAuthError{Code: service.OAuthErrServerError})
+		return
+	}
+	status := http.StatusBadRequest
+	if oauthErr.Code == service.OAuthErrInvalidClient {
+		status = http.StatusUnauthorized
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	}
+	c.JSON(status, oauthErr)
+}
+
+// UserInfo serves the OpenID Connect UserInfo endpoint. It authenticates
+// the bearer token itself because tokens issued to clients are refused by
+// AuthMiddleware.
+func (h *OAuthServerHandler) UserInfo(c *gin.Context) {
+	authHeader := c.GetHeader(middleware.AuthorizationHeader)
+	if !strings.HasPrefix(authHeader, middleware.BearerPrefix) {
+		c.Header("WWW-Authenticate", `Bearer`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	info, err := h.oauthServer.UserInfo(c.Request.Context(), strings.TrimPrefix(authHeader, middleware.BearerPrefix))
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			c.Header("WWW-Authenticate", `Bearer error="`+oauthErr.Code+`"`)
+			c.JSON(http.StatusUnauthorized, oauthErr)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, &service.OAuthError{Code: service.OAuthErrServerError})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, info)
+}
+
+// GetRequest returns a pending authorization request for the consent page
+func (h *OAuthServerHandler) GetRequest(c *gin.Context) {
+	userID, id, ok := h.requestParams(c)
+	if !ok {
+		return
+	}
+
+	req, err := h.oauthServer.GetRequest(c.Request.Context(), userID, id)
+	if err != nil {
+		h.handleRequestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(req))
+}
+
+// Approve returns the client redirect URI carrying the authorization code
+// for the frontend to navigate to
+func (h *OAuthServerHandler) Approve(c *gin.Context) {
+	userID, id, ok := h.requestParams(c)
+	if !ok {
+		return
+	}
+
+	target, err := h.oauthServer.Approve(c.Request.Context(), userID, id)
+	if err != nil {
+		h.handleRequestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{"redirect_uri": target}))
+}
+
+func (h *OAuthServerHandler) Deny(c *gin.Context) {
+	userID, id, ok := h.requestParams(c)
+	if !ok {
+		return
+	}
+
+	target, err := h.oauthServer.Deny(c.Request.Context(), userID, id)
+	if err != nil {
+		h.handleRequestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{"redirect_uri": target}))
+}
+
+func (h *OAuthServerHandler) ListConsents(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	consents, err := h.oauthServer.ListConsents(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to list authorized applications",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(consents))
+}
+
+// RevokeConsent withdraws an application's access
+func (h *OAuthServerHandler) RevokeConsent(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthServer.RevokeConsent(c.Request.Context(), userID, c.Param("client_id")); err != nil {
+		if errors.Is(err, service.ErrOAuthConsentNotFound) {
+			c.JSON(http.StatusNotFound, response.Error(
+				response.CodeNotFound,
+				"Authorized application not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to revoke access",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Access revoked successfully",
+	}))
+}
+
+func (h *OAuthServerHandler) requestParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request ID",
+		))
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, id, true
+}
+
+func (h *OAuthServerHandler) handleRequestError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrOAuthRequestNotFound) {
+		c.JSON(http.StatusNotFound, response.Error(
+			response.CodeNotFound,
+			"Authorization request not found or expired",
+		))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, response.Error(
+		response.CodeInternalError,
+		"Failed to process authorization request",
+	))
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/oidc"
+	"github.com/ablaze/gonexttemp-backend/internal/oidc/oidctest"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const testAppURL = "https://app.example.com"
+
+func (s loginAuthService) LoginExternal(context.Context, uuid.UUID, service.ClientInfo) (*service.AuthResponse, error) {
+	return s.res, nil
+}
+
+// newOAuthRouter serves the login flow of an oidctest provider named "test"
+func newOAuthRouter(t *testing.T) (*gin.Engine, *oidctest.Server) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	idp := oidctest.NewServer("client-id", "client-secret")
+	t.Cleanup(idp.Close)
+	provider, err := oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       idp.Issuer(),
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  "https://api.example.com/oauth/test/callback",
+	}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := repositorytest.NewUsers()
+	oauthService := service.NewOAuthService(
+		repositorytest.NewIdentities(users),
+		users,
+		[]*oidc.Provider{provider},
+		auth.NewTokenHasher("test-token-hash-key"),
+		service.OAuthOptions{StateExpiry: 10 * time.Minute},
+	)
+	authService := loginAuthService{res: &service.AuthResponse{
+		User:         &model.User{ID: uuid.New()},
+		AccessToken:  "access-token",
+		RefreshToken: "refresh-token",
+		ExpiresIn:    900,
+	}}
+	h := NewOAuthHandler(oauthService, authService, testAppURL)
+
+	router := gin.New()
+	router.GET("/oauth/:provider", h.Start)
+	router.GET("/oauth/:provider/callback", h.Callback)
+	return router, idp
+}
+
+// startOAuth begins a flow and returns the state cookie it set and the
+// callback the provider redirected to
+func startOAuth(t *testing.T, router *gin.Engine, idp *oidctest.Server) (*http.Cookie, *url.URL) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/oauth/test", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("start status = %d", rec.Code)
+	}
+	stateCookie := findCookie(rec, OAuthStateCookie)
+	if stateCookie == nil || stateCookie.Value == "" {
+		t.Fatal("start did not set the state cookie")
+	}
+
+	callback, err := idp.Authorize(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stateCookie, callback
+}
+
+func serveCallback(router *gin.Engine, callback *url.URL, stateCookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	if stateCookie != nil {
+		req.AddCookie(&http.Cookie{Name: stateCookie.Name, Value: stateCookie.Value})
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func findCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestOAuthCallbackRequiresStateCookie(t *testing.T) {
+	router, idp := newOAuthRouter(t)
+	stateCookie, callback := startOAuth(t, router, idp)
+	otherCookie, _ := startOAuth(t, router, idp)
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{"missing", nil},
+		{"of another flow", otherCookie},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveCallback(router, callback, tt.cookie)
+
+			want := testAppURL + oauthCallbackPath + "?error=" + oauthErrorInvalidState
+			if rec.Code != http.StatusFound || rec.Header().Get("Location") != want {
+				t.Fatalf("callback = %d %q, want redirect to %q", rec.Code, rec.Header().Get("Location"), want)
+			}
+			if findCookie(rec, RefreshTokenCookie) != nil {
+				t.Fatal("callback with a mismatched state set the refresh token cookie")
+			}
+			if cleared := findCookie(rec, OAuthStateCookie); cleared == nil || cleared.MaxAge >= 0 {
+				t.Fatalf("callback did not clear the state cookie: %+v", cleared)
+			}
+		})
+	}
+
+	// The rejected callbacks must not have consumed the state
+	rec := serveCallback(router, callback, stateCookie)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != testAppURL+oauthCallbackPath {
+		t.Fatalf("callback = %d %q, want redirect to %q", rec.Code, rec.Header().Get("Location"), testAppURL+oauthCallbackPath)
+	}
+	if refresh := findCookie(rec, RefreshTokenCookie); refresh == nil || refresh.Value != "refresh-token" {
+		t.Fatalf("refresh token cookie = %+v", refresh)
+	}
+}
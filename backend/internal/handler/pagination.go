@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/pagination"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// listQueryRejected answers 400 when err rejects a list query parameter,
+// and reports whether it did
+func listQueryRejected(c *gin.Context, err error) bool {
+	var queryErr *pagination.Error
+	if !errors.As(err, &queryErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, response.ErrorWithDetails(
+		response.CodeValidationError,
+		"Invalid query parameters",
+		[]response.FieldError{{Field: queryErr.Param, Code: "invalid", Message: queryErr.Error()}},
+	))
+	return true
+}
+
+// respondPage answers with the items of a page, and the cursor of the next
+// page in meta
+func respondPage[T any](c *gin.Context, page *pagination.Page[T]) {
+	c.JSON(http.StatusOK, response.SuccessWithMeta(page.Items, response.Meta{
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}))
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type PasskeyHandler struct {
+	passkeyService service.PasskeyService
+	authService    service.AuthService
+	validate       *validator.Validate
+}
+
+func NewPasskeyHandler(passkeyService service.PasskeyService, authService service.AuthService) *PasskeyHandler {
+	return &PasskeyHandler{
+		passkeyService: passkeyService,
+		authService:    authService,
+		validate:       validator.New(),
+	}
+}
+
+func (h *PasskeyHandler) RegistrationOptions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	options, err := h.passkeyService.BeginRegistration(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, response.Error(
+				response.CodeNotFound,
+				"User not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to start passkey registration",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(options))
+}
+
+func (h *PasskeyHandler) Register(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.PasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return
+	}
+
+	credential, err := h.passkeyService.FinishRegistration(c.Request.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPasskeyChallenge):
+			c.JSON(http.StatusBadRequest, response.Error(
+				response.CodeTokenInvalid,
+				"Invalid or expired passkey challenge",
+			))
+		case errors.Is(err, service.ErrInvalidPasskey):
+			c.JSON(http.StatusBadRequest, response.Error(
+				response.CodeValidationError,
+				"Invalid passkey",
+			))
+		case errors.Is(err, service.ErrPasskeyAlreadyRegistered):
+			c.JSON(http.StatusConflict, response.Error(
+				response.CodeConflict,
+				"Passkey is already registered",
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, response.Error(
+				response.CodeInternalError,
+				"Failed to register passkey",
+			))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.Success(credential))
+}
+
+func (h *PasskeyHandler) List(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	credentials, err := h.passkeyService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to list passkeys",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(credentials))
+}
+
+func (h *PasskeyHandler) Delete(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid passkey ID",
+		))
+		return
+	}
+
+	if err := h.passkeyService.Delete(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, service.ErrPasskeyNotFound) {
+			c.JSON(http.StatusNotFound, response.Error(
+				response.CodeNotFound,
+				"Passkey not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to delete passkey",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Passkey deleted successfully",
+	}))
+}
+
+func (h *PasskeyHandler) LoginOptions(c *gin.Context) {
+	options, err := h.passkeyService.BeginLogin(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to start passkey login",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(options))
+}
+
+// Login completes a passkey login with the same response and refresh token
+// cookie as a password login
+func (h *PasskeyHandler) Login(c *gin.Context) {
+	var req service.PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	authRes, err := h.authService.LoginWithPasskey(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPasskeyChallenge):
+			c.JSON(http.StatusUnauthorized, response.Error(
+				response.CodeTokenInvalid,
+				"Invalid or expired passkey challenge",
+			))
+		case errors.Is(err, service.ErrInvalidPasskey):
+			c.JSON(http.StatusUnauthorized, response.Error(
+				response.CodeInvalidCredentials,
+				"Invalid passkey",
+			))
+		case errors.Is(err, service.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, response.Error(
+				response.CodeEmailNotVerified,
+				"Email address has not been verified",
+			))
+		case errors.Is(err, service.ErrAccountDisabled):
+			accountDisabled(c)
+		default:
+			c.JSON(http.StatusInternalServerError, response.Error(
+				response.CodeInternalError,
+				"Failed to login",
+			))
+		}
+		return
+	}
+
+	setRefreshTokenCookie(c, authRes.RefreshToken)
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"user":         authRes.User,
+		"access_token": authRes.AccessToken,
+		"expires_in":   authRes.ExpiresIn,
+	}))
+}
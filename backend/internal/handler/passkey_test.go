@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// loginAuthService completes every login with res
+type loginAuthService struct {
+	service.AuthService
+	res *service.AuthResponse
+}
+
+func (s loginAuthService) Login(context.Context, service.LoginRequest, service.ClientInfo) (*service.AuthResponse, error) {
+	return s.res, nil
+}
+
+func (s loginAuthService) LoginWithPasskey(context.Context, service.PasskeyLoginRequest, service.ClientInfo) (*service.AuthResponse, error) {
+	return s.res, nil
+}
+
+func serveLogin(t *testing.T, handle gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/login", handle)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPasskeyLoginRespondsLikePasswordLogin(t *testing.T) {
+	authService := loginAuthService{res: &service.AuthResponse{
+		User:         &model.User{ID: uuid.New(), Email: "alice@example.com", Name: "Alice"},
+		AccessToken:  "access-token",
+		RefreshToken: "refresh-token",
+		ExpiresIn:    900,
+	}}
+
+	password := serveLogin(t, NewAuthHandler(authService).Login,
+		`{"email":"alice@example.com","password":"correct horse battery"}`)
+	passkey := serveLogin(t, NewPasskeyHandler(nil, authService).Login, `{"credential":{}}`)
+
+	if password.Code != http.StatusOK || passkey.Code != http.StatusOK {
+		t.Fatalf("status password = %d, passkey = %d; body %s", password.Code, passkey.Code, passkey.Body)
+	}
+
+	var passwordBody, passkeyBody map[string]any
+	if err := json.Unmarshal(password.Body.Bytes(), &passwordBody); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(passkey.Body.Bytes(), &passkeyBody); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(passwordBody, passkeyBody) {
+		t.Fatalf("passkey login body = %v, password login body = %v", passkeyBody, passwordBody)
+	}
+
+	passwordCookies := password.Result().Cookies()
+	passkeyCookies := passkey.Result().Cookies()
+	if len(passwordCookies) != 1 || len(passkeyCookies) != 1 {
+		t.Fatalf("cookies password = %v, passkey = %v", passwordCookies, passkeyCookies)
+	}
+	cookie := passkeyCookies[0]
+	if cookie.Name != RefreshTokenCookie || cookie.Value != "refresh-token" || !cookie.HttpOnly {
+		t.Fatalf("passkey login cookie = %+v", cookie)
+	}
+	if cookie.String() != passwordCookies[0].String() {
+		t.Fatalf("passkey login cookie %q, password login cookie %q", cookie, passwordCookies[0])
+	}
+}
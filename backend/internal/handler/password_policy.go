@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/passwordpolicy"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// passwordRejected answers 400 with one detail per broken rule when err is
+// a password policy violation, and reports whether it did
+func passwordRejected(c *gin.Context, field string, err error) bool {
+	var policyErr *passwordpolicy.Error
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	details := make([]response.FieldError, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		details[i] = response.FieldError{Field: field, Code: v.Rule, Message: v.Message}
+	}
+	c.JSON(http.StatusBadRequest, response.ErrorWithDetails(
+		response.CodeValidationError,
+		"Password does not meet the requirements",
+		details,
+	))
+	return true
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type PersonalAccessTokenHandler struct {
+	patService service.PersonalAccessTokenService
+	validate   *validator.Validate
+}
+
+func NewPersonalAccessTokenHandler(patService service.PersonalAccessTokenService) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{
+		patService: patService,
+		validate:   validator.New(),
+	}
+}
+
+// Create issues a token. The token is in the response only this once.
+func (h *PersonalAccessTokenHandler) Create(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreatePersonalAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return
+	}
+
+	created, err := h.patService.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTokenScope):
+			c.JSON(http.StatusBadRequest, response.Error(
+				response.CodeValidationError,
+				"Unknown scope; allowed scopes are "+strings.Join(auth.PersonalAccessTokenScopes, ", "),
+			))
+		case errors.Is(err, service.ErrInvalidTokenExpiry):
+			c.JSON(http.StatusBadRequest, response.Error(
+				response.CodeValidationError,
+				"Expiry must be in the future and within the maximum token lifetime",
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, response.Error(
+				response.CodeInternalError,
+				"Failed to create token",
+			))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.Success(created))
+}
+
+func (h *PersonalAccessTokenHandler) List(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.patService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to list tokens",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(tokens))
+}
+
+func (h *PersonalAccessTokenHandler) Revoke(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid token ID",
+		))
+		return
+	}
+
+	if err := h.patService.Revoke(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, service.ErrPersonalAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, response.Error(
+				response.CodeNotFound,
+				"Token not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to revoke token",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Token revoked successfully",
+	}))
+}
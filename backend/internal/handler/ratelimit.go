@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// setRetryAfter tells the client how many seconds to wait, rounded up
+func setRetryAfter(c *gin.Context, d time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// rateLimited answers 429 with a Retry-After header
+func rateLimited(c *gin.Context, retryAfter time.Duration) {
+	setRetryAfter(c, retryAfter)
+	c.JSON(http.StatusTooManyRequests, response.Error(
+		response.CodeRateLimited,
+		"Too many requests; try again later",
+	))
+}
+
+// accountLocked answers 423 with a Retry-After header
+func accountLocked(c *gin.Context, retryAfter time.Duration) {
+	setRetryAfter(c, retryAfter)
+	c.JSON(http.StatusLocked, response.Error(
+		response.CodeAccountLocked,
+		"Account temporarily locked after too many failed logins; try again later",
+	))
+}
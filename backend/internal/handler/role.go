@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	roleService service.RoleService
+}
+
+func NewRoleHandler(roleService service.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+func (h *RoleHandler) List(c *gin.Context) {
+	roles, err := h.roleService.List(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err, "Failed to list roles")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(roles))
+}
+
+func (h *RoleHandler) ListUserRoles(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.roleService.UserRoles(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err, "Failed to list roles")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(roles))
+}
+
+func (h *RoleHandler) Assign(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.roleService.Assign(c.Request.Context(), actorID, userID, c.Param("role")); err != nil {
+		h.handleError(c, err, "Failed to assign role")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Role assigned successfully",
+	}))
+}
+
+func (h *RoleHandler) Unassign(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.roleService.Unassign(c.Request.Context(), actorID, userID, c.Param("role")); err != nil {
+		h.handleError(c, err, "Failed to remove role")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Role removed successfully",
+	}))
+}
+
+func (h *RoleHandler) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, response.Error(
+			response.CodeNotFound,
+			"User not found",
+		))
+	case errors.Is(err, service.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, response.Error(
+			response.CodeNotFound,
+			"Role not found",
+		))
+	case errors.Is(err, service.ErrRoleNotAssigned):
+		c.JSON(http.StatusNotFound, response.Error(
+			response.CodeNotFound,
+			"User does not have the role",
+		))
+	case errors.Is(err, service.ErrLastAdmin):
+		c.JSON(http.StatusConflict, response.Error(
+			response.CodeConflict,
+			"Cannot remove the last admin",
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			message,
+		))
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceAccountHandler issues access tokens to service accounts. Like the
+// OAuth token endpoint it speaks RFC 6749 rather than the API envelope, so
+// standard client credentials libraries can use it.
+type ServiceAccountHandler struct {
+	serviceAccounts service.ServiceAccountService
+}
+
+func NewServiceAccountHandler(serviceAccounts service.ServiceAccountService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{serviceAccounts: serviceAccounts}
+}
+
+// Token serves the client credentials grant for service accounts
+func (h *ServiceAccountHandler) Token(c *gin.Context) {
+	req, basic, ok := bindTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.serviceAccounts.Token(c.Request.Context(), req)
+	if err != nil {
+		writeTokenError(c, err, basic)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SessionHandler struct {
+	sessionService service.SessionService
+}
+
+func NewSessionHandler(sessionService service.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+func (h *SessionHandler) List(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.List(c.Request.Context(), userID, currentSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to list sessions",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(sessions))
+}
+
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid session ID",
+		))
+		return
+	}
+
+	if err := h.sessionService.Revoke(c.Request.Context(), userID, sessionID); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, response.Error(
+				response.CodeNotFound,
+				"Session not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to revoke session",
+		))
+		return
+	}
+
+	if sessionID == currentSessionID(c) {
+		clearRefreshTokenCookie(c)
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Session revoked successfully",
+	}))
+}
+
+func (h *SessionHandler) LogoutAll(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.RevokeAll(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to log out sessions",
+		))
+		return
+	}
+
+	clearRefreshTokenCookie(c)
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Logged out of all sessions successfully",
+	}))
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/internal/service"
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type UserHandler struct {
+	userService        service.UserService
+	emailChangeService service.EmailChangeService
+	validate           *validator.Validate
+}
+
+func NewUserHandler(userService service.UserService, emailChangeService service.EmailChangeService) *UserHandler {
+	return &UserHandler{
+		userService:        userService,
+		emailChangeService: emailChangeService,
+		validate:           validator.New(),
+	}
+}
+
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.UpdateProfileRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(c.Request.Context(), userID, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to update profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(user))
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.ChangePasswordRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.userService.ChangePassword(c.Request.Context(), userID, currentSessionID(c), req); err != nil {
+		if passwordRejected(c, "new_password", err) {
+			return
+		}
+		h.handleError(c, err, "Failed to change password")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Password changed successfully",
+	}))
+}
+
+func (h *UserHandler) Delete(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Accounts without a password may send no body at all
+	var req service.DeleteAccountRequest
+	if c.Request.ContentLength != 0 && !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.userService.Delete(c.Request.Context(), userID, req); err != nil {
+		h.handleError(c, err, "Failed to delete account")
+		return
+	}
+
+	clearRefreshTokenCookie(c)
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Account deleted successfully",
+	}))
+}
+
+func (h *UserHandler) RequestEmailChange(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.ChangeEmailRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.emailChangeService.Request(c.Request.Context(), userID, req); err != nil {
+		h.handleError(c, err, "Failed to request email change")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Confirmation email sent to the new address",
+	}))
+}
+
+// ConfirmEmailChange is opened from the link sent to the new address. Every
+// session ends, so the caller's refresh cookie is cleared as well.
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	var req service.ConfirmEmailChangeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.emailChangeService.Confirm(c.Request.Context(), req); err != nil {
+		h.handleError(c, err, "Failed to change email")
+		return
+	}
+
+	clearRefreshTokenCookie(c)
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Email changed successfully",
+	}))
+}
+
+func (h *UserHandler) RevertEmailChange(c *gin.Context) {
+	var req service.RevertEmailChangeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.emailChangeService.Revert(c.Request.Context(), req); err != nil {
+		h.handleError(c, err, "Failed to revert email change")
+		return
+	}
+
+	clearRefreshTokenCookie(c)
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"message": "Email change reverted successfully",
+	}))
+}
+
+func (h *UserHandler) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"Invalid request body",
+		))
+		return false
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			err.Error(),
+		))
+		return false
+	}
+	return true
+}
+
+func (h *UserHandler) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeInvalidCredentials,
+			"Current password is incorrect",
+		))
+	case errors.Is(err, service.ErrEmailUnchanged):
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeValidationError,
+			"New email address is the current one",
+		))
+	case errors.Is(err, service.ErrEmailTaken):
+		c.JSON(http.StatusConflict, response.Error(
+			response.CodeConflict,
+			"Email address is already in use",
+		))
+	case errors.Is(err, service.ErrInvalidToken):
+		c.JSON(http.StatusBadRequest, response.Error(
+			response.CodeTokenInvalid,
+			"Invalid or expired token",
+		))
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, response.Error(
+			response.CodeNotFound,
+			"User not found",
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			message,
+		))
+	}
+}
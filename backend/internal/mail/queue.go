@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// ErrQueueFull is returned by QueueMailer.Send when the queue has no room
+var ErrQueueFull = errors.New("mail queue is full")
+
+// QueueMailer sends messages in the background, so that how long a request
+// takes does not reveal whether it sent mail, e.g. whether an address
+// belongs to an account. Messages still queued when the process exits are
+// lost.
+type QueueMailer struct {
+	next  Mailer
+	queue chan Message
+}
+
+// NewQueueMailer starts a worker delivering through next. Send fails once
+// size messages are waiting.
+func NewQueueMailer(next Mailer, size int) *QueueMailer {
+	m := &QueueMailer{next: next, queue: make(chan Message, size)}
+	go m.run()
+	return m
+}
+
+func (m *QueueMailer) Send(ctx context.Context, msg Message) error {
+	select {
+	case m.queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (m *QueueMailer) run() {
+	for msg := range m.queue {
+		// The request that queued the message may be gone by now
+		if err := m.next.Send(context.Background(), msg); err != nil {
+			slog.Error("Failed to send email", "error", err, "subject", msg.Subject)
+		}
+	}
+}
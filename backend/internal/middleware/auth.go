@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -10,13 +11,44 @@ import (
 )
 
 const (
-	AuthorizationHeader = "Authorization"
-	BearerPrefix        = "Bearer "
-	ContextUserID       = "userID"
-	ContextUserEmail    = "userEmail"
+	AuthorizationHeader  = "Authorization"
+	BearerPrefix         = "Bearer "
+	ContextUserID        = "userID"
+	ContextUserEmail     = "userEmail"
+	ContextSessionID     = "sessionID"
+	ContextEmailVerified = "emailVerified"
+	// ContextScopes holds the scopes of a personal access token or a
+	// service account token. It is not set for user JWTs, which carry the
+	// user's full access.
+	ContextScopes = "scopes"
+	// ContextPrincipalType is auth.PrincipalUser or auth.PrincipalService.
+	// Service accounts have no ContextUserID, only ContextServiceAccountID.
+	ContextPrincipalType    = "principalType"
+	ContextServiceAccountID = "serviceAccountID"
+	// ContextPermissions resolves the permissions of a user principal for
+	// RequirePermission
+	ContextPermissions = "permissions"
 )
 
-func AuthMiddleware(jwt *auth.JWTManager) gin.HandlerFunc {
+// RevocationChecker reports whether an access token has been revoked
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// PersonalAccessTokenAuthenticator resolves personal access tokens. It
+// returns auth.ErrInvalidToken or auth.ErrExpiredToken for bad tokens.
+type PersonalAccessTokenAuthenticator interface {
+	AuthenticatePersonalAccessToken(ctx context.Context, token string) (*auth.Claims, error)
+}
+
+// AuthMiddleware accepts JWT access tokens (of users and service accounts)
+// and personal access tokens
+func AuthMiddleware(
+	jwt *auth.JWTManager,
+	revocations RevocationChecker,
+	pats PersonalAccessTokenAuthenticator,
+	permissions PermissionResolver,
+) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader(AuthorizationHeader)
 		if authHeader == "" {
@@ -37,15 +69,33 @@ func AuthMiddleware(jwt *auth.JWTManager) gin.HandlerFunc {
 
 		tokenString := strings.TrimPrefix(authHeader, BearerPrefix)
 
-		claims, err := jwt.ValidateAccessToken(tokenString)
-		if err != nil {
-			if err == auth.ErrExpiredToken {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, response.Error(
-					response.CodeTokenExpired,
-					"Access token has expired",
-				))
+		if auth.IsPersonalAccessToken(tokenString) {
+			claims, err := pats.AuthenticatePersonalAccessToken(c.Request.Context(), tokenString)
+			if err != nil {
+				abortTokenError(c, err)
 				return
 			}
+
+			c.Set(ContextPrincipalType, auth.PrincipalUser)
+			c.Set(ContextUserID, claims.UserID)
+			c.Set(ContextUserEmail, claims.Email)
+			c.Set(ContextSessionID, "")
+			c.Set(ContextEmailVerified, claims.EmailVerified)
+			c.Set(ContextScopes, strings.Fields(claims.Scope))
+			c.Set(ContextPermissions, &userPermissions{resolver: permissions, userID: claims.UserID})
+			c.Next()
+			return
+		}
+
+		claims, err := jwt.ValidateAccessToken(tokenString)
+		if err != nil {
+			abortTokenError(c, err)
+			return
+		}
+
+		// Tokens issued to OAuth clients are meant for their own resource
+		// servers and /userinfo, not for acting as the user here
+		if claims.ClientID != "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, response.Error(
 				response.CodeTokenInvalid,
 				"Invalid access token",
@@ -53,8 +103,61 @@ func AuthMiddleware(jwt *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		revoked, err := revocations.IsRevoked(c.Request.Context(), claims.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, response.Error(
+				response.CodeInternalError,
+				"Failed to verify access token",
+			))
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, response.Error(
+				response.CodeTokenRevoked,
+				"Access token has been revoked",
+			))
+			return
+		}
+
+		if claims.Principal() == auth.PrincipalService {
+			c.Set(ContextPrincipalType, auth.PrincipalService)
+			c.Set(ContextServiceAccountID, claims.ServiceAccountID)
+			c.Set(ContextScopes, strings.Fields(claims.Scope))
+			c.Next()
+			return
+		}
+
+		c.Set(ContextPrincipalType, auth.PrincipalUser)
 		c.Set(ContextUserID, claims.UserID)
 		c.Set(ContextUserEmail, claims.Email)
+		c.Set(ContextSessionID, claims.SessionID)
+		c.Set(ContextEmailVerified, claims.EmailVerified)
+		c.Set(ContextPermissions, &userPermissions{
+			resolver: permissions,
+			userID:   claims.UserID,
+			claimed:  claims.Permissions,
+			version:  claims.AuthzVersion,
+		})
 		c.Next()
 	}
 }
+
+func abortTokenError(c *gin.Context, err error) {
+	switch err {
+	case auth.ErrExpiredToken:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, response.Error(
+			response.CodeTokenExpired,
+			"Access token has expired",
+		))
+	case auth.ErrInvalidToken:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, response.Error(
+			response.CodeTokenInvalid,
+			"Invalid access token",
+		))
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, response.Error(
+			response.CodeInternalError,
+			"Failed to verify access token",
+		))
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PermissionResolver looks up what a user's roles currently allow
+type PermissionResolver interface {
+	// AuthzVersion returns the user's authorization version, which changes
+	// whenever their roles do
+	AuthzVersion(ctx context.Context, userID uuid.UUID) (int, error)
+	Permissions(ctx context.Context, userID uuid.UUID) ([]string, error)
+}
+
+// userPermissions resolves the permissions of a user principal at most
+// once per request, trusting those carried by the access token while its
+// authorization version is current
+type userPermissions struct {
+	resolver PermissionResolver
+	userID   string
+	claimed  []string
+	version  int
+
+	resolved    []string
+	resolvedErr error
+	done        bool
+}
+
+func (p *userPermissions) get(ctx context.Context) ([]string, error) {
+	if p.done {
+		return p.resolved, p.resolvedErr
+	}
+	p.resolved, p.resolvedErr = p.resolve(ctx)
+	p.done = true
+	return p.resolved, p.resolvedErr
+}
+
+func (p *userPermissions) resolve(ctx context.Context) ([]string, error) {
+	userID, err := uuid.Parse(p.userID)
+	if err != nil {
+		return nil, nil
+	}
+	// Tokens issued before roles existed carry version 0
+	if p.version != 0 {
+		current, err := p.resolver.AuthzVersion(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if current == p.version {
+			return p.claimed, nil
+		}
+	}
+	return p.resolver.Permissions(ctx, userID)
+}
+
+// RequirePermission rejects principals whose roles do not grant the
+// permission. Service accounts have no roles and are always rejected. It
+// must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var granted []string
+		if value, ok := c.Get(ContextPermissions); ok {
+			permissions, err := value.(*userPermissions).get(c.Request.Context())
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, response.Error(
+					response.CodeInternalError,
+					"Failed to check permissions",
+				))
+				return
+			}
+			granted = permissions
+		}
+
+		if !slices.Contains(granted, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.Error(
+				response.CodeForbidden,
+				"Missing permission: "+permission,
+			))
+			return
+		}
+		c.Next()
+	}
+}
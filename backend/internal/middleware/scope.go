@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope rejects personal access tokens and service accounts that
+// were not granted the scope. Logged in users (JWTs) pass. It must run
+// after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopes, ok := c.Get(ContextScopes); ok && !slices.Contains(scopes.([]string), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.Error(
+				response.CodeInsufficientScope,
+				"Token lacks the required scope: "+scope,
+			))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession rejects personal access tokens and service accounts,
+// keeping account security settings (tokens, MFA, passkeys, sessions) out
+// of reach of a leaked token. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(ContextScopes); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.Error(
+				response.CodeInsufficientScope,
+				"This endpoint requires a login session",
+			))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const testPAT = auth.PersonalAccessTokenPrefix + "test"
+
+// scopedPATs accepts testPAT with the user:read scope
+type scopedPATs struct{}
+
+func (scopedPATs) AuthenticatePersonalAccessToken(ctx context.Context, token string) (*auth.Claims, error) {
+	if token != testPAT {
+		return nil, auth.ErrInvalidToken
+	}
+	return &auth.Claims{UserID: uuid.NewString(), Scope: auth.ScopeUserRead}, nil
+}
+
+type noRevocations struct{}
+
+func (noRevocations) IsRevoked(context.Context, string) (bool, error) { return false, nil }
+
+func TestScopeAndSessionRequirements(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwt := auth.NewJWTManager(auth.NewKeyRing(auth.NewHMACKey("test-jwt-secret")), 15*time.Minute, time.Hour)
+	session, _, err := jwt.GenerateAccessToken(auth.Claims{UserID: uuid.NewString(), SessionID: uuid.NewString()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(AuthMiddleware(jwt, noRevocations{}, scopedPATs{}, nil))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/me", RequireScope(auth.ScopeUserRead), ok)
+	router.GET("/reports", RequireScope("reports:read"), ok)
+	router.GET("/tokens", RequireSession(), ok)
+
+	tests := []struct {
+		path  string
+		token string
+		want  int
+	}{
+		{"/me", testPAT, http.StatusOK},
+		{"/reports", testPAT, http.StatusForbidden},
+		{"/tokens", testPAT, http.StatusForbidden},
+		{"/me", session, http.StatusOK},
+		{"/reports", session, http.StatusOK},
+		{"/tokens", session, http.StatusOK},
+		{"/me", testPAT + "x", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set(AuthorizationHeader, BearerPrefix+tt.token)
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s with %.12s...: status = %d, want %d; body %s", tt.path, tt.token, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ablaze/gonexttemp-backend/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail rejects users whose access token says their email
+// address is unverified. It must run after AuthMiddleware.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool(ContextEmailVerified) {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.Error(
+				response.CodeEmailNotVerified,
+				"Email address has not been verified",
+			))
+			return
+		}
+		c.Next()
+	}
+}
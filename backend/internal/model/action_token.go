@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes of single-use action tokens
+const (
+	ActionTokenPasswordReset     = "password_reset"
+	ActionTokenEmailVerification = "email_verification"
+	ActionTokenMagicLink         = "magic_link"
+	ActionTokenEmailChange       = "email_change"
+	ActionTokenEmailChangeRevert = "email_change_revert"
+)
+
+// ActionToken is a single-use, time-limited token emailed to a user to
+// confirm an action. Only the hash of the token is stored.
+type ActionToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Purpose   string     `gorm:"not null;size:32" json:"purpose"`
+	TokenHash string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// BindingHash ties the token to the browser that requested it: it is
+	// the hash of a value kept in a cookie there. Nil for unbound tokens.
+	BindingHash *string `gorm:"size:64" json:"-"`
+
+	// Email is the address an email change token switches the user to, as
+	// it was typed: the new address to confirm, or the previous one to
+	// revert to. Nil for other purposes. The repository keeps
+	// EmailCanonical in step with it for lookups.
+	Email          *string `gorm:"size:255" json:"-"`
+	EmailCanonical *string `gorm:"size:255;index:idx_action_tokens_email_canonical,where:email_canonical IS NOT NULL" json:"-"`
+
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (ActionToken) TableName() string {
+	return "action_tokens"
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions admins take on user accounts
+const (
+	AdminActionDisable            = "disable"
+	AdminActionEnable             = "enable"
+	AdminActionForcePasswordReset = "force_password_reset"
+	AdminActionRevokeSessions     = "revoke_sessions"
+	AdminActionDelete             = "delete"
+	AdminActionRestore            = "restore"
+	AdminActionUnlock             = "unlock"
+)
+
+// AdminAction records an admin acting on a user account. The IDs are kept
+// without foreign keys so the record outlives either account.
+type AdminAction struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ActorID   uuid.UUID `gorm:"type:uuid;not null;index" json:"actor_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Action    string    `gorm:"not null;size:32" json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AdminAction) TableName() string {
+	return "admin_actions"
+}
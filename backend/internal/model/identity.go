@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity links an account at an external identity provider to a user
+type Identity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider    string     `gorm:"not null;size:50;uniqueIndex:idx_identities_provider_subject" json:"provider"`
+	Subject     string     `gorm:"not null;size:255;uniqueIndex:idx_identities_provider_subject" json:"-"`
+	Email       string     `gorm:"size:255" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (Identity) TableName() string {
+	return "identities"
+}
+
+// OAuthState is an authorization request waiting for the provider callback
+type OAuthState struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	StateHash    string    `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	Provider     string    `gorm:"not null;size:50" json:"provider"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	// LinkUserID is set when a logged in user is linking a new identity
+	LinkUserID *uuid.UUID `gorm:"type:uuid" json:"link_user_id,omitempty"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (OAuthState) TableName() string {
+	return "oauth_states"
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// LoginAttempt counts recent failed logins for a key, which names either
+// an account ("account:<email>") or a client IP ("ip:<address>")
+type LoginAttempt struct {
+	Key           string    `gorm:"primaryKey;size:320" json:"key"`
+	Failures      int       `gorm:"not null" json:"failures"`
+	LastFailureAt time.Time `gorm:"not null;index" json:"last_failure_at"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPCredential is a user's authenticator app enrollment. The secret is
+// encrypted at rest; the credential only counts once EnabledAt is set.
+type TOTPCredential struct {
+	UserID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	SecretEncrypted string     `gorm:"not null" json:"-"`
+	EnabledAt       *time.Time `json:"enabled_at,omitempty"`
+	LastUsedStep    int64      `gorm:"not null;default:0" json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (TOTPCredential) TableName() string {
+	return "totp_credentials"
+}
+
+// IsEnabled reports whether enrollment has been confirmed
+func (c *TOTPCredential) IsEnabled() bool {
+	return c.EnabledAt != nil
+}
+
+// MFAChallenge is the pending second step of a login whose password has
+// already been checked
+type MFAChallenge struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash string    `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	Attempts  int       `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (MFAChallenge) TableName() string {
+	return "mfa_challenges"
+}
+
+// RecoveryCode is a one-time code that stands in for the authenticator app.
+// Only a bcrypt hash is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
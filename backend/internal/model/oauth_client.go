@@ -0,0 +1,136 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuth grant types a client may use
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OAuthClient is an application registered to use this server as its
+// identity provider
+type OAuthClient struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ClientID string    `gorm:"uniqueIndex;not null;size:64" json:"client_id"`
+	// SecretHash is empty for public clients (SPAs, native apps), which
+	// authenticate with PKCE alone
+	SecretHash   string   `gorm:"size:64" json:"-"`
+	Name         string   `gorm:"not null;size:255" json:"name"`
+	RedirectURIs []string `gorm:"type:jsonb;serializer:json;not null" json:"redirect_uris"`
+	GrantTypes   []string `gorm:"type:jsonb;serializer:json;not null" json:"grant_types"`
+	Scopes       []string `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	// SkipConsent marks trusted first-party apps that users are not asked
+	// to approve
+	SkipConsent bool      `gorm:"not null;default:false" json:"skip_consent"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// IsPublic reports whether the client has no secret
+func (c *OAuthClient) IsPublic() bool {
+	return c.SecretHash == ""
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// AllowsRedirectURI requires an exact match with a registered URI
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+func (c *OAuthClient) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// OAuthAuthorization is an authorization request. It waits for the user's
+// decision and, once approved, holds the authorization code until the
+// client redeems it. A redeemed code is kept with the refresh token family
+// and access token issued for it, which are revoked if the code is
+// presented again.
+type OAuthAuthorization struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ClientID       string     `gorm:"not null;size:64;index" json:"client_id"`
+	RedirectURI    string     `gorm:"not null" json:"redirect_uri"`
+	Scopes         []string   `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	State          string     `json:"-"`
+	Nonce          string     `json:"-"`
+	CodeChallenge  string     `gorm:"not null" json:"-"`
+	UserID         *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	CodeHash       *string    `gorm:"uniqueIndex;size:64" json:"-"`
+	AuthTime       *time.Time `json:"-"`
+	ConsumedAt     *time.Time `json:"-"`
+	FamilyID       *uuid.UUID `gorm:"type:uuid" json:"-"`
+	AccessTokenJTI *string    `gorm:"column:access_token_jti;size:64" json:"-"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (OAuthAuthorization) TableName() string {
+	return "oauth_authorizations"
+}
+
+// OAuthConsent records the scopes a user has granted a client
+type OAuthConsent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_oauth_consents_user_client" json:"user_id"`
+	ClientID  string    `gorm:"not null;size:64;uniqueIndex:idx_oauth_consents_user_client" json:"client_id"`
+	Scopes    []string  `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
+
+// Covers reports whether the consent includes every scope
+func (c *OAuthConsent) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// OAuthRefreshToken is a refresh token issued to an OAuth client. Like
+// first-party refresh tokens it rotates on use, and a reused token revokes
+// its whole family.
+type OAuthRefreshToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	TokenHash  string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	FamilyID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"family_id"`
+	ClientID   string     `gorm:"not null;size:64" json:"client_id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Scopes     []string   `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	AuthTime   time.Time  `gorm:"not null" json:"auth_time"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (OAuthRefreshToken) TableName() string {
+	return "oauth_refresh_tokens"
+}
+
+func (t *OAuthRefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalAccessToken is a long-lived bearer token a user creates for
+// scripts and CI. Only an HMAC of the token is stored.
+type PersonalAccessToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"-"`
+	Name      string    `gorm:"not null;size:255" json:"name"`
+	TokenHash string    `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	// Hint is the last few characters of the token, to help users tell
+	// their tokens apart
+	Hint       string     `gorm:"not null;size:16" json:"hint"`
+	Scopes     []string   `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (PersonalAccessToken) TableName() string {
+	return "personal_access_tokens"
+}
+
+// IsExpired reports whether the token has an expiry that has passed
+func (t *PersonalAccessToken) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// RateLimit counts the requests for a key in the window starting at
+// WindowStart
+type RateLimit struct {
+	Key         string    `gorm:"primaryKey;size:400" json:"key"`
+	Hits        int       `gorm:"not null" json:"hits"`
+	WindowStart time.Time `gorm:"not null;index" json:"window_start"`
+}
+
+func (RateLimit) TableName() string {
+	return "rate_limits"
+}
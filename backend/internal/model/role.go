@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permissions checked by the API. Migrations seed them and grant them to
+// roles; a new permission needs both a constant here and a migration.
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersWrite  = "users:write"
+	PermissionRolesRead   = "roles:read"
+	PermissionRolesAssign = "roles:assign"
+)
+
+// Roles seeded by migrations
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Role is a named set of permissions assigned to users
+type Role struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name        string    `gorm:"uniqueIndex;not null;size:64" json:"name"`
+	Description string    `gorm:"not null;size:255" json:"description"`
+	// IsDefault roles are assigned to every new user
+	IsDefault bool      `gorm:"not null;default:false" json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// PermissionNames returns the names of the role's permissions
+func (r *Role) PermissionNames() []string {
+	names := make([]string, len(r.Permissions))
+	for i, p := range r.Permissions {
+		names[i] = p.Name
+	}
+	return names
+}
+
+type Permission struct {
+	Name        string `gorm:"primaryKey;size:64" json:"name"`
+	Description string `gorm:"not null;size:255" json:"description"`
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// UserRole assigns a role to a user. AssignedBy is the admin who made the
+// assignment, nil for assignments made by migrations or the CLI.
+type UserRole struct {
+	UserID     uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	RoleID     uuid.UUID  `gorm:"type:uuid;primaryKey;index" json:"role_id"`
+	AssignedBy *uuid.UUID `gorm:"type:uuid" json:"assigned_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID" json:"-"`
+	Role Role `gorm:"foreignKey:RoleID" json:"-"`
+}
+
+func (UserRole) TableName() string {
+	return "user_roles"
+}
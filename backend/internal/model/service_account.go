@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServiceAccount is a non-human principal that authenticates with the
+// client credentials grant. It is not a User and cannot log in.
+type ServiceAccount struct {
+	ID        uuid.UUID              `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ClientID  string                 `gorm:"uniqueIndex;not null;size:64" json:"client_id"`
+	Name      string                 `gorm:"not null;size:255" json:"name"`
+	Scopes    []string               `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	Secrets   []ServiceAccountSecret `gorm:"foreignKey:ServiceAccountID" json:"secrets,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+func (ServiceAccount) TableName() string {
+	return "service_accounts"
+}
+
+// ServiceAccountSecret is one of a service account's client secrets. A
+// rotation gives the previous secrets an expiry so callers can switch
+// over. Only an HMAC of the secret is stored.
+type ServiceAccountSecret struct {
+	ID               uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ServiceAccountID uuid.UUID `gorm:"type:uuid;not null;index" json:"-"`
+	SecretHash       string    `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	// Hint is the last few characters of the secret
+	Hint       string     `gorm:"not null;size:16" json:"hint"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (ServiceAccountSecret) TableName() string {
+	return "service_account_secrets"
+}
+
+// IsExpired reports whether the secret has an expiry that has passed
+func (s *ServiceAccountSecret) IsExpired() bool {
+	return s.ExpiresAt != nil && time.Now().After(*s.ExpiresAt)
+}
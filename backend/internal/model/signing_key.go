@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// Signing key lifecycle: next (published, verify-only) -> active (signs new
+// tokens) -> previous (verify-only until outstanding tokens expire)
+const (
+	SigningKeyStatusNext     = "next"
+	SigningKeyStatusActive   = "active"
+	SigningKeyStatusPrevious = "previous"
+)
+
+type SigningKey struct {
+	ID            string     `gorm:"primaryKey;size:64" json:"id"`
+	Algorithm     string     `gorm:"not null;size:16" json:"algorithm"`
+	PrivateKey    string     `gorm:"not null" json:"-"`
+	Status        string     `gorm:"not null;size:16;index" json:"status"`
+	ActivatedAt   *time.Time `json:"activated_at,omitempty"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
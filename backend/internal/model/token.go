@@ -7,11 +7,19 @@ import (
 )
 
 type RefreshToken struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
-	Token     string    `gorm:"uniqueIndex;not null;size:255" json:"-"`
-	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	FamilyID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"family_id"`
+	TokenHash       string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	AccessTokenJTI  string     `gorm:"column:access_token_jti;not null;size:64" json:"-"`
+	UserAgent       string     `gorm:"size:512" json:"user_agent"`
+	IPAddress       string     `gorm:"size:45" json:"ip_address"`
+	DeviceLabel     string     `gorm:"size:255" json:"device_label"`
+	AuthenticatedAt time.Time  `gorm:"not null" json:"authenticated_at"`
+	LastUsedAt      time.Time  `gorm:"not null" json:"last_used_at"`
+	ExpiresAt       time.Time  `gorm:"not null" json:"expires_at"`
+	ConsumedAt      *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
 
 	User User `gorm:"foreignKey:UserID" json:"-"`
 }
@@ -19,3 +27,8 @@ type RefreshToken struct {
 func (RefreshToken) TableName() string {
 	return "refresh_tokens"
 }
+
+// IsConsumed reports whether the token has already been rotated
+func (t *RefreshToken) IsConsumed() bool {
+	return t.ConsumedAt != nil
+}
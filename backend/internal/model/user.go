@@ -9,14 +9,42 @@ import (
 
 type User struct {
 	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	Email     string         `gorm:"uniqueIndex;not null;size:255" json:"email"`
+	Email     string         `gorm:"not null;size:255" json:"email"`
 	Password  string         `gorm:"not null;size:255" json:"-"`
 	Name      string         `gorm:"size:255" json:"name"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// EmailCanonical identifies the account (see auth.CanonicalEmail), while
+	// Email keeps the address as the user typed it. The repository keeps it
+	// in sync with Email.
+	EmailCanonical string `gorm:"index:idx_users_email_canonical_active,unique,where:deleted_at IS NULL;not null;size:255" json:"-"`
+
+	// AuthzVersion changes whenever the user's roles do, telling access
+	// tokens with outdated permission claims apart
+	AuthzVersion int `gorm:"not null;default:1" json:"-"`
+
+	// DisabledAt is set while an admin has disabled the account, which
+	// keeps the user from signing in
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// PasswordResetRequired is set by an admin to refuse password logins
+	// until the user chooses a new password
+	PasswordResetRequired bool `gorm:"not null;default:false" json:"password_reset_required"`
 }
 
 func (User) TableName() string {
 	return "users"
 }
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsDisabled reports whether an admin has disabled the account
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey registered to a user
+type WebAuthnCredential struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CredentialID   []byte     `gorm:"type:bytea;uniqueIndex;not null" json:"-"`
+	PublicKey      []byte     `gorm:"type:bytea;not null" json:"-"`
+	SignCount      int64      `gorm:"not null;default:0" json:"-"`
+	Transports     []string   `gorm:"type:jsonb;serializer:json;not null" json:"transports"`
+	AAGUID         []byte     `gorm:"column:aaguid;type:bytea" json:"-"`
+	Name           string     `gorm:"not null;size:100" json:"name"`
+	BackupEligible bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	BackupState    bool       `gorm:"not null;default:false" json:"backup_state"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// WebAuthn ceremonies
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+)
+
+// WebAuthnChallenge is the server state of a ceremony in progress. It is
+// found again through the challenge echoed in the client data.
+type WebAuthnChallenge struct {
+	ID            uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ChallengeHash string    `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	Purpose       string    `gorm:"not null;size:32" json:"purpose"`
+	// UserID is set for registration; logins start without a known user
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (WebAuthnChallenge) TableName() string {
+	return "webauthn_challenges"
+}
@@ -0,0 +1,452 @@
+// Package oidc implements the client side of OpenID Connect (and GitHub's
+// OAuth 2.0 flavour) for logging in with external identity providers.
+// Every flow uses the authorization code grant with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Provider kinds
+const (
+	// KindOIDC is any OpenID Connect issuer that supports discovery
+	KindOIDC = "oidc"
+	// KindGitHub is GitHub, which speaks OAuth 2.0 without ID tokens
+	KindGitHub = "github"
+)
+
+const (
+	maxResponseSize = 1 << 20
+	// jwksRefreshInterval limits how often an unknown kid refetches the JWKS
+	jwksRefreshInterval = time.Minute
+	clockSkew           = time.Minute
+)
+
+var ErrIDTokenInvalid = errors.New("oidc: invalid ID token")
+
+// Config describes one identity provider
+type Config struct {
+	Name         string
+	Kind         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+
+	// Endpoints for KindGitHub (defaults to github.com); OIDC providers
+	// discover theirs from the issuer
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+}
+
+// Identity is the authenticated user as reported by the provider
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs authorization code flows against one identity provider
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProvider creates a provider. The well-known names "google" and
+// "github" only need client credentials; defaults fill in the rest.
+func NewProvider(cfg Config, client *http.Client) (*Provider, error) {
+	applyDefaults(&cfg)
+	switch cfg.Kind {
+	case KindOIDC:
+		if cfg.Issuer == "" {
+			return nil, fmt.Errorf("oidc: provider %s needs an issuer", cfg.Name)
+		}
+	case KindGitHub:
+	default:
+		return nil, fmt.Errorf("oidc: provider %s has unknown kind %q", cfg.Name, cfg.Kind)
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("oidc: provider %s needs a client ID", cfg.Name)
+	}
+	return &Provider{cfg: cfg, client: client}, nil
+}
+
+func applyDefaults(cfg *Config) {
+	switch cfg.Name {
+	case "google":
+		if cfg.Kind == "" {
+			cfg.Kind = KindOIDC
+		}
+		if cfg.Issuer == "" {
+			cfg.Issuer = "https://accounts.google.com"
+		}
+	case "github":
+		if cfg.Kind == "" {
+			cfg.Kind = KindGitHub
+		}
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = KindOIDC
+	}
+
+	if cfg.Kind == KindGitHub {
+		if cfg.AuthURL == "" {
+			cfg.AuthURL = "https://github.com/login/oauth/authorize"
+		}
+		if cfg.TokenURL == "" {
+			cfg.TokenURL = "https://github.com/login/oauth/access_token"
+		}
+		if cfg.APIURL == "" {
+			cfg.APIURL = "https://api.github.com"
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"read:user", "user:email"}
+		}
+	} else if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns the URL to send the browser to. nonce is ignored by
+// providers without ID tokens.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	authURL := p.cfg.AuthURL
+	if p.cfg.Kind == KindOIDC {
+		md, err := p.discover(ctx)
+		if err != nil {
+			return "", err
+		}
+		authURL = md.AuthorizationEndpoint
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+	if p.cfg.Kind == KindOIDC {
+		params.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(authURL, "?") {
+		sep = "&"
+	}
+	return authURL + sep + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified identity
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	tokenURL := p.cfg.TokenURL
+	if p.cfg.Kind == KindOIDC {
+		md, err := p.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tokenURL = md.TokenEndpoint
+	}
+
+	tokens, err := p.redeem(ctx, tokenURL, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.cfg.Kind == KindGitHub {
+		return p.gitHubIdentity(ctx, tokens.AccessToken)
+	}
+	return p.oidcIdentity(ctx, tokens, nonce)
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (p *Provider) redeem(ctx context.Context, tokenURL, code, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens tokenResponse
+	if err := p.do(req, &tokens); err != nil && tokens.Error == "" {
+		return nil, err
+	}
+	if tokens.Error != "" {
+		return nil, fmt.Errorf("oidc: token request failed: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.AccessToken == "" {
+		return nil, errors.New("oidc: token response without access token")
+	}
+	return &tokens, nil
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+func (p *Provider) oidcIdentity(ctx context.Context, tokens *tokenResponse, nonce string) (*Identity, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("oidc: token response without ID token")
+	}
+
+	var claims idTokenClaims
+	if _, err := jwt.ParseWithClaims(tokens.IDToken, &claims,
+		func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+			return p.verificationKey(ctx, md.JWKSURI, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIDTokenInvalid, err)
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrIDTokenInvalid)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrIDTokenInvalid)
+	}
+
+	identity := &Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		Name:          claims.Name,
+	}
+
+	// Some providers only put profile claims in the UserInfo response
+	if identity.Email == "" && md.UserInfoEndpoint != "" {
+		var info struct {
+			Subject       string `json:"sub"`
+			Email         string `json:"email"`
+			EmailVerified any    `json:"email_verified"`
+			Name          string `json:"name"`
+		}
+		if err := p.getJSON(ctx, md.UserInfoEndpoint, tokens.AccessToken, &info); err != nil {
+			return nil, err
+		}
+		if info.Subject != identity.Subject {
+			return nil, errors.New("oidc: userinfo subject does not match ID token")
+		}
+		identity.Email = info.Email
+		identity.EmailVerified = isTrue(info.EmailVerified)
+		if identity.Name == "" {
+			identity.Name = info.Name
+		}
+	}
+	return identity, nil
+}
+
+// isTrue accepts email_verified as a boolean or, as some providers send it,
+// a string
+func isTrue(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func (p *Provider) gitHubIdentity(ctx context.Context, accessToken string) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.cfg.APIURL+"/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("oidc: GitHub user without ID")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.cfg.APIURL+"/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+		}
+	}
+	return identity, nil
+}
+
+// discover fetches and caches the issuer's metadata. Failures are not
+// cached, so a provider outage recovers on its own.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var md metadata
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, "", &md); err != nil {
+		return nil, err
+	}
+	if md.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", md.Issuer, p.cfg.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("oidc: incomplete discovery document")
+	}
+	p.metadata = &md
+	return p.metadata, nil
+}
+
+// verificationKey finds the provider key for kid, refetching the JWKS when
+// the provider may have rotated keys
+func (p *Provider) verificationKey(ctx context.Context, jwksURI, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("oidc: unknown key %q", kid)
+	}
+
+	var set auth.JWKS
+	if err := p.getJSON(ctx, jwksURI, "", &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("oidc: unknown key %q", kid)
+}
+
+// lookupKey is called with p.mu held. Tokens without a kid are accepted
+// only when the provider publishes a single key.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint, bearer string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	return p.do(req, out)
+}
+
+// do sends req and decodes the JSON body into out. The body is decoded even
+// for error statuses so OAuth error responses can be reported.
+func (p *Provider) do(req *http.Request, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(body, out)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s %s returned %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	return decodeErr
+}
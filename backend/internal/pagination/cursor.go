@@ -0,0 +1,141 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errMalformedCursor = errors.New("is malformed or has been altered")
+	errStaleCursor     = errors.New("does not belong to this query")
+)
+
+// Signer makes cursors tamper-evident. Cursors are not encrypted; they
+// only carry values of the row the client has already been sent.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner derives the cursor signing key from a server secret, so the
+// secret can be shared with other uses of HMAC
+func NewSigner(secret string) *Signer {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("pagination cursor"))
+	return &Signer{key: mac.Sum(nil)}
+}
+
+func (s *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// cursor is the signed payload: the query it belongs to and the position
+// of the last row of the page
+type cursor struct {
+	Resource string    `json:"r"`
+	Sort     string    `json:"s"`
+	Filters  string    `json:"f"`
+	Kind     string    `json:"k"`
+	Value    string    `json:"v"`
+	ID       uuid.UUID `json:"id"`
+}
+
+// Kinds of sort value
+const (
+	kindTime   = "t"
+	kindString = "s"
+	kindInt    = "i"
+)
+
+func (q *Query[T]) encodeCursor(last T) string {
+	c := cursor{
+		Resource: q.resource.Name,
+		Sort:     q.sort(),
+		Filters:  q.filterDigest(),
+		ID:       q.resource.ID(last),
+	}
+	switch value := q.resource.Sorts[q.sortKey].Value(last).(type) {
+	case time.Time:
+		c.Kind, c.Value = kindTime, value.UTC().Format(time.RFC3339Nano)
+	case string:
+		c.Kind, c.Value = kindString, value
+	case int64:
+		c.Kind, c.Value = kindInt, strconv.FormatInt(value, 10)
+	default:
+		panic(fmt.Sprintf("pagination: unsupported sort value %T", value))
+	}
+
+	// Marshaling strings and a UUID cannot fail
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(q.signer.sign(payload))
+}
+
+func (q *Query[T]) decodeCursor(s string) (*position, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(s, ".")
+	if !ok {
+		return nil, errMalformedCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, q.signer.sign(payload)) {
+		return nil, errMalformedCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, errMalformedCursor
+	}
+	if c.Resource != q.resource.Name || c.Sort != q.sort() || c.Filters != q.filterDigest() {
+		return nil, errStaleCursor
+	}
+
+	p := &position{id: c.ID}
+	switch c.Kind {
+	case kindTime:
+		p.value, err = time.Parse(time.RFC3339Nano, c.Value)
+	case kindString:
+		p.value = c.Value
+	case kindInt:
+		p.value, err = strconv.ParseInt(c.Value, 10, 64)
+	default:
+		err = errMalformedCursor
+	}
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+	return p, nil
+}
+
+// sort returns the sort parameter in its canonical form
+func (q *Query[T]) sort() string {
+	if q.desc {
+		return "-" + q.sortKey
+	}
+	return q.sortKey
+}
+
+// filterDigest identifies the filters of the query
+func (q *Query[T]) filterDigest() string {
+	params := make([]string, 0, len(q.filters))
+	for param, value := range q.filters {
+		params = append(params, param+"="+value)
+	}
+	slices.Sort(params)
+	sum := sha256.Sum256([]byte(strings.Join(params, "&")))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -0,0 +1,231 @@
+// Package pagination implements the query contract of list endpoints:
+//
+//	GET /things?limit=20&sort=-created_at&status=active&cursor=...
+//
+// limit bounds the page size, sort names one allowlisted sort key (a
+// leading "-" sorts descending) and every other parameter must be an
+// allowlisted filter. Pages are read with keyset pagination on the sort
+// column and the id column, so rows added or removed between requests do
+// not shift later pages. The cursor for the next page is opaque and signed,
+// and only valid with the query that produced it.
+//
+// The contract is for lists that grow with the number of users, such as
+// the admin user list. Lists of one account's sessions, tokens, passkeys,
+// identities and consents, and the list of roles, stay small and are
+// returned whole.
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reserved query parameters
+const (
+	ParamLimit  = "limit"
+	ParamCursor = "cursor"
+	ParamSort   = "sort"
+)
+
+// Scope narrows a GORM query
+type Scope = func(*gorm.DB) *gorm.DB
+
+// Filter turns the value of a filter parameter into a scope, or returns an
+// error saying why the value is not accepted
+type Filter func(value string) (Scope, error)
+
+// Sort is a sort key of a resource. Value returns the row's value of
+// Column, which must be a time.Time, string or int64.
+type Sort[T any] struct {
+	Column string
+	Value  func(T) any
+}
+
+// Resource is what a list endpoint lets clients sort and filter by. Rows
+// are told apart by an "id" column holding ID.
+type Resource[T any] struct {
+	// Name binds cursors to the resource
+	Name    string
+	Sorts   map[string]Sort[T]
+	Filters map[string]Filter
+	ID      func(T) uuid.UUID
+	// DefaultSort is used when the query has no sort, e.g. "-created_at"
+	DefaultSort  string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Query is a parsed and validated list query
+type Query[T any] struct {
+	resource *Resource[T]
+	signer   *Signer
+	limit    int
+	sortKey  string
+	desc     bool
+	filters  map[string]string
+	after    *position
+}
+
+// position is the sort value and id of the last row of a page
+type position struct {
+	value any
+	id    uuid.UUID
+}
+
+// Page is one page of a list. NextCursor is set when HasMore is.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	HasMore    bool
+}
+
+// Error rejects a query parameter. Message completes a sentence starting
+// with the parameter name.
+type Error struct {
+	Param   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Param + " " + e.Message
+}
+
+// Parse validates a list query against the resource
+func (r *Resource[T]) Parse(values url.Values, signer *Signer) (*Query[T], error) {
+	q := &Query[T]{resource: r, signer: signer, limit: r.DefaultLimit, filters: make(map[string]string)}
+
+	for param, vals := range values {
+		if len(vals) != 1 {
+			return nil, &Error{Param: param, Message: "must be given once"}
+		}
+		switch value := vals[0]; param {
+		case ParamLimit:
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > r.MaxLimit {
+				return nil, &Error{Param: param, Message: fmt.Sprintf("must be a number from 1 to %d", r.MaxLimit)}
+			}
+			q.limit = limit
+		case ParamSort, ParamCursor:
+			// Handled below, once the sort and filters are known
+		default:
+			filter, ok := r.Filters[param]
+			if !ok {
+				return nil, &Error{Param: param, Message: "is not a filter of " + r.Name}
+			}
+			if _, err := filter(value); err != nil {
+				return nil, &Error{Param: param, Message: err.Error()}
+			}
+			q.filters[param] = value
+		}
+	}
+
+	sort := values.Get(ParamSort)
+	if sort == "" {
+		sort = r.DefaultSort
+	}
+	q.sortKey, q.desc = strings.CutPrefix(sort, "-")
+	if _, ok := r.Sorts[q.sortKey]; !ok {
+		return nil, &Error{Param: ParamSort, Message: "must be one of " + strings.Join(r.sortKeys(), ", ")}
+	}
+
+	if cursor := values.Get(ParamCursor); cursor != "" {
+		after, err := q.decodeCursor(cursor)
+		if err != nil {
+			return nil, &Error{Param: ParamCursor, Message: err.Error()}
+		}
+		q.after = after
+	}
+	return q, nil
+}
+
+// Limit returns the page size
+func (q *Query[T]) Limit() int {
+	return q.limit
+}
+
+// Filter returns the value of a filter parameter, if the query has it
+func (q *Query[T]) Filter(param string) (string, bool) {
+	value, ok := q.filters[param]
+	return value, ok
+}
+
+// Sort returns the sort key the rows are ordered by, and whether the
+// order is descending
+func (q *Query[T]) Sort() (Sort[T], bool) {
+	return q.resource.Sorts[q.sortKey], q.desc
+}
+
+// After returns the sort value and id of the last row of the previous
+// page, if the query has a cursor
+func (q *Query[T]) After() (any, uuid.UUID, bool) {
+	if q.after == nil {
+		return nil, uuid.Nil, false
+	}
+	return q.after.value, q.after.id, true
+}
+
+// Scope applies the filters, the cursor and the order to a query, and
+// reads one row more than the page size to tell whether more follow
+func (q *Query[T]) Scope(db *gorm.DB) *gorm.DB {
+	for param, value := range q.filters {
+		// Parse has accepted the value
+		scope, _ := q.resource.Filters[param](value)
+		db = scope(db)
+	}
+
+	column := q.resource.Sorts[q.sortKey].Column
+	op, dir := ">", "ASC"
+	if q.desc {
+		op, dir = "<", "DESC"
+	}
+	if q.after != nil {
+		db = db.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, op), q.after.value, q.after.id)
+	}
+	return db.Order(fmt.Sprintf("%s %s, id %s", column, dir, dir)).Limit(q.limit + 1)
+}
+
+// Page turns the rows read with Scope into a page
+func (q *Query[T]) Page(items []T) *Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	if len(items) <= q.limit {
+		return &Page[T]{Items: items}
+	}
+	items = items[:q.limit]
+	return &Page[T]{Items: items, NextCursor: q.encodeCursor(items[len(items)-1]), HasMore: true}
+}
+
+func (r *Resource[T]) sortKeys() []string {
+	keys := make([]string, 0, len(r.Sorts))
+	for key := range r.Sorts {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// OneOf is a filter accepting the values of scopes
+func OneOf(scopes map[string]Scope) Filter {
+	values := make([]string, 0, len(scopes))
+	for value := range scopes {
+		values = append(values, value)
+	}
+	slices.Sort(values)
+	message := "must be one of " + strings.Join(values, ", ")
+
+	return func(value string) (Scope, error) {
+		scope, ok := scopes[value]
+		if !ok {
+			return nil, errors.New(message)
+		}
+		return scope, nil
+	}
+}
@@ -0,0 +1,159 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+var items = &Resource[item]{
+	Name: "items",
+	Sorts: map[string]Sort[item]{
+		"created_at": {Column: "created_at", Value: func(i item) any { return i.CreatedAt }},
+		"name":       {Column: "name", Value: func(i item) any { return i.Name }},
+	},
+	Filters: map[string]Filter{
+		"name": OneOf(map[string]Scope{
+			"a": func(db *gorm.DB) *gorm.DB { return db.Where("name = ?", "a") },
+		}),
+	},
+	ID:           func(i item) uuid.UUID { return i.ID },
+	DefaultSort:  "-created_at",
+	DefaultLimit: 2,
+	MaxLimit:     10,
+}
+
+var testSigner = NewSigner("test-cursor-key")
+
+// dryRun returns the SQL and arguments scope would query items with
+func dryRun(t *testing.T, scope Scope) (string, []any) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := db.Table("items").Scopes(scope).Find(&[]item{}).Statement
+	return stmt.SQL.String(), stmt.Vars
+}
+
+func parse(t *testing.T, query string) *Query[item] {
+	t.Helper()
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := items.Parse(values, testSigner)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", query, err)
+	}
+	return q
+}
+
+func TestParse(t *testing.T) {
+	q := parse(t, "")
+	if q.Limit() != items.DefaultLimit || q.sort() != items.DefaultSort {
+		t.Errorf("defaults: limit %d, sort %q", q.Limit(), q.sort())
+	}
+	q = parse(t, "limit=10&sort=name&name=a")
+	if value, ok := q.Filter("name"); q.Limit() != 10 || q.sort() != "name" || !ok || value != "a" {
+		t.Errorf("Parse = limit %d, sort %q, filter %q", q.Limit(), q.sort(), value)
+	}
+
+	tests := []struct {
+		query string
+		param string
+	}{
+		{"limit=0", ParamLimit},
+		{"limit=11", ParamLimit},
+		{"limit=x", ParamLimit},
+		{"limit=1&limit=2", ParamLimit},
+		{"sort=id", ParamSort},
+		{"sort=--name", ParamSort},
+		{"color=red", "color"},
+		{"name=b", "name"},
+		{"cursor=x", ParamCursor},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		_, err := items.Parse(values, testSigner)
+		var paramErr *Error
+		if !errors.As(err, &paramErr) || paramErr.Param != tt.param {
+			t.Errorf("Parse(%q): err = %v, want an Error for %s", tt.query, err, tt.param)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	q := parse(t, "sort=-created_at&name=a")
+	last := item{ID: uuid.New(), CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)}
+	cursor := q.encodeCursor(last)
+
+	p, err := q.decodeCursor(cursor)
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if p.id != last.ID || !p.value.(time.Time).Equal(last.CreatedAt) {
+		t.Errorf("position = %+v, want %s at %s", p, last.ID, last.CreatedAt)
+	}
+
+	payload, sig, _ := strings.Cut(cursor, ".")
+	flipped := string(rune(payload[0]^1)) + payload[1:]
+	otherKey, err := items.Parse(url.Values{"sort": {"-created_at"}, "name": {"a"}}, NewSigner("other-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		query *Query[item]
+		value string
+		want  error
+	}{
+		{"no signature", q, payload, errMalformedCursor},
+		{"altered payload", q, flipped + "." + sig, errMalformedCursor},
+		{"other key", otherKey, cursor, errMalformedCursor},
+		{"other sort", parse(t, "sort=created_at&name=a"), cursor, errStaleCursor},
+		{"other filters", parse(t, "sort=-created_at"), cursor, errStaleCursor},
+	}
+	for _, tt := range tests {
+		if _, err := tt.query.decodeCursor(tt.value); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestScope(t *testing.T) {
+	sql, vars := dryRun(t, parse(t, "sort=name&name=a").Scope)
+	if want := `SELECT * FROM "items" WHERE name = $1 ORDER BY name ASC, id ASC LIMIT $2`; sql != want {
+		t.Errorf("first page SQL = %s, want %s", sql, want)
+	}
+	if want := []any{"a", 3}; !reflect.DeepEqual(vars, want) {
+		t.Errorf("first page args = %v, want %v", vars, want)
+	}
+
+	first := parse(t, "")
+	last := item{ID: uuid.New(), CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	next := parse(t, "cursor="+url.QueryEscape(first.encodeCursor(last)))
+	sql, vars = dryRun(t, next.Scope)
+	if want := `SELECT * FROM "items" WHERE (created_at, id) < ($1, $2) ORDER BY created_at DESC, id DESC LIMIT $3`; sql != want {
+		t.Errorf("next page SQL = %s, want %s", sql, want)
+	}
+	if len(vars) != 3 || !vars[0].(time.Time).Equal(last.CreatedAt) || vars[1] != last.ID || vars[2] != 3 {
+		t.Errorf("next page args = %v, want %s, %s, 3", vars, last.CreatedAt, last.ID)
+	}
+}
@@ -0,0 +1,70 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RangeDir checks passwords against a local copy of the Have I Been Pwned
+// range files, as downloaded by the official downloader: one file per
+// five-character SHA-1 prefix, named after the prefix (optionally with a
+// .txt extension), holding "SUFFIX:COUNT" lines. Only the range file for
+// the password's prefix is read, and the password never leaves the server.
+type RangeDir struct {
+	dir string
+	// minCount ignores hashes seen fewer times than this
+	minCount int
+}
+
+var _ BreachChecker = (*RangeDir)(nil)
+
+func NewRangeDir(dir string, minCount int) *RangeDir {
+	return &RangeDir{dir: dir, minCount: max(minCount, 1)}
+}
+
+// Breached reports whether the password's SHA-1 hash is listed. A missing
+// range file means the password is not listed, so partial copies work.
+func (d *RangeDir) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := d.open(prefix)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		candidate, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			// Lists without counts mean "seen at least once"
+			n = 1
+		}
+		return n >= d.minCount, nil
+	}
+	return false, scanner.Err()
+}
+
+func (d *RangeDir) open(prefix string) (*os.File, error) {
+	f, err := os.Open(filepath.Join(d.dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = os.Open(filepath.Join(d.dir, prefix+".txt"))
+	}
+	return f, err
+}
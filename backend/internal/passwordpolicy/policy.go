@@ -0,0 +1,173 @@
+// Package passwordpolicy decides whether a new password is acceptable:
+// length bounds, a denylist of common passwords, no personal details, and
+// optionally an offline check against known breached passwords.
+package passwordpolicy
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules a password can violate; they appear as codes in error details
+const (
+	RuleTooShort     = "too_short"
+	RuleTooLong      = "too_long"
+	RuleCommon       = "common"
+	RulePersonalInfo = "contains_personal_info"
+	RuleBreached     = "breached"
+)
+
+// minPersonalToken is the shortest part of an email or name that a
+// password may not contain; shorter parts match too much by accident
+const minPersonalToken = 3
+
+//go:embed common.txt
+var commonPasswords string
+
+// Violation is one rule a password breaks
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// Error lists the rules a password breaks
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password policy: " + strings.Join(messages, "; ")
+}
+
+// BreachChecker reports whether a password is known from data breaches
+type BreachChecker interface {
+	Breached(password string) (bool, error)
+}
+
+// Options configures a Policy. Lengths count characters, not bytes.
+type Options struct {
+	MinLength int
+	MaxLength int
+	// Denylist adds to the built-in list of common passwords
+	Denylist []string
+	// Breaches is optional
+	Breaches BreachChecker
+}
+
+// Policy checks new passwords
+type Policy struct {
+	opts     Options
+	denylist map[string]struct{}
+}
+
+func New(opts Options) *Policy {
+	p := &Policy{opts: opts, denylist: make(map[string]struct{})}
+	common, _ := readList(strings.NewReader(commonPasswords))
+	for _, password := range append(common, opts.Denylist...) {
+		p.denylist[strings.ToLower(password)] = struct{}{}
+	}
+	return p
+}
+
+// LoadDenylist reads a file with one password per line; lines starting
+// with # are ignored
+func LoadDenylist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readList(f)
+}
+
+func readList(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// Check returns nil if password is acceptable for the user with the given
+// email and name, *Error listing every broken rule if not, or an error if
+// the breach check failed
+func (p *Policy) Check(password, email, name string) error {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.opts.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleTooShort,
+			Message: fmt.Sprintf("must be at least %d characters", p.opts.MinLength),
+		})
+	}
+	if p.opts.MaxLength > 0 && length > p.opts.MaxLength {
+		violations = append(violations, Violation{
+			Rule:    RuleTooLong,
+			Message: fmt.Sprintf("must be at most %d characters", p.opts.MaxLength),
+		})
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := p.denylist[lower]; ok {
+		violations = append(violations, Violation{
+			Rule:    RuleCommon,
+			Message: "is too common",
+		})
+	}
+	if containsPersonalInfo(lower, email, name) {
+		violations = append(violations, Violation{
+			Rule:    RulePersonalInfo,
+			Message: "must not contain your email address or name",
+		})
+	}
+
+	// The breach check is the expensive one and adds nothing if the
+	// password is already rejected
+	if len(violations) == 0 && p.opts.Breaches != nil {
+		breached, err := p.opts.Breaches.Breached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, Violation{
+				Rule:    RuleBreached,
+				Message: "has appeared in a data breach",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether the lowercased password contains
+// the email's local part or a word of the name
+func containsPersonalInfo(lower, email, name string) bool {
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	tokens := append([]string{local}, strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})...)
+
+	for _, token := range tokens {
+		if utf8.RuneCountInString(token) >= minPersonalToken && strings.Contains(lower, token) {
+			return true
+		}
+	}
+	return false
+}
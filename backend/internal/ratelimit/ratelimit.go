@@ -0,0 +1,93 @@
+// Package ratelimit counts requests per key in fixed windows
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/cache"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+)
+
+// memoryCapacity bounds how many keys the in-memory limiter tracks; the
+// least recently seen keys are forgotten first
+const memoryCapacity = 10000
+
+// Limiter allows a number of hits per key and window
+type Limiter interface {
+	// Allow records a hit for key. When the key is over its limit it
+	// returns false and how long until the window resets.
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+type window struct {
+	count   int
+	resetAt time.Time
+}
+
+// Memory is a Limiter local to this process
+type Memory struct {
+	limit  int
+	period time.Duration
+
+	mu      sync.Mutex
+	windows *cache.LRU[string, window]
+}
+
+var _ Limiter = (*Memory)(nil)
+
+// NewMemory allows limit hits per key in each period
+func NewMemory(limit int, period time.Duration) *Memory {
+	return &Memory{
+		limit:   limit,
+		period:  period,
+		windows: cache.NewLRU[string, window](memoryCapacity),
+	}
+}
+
+func (l *Memory) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w, ok := l.windows.Get(key)
+	if !ok {
+		w = window{resetAt: now.Add(l.period)}
+	}
+	if w.count >= l.limit {
+		return false, w.resetAt.Sub(now), nil
+	}
+	w.count++
+	l.windows.Set(key, w, w.resetAt)
+	return true, 0, nil
+}
+
+// Store is a Limiter whose counts live in a repository.RateLimitRepository,
+// shared by every replica using the same database
+type Store struct {
+	repo   repository.RateLimitRepository
+	prefix string
+	limit  int
+	period time.Duration
+}
+
+var _ Limiter = (*Store)(nil)
+
+// NewStore allows limit hits per key in each period. Keys are stored with
+// prefix so limiters sharing the repository do not collide.
+func NewStore(repo repository.RateLimitRepository, prefix string, limit int, period time.Duration) *Store {
+	return &Store{repo: repo, prefix: prefix, limit: limit, period: period}
+}
+
+func (l *Store) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := time.Now()
+	w, err := l.repo.Hit(ctx, l.prefix+key, now, now.Add(-l.period))
+	if err != nil {
+		return false, 0, err
+	}
+	if w.Hits > l.limit {
+		return false, w.WindowStart.Add(l.period).Sub(now), nil
+	}
+	return true, 0, nil
+}
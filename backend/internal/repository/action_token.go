@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActionTokenRepository finds tokens by the canonical form of the email
+// address they carry, so callers pass addresses as they were typed
+type ActionTokenRepository interface {
+	Create(ctx context.Context, token *model.ActionToken) error
+	Find(ctx context.Context, purpose, tokenHash string) (*model.ActionToken, error)
+	Consume(ctx context.Context, purpose, tokenHash string) (*model.ActionToken, error)
+	FindByEmail(ctx context.Context, purpose, email string) (*model.ActionToken, error)
+	DeleteByUserID(ctx context.Context, userID uuid.UUID, purpose string) error
+	DeleteExpired(ctx context.Context) error
+}
+
+type actionTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewActionTokenRepository(db *gorm.DB) ActionTokenRepository {
+	return &actionTokenRepository{db: db}
+}
+
+func (r *actionTokenRepository) Create(ctx context.Context, token *model.ActionToken) error {
+	if token.Email != nil {
+		canonical := auth.CanonicalEmail(*token.Email)
+		token.EmailCanonical = &canonical
+	}
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// Find returns an unused, unexpired token without using it up
+func (r *actionTokenRepository) Find(ctx context.Context, purpose, tokenHash string) (*model.ActionToken, error) {
+	var token model.ActionToken
+	err := r.db.WithContext(ctx).
+		Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, tokenHash, time.Now()).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Consume atomically marks an unused, unexpired token as used and returns
+// it. It returns gorm.ErrRecordNotFound if no such token exists, so a token
+// can only ever be consumed once.
+func (r *actionTokenRepository) Consume(ctx context.Context, purpose, tokenHash string) (*model.ActionToken, error) {
+	var token model.ActionToken
+	result := r.db.WithContext(ctx).
+		Model(&token).
+		Clauses(clause.Returning{}).
+		Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, tokenHash, time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &token, nil
+}
+
+// FindByEmail returns an unused, unexpired token for purpose that carries
+// email, compared in canonical form
+func (r *actionTokenRepository) FindByEmail(ctx context.Context, purpose, email string) (*model.ActionToken, error) {
+	var token model.ActionToken
+	err := r.db.WithContext(ctx).
+		Where("purpose = ? AND email_canonical = ? AND used_at IS NULL AND expires_at > ?", purpose, auth.CanonicalEmail(email), time.Now()).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *actionTokenRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID, purpose string) error {
+	return r.db.WithContext(ctx).Delete(&model.ActionToken{}, "user_id = ? AND purpose = ?", userID, purpose).Error
+}
+
+func (r *actionTokenRepository) DeleteExpired(ctx context.Context) error {
+	return r.db.WithContext(ctx).Delete(&model.ActionToken{}, "expires_at < ?", time.Now()).Error
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AdminActionRepository interface {
+	Create(ctx context.Context, action *model.AdminAction) error
+	// FindByUserID returns the latest actions taken on a user, newest first
+	FindByUserID(ctx context.Context, userID uuid.UUID, limit int) ([]model.AdminAction, error)
+}
+
+type adminActionRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminActionRepository(db *gorm.DB) AdminActionRepository {
+	return &adminActionRepository{db: db}
+}
+
+func (r *adminActionRepository) Create(ctx context.Context, action *model.AdminAction) error {
+	return r.db.WithContext(ctx).Create(action).Error
+}
+
+func (r *adminActionRepository) FindByUserID(ctx context.Context, userID uuid.UUID, limit int) ([]model.AdminAction, error) {
+	var actions []model.AdminAction
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&actions).Error
+	return actions, err
+}
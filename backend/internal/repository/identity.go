@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdentityRepository interface {
+	Create(ctx context.Context, identity *model.Identity) error
+	CreateWithUser(ctx context.Context, user *model.User, identity *model.Identity) error
+	FindByProviderSubject(ctx context.Context, provider, subject string) (*model.Identity, error)
+	FindByUserID(ctx context.Context, userID uuid.UUID) ([]model.Identity, error)
+	RecordLogin(ctx context.Context, id uuid.UUID, email string) error
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+
+	CreateState(ctx context.Context, state *model.OAuthState) error
+	ConsumeState(ctx context.Context, stateHash string) (*model.OAuthState, error)
+	DeleteExpiredStates(ctx context.Context) error
+}
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) Create(ctx context.Context, identity *model.Identity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+// CreateWithUser creates a new user together with their first identity
+func (r *identityRepository) CreateWithUser(ctx context.Context, user *model.User, identity *model.Identity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (r *identityRepository) FindByProviderSubject(ctx context.Context, provider, subject string) (*model.Identity, error) {
+	var identity model.Identity
+	if err := r.db.WithContext(ctx).
+		First(&identity, "provider = ? AND subject = ?", provider, subject).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]model.Identity, error) {
+	var identities []model.Identity
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// RecordLogin stores the login time and the email the provider reported
+func (r *identityRepository) RecordLogin(ctx context.Context, id uuid.UUID, email string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Identity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"email":         email,
+			"last_login_at": time.Now(),
+		}).Error
+}
+
+// Delete unlinks one of the user's identities. It returns
+// gorm.ErrRecordNotFound if the user has no such identity.
+func (r *identityRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&model.Identity{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *identityRepository) CreateState(ctx context.Context, state *model.OAuthState) error {
+	return r.db.WithContext(ctx).Create(state).Error
+}
+
+// ConsumeState atomically deletes and returns an unexpired state, so each
+// authorization response can only be used once
+func (r *identityRepository) ConsumeState(ctx context.Context, stateHash string) (*model.OAuthState, error) {
+	var state model.OAuthState
+	result := r.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("state_hash = ? AND expires_at > ?", stateHash, time.Now()).
+		Delete(&state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &state, nil
+}
+
+func (r *identityRepository) DeleteExpiredStates(ctx context.Context) error {
+	return r.db.WithContext(ctx).Delete(&model.OAuthState{}, "expires_at < ?", time.Now()).Error
+}
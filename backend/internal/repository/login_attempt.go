@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/cache"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// LoginAttemptRepository tracks failed logins. The database repository
+// shares the counts between replicas; the in-memory one is for a single
+// node.
+type LoginAttemptRepository interface {
+	Find(ctx context.Context, key string) (*model.LoginAttempt, error)
+	// RecordFailure counts a failure at now and returns the new count.
+	// Failures before since are forgotten first.
+	RecordFailure(ctx context.Context, key string, now, since time.Time) (*model.LoginAttempt, error)
+	Delete(ctx context.Context, key string) error
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Find(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	var attempt model.LoginAttempt
+	if err := r.db.WithContext(ctx).First(&attempt, "key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// RecordFailure increments the count in a single statement, so concurrent
+// failures on different replicas are all counted
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, key string, now, since time.Time) (*model.LoginAttempt, error) {
+	var attempt model.LoginAttempt
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO login_attempts (key, failures, last_failure_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING key, failures, last_failure_at`,
+		key, now, since,
+	).Scan(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) Delete(ctx context.Context, key string) error {
+	return r.db.WithContext(ctx).Delete(&model.LoginAttempt{}, "key = ?", key).Error
+}
+
+func (r *loginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Delete(&model.LoginAttempt{}, "last_failure_at < ?", before).Error
+}
+
+// memoryLoginAttempts bounds how many keys the in-memory repository
+// tracks; the least recently failed keys are forgotten first
+const memoryLoginAttempts = 100000
+
+type memoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts *cache.LRU[string, model.LoginAttempt]
+}
+
+// NewMemoryLoginAttemptRepository keeps failed logins in this process.
+// Each replica then counts on its own and counts are lost on restart, or
+// when a flood of other keys evicts them.
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	return &memoryLoginAttemptRepository{attempts: cache.NewLRU[string, model.LoginAttempt](memoryLoginAttempts)}
+}
+
+func (r *memoryLoginAttemptRepository) Find(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	attempt, ok := r.attempts.Get(key)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &attempt, nil
+}
+
+// RecordFailure keeps each key for one window, now - since, after its
+// last failure
+func (r *memoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now, since time.Time) (*model.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts.Get(key)
+	if !ok || attempt.LastFailureAt.Before(since) {
+		attempt = model.LoginAttempt{Key: key}
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	r.attempts.Set(key, attempt, time.Now().Add(now.Sub(since)))
+	return &attempt, nil
+}
+
+func (r *memoryLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	r.attempts.Delete(key)
+	return nil
+}
+
+// DeleteStale has nothing to do; entries expire on their own
+func (r *memoryLoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	return nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestMemoryLoginAttemptsAreBounded(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryLoginAttemptRepository()
+	now := time.Now()
+	since := now.Add(-time.Hour)
+
+	for i := 0; i <= memoryLoginAttempts; i++ {
+		if _, err := repo.RecordFailure(ctx, fmt.Sprintf("ip:%d", i), now, since); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.Find(ctx, "ip:0"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("oldest key: err = %v, want it evicted", err)
+	}
+	attempt, err := repo.Find(ctx, fmt.Sprintf("ip:%d", memoryLoginAttempts))
+	if err != nil || attempt.Failures != 1 {
+		t.Errorf("newest key = %+v, %v; want 1 failure", attempt, err)
+	}
+
+	// Failures before since start the count over
+	attempt, err = repo.RecordFailure(ctx, "ip:1", now.Add(2*time.Hour), now.Add(time.Hour))
+	if err != nil || attempt.Failures != 1 {
+		t.Errorf("after the window = %+v, %v; want 1 failure", attempt, err)
+	}
+}
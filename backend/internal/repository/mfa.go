@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MFARepository interface {
+	FindTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPCredential, error)
+	SaveTOTP(ctx context.Context, credential *model.TOTPCredential) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []model.RecoveryCode) error
+	FindUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]model.RecoveryCode, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error)
+	UseRecoveryCode(ctx context.Context, id uuid.UUID) error
+	DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error
+
+	CreateChallenge(ctx context.Context, challenge *model.MFAChallenge) error
+	FindChallenge(ctx context.Context, tokenHash string) (*model.MFAChallenge, error)
+	IncrementChallengeAttempts(ctx context.Context, id uuid.UUID, maxAttempts int) error
+	DeleteChallenge(ctx context.Context, id uuid.UUID) error
+	DeleteExpiredChallenges(ctx context.Context) error
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) FindTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPCredential, error) {
+	var credential model.TOTPCredential
+	if err := r.db.WithContext(ctx).First(&credential, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// SaveTOTP inserts or replaces the user's credential
+func (r *mfaRepository) SaveTOTP(ctx context.Context, credential *model.TOTPCredential) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(credential).Error
+}
+
+// UseTOTPStep records step as the last accepted code. It returns
+// gorm.ErrRecordNotFound if that step (or a later one) was already used,
+// which makes every code single-use.
+func (r *mfaRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.TOTPCredential{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&model.TOTPCredential{}, "user_id = ?", userID).Error
+}
+
+// ReplaceRecoveryCodes discards the user's existing recovery codes and
+// stores a new set
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []model.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.RecoveryCode{}, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *mfaRepository) FindUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]model.RecoveryCode, error) {
+	var codes []model.RecoveryCode
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Find(&codes).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (r *mfaRepository) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// UseRecoveryCode marks a code as used. It returns gorm.ErrRecordNotFound
+// if the code was already used, so concurrent logins cannot share one.
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.RecoveryCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&model.RecoveryCode{}, "user_id = ?", userID).Error
+}
+
+func (r *mfaRepository) CreateChallenge(ctx context.Context, challenge *model.MFAChallenge) error {
+	return r.db.WithContext(ctx).Create(challenge).Error
+}
+
+// FindChallenge returns an unexpired challenge by its hash
+func (r *mfaRepository) FindChallenge(ctx context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	var challenge model.MFAChallenge
+	if err := r.db.WithContext(ctx).
+		First(&challenge, "token_hash = ? AND expires_at > ?", tokenHash, time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// IncrementChallengeAttempts uses up one attempt. It returns
+// gorm.ErrRecordNotFound once maxAttempts have been made.
+func (r *mfaRepository) IncrementChallengeAttempts(ctx context.Context, id uuid.UUID, maxAttempts int) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.MFAChallenge{}).
+		Where("id = ? AND attempts < ?", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteChallenge removes a challenge. It returns gorm.ErrRecordNotFound if
+// it was already gone, so a challenge can only be completed once.
+func (r *mfaRepository) DeleteChallenge(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&model.MFAChallenge{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) DeleteExpiredChallenges(ctx context.Context) error {
+	return r.db.WithContext(ctx).Delete(&model.MFAChallenge{}, "expires_at < ?", time.Now()).Error
+}
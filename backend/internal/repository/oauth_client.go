@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type OAuthClientRepository interface {
+	Create(ctx context.Context, client *model.OAuthClient) error
+	FindByClientID(ctx context.Context, clientID string) (*model.OAuthClient, error)
+	FindAll(ctx context.Context) ([]model.OAuthClient, error)
+	UpdateSecretHash(ctx context.Context, clientID, secretHash string) error
+	Delete(ctx context.Context, clientID string) error
+}
+
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	return &oauthClientRepository{db: db}
+}
+
+func (r *oauthClientRepository) Create(ctx context.Context, client *model.OAuthClient) error {
+	return r.db.WithContext(ctx).Create(client).Error
+}
+
+func (r *oauthClientRepository) FindByClientID(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	var client model.OAuthClient
+	if err := r.db.WithContext(ctx).First(&client, "client_id = ?", clientID).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) FindAll(ctx context.Context) ([]model.OAuthClient, error) {
+	var clients []model.OAuthClient
+	if err := r.db.WithContext(ctx).Order("created_at").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (r *oauthClientRepository) UpdateSecretHash(ctx context.Context, clientID, secretHash string) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.OAuthClient{}).
+		Where("client_id = ?", clientID).
+		Update("secret_hash", secretHash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *oauthClientRepository) Delete(ctx context.Context, clientID string) error {
+	result := r.db.WithContext(ctx).Delete(&model.OAuthClient{}, "client_id = ?", clientID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OAuthGrantRepository stores what users have granted OAuth clients:
+// authorization requests and codes, consents and refresh tokens
+type OAuthGrantRepository interface {
+	CreateAuthorization(ctx context.Context, authorization *model.OAuthAuthorization) error
+	FindPendingAuthorization(ctx context.Context, id uuid.UUID) (*model.OAuthAuthorization, error)
+	ApproveAuthorization(ctx context.Context, id, userID uuid.UUID, codeHash string, expiresAt time.Time) error
+	DeleteAuthorization(ctx context.Context, id uuid.UUID) error
+	ConsumeCode(ctx context.Context, codeHash string, familyID uuid.UUID, keepUntil time.Time) (*model.OAuthAuthorization, error)
+	FindConsumedCode(ctx context.Context, codeHash string) (*model.OAuthAuthorization, error)
+	SetCodeAccessToken(ctx context.Context, id uuid.UUID, jti string) error
+	DeleteExpiredAuthorizations(ctx context.Context) error
+
+	FindConsent(ctx context.Context, userID uuid.UUID, clientID string) (*model.OAuthConsent, error)
+	FindConsentsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error)
+	SaveConsent(ctx context.Context, consent *model.OAuthConsent) error
+	DeleteConsent(ctx context.Context, userID uuid.UUID, clientID string) error
+
+	CreateRefreshToken(ctx context.Context, token *model.OAuthRefreshToken) error
+	FindRefreshToken(ctx context.Context, tokenHash string) (*model.OAuthRefreshToken, error)
+	MarkRefreshTokenConsumed(ctx context.Context, id uuid.UUID) error
+	DeleteRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	DeleteRefreshTokensByUserAndClient(ctx context.Context, userID uuid.UUID, clientID string) error
+	DeleteExpiredRefreshTokens(ctx context.Context) error
+}
+
+type oauthGrantRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthGrantRepository(db *gorm.DB) OAuthGrantRepository {
+	return &oauthGrantRepository{db: db}
+}
+
+func (r *oauthGrantRepository) CreateAuthorization(ctx context.Context, authorization *model.OAuthAuthorization) error {
+	return r.db.WithContext(ctx).Create(authorization).Error
+}
+
+// FindPendingAuthorization returns an unexpired request the user has not
+// decided on yet
+func (r *oauthGrantRepository) FindPendingAuthorization(ctx context.Context, id uuid.UUID) (*model.OAuthAuthorization, error) {
+	var authorization model.OAuthAuthorization
+	if err := r.db.WithContext(ctx).
+		First(&authorization, "id = ? AND code_hash IS NULL AND expires_at > ?", id, time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return &authorization, nil
+}
+
+// ApproveAuthorization attaches the user and authorization code to a
+// pending request. It returns gorm.ErrRecordNotFound if the request was
+// already decided or has expired.
+func (r *oauthGrantRepository) ApproveAuthorization(ctx context.Context, id, userID uuid.UUID, codeHash string, expiresAt time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.OAuthAuthorization{}).
+		Where("id = ? AND code_hash IS NULL AND expires_at > ?", id, time.Now()).
+		Updates(map[string]interface{}{
+			"user_id":    userID,
+			"code_hash":  codeHash,
+			"auth_time":  time.Now(),
+			"expires_at": expiresAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *oauthGrantRepository) DeleteAuthorization(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&model.OAuthAuthorization{}, "id = ?", id).Error
+}
+
+// ConsumeCode atomically marks an unexpired code redeemed and returns its
+// authorization, so each code can only be redeemed once. The redeemed code
+// is kept until keepUntil with the refresh token family issued for it.
+func (r *oauthGrantRepository) ConsumeCode(ctx context.Context, codeHash string, familyID uuid.UUID, keepUntil time.Time) (*model.OAuthAuthorization, error) {
+	var authorization model.OAuthAuthorization
+	result := r.db.WithContext(ctx).
+		Model(&authorization).
+		Clauses(clause.Returning{}).
+		Where("code_hash = ? AND consumed_at IS NULL AND expires_at > ?", codeHash, time.Now()).
+		Updates(map[string]interface{}{
+			"consumed_at": time.Now(),
+			"family_id":   familyID,
+			"expires_at":  keepUntil,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &authorization, nil
+}
+
+// FindConsumedCode returns the authorization of a code that has already
+// been redeemed
+func (r *oauthGrantRepository) FindConsumedCode(ctx context.Context, codeHash string) (*model.OAuthAuthorization, error) {
+	var authorization model.OAuthAuthorization
+	if err := r.db.WithContext(ctx).
+		First(&authorization, "code_hash = ? AND consumed_at IS NOT NULL AND expires_at > ?", codeHash, time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return &authorization, nil
+}
+
+// SetCodeAccessToken records the access token issued for a redeemed code
+func (r *oauthGrantRepository) SetCodeAccessToken(ctx context.Context, id uuid.UUID, jti string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.OAuthAuthorization{}).
+		Where("id = ?", id).
+		Update("access_token_jti", jti).Error
+}
+
+func (r *oauthGrantRepository) DeleteExpiredAuthorizations(ctx context.Context) error {
+	return r.db.WithContext(ctx).Delete(&model.OAuthAuthorization{}, "expires_at < ?", time.Now()).Error
+}
+
+func (r *oauthGrantRepository) FindConsent(ctx context.Context, userID uuid.UUID, clientID string) (*model.OAuthConsent, error) {
+	var consent model.OAuthConsent
+	if err := r.db.WithContext(ctx).
+		First(&consent, "user_id = ? AND client_id = ?", userID, clientID).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (r *oauthGrantRepository) FindConsentsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error) {
+	var consents []model.OAuthConsent
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// SaveConsent inserts or replaces the user's consent for the client
+func (r *oauthGrantRepository) SaveConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+		}).
+		Create(consent).Error
+}
+
+// DeleteConsent returns gorm.ErrRecordNotFound if there was no consent
+func (r *oauthGrantRepository) DeleteConsent(ctx context.Context, userID uuid.UUID, clientID string) error {
+	result := r.db.WithContext(ctx).Delete(&model.OAuthConsent{}, "user_id = ? AND client_id = ?", userID, clientID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *oauthGrantRepository) CreateRefreshToken(ctx context.Context, token *model.OAuthRefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *oauthGrantRepository) FindRefreshToken(ctx context.Context, tokenHash string) (*model.OAuthRefreshToken, error) {
+	var token model.OAuthRefreshToken
+	if err := r.db.WithContext(ctx).First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenConsumed returns gorm.ErrRecordNotFound if the token was
+// consumed concurrently
+func (r *oauthGrantRepository) MarkRefreshTokenConsumed(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.OAuthRefreshToken{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *oauthGrantRepository) DeleteRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&model.OAuthRefreshToken{}, "family_id = ?", familyID).Error
+}
+
+func (r *oauthGrantRepository) DeleteRefreshTokensByUserAndClient(ctx context.Context, userID uuid.UUID, clientID string) error {
+	return r.db.WithContext(ctx).
+		Delete(&model.OAuthRefreshToken{}, "user_id = ? AND client_id = ?", userID, clientID).Error
+}
+
+func (r *oauthGrantRepository) DeleteExpiredRefreshTokens(ctx context.Context) error {
+	return r.db.WithContext(ctx).Delete(&model.OAuthRefreshToken{}, "expires_at < ?", time.Now()).Error
+}
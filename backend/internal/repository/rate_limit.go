@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// RateLimitRepository counts requests per key in the database, so every
+// replica sees the same counts
+type RateLimitRepository interface {
+	// Hit counts a request at now and returns the new count. A window
+	// that started before since is replaced by one starting at now.
+	Hit(ctx context.Context, key string, now, since time.Time) (*model.RateLimit, error)
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
+type rateLimitRepository struct {
+	db *gorm.DB
+}
+
+func NewRateLimitRepository(db *gorm.DB) RateLimitRepository {
+	return &rateLimitRepository{db: db}
+}
+
+// Hit increments the count in a single statement, so concurrent requests
+// on different replicas are all counted
+func (r *rateLimitRepository) Hit(ctx context.Context, key string, now, since time.Time) (*model.RateLimit, error) {
+	var limit model.RateLimit
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO rate_limits (key, hits, window_start) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			hits = CASE WHEN rate_limits.window_start < ? THEN 1 ELSE rate_limits.hits + 1 END,
+			window_start = CASE WHEN rate_limits.window_start < ? THEN EXCLUDED.window_start ELSE rate_limits.window_start END
+		RETURNING key, hits, window_start`,
+		key, now, since, since,
+	).Scan(&limit).Error
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+func (r *rateLimitRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Delete(&model.RateLimit{}, "window_start < ?", before).Error
+}
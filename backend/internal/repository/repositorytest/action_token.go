@@ -0,0 +1,96 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ActionTokens is an in-memory repository.ActionTokenRepository
+type ActionTokens struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]model.ActionToken
+}
+
+var _ repository.ActionTokenRepository = (*ActionTokens)(nil)
+
+func NewActionTokens() *ActionTokens {
+	return &ActionTokens{tokens: make(map[uuid.UUID]model.ActionToken)}
+}
+
+func (r *ActionTokens) Create(ctx context.Context, token *model.ActionToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now()
+	r.tokens[token.ID] = *token
+	return nil
+}
+
+func (r *ActionTokens) Find(ctx context.Context, purpose, tokenHash string) (*model.ActionToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.Purpose == purpose && token.TokenHash == tokenHash && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *ActionTokens) Consume(ctx context.Context, purpose, tokenHash string) (*model.ActionToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, token := range r.tokens {
+		if token.Purpose == purpose && token.TokenHash == tokenHash && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			token.UsedAt = &now
+			r.tokens[id] = token
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *ActionTokens) FindByEmail(ctx context.Context, purpose, email string) (*model.ActionToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.Purpose == purpose && token.Email != nil && auth.CanonicalEmail(*token.Email) == auth.CanonicalEmail(email) &&
+			token.UsedAt == nil && token.ExpiresAt.After(now) {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *ActionTokens) DeleteByUserID(ctx context.Context, userID uuid.UUID, purpose string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, token := range r.tokens {
+		if token.UserID == userID && token.Purpose == purpose {
+			delete(r.tokens, id)
+		}
+	}
+	return nil
+}
+
+func (r *ActionTokens) DeleteExpired(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, token := range r.tokens {
+		if token.ExpiresAt.Before(now) {
+			delete(r.tokens, id)
+		}
+	}
+	return nil
+}
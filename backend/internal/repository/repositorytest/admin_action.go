@@ -0,0 +1,44 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// AdminActions is an in-memory repository.AdminActionRepository
+type AdminActions struct {
+	mu      sync.Mutex
+	actions []model.AdminAction
+}
+
+var _ repository.AdminActionRepository = (*AdminActions)(nil)
+
+func NewAdminActions() *AdminActions {
+	return &AdminActions{}
+}
+
+func (r *AdminActions) Create(ctx context.Context, action *model.AdminAction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	action.ID = uuid.New()
+	action.CreatedAt = time.Now()
+	r.actions = append(r.actions, *action)
+	return nil
+}
+
+func (r *AdminActions) FindByUserID(ctx context.Context, userID uuid.UUID, limit int) ([]model.AdminAction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	actions := []model.AdminAction{}
+	for i := len(r.actions) - 1; i >= 0 && len(actions) < limit; i-- {
+		if r.actions[i].UserID == userID {
+			actions = append(actions, r.actions[i])
+		}
+	}
+	return actions, nil
+}
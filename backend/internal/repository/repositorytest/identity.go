@@ -0,0 +1,166 @@
+package repositorytest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Identities is an in-memory repository.IdentityRepository. It creates the
+// users of CreateWithUser in users.
+type Identities struct {
+	users *Users
+
+	mu         sync.Mutex
+	identities map[uuid.UUID]model.Identity
+	states     map[uuid.UUID]model.OAuthState
+}
+
+var _ repository.IdentityRepository = (*Identities)(nil)
+
+func NewIdentities(users *Users) *Identities {
+	return &Identities{
+		users:      users,
+		identities: make(map[uuid.UUID]model.Identity),
+		states:     make(map[uuid.UUID]model.OAuthState),
+	}
+}
+
+func (r *Identities) Create(ctx context.Context, identity *model.Identity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.create(identity)
+}
+
+func (r *Identities) CreateWithUser(ctx context.Context, user *model.User, identity *model.Identity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.findByProviderSubject(identity.Provider, identity.Subject); ok {
+		return gorm.ErrDuplicatedKey
+	}
+	if err := r.users.Create(ctx, user); err != nil {
+		return err
+	}
+	identity.UserID = user.ID
+	return r.create(identity)
+}
+
+func (r *Identities) FindByProviderSubject(ctx context.Context, provider, subject string) (*model.Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity, ok := r.findByProviderSubject(provider, subject)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &identity, nil
+}
+
+func (r *Identities) FindByUserID(ctx context.Context, userID uuid.UUID) ([]model.Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var identities []model.Identity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].CreatedAt.Before(identities[j].CreatedAt)
+	})
+	return identities, nil
+}
+
+func (r *Identities) RecordLogin(ctx context.Context, id uuid.UUID, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if identity, ok := r.identities[id]; ok {
+		now := time.Now()
+		identity.Email = email
+		identity.LastLoginAt = &now
+		r.identities[id] = identity
+	}
+	return nil
+}
+
+func (r *Identities) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity, ok := r.identities[id]
+	if !ok || identity.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.identities, id)
+	return nil
+}
+
+func (r *Identities) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, identity := range r.identities {
+		if identity.UserID == userID {
+			delete(r.identities, id)
+		}
+	}
+	return nil
+}
+
+func (r *Identities) CreateState(ctx context.Context, state *model.OAuthState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state.ID = uuid.New()
+	state.CreatedAt = time.Now()
+	r.states[state.ID] = *state
+	return nil
+}
+
+func (r *Identities) ConsumeState(ctx context.Context, stateHash string) (*model.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, state := range r.states {
+		if state.StateHash == stateHash && state.ExpiresAt.After(time.Now()) {
+			delete(r.states, id)
+			return &state, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *Identities) DeleteExpiredStates(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, state := range r.states {
+		if state.ExpiresAt.Before(now) {
+			delete(r.states, id)
+		}
+	}
+	return nil
+}
+
+// create must be called with mu held
+func (r *Identities) create(identity *model.Identity) error {
+	if _, ok := r.findByProviderSubject(identity.Provider, identity.Subject); ok {
+		return gorm.ErrDuplicatedKey
+	}
+	identity.ID = uuid.New()
+	identity.CreatedAt = time.Now()
+	identity.UpdatedAt = identity.CreatedAt
+	r.identities[identity.ID] = *identity
+	return nil
+}
+
+// findByProviderSubject must be called with mu held
+func (r *Identities) findByProviderSubject(provider, subject string) (model.Identity, bool) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, true
+		}
+	}
+	return model.Identity{}, false
+}
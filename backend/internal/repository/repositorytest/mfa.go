@@ -0,0 +1,186 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MFA is an in-memory repository.MFARepository
+type MFA struct {
+	mu            sync.Mutex
+	totp          map[uuid.UUID]model.TOTPCredential
+	recoveryCodes map[uuid.UUID]model.RecoveryCode
+	challenges    map[uuid.UUID]model.MFAChallenge
+}
+
+var _ repository.MFARepository = (*MFA)(nil)
+
+func NewMFA() *MFA {
+	return &MFA{
+		totp:          make(map[uuid.UUID]model.TOTPCredential),
+		recoveryCodes: make(map[uuid.UUID]model.RecoveryCode),
+		challenges:    make(map[uuid.UUID]model.MFAChallenge),
+	}
+}
+
+func (r *MFA) FindTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.totp[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &credential, nil
+}
+
+func (r *MFA) SaveTOTP(ctx context.Context, credential *model.TOTPCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if existing, ok := r.totp[credential.UserID]; ok {
+		credential.CreatedAt = existing.CreatedAt
+	} else {
+		credential.CreatedAt = now
+	}
+	credential.UpdatedAt = now
+	r.totp[credential.UserID] = *credential
+	return nil
+}
+
+func (r *MFA) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.totp[userID]
+	if !ok || credential.LastUsedStep >= step {
+		return gorm.ErrRecordNotFound
+	}
+	credential.LastUsedStep = step
+	r.totp[userID] = credential
+	return nil
+}
+
+func (r *MFA) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.totp, userID)
+	return nil
+}
+
+func (r *MFA) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []model.RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleteRecoveryCodes(userID)
+	now := time.Now()
+	for _, code := range codes {
+		code.ID = uuid.New()
+		code.CreatedAt = now
+		r.recoveryCodes[code.ID] = code
+	}
+	return nil
+}
+
+func (r *MFA) FindUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]model.RecoveryCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var codes []model.RecoveryCode
+	for _, code := range r.recoveryCodes {
+		if code.UserID == userID && code.UsedAt == nil {
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+func (r *MFA) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	codes, err := r.FindUnusedRecoveryCodes(ctx, userID)
+	return int64(len(codes)), err
+}
+
+func (r *MFA) UseRecoveryCode(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code, ok := r.recoveryCodes[id]
+	if !ok || code.UsedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	r.recoveryCodes[id] = code
+	return nil
+}
+
+func (r *MFA) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleteRecoveryCodes(userID)
+	return nil
+}
+
+func (r *MFA) deleteRecoveryCodes(userID uuid.UUID) {
+	for id, code := range r.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.recoveryCodes, id)
+		}
+	}
+}
+
+func (r *MFA) CreateChallenge(ctx context.Context, challenge *model.MFAChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge.ID = uuid.New()
+	challenge.CreatedAt = time.Now()
+	r.challenges[challenge.ID] = *challenge
+	return nil
+}
+
+func (r *MFA) FindChallenge(ctx context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, challenge := range r.challenges {
+		if challenge.TokenHash == tokenHash && challenge.ExpiresAt.After(now) {
+			return &challenge, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *MFA) IncrementChallengeAttempts(ctx context.Context, id uuid.UUID, maxAttempts int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge, ok := r.challenges[id]
+	if !ok || challenge.Attempts >= maxAttempts {
+		return gorm.ErrRecordNotFound
+	}
+	challenge.Attempts++
+	r.challenges[id] = challenge
+	return nil
+}
+
+func (r *MFA) DeleteChallenge(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.challenges[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.challenges, id)
+	return nil
+}
+
+func (r *MFA) DeleteExpiredChallenges(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, challenge := range r.challenges {
+		if challenge.ExpiresAt.Before(now) {
+			delete(r.challenges, id)
+		}
+	}
+	return nil
+}
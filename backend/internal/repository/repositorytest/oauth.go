@@ -0,0 +1,314 @@
+package repositorytest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuthClients is an in-memory repository.OAuthClientRepository
+type OAuthClients struct {
+	mu      sync.Mutex
+	clients map[string]model.OAuthClient
+}
+
+var _ repository.OAuthClientRepository = (*OAuthClients)(nil)
+
+func NewOAuthClients() *OAuthClients {
+	return &OAuthClients{clients: make(map[string]model.OAuthClient)}
+}
+
+func (r *OAuthClients) Create(ctx context.Context, client *model.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[client.ClientID]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	client.ID = uuid.New()
+	client.CreatedAt = time.Now()
+	client.UpdatedAt = client.CreatedAt
+	r.clients[client.ClientID] = *client
+	return nil
+}
+
+func (r *OAuthClients) FindByClientID(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &client, nil
+}
+
+func (r *OAuthClients) FindAll(ctx context.Context) ([]model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make([]model.OAuthClient, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+	})
+	return clients, nil
+}
+
+func (r *OAuthClients) UpdateSecretHash(ctx context.Context, clientID, secretHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[clientID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	client.SecretHash = secretHash
+	r.clients[clientID] = client
+	return nil
+}
+
+func (r *OAuthClients) Delete(ctx context.Context, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[clientID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.clients, clientID)
+	return nil
+}
+
+// OAuthGrants is an in-memory repository.OAuthGrantRepository
+type OAuthGrants struct {
+	mu             sync.Mutex
+	authorizations map[uuid.UUID]model.OAuthAuthorization
+	consents       []model.OAuthConsent
+	refreshTokens  map[uuid.UUID]model.OAuthRefreshToken
+}
+
+var _ repository.OAuthGrantRepository = (*OAuthGrants)(nil)
+
+func NewOAuthGrants() *OAuthGrants {
+	return &OAuthGrants{
+		authorizations: make(map[uuid.UUID]model.OAuthAuthorization),
+		refreshTokens:  make(map[uuid.UUID]model.OAuthRefreshToken),
+	}
+}
+
+func (r *OAuthGrants) CreateAuthorization(ctx context.Context, authorization *model.OAuthAuthorization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	authorization.ID = uuid.New()
+	authorization.CreatedAt = time.Now()
+	r.authorizations[authorization.ID] = *authorization
+	return nil
+}
+
+func (r *OAuthGrants) FindPendingAuthorization(ctx context.Context, id uuid.UUID) (*model.OAuthAuthorization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	authorization, ok := r.authorizations[id]
+	if !ok || authorization.CodeHash != nil || !authorization.ExpiresAt.After(time.Now()) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &authorization, nil
+}
+
+func (r *OAuthGrants) ApproveAuthorization(ctx context.Context, id, userID uuid.UUID, codeHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	authorization, ok := r.authorizations[id]
+	if !ok || authorization.CodeHash != nil || !authorization.ExpiresAt.After(time.Now()) {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	authorization.UserID = &userID
+	authorization.CodeHash = &codeHash
+	authorization.AuthTime = &now
+	authorization.ExpiresAt = expiresAt
+	r.authorizations[id] = authorization
+	return nil
+}
+
+func (r *OAuthGrants) DeleteAuthorization(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.authorizations, id)
+	return nil
+}
+
+func (r *OAuthGrants) ConsumeCode(ctx context.Context, codeHash string, familyID uuid.UUID, keepUntil time.Time) (*model.OAuthAuthorization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, authorization := range r.authorizations {
+		if authorization.CodeHash != nil && *authorization.CodeHash == codeHash &&
+			authorization.ConsumedAt == nil && authorization.ExpiresAt.After(now) {
+			authorization.ConsumedAt = &now
+			authorization.FamilyID = &familyID
+			authorization.ExpiresAt = keepUntil
+			r.authorizations[id] = authorization
+			return &authorization, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *OAuthGrants) FindConsumedCode(ctx context.Context, codeHash string) (*model.OAuthAuthorization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, authorization := range r.authorizations {
+		if authorization.CodeHash != nil && *authorization.CodeHash == codeHash &&
+			authorization.ConsumedAt != nil && authorization.ExpiresAt.After(now) {
+			return &authorization, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *OAuthGrants) SetCodeAccessToken(ctx context.Context, id uuid.UUID, jti string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if authorization, ok := r.authorizations[id]; ok {
+		authorization.AccessTokenJTI = &jti
+		r.authorizations[id] = authorization
+	}
+	return nil
+}
+
+func (r *OAuthGrants) DeleteExpiredAuthorizations(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, authorization := range r.authorizations {
+		if authorization.ExpiresAt.Before(now) {
+			delete(r.authorizations, id)
+		}
+	}
+	return nil
+}
+
+func (r *OAuthGrants) FindConsent(ctx context.Context, userID uuid.UUID, clientID string) (*model.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, consent := range r.consents {
+		if consent.UserID == userID && consent.ClientID == clientID {
+			return &consent, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *OAuthGrants) FindConsentsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var consents []model.OAuthConsent
+	for _, consent := range r.consents {
+		if consent.UserID == userID {
+			consents = append(consents, consent)
+		}
+	}
+	return consents, nil
+}
+
+func (r *OAuthGrants) SaveConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i, existing := range r.consents {
+		if existing.UserID == consent.UserID && existing.ClientID == consent.ClientID {
+			r.consents[i].Scopes = consent.Scopes
+			r.consents[i].UpdatedAt = now
+			return nil
+		}
+	}
+	consent.ID = uuid.New()
+	consent.CreatedAt = now
+	consent.UpdatedAt = now
+	r.consents = append(r.consents, *consent)
+	return nil
+}
+
+func (r *OAuthGrants) DeleteConsent(ctx context.Context, userID uuid.UUID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, consent := range r.consents {
+		if consent.UserID == userID && consent.ClientID == clientID {
+			r.consents = append(r.consents[:i], r.consents[i+1:]...)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (r *OAuthGrants) CreateRefreshToken(ctx context.Context, token *model.OAuthRefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now()
+	r.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (r *OAuthGrants) FindRefreshToken(ctx context.Context, tokenHash string) (*model.OAuthRefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *OAuthGrants) MarkRefreshTokenConsumed(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.refreshTokens[id]
+	if !ok || token.ConsumedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	token.ConsumedAt = &now
+	r.refreshTokens[id] = token
+	return nil
+}
+
+func (r *OAuthGrants) DeleteRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, token := range r.refreshTokens {
+		if token.FamilyID == familyID {
+			delete(r.refreshTokens, id)
+		}
+	}
+	return nil
+}
+
+func (r *OAuthGrants) DeleteRefreshTokensByUserAndClient(ctx context.Context, userID uuid.UUID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, token := range r.refreshTokens {
+		if token.UserID == userID && token.ClientID == clientID {
+			delete(r.refreshTokens, id)
+		}
+	}
+	return nil
+}
+
+func (r *OAuthGrants) DeleteExpiredRefreshTokens(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, token := range r.refreshTokens {
+		if token.ExpiresAt.Before(now) {
+			delete(r.refreshTokens, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package repositorytest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PersonalAccessTokens is an in-memory
+// repository.PersonalAccessTokenRepository
+type PersonalAccessTokens struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]model.PersonalAccessToken
+}
+
+var _ repository.PersonalAccessTokenRepository = (*PersonalAccessTokens)(nil)
+
+func NewPersonalAccessTokens() *PersonalAccessTokens {
+	return &PersonalAccessTokens{tokens: make(map[uuid.UUID]model.PersonalAccessToken)}
+}
+
+func (r *PersonalAccessTokens) Create(ctx context.Context, token *model.PersonalAccessToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.tokens {
+		if existing.TokenHash == token.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now()
+	r.tokens[token.ID] = *token
+	return nil
+}
+
+func (r *PersonalAccessTokens) FindByHash(ctx context.Context, tokenHash string) (*model.PersonalAccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *PersonalAccessTokens) FindByUserID(ctx context.Context, userID uuid.UUID) ([]model.PersonalAccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []model.PersonalAccessToken
+	for _, token := range r.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (r *PersonalAccessTokens) UpdateLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[id]; ok {
+		token.LastUsedAt = &usedAt
+		r.tokens[id] = token
+	}
+	return nil
+}
+
+func (r *PersonalAccessTokens) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok || token.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.tokens, id)
+	return nil
+}
+
+func (r *PersonalAccessTokens) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, token := range r.tokens {
+		if token.UserID == userID {
+			delete(r.tokens, id)
+		}
+	}
+	return nil
+}
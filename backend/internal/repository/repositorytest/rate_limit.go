@@ -0,0 +1,45 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+)
+
+// RateLimits is an in-memory repository.RateLimitRepository
+type RateLimits struct {
+	mu     sync.Mutex
+	limits map[string]model.RateLimit
+}
+
+var _ repository.RateLimitRepository = (*RateLimits)(nil)
+
+func NewRateLimits() *RateLimits {
+	return &RateLimits{limits: make(map[string]model.RateLimit)}
+}
+
+func (r *RateLimits) Hit(ctx context.Context, key string, now, since time.Time) (*model.RateLimit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	limit, ok := r.limits[key]
+	if !ok || limit.WindowStart.Before(since) {
+		limit = model.RateLimit{Key: key, WindowStart: now}
+	}
+	limit.Hits++
+	r.limits[key] = limit
+	return &limit, nil
+}
+
+func (r *RateLimits) DeleteStale(ctx context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, limit := range r.limits {
+		if limit.WindowStart.Before(before) {
+			delete(r.limits, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+)
+
+// RevokedTokens is an in-memory repository.RevokedTokenRepository
+type RevokedTokens struct {
+	mu     sync.Mutex
+	tokens map[string]model.RevokedToken
+}
+
+var _ repository.RevokedTokenRepository = (*RevokedTokens)(nil)
+
+func NewRevokedTokens() *RevokedTokens {
+	return &RevokedTokens{tokens: make(map[string]model.RevokedToken)}
+}
+
+func (r *RevokedTokens) Create(ctx context.Context, tokens []model.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range tokens {
+		if _, ok := r.tokens[token.JTI]; ok {
+			continue
+		}
+		token.CreatedAt = time.Now()
+		r.tokens[token.JTI] = token
+	}
+	return nil
+}
+
+func (r *RevokedTokens) Exists(ctx context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.tokens[jti]
+	return ok, nil
+}
+
+func (r *RevokedTokens) DeleteExpired(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for jti, token := range r.tokens {
+		if token.ExpiresAt.Before(now) {
+			delete(r.tokens, jti)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package repositorytest
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Roles is an in-memory repository.RoleRepository seeded with the roles
+// the migrations create. Authorization versions are kept on users.
+type Roles struct {
+	users *Users
+
+	mu          sync.Mutex
+	roles       map[uuid.UUID]model.Role
+	assignments []model.UserRole
+}
+
+var _ repository.RoleRepository = (*Roles)(nil)
+
+func NewRoles(users *Users) *Roles {
+	r := &Roles{users: users, roles: make(map[uuid.UUID]model.Role)}
+	all := []model.Permission{
+		{Name: model.PermissionUsersRead},
+		{Name: model.PermissionUsersWrite},
+		{Name: model.PermissionRolesRead},
+		{Name: model.PermissionRolesAssign},
+	}
+	for _, role := range []model.Role{
+		{Name: model.RoleAdmin, Permissions: all},
+		{Name: model.RoleMember, IsDefault: true, Permissions: []model.Permission{}},
+	} {
+		role.ID = uuid.New()
+		r.roles[role.ID] = role
+	}
+	return r
+}
+
+func (r *Roles) List(ctx context.Context) ([]model.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	roles := make([]model.Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *Roles) FindByName(ctx context.Context, name string) (*model.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, role := range r.roles {
+		if role.Name == name {
+			return &role, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *Roles) FindByUserID(ctx context.Context, userID uuid.UUID) ([]model.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	roles := []model.Role{}
+	for _, a := range r.assignments {
+		if a.UserID == userID {
+			roles = append(roles, r.roles[a.RoleID])
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *Roles) Assign(ctx context.Context, assignment *model.UserRole) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.indexOf(assignment.UserID, assignment.RoleID) >= 0 {
+		return nil
+	}
+	assignment.CreatedAt = time.Now()
+	r.assignments = append(r.assignments, *assignment)
+	r.users.bumpAuthzVersion(assignment.UserID)
+	return nil
+}
+
+func (r *Roles) Unassign(ctx context.Context, userID, roleID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.indexOf(userID, roleID)
+	if i < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	r.assignments = slices.Delete(r.assignments, i, i+1)
+	r.users.bumpAuthzVersion(userID)
+	return nil
+}
+
+func (r *Roles) CountUsers(ctx context.Context, roleID uuid.UUID) (int64, error) {
+	r.mu.Lock()
+	assignments := slices.Clone(r.assignments)
+	r.mu.Unlock()
+
+	var count int64
+	for _, a := range assignments {
+		if a.RoleID != roleID {
+			continue
+		}
+		if _, err := r.users.FindByID(ctx, a.UserID); err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *Roles) AuthzVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	user, err := r.users.FindByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return user.AuthzVersion, nil
+}
+
+func (r *Roles) Permissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	roles, err := r.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, role := range roles {
+		for _, p := range role.Permissions {
+			if !slices.Contains(names, p.Name) {
+				names = append(names, p.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// indexOf must be called with mu held
+func (r *Roles) indexOf(userID, roleID uuid.UUID) int {
+	return slices.IndexFunc(r.assignments, func(a model.UserRole) bool {
+		return a.UserID == userID && a.RoleID == roleID
+	})
+}
@@ -0,0 +1,142 @@
+package repositorytest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ServiceAccounts is an in-memory repository.ServiceAccountRepository
+type ServiceAccounts struct {
+	mu       sync.Mutex
+	accounts map[uuid.UUID]model.ServiceAccount
+	secrets  map[uuid.UUID]model.ServiceAccountSecret
+}
+
+var _ repository.ServiceAccountRepository = (*ServiceAccounts)(nil)
+
+func NewServiceAccounts() *ServiceAccounts {
+	return &ServiceAccounts{
+		accounts: make(map[uuid.UUID]model.ServiceAccount),
+		secrets:  make(map[uuid.UUID]model.ServiceAccountSecret),
+	}
+}
+
+func (r *ServiceAccounts) Create(ctx context.Context, account *model.ServiceAccount, secret *model.ServiceAccountSecret) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.findByClientID(account.ClientID); ok {
+		return gorm.ErrDuplicatedKey
+	}
+	account.ID = uuid.New()
+	account.CreatedAt = time.Now()
+	account.UpdatedAt = account.CreatedAt
+	stored := *account
+	stored.Secrets = nil
+	r.accounts[account.ID] = stored
+	secret.ServiceAccountID = account.ID
+	r.createSecret(secret)
+	return nil
+}
+
+func (r *ServiceAccounts) FindByClientID(ctx context.Context, clientID string) (*model.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.findByClientID(clientID)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &account, nil
+}
+
+func (r *ServiceAccounts) FindAll(ctx context.Context) ([]model.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	accounts := make([]model.ServiceAccount, 0, len(r.accounts))
+	for _, account := range r.accounts {
+		for _, secret := range r.secrets {
+			if secret.ServiceAccountID == account.ID {
+				account.Secrets = append(account.Secrets, secret)
+			}
+		}
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].CreatedAt.Before(accounts[j].CreatedAt)
+	})
+	return accounts, nil
+}
+
+func (r *ServiceAccounts) Delete(ctx context.Context, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.findByClientID(clientID)
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.accounts, account.ID)
+	for id, secret := range r.secrets {
+		if secret.ServiceAccountID == account.ID {
+			delete(r.secrets, id)
+		}
+	}
+	return nil
+}
+
+func (r *ServiceAccounts) RotateSecret(ctx context.Context, secret *model.ServiceAccountSecret, expireOthersAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, other := range r.secrets {
+		if other.ServiceAccountID == secret.ServiceAccountID && (other.ExpiresAt == nil || other.ExpiresAt.After(expireOthersAt)) {
+			expiresAt := expireOthersAt
+			other.ExpiresAt = &expiresAt
+			r.secrets[id] = other
+		}
+	}
+	r.createSecret(secret)
+	return nil
+}
+
+func (r *ServiceAccounts) FindActiveSecret(ctx context.Context, serviceAccountID uuid.UUID, secretHash string) (*model.ServiceAccountSecret, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range r.secrets {
+		if secret.ServiceAccountID == serviceAccountID && secret.SecretHash == secretHash && !secret.IsExpired() {
+			return &secret, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *ServiceAccounts) UpdateSecretLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if secret, ok := r.secrets[id]; ok {
+		secret.LastUsedAt = &usedAt
+		r.secrets[id] = secret
+	}
+	return nil
+}
+
+// createSecret must be called with mu held
+func (r *ServiceAccounts) createSecret(secret *model.ServiceAccountSecret) {
+	secret.ID = uuid.New()
+	secret.CreatedAt = time.Now()
+	r.secrets[secret.ID] = *secret
+}
+
+// findByClientID must be called with mu held
+func (r *ServiceAccounts) findByClientID(clientID string) (model.ServiceAccount, bool) {
+	for _, account := range r.accounts {
+		if account.ClientID == clientID {
+			return account, true
+		}
+	}
+	return model.ServiceAccount{}, false
+}
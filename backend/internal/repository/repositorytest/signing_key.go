@@ -0,0 +1,98 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// SigningKeys is an in-memory repository.SigningKeyRepository
+type SigningKeys struct {
+	mu   sync.Mutex
+	keys []model.SigningKey
+}
+
+var _ repository.SigningKeyRepository = (*SigningKeys)(nil)
+
+func NewSigningKeys() *SigningKeys {
+	return &SigningKeys{}
+}
+
+func (r *SigningKeys) Create(ctx context.Context, key *model.SigningKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.find(key.ID) >= 0 {
+		return gorm.ErrDuplicatedKey
+	}
+	key.CreatedAt = time.Now()
+	r.keys = append(r.keys, *key)
+	return nil
+}
+
+// FindAll returns the keys in the order they were created
+func (r *SigningKeys) FindAll(ctx context.Context) ([]model.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]model.SigningKey(nil), r.keys...), nil
+}
+
+func (r *SigningKeys) FindByID(ctx context.Context, id string) (*model.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.find(id)
+	if i < 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	key := r.keys[i]
+	return &key, nil
+}
+
+func (r *SigningKeys) Activate(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.find(id)
+	if i < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	for j := range r.keys {
+		if r.keys[j].Status == model.SigningKeyStatusActive && j != i {
+			r.keys[j].Status = model.SigningKeyStatusPrevious
+			r.keys[j].DeactivatedAt = &now
+		}
+	}
+	r.keys[i].Status = model.SigningKeyStatusActive
+	r.keys[i].ActivatedAt = &now
+	r.keys[i].DeactivatedAt = nil
+	return nil
+}
+
+func (r *SigningKeys) DeletePreviousBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.keys[:0]
+	var deleted int64
+	for _, key := range r.keys {
+		if key.Status == model.SigningKeyStatusPrevious && key.DeactivatedAt.Before(before) {
+			deleted++
+			continue
+		}
+		kept = append(kept, key)
+	}
+	r.keys = kept
+	return deleted, nil
+}
+
+// find must be called with mu held
+func (r *SigningKeys) find(id string) int {
+	for i, key := range r.keys {
+		if key.ID == id {
+			return i
+		}
+	}
+	return -1
+}
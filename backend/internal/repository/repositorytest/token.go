@@ -0,0 +1,142 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tokens is an in-memory repository.TokenRepository. It loads the user of
+// a token from users, as the database repository preloads it.
+type Tokens struct {
+	users *Users
+
+	mu     sync.Mutex
+	tokens map[uuid.UUID]model.RefreshToken
+}
+
+var _ repository.TokenRepository = (*Tokens)(nil)
+
+func NewTokens(users *Users) *Tokens {
+	return &Tokens{users: users, tokens: make(map[uuid.UUID]model.RefreshToken)}
+}
+
+func (r *Tokens) Create(ctx context.Context, token *model.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now()
+	r.tokens[token.ID] = *token
+	return nil
+}
+
+func (r *Tokens) FindByToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	token, ok := r.find(func(t model.RefreshToken) bool {
+		return t.TokenHash == tokenHash && t.ExpiresAt.After(time.Now())
+	})
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	user, err := r.users.FindByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	token.User = *user
+	return &token, nil
+}
+
+func (r *Tokens) FindActiveByUserID(ctx context.Context, userID uuid.UUID) ([]model.RefreshToken, error) {
+	now := time.Now()
+	return r.filter(func(t model.RefreshToken) bool {
+		return t.UserID == userID && !t.IsConsumed() && t.ExpiresAt.After(now)
+	}), nil
+}
+
+func (r *Tokens) FindByFamilyIDSince(ctx context.Context, familyID uuid.UUID, since time.Time) ([]model.RefreshToken, error) {
+	return r.filter(func(t model.RefreshToken) bool {
+		return t.FamilyID == familyID && t.CreatedAt.After(since)
+	}), nil
+}
+
+func (r *Tokens) FindByUserIDSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]model.RefreshToken, error) {
+	return r.filter(func(t model.RefreshToken) bool {
+		return t.UserID == userID && t.CreatedAt.After(since)
+	}), nil
+}
+
+func (r *Tokens) MarkConsumed(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok || token.IsConsumed() {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	token.ConsumedAt = &now
+	r.tokens[id] = token
+	return nil
+}
+
+func (r *Tokens) DeleteByToken(ctx context.Context, tokenHash string) error {
+	r.delete(func(t model.RefreshToken) bool { return t.TokenHash == tokenHash })
+	return nil
+}
+
+func (r *Tokens) DeleteByFamilyID(ctx context.Context, familyID uuid.UUID) error {
+	r.delete(func(t model.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *Tokens) DeleteByUserIDAndFamilyID(ctx context.Context, userID, familyID uuid.UUID) error {
+	r.delete(func(t model.RefreshToken) bool { return t.UserID == userID && t.FamilyID == familyID })
+	return nil
+}
+
+func (r *Tokens) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	r.delete(func(t model.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func (r *Tokens) DeleteExpired(ctx context.Context) error {
+	now := time.Now()
+	r.delete(func(t model.RefreshToken) bool { return t.ExpiresAt.Before(now) })
+	return nil
+}
+
+func (r *Tokens) find(match func(model.RefreshToken) bool) (model.RefreshToken, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if match(token) {
+			return token, true
+		}
+	}
+	return model.RefreshToken{}, false
+}
+
+func (r *Tokens) filter(match func(model.RefreshToken) bool) []model.RefreshToken {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []model.RefreshToken
+	for _, token := range r.tokens {
+		if match(token) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+func (r *Tokens) delete(match func(model.RefreshToken) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, token := range r.tokens {
+		if match(token) {
+			delete(r.tokens, id)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Package repositorytest provides in-memory repositories for exercising
+// services in tests without a database. They keep the semantics the
+// services rely on, such as unique email addresses, soft deletion and
+// single-use challenges.
+package repositorytest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/pagination"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Users is an in-memory repository.UserRepository
+type Users struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]model.User
+}
+
+var _ repository.UserRepository = (*Users)(nil)
+
+func NewUsers() *Users {
+	return &Users{users: make(map[uuid.UUID]model.User)}
+}
+
+func (r *Users) Create(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.EmailCanonical = auth.CanonicalEmail(user.Email)
+	if _, ok := r.findActive(user.Email); ok {
+		return gorm.ErrDuplicatedKey
+	}
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	user.AuthzVersion = 1
+	now := time.Now()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *Users) FindByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (r *Users) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.findActive(email)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (r *Users) FindDeletedByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *model.User
+	for _, user := range r.users {
+		if user.EmailCanonical == auth.CanonicalEmail(email) && user.DeletedAt.Valid && (latest == nil || user.DeletedAt.Time.After(latest.DeletedAt.Time)) {
+			latest = &user
+		}
+	}
+	if latest == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return latest, nil
+}
+
+func (r *Users) Update(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.EmailCanonical = auth.CanonicalEmail(user.Email)
+	if other, ok := r.findActive(user.Email); ok && other.ID != user.ID {
+		return gorm.ErrDuplicatedKey
+	}
+	// Like the database repository, leave the version to Roles and the
+	// admin controlled state to its own methods
+	if stored, ok := r.users[user.ID]; ok {
+		user.AuthzVersion = stored.AuthzVersion
+		user.DisabledAt = stored.DisabledAt
+		user.PasswordResetRequired = stored.PasswordResetRequired
+	}
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *Users) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.users[id] = user
+	return nil
+}
+
+// List returns every user that has not been deleted
+func (r *Users) List(ctx context.Context) ([]model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := make([]model.User, 0, len(r.users))
+	for _, user := range r.users {
+		if !user.DeletedAt.Valid {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *Users) Search(ctx context.Context, q *pagination.Query[model.User]) ([]model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status, _ := q.Filter(repository.UserFilterStatus)
+	query, _ := q.Filter(repository.UserFilterQuery)
+	query = strings.ToLower(query)
+	var matched []model.User
+	for _, user := range r.users {
+		var ok bool
+		switch status {
+		case repository.UserStatusActive:
+			ok = !user.DeletedAt.Valid && !user.IsDisabled()
+		case repository.UserStatusDisabled:
+			ok = !user.DeletedAt.Valid && user.IsDisabled()
+		case repository.UserStatusDeleted:
+			ok = user.DeletedAt.Valid
+		default:
+			ok = !user.DeletedAt.Valid
+		}
+		if ok && (strings.Contains(user.EmailCanonical, query) || strings.Contains(strings.ToLower(user.Name), query)) {
+			matched = append(matched, user)
+		}
+	}
+	return page(q, matched, func(u model.User) uuid.UUID { return u.ID }), nil
+}
+
+func (r *Users) FindByIDWithDeleted(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (r *Users) Restore(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || !user.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	if _, ok := r.findActive(user.Email); ok {
+		return gorm.ErrDuplicatedKey
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	r.users[id] = user
+	return nil
+}
+
+func (r *Users) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error {
+	return r.modify(id, func(user *model.User) {
+		user.DisabledAt = nil
+		if disabled {
+			now := time.Now()
+			user.DisabledAt = &now
+		}
+	})
+}
+
+func (r *Users) SetPasswordResetRequired(ctx context.Context, id uuid.UUID, required bool) error {
+	return r.modify(id, func(user *model.User) { user.PasswordResetRequired = required })
+}
+
+func (r *Users) ReplacePasswordHash(ctx context.Context, id uuid.UUID, current, hashed string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt.Valid || user.Password != current {
+		return gorm.ErrRecordNotFound
+	}
+	user.Password = hashed
+	r.users[id] = user
+	return nil
+}
+
+func (r *Users) CountStaleCanonicalEmails(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stale := 0
+	for _, user := range r.users {
+		if user.EmailCanonical != auth.CanonicalEmail(user.Email) {
+			stale++
+		}
+	}
+	return stale, nil
+}
+
+func (r *Users) modify(id uuid.UUID, update func(*model.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	update(&user)
+	r.users[id] = user
+	return nil
+}
+
+// findActive must be called with mu held
+func (r *Users) findActive(email string) (model.User, bool) {
+	for _, user := range r.users {
+		if user.EmailCanonical == auth.CanonicalEmail(email) && !user.DeletedAt.Valid {
+			return user, true
+		}
+	}
+	return model.User{}, false
+}
+
+func (r *Users) bumpAuthzVersion(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user, ok := r.users[id]; ok {
+		user.AuthzVersion++
+		r.users[id] = user
+	}
+}
+
+// page does for in-memory rows what pagination.Query.Scope does for the
+// database, except for filtering: it sorts the rows, skips those up to the
+// cursor and keeps one row more than the page size
+func page[T any](q *pagination.Query[T], items []T, id func(T) uuid.UUID) []T {
+	sort, desc := q.Sort()
+	compare := func(value any, rowID uuid.UUID, item T) int {
+		c := compareValues(sort.Value(item), value)
+		if c == 0 {
+			c = strings.Compare(id(item).String(), rowID.String())
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+
+	items = slices.Clone(items)
+	slices.SortFunc(items, func(a, b T) int { return compare(sort.Value(b), id(b), a) })
+	if value, afterID, ok := q.After(); ok {
+		start := len(items)
+		for i, item := range items {
+			if compare(value, afterID, item) > 0 {
+				start = i
+				break
+			}
+		}
+		items = items[start:]
+	}
+	return items[:min(len(items), q.Limit()+1)]
+}
+
+// compareValues compares sort values of the types pagination.Sort allows
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	}
+	panic(fmt.Sprintf("repositorytest: unsupported sort value %T", a))
+}
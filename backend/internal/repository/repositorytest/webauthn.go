@@ -0,0 +1,126 @@
+package repositorytest
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebAuthn is an in-memory repository.WebAuthnRepository
+type WebAuthn struct {
+	mu          sync.Mutex
+	credentials map[uuid.UUID]model.WebAuthnCredential
+	challenges  map[uuid.UUID]model.WebAuthnChallenge
+}
+
+var _ repository.WebAuthnRepository = (*WebAuthn)(nil)
+
+func NewWebAuthn() *WebAuthn {
+	return &WebAuthn{
+		credentials: make(map[uuid.UUID]model.WebAuthnCredential),
+		challenges:  make(map[uuid.UUID]model.WebAuthnChallenge),
+	}
+}
+
+func (r *WebAuthn) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.credentials {
+		if bytes.Equal(existing.CredentialID, credential.CredentialID) {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	credential.ID = uuid.New()
+	credential.CreatedAt = time.Now()
+	credential.UpdatedAt = credential.CreatedAt
+	r.credentials[credential.ID] = *credential
+	return nil
+}
+
+func (r *WebAuthn) FindCredentialByCredentialID(ctx context.Context, credentialID []byte) (*model.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, credential := range r.credentials {
+		if bytes.Equal(credential.CredentialID, credentialID) {
+			return &credential, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *WebAuthn) FindCredentialsByUserID(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var credentials []model.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *WebAuthn) UpdateCredentialUsage(ctx context.Context, id uuid.UUID, oldSignCount, newSignCount int64, backupState bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.credentials[id]
+	if !ok || credential.SignCount != oldSignCount {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	credential.SignCount = newSignCount
+	credential.BackupState = backupState
+	credential.LastUsedAt = &now
+	r.credentials[id] = credential
+	return nil
+}
+
+func (r *WebAuthn) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.credentials[id]
+	if !ok || credential.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.credentials, id)
+	return nil
+}
+
+func (r *WebAuthn) CreateChallenge(ctx context.Context, challenge *model.WebAuthnChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge.ID = uuid.New()
+	challenge.CreatedAt = time.Now()
+	r.challenges[challenge.ID] = *challenge
+	return nil
+}
+
+func (r *WebAuthn) ConsumeChallenge(ctx context.Context, purpose, challengeHash string) (*model.WebAuthnChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, challenge := range r.challenges {
+		if challenge.Purpose == purpose && challenge.ChallengeHash == challengeHash && challenge.ExpiresAt.After(time.Now()) {
+			delete(r.challenges, id)
+			return &challenge, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *WebAuthn) DeleteExpiredChallenges(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, challenge := range r.challenges {
+		if challenge.ExpiresAt.Before(now) {
+			delete(r.challenges, id)
+		}
+	}
+	return nil
+}
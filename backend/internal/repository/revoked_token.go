@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevokedTokenRepository interface {
+	Create(ctx context.Context, tokens []model.RevokedToken) error
+	Exists(ctx context.Context, jti string) (bool, error)
+	DeleteExpired(ctx context.Context) error
+}
+
+type revokedTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRevokedTokenRepository(db *gorm.DB) RevokedTokenRepository {
+	return &revokedTokenRepository{db: db}
+}
+
+// Create adds denylist entries, ignoring jtis that are already revoked
+func (r *revokedTokenRepository) Create(ctx context.Context, tokens []model.RevokedToken) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&tokens).Error
+}
+
+func (r *revokedTokenRepository) Exists(ctx context.Context, jti string) (bool, error) {
+	var token model.RevokedToken
+	err := r.db.WithContext(ctx).Select("jti").First(&token, "jti = ?", jti).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *revokedTokenRepository) DeleteExpired(ctx context.Context) error {
+	return r.db.WithContext(ctx).Delete(&model.RevokedToken{}, "expires_at < ?", time.Now()).Error
+}
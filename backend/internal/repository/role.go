@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoleRepository stores roles and their assignment to users. Changing a
+// user's roles bumps users.authz_version in the same transaction.
+type RoleRepository interface {
+	List(ctx context.Context) ([]model.Role, error)
+	FindByName(ctx context.Context, name string) (*model.Role, error)
+	FindByUserID(ctx context.Context, userID uuid.UUID) ([]model.Role, error)
+	// Assign is a no-op for a role the user already has
+	Assign(ctx context.Context, assignment *model.UserRole) error
+	// Unassign returns gorm.ErrRecordNotFound if the user lacks the role
+	Unassign(ctx context.Context, userID, roleID uuid.UUID) error
+	// CountUsers counts the active users holding the role
+	CountUsers(ctx context.Context, roleID uuid.UUID) (int64, error)
+	// AuthzVersion returns users.authz_version of an active user
+	AuthzVersion(ctx context.Context, userID uuid.UUID) (int, error)
+	// Permissions returns the names of the permissions the user's roles grant
+	Permissions(ctx context.Context, userID uuid.UUID) ([]string, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) List(ctx context.Context) ([]model.Role, error) {
+	var roles []model.Role
+	err := r.db.WithContext(ctx).Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) FindByName(ctx context.Context, name string) (*model.Role, error) {
+	var role model.Role
+	if err := r.db.WithContext(ctx).Preload("Permissions").First(&role, "name = ?", name).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]model.Role, error) {
+	var roles []model.Role
+	err := r.db.WithContext(ctx).
+		Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) Assign(ctx context.Context, assignment *model.UserRole) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(assignment)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return bumpAuthzVersion(tx, assignment.UserID)
+	})
+}
+
+func (r *roleRepository) Unassign(ctx context.Context, userID, roleID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.UserRole{}, "user_id = ? AND role_id = ?", userID, roleID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return bumpAuthzVersion(tx, userID)
+	})
+}
+
+func (r *roleRepository) CountUsers(ctx context.Context, roleID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.UserRole{}).
+		Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL").
+		Where("user_roles.role_id = ?", roleID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *roleRepository) AuthzVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).Select("authz_version").First(&user, "id = ?", userID).Error; err != nil {
+		return 0, err
+	}
+	return user.AuthzVersion, nil
+}
+
+func (r *roleRepository) Permissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	var names []string
+	err := r.db.WithContext(ctx).
+		Table("role_permissions").
+		Distinct("role_permissions.permission_name").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Order("role_permissions.permission_name").
+		Pluck("role_permissions.permission_name", &names).Error
+	return names, err
+}
+
+// bumpAuthzVersion marks access tokens issued with the user's previous
+// roles as outdated
+func bumpAuthzVersion(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Model(&model.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("authz_version", gorm.Expr("authz_version + 1")).Error
+}
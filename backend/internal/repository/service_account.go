@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ServiceAccountRepository interface {
+	Create(ctx context.Context, account *model.ServiceAccount, secret *model.ServiceAccountSecret) error
+	FindByClientID(ctx context.Context, clientID string) (*model.ServiceAccount, error)
+	FindAll(ctx context.Context) ([]model.ServiceAccount, error)
+	Delete(ctx context.Context, clientID string) error
+	RotateSecret(ctx context.Context, secret *model.ServiceAccountSecret, expireOthersAt time.Time) error
+	FindActiveSecret(ctx context.Context, serviceAccountID uuid.UUID, secretHash string) (*model.ServiceAccountSecret, error)
+	UpdateSecretLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+type serviceAccountRepository struct {
+	db *gorm.DB
+}
+
+func NewServiceAccountRepository(db *gorm.DB) ServiceAccountRepository {
+	return &serviceAccountRepository{db: db}
+}
+
+// Create creates a service account together with its first secret
+func (r *serviceAccountRepository) Create(ctx context.Context, account *model.ServiceAccount, secret *model.ServiceAccountSecret) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Secrets").Create(account).Error; err != nil {
+			return err
+		}
+		secret.ServiceAccountID = account.ID
+		return tx.Create(secret).Error
+	})
+}
+
+func (r *serviceAccountRepository) FindByClientID(ctx context.Context, clientID string) (*model.ServiceAccount, error) {
+	var account model.ServiceAccount
+	if err := r.db.WithContext(ctx).First(&account, "client_id = ?", clientID).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindAll returns every service account with its secrets, newest first
+func (r *serviceAccountRepository) FindAll(ctx context.Context) ([]model.ServiceAccount, error) {
+	var accounts []model.ServiceAccount
+	if err := r.db.WithContext(ctx).
+		Preload("Secrets", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC") }).
+		Order("created_at").
+		Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// Delete removes a service account and its secrets
+func (r *serviceAccountRepository) Delete(ctx context.Context, clientID string) error {
+	result := r.db.WithContext(ctx).Delete(&model.ServiceAccount{}, "client_id = ?", clientID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RotateSecret adds a secret and makes the account's other secrets expire
+// at expireOthersAt, unless they expire sooner already
+func (r *serviceAccountRepository) RotateSecret(ctx context.Context, secret *model.ServiceAccountSecret, expireOthersAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.ServiceAccountSecret{}).
+			Where("service_account_id = ? AND (expires_at IS NULL OR expires_at > ?)", secret.ServiceAccountID, expireOthersAt).
+			Update("expires_at", expireOthersAt).Error; err != nil {
+			return err
+		}
+		return tx.Create(secret).Error
+	})
+}
+
+func (r *serviceAccountRepository) FindActiveSecret(ctx context.Context, serviceAccountID uuid.UUID, secretHash string) (*model.ServiceAccountSecret, error) {
+	var secret model.ServiceAccountSecret
+	if err := r.db.WithContext(ctx).
+		Where("service_account_id = ? AND secret_hash = ?", serviceAccountID, secretHash).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		First(&secret).Error; err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+func (r *serviceAccountRepository) UpdateSecretLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.ServiceAccountSecret{}).
+		Where("id = ?", id).
+		Update("last_used_at", usedAt).Error
+}
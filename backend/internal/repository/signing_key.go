@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key *model.SigningKey) error
+	FindAll(ctx context.Context) ([]model.SigningKey, error)
+	FindByID(ctx context.Context, id string) (*model.SigningKey, error)
+	Activate(ctx context.Context, id string) error
+	DeletePreviousBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+func (r *signingKeyRepository) Create(ctx context.Context, key *model.SigningKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *signingKeyRepository) FindAll(ctx context.Context) ([]model.SigningKey, error) {
+	var keys []model.SigningKey
+	if err := r.db.WithContext(ctx).Order("created_at").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepository) FindByID(ctx context.Context, id string) (*model.SigningKey, error) {
+	var key model.SigningKey
+	if err := r.db.WithContext(ctx).First(&key, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Activate makes the key the active one and demotes the current active key
+// to previous, in a single transaction
+func (r *signingKeyRepository) Activate(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&model.SigningKey{}).
+			Where("status = ? AND id <> ?", model.SigningKeyStatusActive, id).
+			Updates(map[string]interface{}{
+				"status":         model.SigningKeyStatusPrevious,
+				"deactivated_at": now,
+			}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.SigningKey{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":         model.SigningKeyStatusActive,
+				"activated_at":   now,
+				"deactivated_at": nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// DeletePreviousBefore removes previous keys deactivated before the given time
+func (r *signingKeyRepository) DeletePreviousBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Delete(&model.SigningKey{},
+		"status = ? AND deactivated_at < ?", model.SigningKeyStatusPrevious, before)
+	return result.RowsAffected, result.Error
+}
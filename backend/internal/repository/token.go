@@ -11,8 +11,14 @@ import (
 
 type TokenRepository interface {
 	Create(ctx context.Context, token *model.RefreshToken) error
-	FindByToken(ctx context.Context, token string) (*model.RefreshToken, error)
-	DeleteByToken(ctx context.Context, token string) error
+	FindByToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	FindActiveByUserID(ctx context.Context, userID uuid.UUID) ([]model.RefreshToken, error)
+	FindByFamilyIDSince(ctx context.Context, familyID uuid.UUID, since time.Time) ([]model.RefreshToken, error)
+	FindByUserIDSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]model.RefreshToken, error)
+	MarkConsumed(ctx context.Context, id uuid.UUID) error
+	DeleteByToken(ctx context.Context, tokenHash string) error
+	DeleteByFamilyID(ctx context.Context, familyID uuid.UUID) error
+	DeleteByUserIDAndFamilyID(ctx context.Context, userID, familyID uuid.UUID) error
 	DeleteByUserID(ctx context.Context, userID uuid.UUID) error
 	DeleteExpired(ctx context.Context) error
 }
@@ -29,18 +35,92 @@ func (r *tokenRepository) Create(ctx context.Context, token *model.RefreshToken)
 	return r.db.WithContext(ctx).Create(token).Error
 }
 
-func (r *tokenRepository) FindByToken(ctx context.Context, token string) (*model.RefreshToken, error) {
+// FindByToken returns an unexpired refresh token by its hash, including
+// consumed ones
+func (r *tokenRepository) FindByToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
 	var refreshToken model.RefreshToken
 	if err := r.db.WithContext(ctx).
 		Preload("User").
-		First(&refreshToken, "token = ? AND expires_at > ?", token, time.Now()).Error; err != nil {
+		First(&refreshToken, "token_hash = ? AND expires_at > ?", tokenHash, time.Now()).Error; err != nil {
 		return nil, err
 	}
 	return &refreshToken, nil
 }
 
-func (r *tokenRepository) DeleteByToken(ctx context.Context, token string) error {
-	return r.db.WithContext(ctx).Delete(&model.RefreshToken{}, "token = ?", token).Error
+// FindActiveByUserID returns the current (unconsumed, unexpired) token of
+// every session the user has, most recently used first
+func (r *tokenRepository) FindActiveByUserID(ctx context.Context, userID uuid.UUID) ([]model.RefreshToken, error) {
+	var tokens []model.RefreshToken
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND consumed_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// FindByFamilyIDSince returns every token of a family, consumed or not,
+// created after since
+func (r *tokenRepository) FindByFamilyIDSince(ctx context.Context, familyID uuid.UUID, since time.Time) ([]model.RefreshToken, error) {
+	var tokens []model.RefreshToken
+	if err := r.db.WithContext(ctx).
+		Where("family_id = ? AND created_at > ?", familyID, since).
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// FindByUserIDSince returns every token of a user, consumed or not, created
+// after since
+func (r *tokenRepository) FindByUserIDSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]model.RefreshToken, error) {
+	var tokens []model.RefreshToken
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// MarkConsumed flags a token as rotated. It returns gorm.ErrRecordNotFound
+// when the token was already consumed, so concurrent rotations of the same
+// token cannot both succeed.
+func (r *tokenRepository) MarkConsumed(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.RefreshToken{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *tokenRepository) DeleteByToken(ctx context.Context, tokenHash string) error {
+	return r.db.WithContext(ctx).Delete(&model.RefreshToken{}, "token_hash = ?", tokenHash).Error
+}
+
+func (r *tokenRepository) DeleteByFamilyID(ctx context.Context, familyID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&model.RefreshToken{}, "family_id = ?", familyID).Error
+}
+
+// DeleteByUserIDAndFamilyID deletes a family only if it belongs to the user.
+// It returns gorm.ErrRecordNotFound when nothing was deleted.
+func (r *tokenRepository) DeleteByUserIDAndFamilyID(ctx context.Context, userID, familyID uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Delete(&model.RefreshToken{}, "user_id = ? AND family_id = ?", userID, familyID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (r *tokenRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
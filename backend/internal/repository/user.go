@@ -2,18 +2,98 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
 	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/pagination"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// UserRepository finds users by the canonical form of an email address,
+// so callers pass addresses as they were typed
 type UserRepository interface {
 	Create(ctx context.Context, user *model.User) error
 	FindByID(ctx context.Context, id uuid.UUID) (*model.User, error)
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	// FindDeletedByEmail returns the most recently deleted account that
+	// used the email
+	FindDeletedByEmail(ctx context.Context, email string) (*model.User, error)
 	Update(ctx context.Context, user *model.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
+
+	// Search lists users for administration, as selected by a query on
+	// UserListing
+	Search(ctx context.Context, query *pagination.Query[model.User]) ([]model.User, error)
+	// FindByIDWithDeleted is FindByID including soft-deleted users
+	FindByIDWithDeleted(ctx context.Context, id uuid.UUID) (*model.User, error)
+	// Restore undeletes a user. It returns gorm.ErrDuplicatedKey when an
+	// active user has taken the email address meanwhile.
+	Restore(ctx context.Context, id uuid.UUID) error
+	// SetDisabled disables or re-enables an active user
+	SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error
+	SetPasswordResetRequired(ctx context.Context, id uuid.UUID, required bool) error
+	// ReplacePasswordHash swaps the stored password hash for hashed, only
+	// while it is still current. It returns gorm.ErrRecordNotFound when the
+	// password has been changed meanwhile.
+	ReplacePasswordHash(ctx context.Context, id uuid.UUID, current, hashed string) error
+	// CountStaleCanonicalEmails counts users, deleted ones included, whose
+	// stored canonical address is not auth.CanonicalEmail of their email
+	CountStaleCanonicalEmails(ctx context.Context) (int, error)
+}
+
+// Account states the status filter of UserListing selects
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+	UserStatusDeleted  = "deleted"
+)
+
+// Filters of UserListing
+const (
+	UserFilterQuery  = "q"
+	UserFilterStatus = "status"
+)
+
+// UserListing is how admins list users. q matches part of the email
+// address or name, ignoring case. Without a status filter, users that have
+// not been deleted are listed.
+var UserListing = &pagination.Resource[model.User]{
+	Name: "users",
+	Sorts: map[string]pagination.Sort[model.User]{
+		"created_at": {Column: "created_at", Value: func(u model.User) any { return u.CreatedAt }},
+		"email":      {Column: "email_canonical", Value: func(u model.User) any { return u.EmailCanonical }},
+		"name":       {Column: "name", Value: func(u model.User) any { return u.Name }},
+	},
+	Filters: map[string]pagination.Filter{
+		UserFilterQuery: func(value string) (pagination.Scope, error) {
+			if len(value) > 255 {
+				return nil, errors.New("must be at most 255 characters")
+			}
+			pattern := "%" + escapeLike(strings.ToLower(value)) + "%"
+			return func(db *gorm.DB) *gorm.DB {
+				return db.Where("email_canonical LIKE ? OR lower(name) LIKE ?", pattern, pattern)
+			}, nil
+		},
+		UserFilterStatus: pagination.OneOf(map[string]pagination.Scope{
+			UserStatusActive: func(db *gorm.DB) *gorm.DB {
+				return db.Where("disabled_at IS NULL")
+			},
+			UserStatusDisabled: func(db *gorm.DB) *gorm.DB {
+				return db.Where("disabled_at IS NOT NULL")
+			},
+			UserStatusDeleted: func(db *gorm.DB) *gorm.DB {
+				return db.Unscoped().Where("deleted_at IS NOT NULL")
+			},
+		}),
+	},
+	ID:           func(u model.User) uuid.UUID { return u.ID },
+	DefaultSort:  "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     100,
 }
 
 type userRepository struct {
@@ -24,8 +104,16 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+// Create inserts the user together with the default roles
 func (r *userRepository) Create(ctx context.Context, user *model.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
+	user.EmailCanonical = auth.CanonicalEmail(user.Email)
+	user.AuthzVersion = 1
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO user_roles (user_id, role_id) SELECT ?, id FROM roles WHERE is_default", user.ID).Error
+	})
 }
 
 func (r *userRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
@@ -38,16 +126,149 @@ func (r *userRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Use
 
 func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
-	if err := r.db.WithContext(ctx).First(&user, "email = ?", email).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&user, "email_canonical = ?", auth.CanonicalEmail(email)).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindDeletedByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("email_canonical = ? AND deleted_at IS NOT NULL", auth.CanonicalEmail(email)).
+		Order("deleted_at DESC").
+		First(&user).Error
+	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
 func (r *userRepository) Update(ctx context.Context, user *model.User) error {
-	return r.db.WithContext(ctx).Save(user).Error
+	user.EmailCanonical = auth.CanonicalEmail(user.Email)
+	// authz_version belongs to RoleRepository and the admin controlled
+	// state to its own methods; a user loaded before they changed must not
+	// write back the old values
+	return r.db.WithContext(ctx).Omit("authz_version", "disabled_at", "password_reset_required").Save(user).Error
 }
 
+// Delete soft-deletes the user together with everything that grants
+// access to the account: sessions, personal access tokens, OAuth grants,
+// emailed links and linked identities. Passkeys and second factors are
+// kept with the soft-deleted row.
 func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.User{}, "id = ?", id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range []any{
+			&model.RefreshToken{},
+			&model.PersonalAccessToken{},
+			&model.OAuthAuthorization{},
+			&model.OAuthConsent{},
+			&model.OAuthRefreshToken{},
+			&model.ActionToken{},
+			&model.Identity{},
+		} {
+			if err := tx.Where("user_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Delete(&model.User{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// CountStaleCanonicalEmails only looks at non-ASCII addresses; SQL
+// canonicalizes ASCII ones exactly as auth.CanonicalEmail does
+func (r *userRepository) CountStaleCanonicalEmails(ctx context.Context) (int, error) {
+	var users []model.User
+	err := r.db.WithContext(ctx).Unscoped().
+		Select("email", "email_canonical").
+		Where("octet_length(email) <> char_length(email)").
+		Find(&users).Error
+	if err != nil {
+		return 0, err
+	}
+	stale := 0
+	for _, user := range users {
+		if user.EmailCanonical != auth.CanonicalEmail(user.Email) {
+			stale++
+		}
+	}
+	return stale, nil
+}
+
+func (r *userRepository) Search(ctx context.Context, query *pagination.Query[model.User]) ([]model.User, error) {
+	var users []model.User
+	err := r.db.WithContext(ctx).Scopes(query.Scope).Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) FindByIDWithDeleted(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).Unscoped().First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Unscoped().
+		Model(&model.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		UpdateColumn("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error {
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+	return r.updateColumn(ctx, id, "disabled_at", disabledAt)
+}
+
+func (r *userRepository) SetPasswordResetRequired(ctx context.Context, id uuid.UUID, required bool) error {
+	return r.updateColumn(ctx, id, "password_reset_required", required)
+}
+
+func (r *userRepository) ReplacePasswordHash(ctx context.Context, id uuid.UUID, current, hashed string) error {
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND password = ?", id, current).
+		UpdateColumn("password", hashed)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) updateColumn(ctx context.Context, id uuid.UUID, column string, value any) error {
+	result := r.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", id).UpdateColumn(column, value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/pagination"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB returns a connection that builds statements without running them
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// dryRunSearch returns the SQL and arguments Search would run for query
+func dryRunSearch(t *testing.T, query string) (string, []any) {
+	t.Helper()
+	db := dryRunDB(t)
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := UserListing.Parse(values, pagination.NewSigner("test-cursor-key"))
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", query, err)
+	}
+	stmt := db.Scopes(q.Scope).Find(&[]model.User{}).Statement
+	return stmt.SQL.String(), stmt.Vars
+}
+
+func TestUserListingScopes(t *testing.T) {
+	tests := []struct {
+		query string
+		sql   string
+		vars  []any
+	}{
+		{
+			query: "q=" + url.QueryEscape(`50%_Off\`),
+			sql:   `SELECT * FROM "users" WHERE (email_canonical LIKE $1 OR lower(name) LIKE $2) AND "users"."deleted_at" IS NULL ORDER BY created_at DESC, id DESC LIMIT $3`,
+			vars:  []any{`%50\%\_off\\%`, `%50\%\_off\\%`, 51},
+		},
+		{
+			query: "status=deleted",
+			sql:   `SELECT * FROM "users" WHERE deleted_at IS NOT NULL ORDER BY created_at DESC, id DESC LIMIT $1`,
+			vars:  []any{51},
+		},
+		{
+			query: "status=disabled",
+			sql:   `SELECT * FROM "users" WHERE disabled_at IS NOT NULL AND "users"."deleted_at" IS NULL ORDER BY created_at DESC, id DESC LIMIT $1`,
+			vars:  []any{51},
+		},
+	}
+	for _, tt := range tests {
+		sql, vars := dryRunSearch(t, tt.query)
+		if sql != tt.sql {
+			t.Errorf("%s: SQL = %s\nwant %s", tt.query, sql, tt.sql)
+		}
+		if !reflect.DeepEqual(vars, tt.vars) {
+			t.Errorf("%s: args = %#v, want %#v", tt.query, vars, tt.vars)
+		}
+	}
+}
+
+func TestReplacePasswordHashIsConditional(t *testing.T) {
+	db := dryRunDB(t)
+	var stmt *gorm.Statement
+	if err := db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+		stmt = tx.Statement
+	}); err != nil {
+		t.Fatal(err)
+	}
+	id := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	// A dry run affects no rows
+	err := NewUserRepository(db).ReplacePasswordHash(context.Background(), id, "old-hash", "new-hash")
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("err = %v, want gorm.ErrRecordNotFound", err)
+	}
+	want := `UPDATE "users" SET "password"=$1 WHERE (id = $2 AND password = $3) AND "users"."deleted_at" IS NULL`
+	if sql := stmt.SQL.String(); sql != want {
+		t.Errorf("SQL = %s\nwant %s", sql, want)
+	}
+	if vars := []any{"new-hash", id, "old-hash"}; !reflect.DeepEqual(stmt.Vars, vars) {
+		t.Errorf("args = %#v, want %#v", stmt.Vars, vars)
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebAuthnRepository interface {
+	CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error
+	FindCredentialByCredentialID(ctx context.Context, credentialID []byte) (*model.WebAuthnCredential, error)
+	FindCredentialsByUserID(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error)
+	UpdateCredentialUsage(ctx context.Context, id uuid.UUID, oldSignCount, newSignCount int64, backupState bool) error
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) error
+
+	CreateChallenge(ctx context.Context, challenge *model.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, purpose, challengeHash string) (*model.WebAuthnChallenge, error)
+	DeleteExpiredChallenges(ctx context.Context) error
+}
+
+type webAuthnRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnRepository(db *gorm.DB) WebAuthnRepository {
+	return &webAuthnRepository{db: db}
+}
+
+func (r *webAuthnRepository) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	return r.db.WithContext(ctx).Create(credential).Error
+}
+
+func (r *webAuthnRepository) FindCredentialByCredentialID(ctx context.Context, credentialID []byte) (*model.WebAuthnCredential, error) {
+	var credential model.WebAuthnCredential
+	if err := r.db.WithContext(ctx).First(&credential, "credential_id = ?", credentialID).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *webAuthnRepository) FindCredentialsByUserID(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error) {
+	var credentials []model.WebAuthnCredential
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// UpdateCredentialUsage stores the new signature counter after a login. It
+// returns gorm.ErrRecordNotFound if the counter changed in the meantime, so
+// two logins cannot both succeed with the same counter value.
+func (r *webAuthnRepository) UpdateCredentialUsage(ctx context.Context, id uuid.UUID, oldSignCount, newSignCount int64, backupState bool) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, oldSignCount).
+		Updates(map[string]interface{}{
+			"sign_count":   newSignCount,
+			"backup_state": backupState,
+			"last_used_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteCredential removes one of the user's passkeys. It returns
+// gorm.ErrRecordNotFound if the user has no such passkey.
+func (r *webAuthnRepository) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&model.WebAuthnCredential{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *webAuthnRepository) CreateChallenge(ctx context.Context, challenge *model.WebAuthnChallenge) error {
+	return r.db.WithContext(ctx).Create(challenge).Error
+}
+
+// ConsumeChallenge atomically deletes and returns an unexpired challenge,
+// so each ceremony can only be completed once
+func (r *webAuthnRepository) ConsumeChallenge(ctx context.Context, purpose, challengeHash string) (*model.WebAuthnChallenge, error) {
+	var challenge model.WebAuthnChallenge
+	result := r.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("purpose = ? AND challenge_hash = ? AND expires_at > ?", purpose, challengeHash, time.Now()).
+		Delete(&challenge)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &challenge, nil
+}
+
+func (r *webAuthnRepository) DeleteExpiredChallenges(ctx context.Context) error {
+	return r.db.WithContext(ctx).Delete(&model.WebAuthnChallenge{}, "expires_at < ?", time.Now()).Error
+}
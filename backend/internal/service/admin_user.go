@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/pagination"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSelfAction     = errors.New("admins cannot do this to their own account")
+	ErrUserNotDeleted = errors.New("user is not deleted")
+)
+
+// adminUserActionsShown is how many recent admin actions Get returns
+const adminUserActionsShown = 20
+
+// AdminUserService lets admins manage other users' accounts. Every change
+// is recorded as a model.AdminAction naming the admin who made it, before
+// it is made, so no change goes unrecorded if recording fails.
+type AdminUserService interface {
+	// List pages through users as repository.UserListing describes. It
+	// returns a *pagination.Error for a query it does not accept.
+	List(ctx context.Context, query url.Values) (*pagination.Page[AdminUser], error)
+	// Get returns any user, including deleted ones
+	Get(ctx context.Context, userID uuid.UUID) (*AdminUserDetail, error)
+	// Disable keeps the user from signing in and signs them out everywhere
+	Disable(ctx context.Context, actorID, userID uuid.UUID) error
+	Enable(ctx context.Context, actorID, userID uuid.UUID) error
+	// ForcePasswordReset refuses password logins until the user sets a new
+	// password, signs them out everywhere and emails them a reset link
+	ForcePasswordReset(ctx context.Context, actorID, userID uuid.UUID) error
+	RevokeSessions(ctx context.Context, actorID, userID uuid.UUID) error
+	// Delete soft-deletes the account like the user deleting it themselves
+	Delete(ctx context.Context, actorID, userID uuid.UUID) error
+	// Restore undeletes an account. Sessions, tokens and linked identities
+	// removed by the deletion stay gone. It returns ErrEmailTaken when the
+	// address could not be changed to either, as EmailChangeService checks.
+	Restore(ctx context.Context, actorID, userID uuid.UUID) error
+	// Unlock forgets the account's failed logins, ending a lockout
+	Unlock(ctx context.Context, actorID, userID uuid.UUID) error
+}
+
+// AdminUser is a user as admins see it
+type AdminUser struct {
+	*model.User
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+// AdminUserDetail adds the user's roles and the latest admin actions
+// taken on the account
+type AdminUserDetail struct {
+	AdminUser
+	Roles   []string            `json:"roles"`
+	Actions []model.AdminAction `json:"actions"`
+}
+
+// AdminUserOptions configures AdminUserService
+type AdminUserOptions struct {
+	// DeletedEmailHold is AuthOptions.DeletedEmailHold
+	DeletedEmailHold time.Duration
+}
+
+type adminUserService struct {
+	userRepo        repository.UserRepository
+	roleRepo        repository.RoleRepository
+	adminActionRepo repository.AdminActionRepository
+	actionTokenRepo repository.ActionTokenRepository
+	sessions        SessionService
+	revocations     RevocationService
+	auth            AuthService
+	throttle        LoginThrottleService
+	cursors         *pagination.Signer
+	opts            AdminUserOptions
+}
+
+func NewAdminUserService(
+	userRepo repository.UserRepository,
+	roleRepo repository.RoleRepository,
+	adminActionRepo repository.AdminActionRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	sessions SessionService,
+	revocations RevocationService,
+	auth AuthService,
+	throttle LoginThrottleService,
+	cursors *pagination.Signer,
+	opts AdminUserOptions,
+) AdminUserService {
+	return &adminUserService{
+		userRepo:        userRepo,
+		roleRepo:        roleRepo,
+		adminActionRepo: adminActionRepo,
+		actionTokenRepo: actionTokenRepo,
+		sessions:        sessions,
+		revocations:     revocations,
+		auth:            auth,
+		throttle:        throttle,
+		cursors:         cursors,
+		opts:            opts,
+	}
+}
+
+func (s *adminUserService) List(ctx context.Context, query url.Values) (*pagination.Page[AdminUser], error) {
+	q, err := repository.UserListing.Parse(query, s.cursors)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.userRepo.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := q.Page(users)
+	items := make([]AdminUser, len(page.Items))
+	for i := range page.Items {
+		items[i] = newAdminUser(&page.Items[i])
+	}
+	return &pagination.Page[AdminUser]{Items: items, NextCursor: page.NextCursor, HasMore: page.HasMore}, nil
+}
+
+func (s *adminUserService) Get(ctx context.Context, userID uuid.UUID) (*AdminUserDetail, error) {
+	user, err := s.userRepo.FindByIDWithDeleted(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	roles, err := s.roleRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := s.adminActionRepo.FindByUserID(ctx, userID, adminUserActionsShown)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &AdminUserDetail{AdminUser: newAdminUser(user), Roles: []string{}, Actions: actions}
+	for _, role := range roles {
+		detail.Roles = append(detail.Roles, role.Name)
+	}
+	return detail, nil
+}
+
+func (s *adminUserService) Disable(ctx context.Context, actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return ErrSelfAction
+	}
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.IsDisabled() {
+		return nil
+	}
+
+	if err := s.record(ctx, actorID, user.ID, model.AdminActionDisable); err != nil {
+		return err
+	}
+	if err := s.userRepo.SetDisabled(ctx, user.ID, true); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAll(ctx, user.ID)
+}
+
+func (s *adminUserService) Enable(ctx context.Context, actorID, userID uuid.UUID) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsDisabled() {
+		return nil
+	}
+
+	if err := s.record(ctx, actorID, user.ID, model.AdminActionEnable); err != nil {
+		return err
+	}
+	return s.userRepo.SetDisabled(ctx, user.ID, false)
+}
+
+func (s *adminUserService) ForcePasswordReset(ctx context.Context, actorID, userID uuid.UUID) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.record(ctx, actorID, user.ID, model.AdminActionForcePasswordReset); err != nil {
+		return err
+	}
+	if err := s.userRepo.SetPasswordResetRequired(ctx, user.ID, true); err != nil {
+		return err
+	}
+	if err := s.sessions.RevokeAll(ctx, user.ID); err != nil {
+		return err
+	}
+	return s.auth.ForgotPassword(ctx, ForgotPasswordRequest{Email: user.Email})
+}
+
+func (s *adminUserService) RevokeSessions(ctx context.Context, actorID, userID uuid.UUID) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.record(ctx, actorID, user.ID, model.AdminActionRevokeSessions); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAll(ctx, user.ID)
+}
+
+func (s *adminUserService) Delete(ctx context.Context, actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return ErrSelfAction
+	}
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.record(ctx, actorID, user.ID, model.AdminActionDelete); err != nil {
+		return err
+	}
+	// As in UserService.Delete, access tokens are denylisted while the
+	// refresh tokens that find them still exist
+	if err := s.revocations.RevokeUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.userRepo.Delete(ctx, user.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *adminUserService) Restore(ctx context.Context, actorID, userID uuid.UUID) error {
+	user, err := s.userRepo.FindByIDWithDeleted(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if !user.DeletedAt.Valid {
+		return ErrUserNotDeleted
+	}
+	if err := emailAvailable(ctx, s.userRepo, s.actionTokenRepo, user.ID, user.Email, s.opts.DeletedEmailHold); err != nil {
+		return err
+	}
+
+	if err := s.record(ctx, actorID, user.ID, model.AdminActionRestore); err != nil {
+		return err
+	}
+	// The unique index settles a race with a registration for the address
+	if err := s.userRepo.Restore(ctx, user.ID); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			return ErrEmailTaken
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return ErrUserNotDeleted
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *adminUserService) Unlock(ctx context.Context, actorID, userID uuid.UUID) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.record(ctx, actorID, user.ID, model.AdminActionUnlock); err != nil {
+		return err
+	}
+	return s.throttle.Unlock(ctx, user.Email)
+}
+
+// record attributes an action to the admin about to take it
+func (s *adminUserService) record(ctx context.Context, actorID, userID uuid.UUID, action string) error {
+	slog.InfoContext(ctx, "Admin action on user",
+		"event", "admin_user_"+action,
+		"actor_id", actorID,
+		"user_id", userID,
+	)
+	return s.adminActionRepo.Create(ctx, &model.AdminAction{
+		ActorID: actorID,
+		UserID:  userID,
+		Action:  action,
+	})
+}
+
+func (s *adminUserService) findUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func newAdminUser(user *model.User) AdminUser {
+	admin := AdminUser{User: user}
+	if user.DeletedAt.Valid {
+		admin.DeletedAt = &user.DeletedAt.Time
+	}
+	return admin
+}
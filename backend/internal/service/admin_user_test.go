@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/pagination"
+)
+
+func TestAdminDisableUser(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := env.createUser(t, "admin@example.com", "correct horse battery")
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	session, _ := env.login(t, user.Email, "correct horse battery")
+
+	if err := env.admin.Disable(ctx, admin.ID, admin.ID); !errors.Is(err, ErrSelfAction) {
+		t.Errorf("disabling oneself: err = %v, want ErrSelfAction", err)
+	}
+	if err := env.admin.Disable(ctx, admin.ID, user.ID); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+
+	if !env.revocations.revokedUser(user.ID) {
+		t.Error("access tokens were not revoked")
+	}
+	if _, err := env.auth.Refresh(ctx, session.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("disabled user can still refresh")
+	}
+	login := LoginRequest{Email: user.Email, Password: "correct horse battery"}
+	if _, err := env.auth.Login(ctx, login, ClientInfo{}); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("Login: err = %v, want ErrAccountDisabled", err)
+	}
+	if _, err := env.auth.LoginExternal(ctx, user.ID, ClientInfo{}); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("LoginExternal: err = %v, want ErrAccountDisabled", err)
+	}
+	// A wrong password does not tell that the account exists and is disabled
+	login.Password = "wrong"
+	if _, err := env.auth.Login(ctx, login, ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with a wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	if err := env.admin.Enable(ctx, admin.ID, user.ID); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	env.login(t, user.Email, "correct horse battery")
+
+	detail, err := env.admin.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Actions) != 2 {
+		t.Fatalf("actions = %+v, want enable and disable", detail.Actions)
+	}
+	for i, want := range []string{model.AdminActionEnable, model.AdminActionDisable} {
+		if got := detail.Actions[i]; got.Action != want || got.ActorID != admin.ID {
+			t.Errorf("actions[%d] = %s by %s, want %s by the admin", i, got.Action, got.ActorID, want)
+		}
+	}
+}
+
+func TestAdminForcePasswordReset(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := env.createUser(t, "admin@example.com", "correct horse battery")
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	if err := env.admin.ForcePasswordReset(ctx, admin.ID, user.ID); err != nil {
+		t.Fatalf("ForcePasswordReset: %v", err)
+	}
+	if !env.revocations.revokedUser(user.ID) {
+		t.Error("access tokens were not revoked")
+	}
+	login := LoginRequest{Email: user.Email, Password: "correct horse battery"}
+	if _, err := env.auth.Login(ctx, login, ClientInfo{}); !errors.Is(err, ErrPasswordResetRequired) {
+		t.Fatalf("Login: err = %v, want ErrPasswordResetRequired", err)
+	}
+
+	msg, ok := env.mailer.Last()
+	if !ok || msg.To != user.Email {
+		t.Fatalf("last email = %+v, want a reset link to %s", msg, user.Email)
+	}
+	link, err := url.Parse(resetPasswordURL.FindString(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset := ResetPasswordRequest{Token: link.Query().Get("token"), Password: "tea party at four"}
+	if err := env.auth.ResetPassword(ctx, reset); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	env.login(t, user.Email, "tea party at four")
+}
+
+func TestAdminDeleteAndRestore(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := env.createUser(t, "admin@example.com", "correct horse battery")
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	if err := env.admin.Delete(ctx, admin.ID, admin.ID); !errors.Is(err, ErrSelfAction) {
+		t.Errorf("deleting oneself: err = %v, want ErrSelfAction", err)
+	}
+	if err := env.admin.Restore(ctx, admin.ID, user.ID); !errors.Is(err, ErrUserNotDeleted) {
+		t.Errorf("Restore of an active user: err = %v, want ErrUserNotDeleted", err)
+	}
+	if err := env.admin.Delete(ctx, admin.ID, user.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := env.users.FindByID(ctx, user.ID); err == nil {
+		t.Error("deleted user is still found")
+	}
+	detail, err := env.admin.Get(ctx, user.ID)
+	if err != nil || detail.DeletedAt == nil {
+		t.Fatalf("Get of a deleted user = %+v, %v", detail, err)
+	}
+
+	if err := env.admin.Restore(ctx, admin.ID, user.ID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	env.login(t, user.Email, "correct horse battery")
+
+	// The address may have been taken while the account was deleted
+	if err := env.admin.Delete(ctx, admin.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	env.createUser(t, "Alice@example.com", "correct horse battery")
+	if err := env.admin.Restore(ctx, admin.ID, user.ID); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Restore with the email taken: err = %v, want ErrEmailTaken", err)
+	}
+}
+
+func TestAdminListUsers(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := env.createUser(t, "admin@example.com", "correct horse battery")
+	alice := env.createUser(t, "alice@example.com", "correct horse battery")
+	bob := env.createUser(t, "bob@wonderland.example", "correct horse battery")
+	carol := env.createUser(t, "carol@wonderland.example", "correct horse battery")
+	if err := env.admin.Disable(ctx, admin.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.admin.Delete(ctx, admin.ID, carol.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{bob.Email, alice.Email, admin.Email}},
+		{"q=WONDERLAND", []string{bob.Email}},
+		{"status=active", []string{alice.Email, admin.Email}},
+		{"status=disabled", []string{bob.Email}},
+		{"status=deleted", []string{carol.Email}},
+		{"sort=email", []string{admin.Email, alice.Email, bob.Email}},
+		{"sort=-email&limit=2", []string{bob.Email, alice.Email}},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		page, err := env.admin.List(ctx, query)
+		if err != nil {
+			t.Fatalf("List(%q): %v", tt.query, err)
+		}
+		if got := pageEmails(page); !slices.Equal(got, tt.want) {
+			t.Errorf("List(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAdminListUsersCursor(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	var want []string
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		want = append(want, env.createUser(t, email, "correct horse battery").Email)
+	}
+
+	var got []string
+	query := url.Values{"sort": {"email"}, "limit": {"2"}}
+	for pages := 1; ; pages++ {
+		page, err := env.admin.List(ctx, query)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		got = append(got, pageEmails(page)...)
+		if !page.HasMore {
+			if pages != 3 || page.NextCursor != "" {
+				t.Errorf("last page %d has cursor %q, want page 3 without one", pages, page.NextCursor)
+			}
+			break
+		}
+		if pages > 3 {
+			t.Fatal("the cursor does not advance")
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	// A cursor only continues the query it came from
+	page, err := env.admin.List(ctx, url.Values{"sort": {"email"}, "limit": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := page.NextCursor
+	payload, sig, _ := strings.Cut(cursor, ".")
+	tampered := []byte(payload)
+	tampered[0] ^= 1
+	rejected := []url.Values{
+		{"sort": {"email"}, "cursor": {string(tampered) + "." + sig}},
+		{"sort": {"email"}, "cursor": {"garbage"}},
+		{"sort": {"-email"}, "cursor": {cursor}},
+		{"sort": {"email"}, "status": {"active"}, "cursor": {cursor}},
+	}
+	for _, query := range rejected {
+		requireQueryRejected(t, env, query, "cursor")
+	}
+	if _, err := env.admin.List(ctx, url.Values{"sort": {"email"}, "limit": {"5"}, "cursor": {cursor}}); err != nil {
+		t.Errorf("changing the limit rejects the cursor: %v", err)
+	}
+}
+
+func TestAdminListUsersRejectsQuery(t *testing.T) {
+	env := newTestEnv(t)
+	tests := []struct {
+		query url.Values
+		param string
+	}{
+		{url.Values{"limit": {"0"}}, "limit"},
+		{url.Values{"limit": {"101"}}, "limit"},
+		{url.Values{"limit": {"ten"}}, "limit"},
+		{url.Values{"sort": {"password"}}, "sort"},
+		{url.Values{"status": {"banned"}}, "status"},
+		{url.Values{"status": {"active", "disabled"}}, "status"},
+		{url.Values{"password": {"secret"}}, "password"},
+	}
+	for _, tt := range tests {
+		requireQueryRejected(t, env, tt.query, tt.param)
+	}
+}
+
+func requireQueryRejected(t *testing.T, env *testEnv, query url.Values, param string) {
+	t.Helper()
+	_, err := env.admin.List(context.Background(), query)
+	var queryErr *pagination.Error
+	if !errors.As(err, &queryErr) || queryErr.Param != param {
+		t.Errorf("List(%v): err = %v, want a pagination.Error for %s", query, err, param)
+	}
+}
+
+func pageEmails(page *pagination.Page[AdminUser]) []string {
+	var emails []string
+	for _, user := range page.Items {
+		emails = append(emails, user.Email)
+	}
+	return emails
+}
+
+func TestAdminUnlockUser(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	stopClock(env)
+	admin := env.createUser(t, "admin@example.com", "correct horse battery")
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	wrong := LoginRequest{Email: user.Email, Password: "wrong"}
+	for i := 0; i < testThrottleOptions.LockoutThreshold; i++ {
+		if err := env.throttle.RecordFailure(ctx, wrong.Email, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var lockedErr *AccountLockedError
+	if _, err := env.auth.Login(ctx, wrong, ClientInfo{}); !errors.As(err, &lockedErr) {
+		t.Fatalf("Login: err = %v, want AccountLockedError", err)
+	}
+
+	if err := env.admin.Unlock(ctx, admin.ID, user.ID); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	env.login(t, user.Email, "correct horse battery")
+
+	detail, err := env.admin.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Actions) != 1 || detail.Actions[0].Action != model.AdminActionUnlock || detail.Actions[0].ActorID != admin.ID {
+		t.Errorf("actions = %+v, want the unlock by the admin", detail.Actions)
+	}
+}
+
+func TestAdminRestoreRespectsRevertReservation(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := env.createUser(t, "admin@example.com", "correct horse battery")
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	if err := env.admin.Delete(ctx, admin.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else took the address and moved on; it stays reserved for
+	// their revert link
+	other := env.createUser(t, "Alice@Example.com", "tea party at four")
+	req := ChangeEmailRequest{NewEmail: "mallory@example.com", Password: "tea party at four"}
+	if err := env.emailChanges.Request(ctx, other.ID, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.emailChanges.Confirm(ctx, ConfirmEmailChangeRequest{Token: env.mailedToken(t, req.NewEmail)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.admin.Restore(ctx, admin.ID, user.ID); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("Restore: err = %v, want ErrEmailTaken", err)
+	}
+	detail, err := env.admin.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.DeletedAt == nil || len(detail.Actions) != 1 || detail.Actions[0].Action != model.AdminActionDelete {
+		t.Errorf("after a refused Restore: deleted at %v, actions %+v; want still deleted with only the deletion recorded", detail.DeletedAt, detail.Actions)
+	}
+}
@@ -3,10 +3,15 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"time"
 
 	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/mail"
 	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/passwordpolicy"
 	"github.com/ablaze/gonexttemp-backend/internal/repository"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -17,19 +22,32 @@ var (
 	ErrUserAlreadyExists  = errors.New("user already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenReused        = errors.New("refresh token reused")
+	ErrEmailNotVerified   = errors.New("email not verified")
+	ErrAccountDisabled    = errors.New("account disabled")
+	// ErrPasswordResetRequired is returned for a correct password that an
+	// admin requires the user to replace through a reset link
+	ErrPasswordResetRequired = errors.New("password reset required")
 )
 
 type AuthService interface {
-	Register(ctx context.Context, req RegisterRequest) (*AuthResponse, error)
-	Login(ctx context.Context, req LoginRequest) (*AuthResponse, error)
-	Refresh(ctx context.Context, refreshToken string) (*AuthResponse, error)
+	Register(ctx context.Context, req RegisterRequest, client ClientInfo) (*AuthResponse, error)
+	Login(ctx context.Context, req LoginRequest, client ClientInfo) (*AuthResponse, error)
+	Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*AuthResponse, error)
 	Logout(ctx context.Context, refreshToken string) error
-	GetCurrentUser(ctx context.Context, userID uuid.UUID) (*model.User, error)
+	GetCurrentUser(ctx context.Context, userID uuid.UUID) (*CurrentUser, error)
+	ForgotPassword(ctx context.Context, req ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req ResetPasswordRequest) error
+	VerifyMFA(ctx context.Context, req VerifyMFARequest, client ClientInfo) (*AuthResponse, error)
+	LoginWithPasskey(ctx context.Context, req PasskeyLoginRequest, client ClientInfo) (*AuthResponse, error)
+	LoginExternal(ctx context.Context, userID uuid.UUID, client ClientInfo) (*AuthResponse, error)
+	VerifyEmail(ctx context.Context, req VerifyEmailRequest) error
+	ResendVerification(ctx context.Context, req ResendVerificationRequest) error
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"`
 	Name     string `json:"name" validate:"required,min=1,max=255"`
 }
 
@@ -38,32 +56,134 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// AuthOptions configures AuthService
+type AuthOptions struct {
+	// AppURL is the frontend base URL used in links sent by email
+	AppURL                  string
+	PasswordResetExpiry     time.Duration
+	EmailVerificationExpiry time.Duration
+	// RequireVerifiedEmail makes Login reject users who have not verified
+	// their email address, and Register return without starting a session
+	RequireVerifiedEmail bool
+	// DeletedEmailHold keeps the email of a deleted account from being
+	// registered again for this long; zero frees it at once and a negative
+	// hold keeps it for good
+	DeletedEmailHold time.Duration
+}
+
+// ClientInfo describes the client a session is started or refreshed from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
 type AuthResponse struct {
 	User         *model.User `json:"user"`
 	AccessToken  string      `json:"access_token"`
 	RefreshToken string      `json:"refresh_token"`
 	ExpiresIn    int64       `json:"expires_in"`
+
+	// EmailVerificationRequired is set instead of tokens when the user must
+	// verify their email address before logging in
+	EmailVerificationRequired bool `json:"email_verification_required,omitempty"`
+
+	// MFAToken is set instead of tokens when the password was correct but a
+	// second factor is required; it is exchanged through VerifyMFA
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+// WarningLowRecoveryCodes tells the user to regenerate their MFA recovery
+// codes before they run out
+const WarningLowRecoveryCodes = "mfa_recovery_codes_low"
+
+// CurrentUser is the user profile with account state the client should
+// surface to the user
+type CurrentUser struct {
+	*model.User
+	MFA         *MFAStatus `json:"mfa"`
+	Roles       []string   `json:"roles"`
+	Permissions []string   `json:"permissions"`
+	Warnings    []string   `json:"warnings,omitempty"`
 }
 
 type authService struct {
-	userRepo  repository.UserRepository
-	tokenRepo repository.TokenRepository
-	jwt       *auth.JWTManager
+	userRepo        repository.UserRepository
+	tokenRepo       repository.TokenRepository
+	actionTokenRepo repository.ActionTokenRepository
+	revocations     RevocationService
+	mfa             MFAService
+	passkeys        PasskeyService
+	throttle        LoginThrottleService
+	roles           RoleService
+	jwt             *auth.JWTManager
+	tokenHasher     *auth.TokenHasher
+	passwords       auth.PasswordHasher
+	passwordPolicy  *passwordpolicy.Policy
+	mailer          mail.Mailer
+	opts            AuthOptions
 }
 
 func NewAuthService(
 	userRepo repository.UserRepository,
 	tokenRepo repository.TokenRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	revocations RevocationService,
+	mfa MFAService,
+	passkeys PasskeyService,
+	throttle LoginThrottleService,
+	roles RoleService,
 	jwt *auth.JWTManager,
+	tokenHasher *auth.TokenHasher,
+	passwords auth.PasswordHasher,
+	passwordPolicy *passwordpolicy.Policy,
+	mailer mail.Mailer,
+	opts AuthOptions,
 ) AuthService {
 	return &authService{
-		userRepo:  userRepo,
-		tokenRepo: tokenRepo,
-		jwt:       jwt,
+		userRepo:        userRepo,
+		tokenRepo:       tokenRepo,
+		actionTokenRepo: actionTokenRepo,
+		revocations:     revocations,
+		mfa:             mfa,
+		passkeys:        passkeys,
+		throttle:        throttle,
+		roles:           roles,
+		jwt:             jwt,
+		tokenHasher:     tokenHasher,
+		passwords:       passwords,
+		passwordPolicy:  passwordPolicy,
+		mailer:          mailer,
+		opts:            opts,
 	}
 }
 
-func (s *authService) Register(ctx context.Context, req RegisterRequest) (*AuthResponse, error) {
+func (s *authService) Register(ctx context.Context, req RegisterRequest, client ClientInfo) (*AuthResponse, error) {
+	if err := s.passwordPolicy.Check(req.Password, req.Email, req.Name); err != nil {
+		return nil, err
+	}
+
 	// Check if user already exists
 	_, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err == nil {
@@ -72,9 +192,20 @@ func (s *authService) Register(ctx context.Context, req RegisterRequest) (*AuthR
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
+	held, err := emailHeld(ctx, s.userRepo, req.Email, s.opts.DeletedEmailHold, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := emailReserved(ctx, s.actionTokenRepo, req.Email, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	if held || reserved {
+		return nil, ErrUserAlreadyExists
+	}
 
 	// Hash password
-	hashedPassword, err := auth.HashPassword(req.Password)
+	hashedPassword, err := s.passwords.Hash(req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -90,27 +221,204 @@ func (s *authService) Register(ctx context.Context, req RegisterRequest) (*AuthR
 		return nil, err
 	}
 
-	return s.generateAuthResponse(ctx, user)
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if s.opts.RequireVerifiedEmail {
+		return &AuthResponse{User: user, EmailVerificationRequired: true}, nil
+	}
+	return s.generateAuthResponse(ctx, user, client, nil)
 }
 
-func (s *authService) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
+func (s *authService) Login(ctx context.Context, req LoginRequest, client ClientInfo) (*AuthResponse, error) {
+	if err := s.throttle.Check(ctx, req.Email, client.IPAddress); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrInvalidCredentials
+			return nil, s.loginFailed(ctx, req.Email, client)
+		}
+		return nil, err
+	}
+
+	ok, rehash, err := s.passwords.Verify(req.Password, user.Password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.loginFailed(ctx, req.Email, client)
+	}
+	if rehash {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+
+	// completeLogin refuses disabled accounts before anything else
+	if user.PasswordResetRequired && !user.IsDisabled() {
+		return nil, ErrPasswordResetRequired
+	}
+	authRes, err := s.completeLogin(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	// With a second factor the failures are forgotten only once VerifyMFA
+	// succeeds, so wrong codes keep counting towards the lockout
+	if authRes.MFAToken == "" {
+		if err := s.throttle.RecordSuccess(ctx, req.Email); err != nil {
+			return nil, err
+		}
+	}
+	return authRes, nil
+}
+
+// loginFailed counts a failed password login and returns the error for it
+func (s *authService) loginFailed(ctx context.Context, email string, client ClientInfo) error {
+	if err := s.throttle.RecordFailure(ctx, email, client.IPAddress); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// rehashPassword replaces a legacy or outdated password hash after a
+// successful login. Failures are only logged; the old hash still works.
+// Only the hash just verified is replaced, so a password changed by a
+// concurrent reset is never overwritten.
+func (s *authService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	hashedPassword, err := s.passwords.Hash(password)
+	if err == nil {
+		err = s.userRepo.ReplacePasswordHash(ctx, user.ID, user.Password, hashedPassword)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.InfoContext(ctx, "Password rehash skipped, password changed meanwhile",
+			"event", "password_rehash_skipped",
+			"user_id", user.ID,
+		)
+		return
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Password rehash failed",
+			"event", "password_rehash_failed",
+			"user_id", user.ID,
+			"error", err,
+		)
+		return
+	}
+	slog.InfoContext(ctx, "Password rehashed", "event", "password_rehashed", "user_id", user.ID)
+}
+
+// LoginExternal logs in a user authenticated outside of AuthService, by
+// an external identity provider or a magic link. The user's own second
+// factor still applies.
+func (s *authService) LoginExternal(ctx context.Context, userID uuid.UUID, client ClientInfo) (*AuthResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return s.completeLogin(ctx, user, client)
+}
+
+// completeLogin issues tokens for an authenticated user, or an MFA
+// challenge when the user has a second factor
+func (s *authService) completeLogin(ctx context.Context, user *model.User, client ClientInfo) (*AuthResponse, error) {
+	if user.IsDisabled() {
+		return nil, ErrAccountDisabled
+	}
+	if s.opts.RequireVerifiedEmail && !user.IsEmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
+	mfaEnabled, err := s.mfa.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		// A locked account gets no new challenge, and with it no fresh
+		// attempts at the second factor
+		if err := s.throttle.Check(ctx, user.Email, client.IPAddress); err != nil {
+			return nil, err
+		}
+		challenge, err := s.mfa.CreateChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &AuthResponse{MFAToken: challenge}, nil
+	}
+
+	return s.generateAuthResponse(ctx, user, client, nil)
+}
+
+// VerifyMFA completes a login that required a second factor. Wrong codes
+// count as failed logins of the account and the IP. While either is
+// throttled the code is not looked at, so challenges opened before a
+// lockout cannot be used to guess it and no recovery code or TOTP step is
+// used up.
+func (s *authService) VerifyMFA(ctx context.Context, req VerifyMFARequest, client ClientInfo) (*AuthResponse, error) {
+	userID, err := s.mfa.ChallengeUser(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, err
+	}
+	if err := s.throttle.Check(ctx, user.Email, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.mfa.VerifyChallenge(ctx, req.MFAToken, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.throttle.RecordFailure(ctx, user.Email, client.IPAddress); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, ErrAccountDisabled
+	}
+
+	if err := s.throttle.RecordSuccess(ctx, user.Email); err != nil {
+		return nil, err
+	}
+	return s.generateAuthResponse(ctx, user, client, nil)
+}
+
+// LoginWithPasskey logs in with a passkey assertion. Passkeys require user
+// verification on the authenticator, so no second factor is asked for.
+func (s *authService) LoginWithPasskey(ctx context.Context, req PasskeyLoginRequest, client ClientInfo) (*AuthResponse, error) {
+	userID, err := s.passkeys.FinishLogin(ctx, &req.Credential)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPasskey
 		}
 		return nil, err
 	}
 
-	if !auth.CheckPassword(req.Password, user.Password) {
-		return nil, ErrInvalidCredentials
+	if user.IsDisabled() {
+		return nil, ErrAccountDisabled
+	}
+	if s.opts.RequireVerifiedEmail && !user.IsEmailVerified() {
+		return nil, ErrEmailNotVerified
 	}
 
-	return s.generateAuthResponse(ctx, user)
+	return s.generateAuthResponse(ctx, user, client, nil)
 }
 
-func (s *authService) Refresh(ctx context.Context, refreshToken string) (*AuthResponse, error) {
-	token, err := s.tokenRepo.FindByToken(ctx, refreshToken)
+func (s *authService) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*AuthResponse, error) {
+	token, err := s.tokenRepo.FindByToken(ctx, s.tokenHasher.Hash(refreshToken))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrInvalidToken
@@ -118,19 +426,43 @@ func (s *authService) Refresh(ctx context.Context, refreshToken string) (*AuthRe
 		return nil, err
 	}
 
-	// Delete old refresh token
-	if err := s.tokenRepo.DeleteByToken(ctx, refreshToken); err != nil {
+	// A consumed token being presented again means it was copied
+	if token.IsConsumed() {
+		return nil, s.revokeReusedFamily(ctx, token)
+	}
+	// Disabling revokes every session, so this only closes a race
+	if token.User.IsDisabled() {
+		return nil, ErrInvalidToken
+	}
+
+	// Keep the old refresh token as consumed instead of deleting it
+	if err := s.tokenRepo.MarkConsumed(ctx, token.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Lost a race against another rotation of the same token
+			return nil, s.revokeReusedFamily(ctx, token)
+		}
 		return nil, err
 	}
 
-	return s.generateAuthResponse(ctx, &token.User)
+	return s.generateAuthResponse(ctx, &token.User, client, token)
 }
 
 func (s *authService) Logout(ctx context.Context, refreshToken string) error {
-	return s.tokenRepo.DeleteByToken(ctx, refreshToken)
+	token, err := s.tokenRepo.FindByToken(ctx, s.tokenHasher.Hash(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.revocations.RevokeSession(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return s.tokenRepo.DeleteByFamilyID(ctx, token.FamilyID)
 }
 
-func (s *authService) GetCurrentUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+func (s *authService) GetCurrentUser(ctx context.Context, userID uuid.UUID) (*CurrentUser, error) {
 	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -138,22 +470,282 @@ func (s *authService) GetCurrentUser(ctx context.Context, userID uuid.UUID) (*mo
 		}
 		return nil, err
 	}
-	return user, nil
+
+	status, err := s.mfa.Status(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	authz, err := s.roles.Authorization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := &CurrentUser{User: user, MFA: status, Roles: authz.Roles, Permissions: authz.Permissions}
+	if status.LowRecoveryCodes {
+		current.Warnings = append(current.Warnings, WarningLowRecoveryCodes)
+	}
+	return current, nil
+}
+
+// ForgotPassword emails a password reset link. It succeeds whether or not
+// the address belongs to an account, so callers cannot probe for users;
+// the server queues mail (mail.QueueMailer) so that sending it does not
+// show in the response time either.
+func (s *authService) ForgotPassword(ctx context.Context, req ForgotPasswordRequest) error {
+	user, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.createActionToken(ctx, user.ID, model.ActionTokenPasswordReset, s.opts.PasswordResetExpiry)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to choose a new password. It expires in %s.\n\n%s\n\n"+
+				"If you did not request a password reset, you can ignore this email.\n",
+			user.Name, s.opts.PasswordResetExpiry, s.appLink("/reset-password", token),
+		),
+	})
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out everywhere
+func (s *authService) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	tokenHash := s.tokenHasher.Hash(req.Token)
+
+	// The new password is checked before the link is used up, so a
+	// rejected password can be corrected with the same link
+	token, err := s.actionTokenRepo.Find(ctx, model.ActionTokenPasswordReset, tokenHash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	if err := s.passwordPolicy.Check(req.Password, user.Email, user.Name); err != nil {
+		return err
+	}
+
+	if _, err := s.actionTokenRepo.Consume(ctx, model.ActionTokenPasswordReset, tokenHash); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	hashedPassword, err := s.passwords.Hash(req.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	if user.PasswordResetRequired {
+		if err := s.userRepo.SetPasswordResetRequired(ctx, user.ID, false); err != nil {
+			return err
+		}
+	}
+
+	// Other outstanding reset links must not work any more either
+	if err := s.actionTokenRepo.DeleteByUserID(ctx, user.ID, model.ActionTokenPasswordReset); err != nil {
+		return err
+	}
+	return s.revokeAllSessions(ctx, user.ID)
+}
+
+// VerifyEmail marks the user's email address as verified
+func (s *authService) VerifyEmail(ctx context.Context, req VerifyEmailRequest) error {
+	token, err := s.actionTokenRepo.Consume(ctx, model.ActionTokenEmailVerification, s.tokenHasher.Hash(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	if !user.IsEmailVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	return s.actionTokenRepo.DeleteByUserID(ctx, user.ID, model.ActionTokenEmailVerification)
+}
+
+// ResendVerification sends a new verification link to an unverified
+// account. Like ForgotPassword, it never reveals whether the account exists.
+func (s *authService) ResendVerification(ctx context.Context, req ResendVerificationRequest) error {
+	user, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.IsEmailVerified() {
+		return nil
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *authService) sendVerificationEmail(ctx context.Context, user *model.User) error {
+	token, err := s.createActionToken(ctx, user.ID, model.ActionTokenEmailVerification, s.opts.EmailVerificationExpiry)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to verify your email address. It expires in %s.\n\n%s\n",
+			user.Name, s.opts.EmailVerificationExpiry, s.appLink("/verify-email", token),
+		),
+	})
+	return nil
+}
+
+// revokeAllSessions signs the user out of every session, including access
+// tokens that have not expired yet
+func (s *authService) revokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	if err := s.revocations.RevokeUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.tokenRepo.DeleteByUserID(ctx, userID)
+}
+
+// createActionToken stores a new single-use token and returns its raw value
+func (s *authService) createActionToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	raw, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	token := &model.ActionToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: s.tokenHasher.Hash(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.actionTokenRepo.Create(ctx, token); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// appLink builds a frontend URL carrying a token
+func (s *authService) appLink(path, token string) string {
+	return s.opts.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendMail sends an email, logging failures instead of returning them so
+// that the response does not reveal whether an address is registered
+func (s *authService) sendMail(ctx context.Context, msg mail.Message) {
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "Failed to send email", "error", err, "subject", msg.Subject)
+	}
 }
 
-func (s *authService) generateAuthResponse(ctx context.Context, user *model.User) (*AuthResponse, error) {
+// revokeReusedFamily revokes every token in the family of a replayed
+// refresh token and records a security event
+func (s *authService) revokeReusedFamily(ctx context.Context, token *model.RefreshToken) error {
+	slog.WarnContext(ctx, "Refresh token reuse detected",
+		"event", "refresh_token_reuse",
+		"user_id", token.UserID,
+		"family_id", token.FamilyID,
+		"token_id", token.ID,
+	)
+
+	if err := s.revocations.RevokeSession(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeleteByFamilyID(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return ErrTokenReused
+}
+
+// generateAuthResponse issues a token pair. A nil parent starts a new
+// session; otherwise the new refresh token continues the parent's family.
+func (s *authService) generateAuthResponse(
+	ctx context.Context,
+	user *model.User,
+	client ClientInfo,
+	parent *model.RefreshToken,
+) (*AuthResponse, error) {
+	now := time.Now()
+	familyID := uuid.New()
+	authenticatedAt := now
+	if parent != nil {
+		familyID = parent.FamilyID
+		authenticatedAt = parent.AuthenticatedAt
+	}
+
+	authz, err := s.roles.Authorization(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate access token
-	accessToken, err := s.jwt.GenerateAccessToken(user.ID, user.Email)
+	accessToken, accessTokenJTI, err := s.jwt.GenerateAccessToken(auth.Claims{
+		UserID:        user.ID.String(),
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		SessionID:     familyID.String(),
+		Permissions:   authz.Permissions,
+		AuthzVersion:  authz.Version,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Generate and store refresh token
-	refreshTokenStr := s.jwt.GenerateRefreshToken()
+	// Generate refresh token and store only its hash
+	refreshTokenStr, err := s.jwt.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	userAgent := truncate(client.UserAgent, maxUserAgentLength)
 	refreshToken := &model.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshTokenStr,
-		ExpiresAt: time.Now().Add(s.jwt.GetRefreshExpiry()),
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       s.tokenHasher.Hash(refreshTokenStr),
+		AccessTokenJTI:  accessTokenJTI,
+		UserAgent:       userAgent,
+		IPAddress:       client.IPAddress,
+		DeviceLabel:     deviceLabel(userAgent),
+		AuthenticatedAt: authenticatedAt,
+		LastUsedAt:      now,
+		ExpiresAt:       now.Add(s.jwt.GetRefreshExpiry()),
 	}
 
 	if err := s.tokenRepo.Create(ctx, refreshToken); err != nil {
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/mail"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmailTaken     = errors.New("email address is already in use")
+	ErrEmailUnchanged = errors.New("email address is unchanged")
+)
+
+// EmailChangeService changes the email address users log in with. The new
+// address must be confirmed before it replaces the old one, and the old
+// address is told about the change with a link to undo it.
+type EmailChangeService interface {
+	// Request emails a confirmation link to the new address
+	Request(ctx context.Context, userID uuid.UUID, req ChangeEmailRequest) error
+	// Confirm switches to the new address, signs the user out everywhere
+	// and sends the previous address a link to revert the change
+	Confirm(ctx context.Context, req ConfirmEmailChangeRequest) error
+	// Revert restores the previous address and signs the user out everywhere
+	Revert(ctx context.Context, req RevertEmailChangeRequest) error
+}
+
+// ChangeEmailRequest asks for a new address, confirmed with the current
+// password. Accounts without a password leave it empty.
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=255"`
+	Password string `json:"password"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type RevertEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// EmailChangeOptions configures EmailChangeService
+type EmailChangeOptions struct {
+	// AppURL is the frontend base URL; links open AppURL/confirm-email-change
+	// and AppURL/revert-email-change
+	AppURL string
+	// Expiry bounds how long the new address has to confirm the change
+	Expiry time.Duration
+	// RevertExpiry bounds how long the previous address can undo the change.
+	// The previous address stays reserved for the account until then.
+	RevertExpiry time.Duration
+	// DeletedEmailHold is AuthOptions.DeletedEmailHold
+	DeletedEmailHold time.Duration
+}
+
+type emailChangeService struct {
+	userRepo        repository.UserRepository
+	actionTokenRepo repository.ActionTokenRepository
+	sessions        SessionService
+	passwords       auth.PasswordHasher
+	tokenHasher     *auth.TokenHasher
+	mailer          mail.Mailer
+	opts            EmailChangeOptions
+}
+
+func NewEmailChangeService(
+	userRepo repository.UserRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	sessions SessionService,
+	passwords auth.PasswordHasher,
+	tokenHasher *auth.TokenHasher,
+	mailer mail.Mailer,
+	opts EmailChangeOptions,
+) EmailChangeService {
+	return &emailChangeService{
+		userRepo:        userRepo,
+		actionTokenRepo: actionTokenRepo,
+		sessions:        sessions,
+		passwords:       passwords,
+		tokenHasher:     tokenHasher,
+		mailer:          mailer,
+		opts:            opts,
+	}
+}
+
+func (s *emailChangeService) Request(ctx context.Context, userID uuid.UUID, req ChangeEmailRequest) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	if user.Password != "" {
+		ok, _, err := s.passwords.Verify(req.Password, user.Password)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidCredentials
+		}
+	}
+
+	if auth.CanonicalEmail(req.NewEmail) == user.EmailCanonical {
+		return ErrEmailUnchanged
+	}
+	if err := s.checkAvailable(ctx, user.ID, req.NewEmail); err != nil {
+		return err
+	}
+
+	// Only the latest request can be confirmed
+	if err := s.actionTokenRepo.DeleteByUserID(ctx, user.ID, model.ActionTokenEmailChange); err != nil {
+		return err
+	}
+	token, err := s.createToken(ctx, user.ID, model.ActionTokenEmailChange, req.NewEmail, s.opts.Expiry)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(ctx, mail.Message{
+		To:      req.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to use this address to sign in. It expires in %s.\n\n%s\n\n"+
+				"Until then you keep signing in with your current address. "+
+				"If you did not ask for this, you can ignore this email.\n",
+			user.Name, s.opts.Expiry, s.appLink("/confirm-email-change", token),
+		),
+	})
+
+	slog.InfoContext(ctx, "Email change requested", "event", "email_change_requested", "user_id", user.ID)
+	return nil
+}
+
+func (s *emailChangeService) Confirm(ctx context.Context, req ConfirmEmailChangeRequest) error {
+	token, err := s.actionTokenRepo.Consume(ctx, model.ActionTokenEmailChange, s.tokenHasher.Hash(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	// The address may have been taken since the request
+	if err := s.checkAvailable(ctx, user.ID, *token.Email); err != nil {
+		return err
+	}
+
+	previous := user.Email
+	if err := s.switchEmail(ctx, user, *token.Email); err != nil {
+		return err
+	}
+
+	// Other reset links went to an address that is no longer the user's
+	if err := s.actionTokenRepo.DeleteByUserID(ctx, user.ID, model.ActionTokenPasswordReset); err != nil {
+		return err
+	}
+	// Earlier revert links stay valid, so a chain of changes cannot cut
+	// the original address off
+	revert, err := s.createToken(ctx, user.ID, model.ActionTokenEmailChangeRevert, previous, s.opts.RevertExpiry)
+	if err != nil {
+		return err
+	}
+	if err := s.sessions.RevokeAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.sendMail(ctx, mail.Message{
+		To:      previous,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe email address of your account was changed to %s and you were signed out everywhere.\n\n"+
+				"If you did not make this change, open the link below within %s to switch back to this address, "+
+				"then reset your password.\n\n%s\n",
+			user.Name, user.Email, s.opts.RevertExpiry, s.appLink("/revert-email-change", revert),
+		),
+	})
+
+	slog.InfoContext(ctx, "Email changed", "event", "email_changed", "user_id", user.ID)
+	return nil
+}
+
+func (s *emailChangeService) Revert(ctx context.Context, req RevertEmailChangeRequest) error {
+	token, err := s.actionTokenRepo.Consume(ctx, model.ActionTokenEmailChangeRevert, s.tokenHasher.Hash(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	if user.EmailCanonical != auth.CanonicalEmail(*token.Email) {
+		if err := s.switchEmail(ctx, user, *token.Email); err != nil {
+			return err
+		}
+	}
+
+	// Whoever changed the address must not be able to finish another
+	// change or reset the password through the address they set
+	for _, purpose := range []string{model.ActionTokenEmailChange, model.ActionTokenEmailChangeRevert, model.ActionTokenPasswordReset} {
+		if err := s.actionTokenRepo.DeleteByUserID(ctx, user.ID, purpose); err != nil {
+			return err
+		}
+	}
+	if err := s.sessions.RevokeAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	slog.WarnContext(ctx, "Email change reverted", "event", "email_change_reverted", "user_id", user.ID)
+	return nil
+}
+
+func (s *emailChangeService) checkAvailable(ctx context.Context, userID uuid.UUID, email string) error {
+	return emailAvailable(ctx, s.userRepo, s.actionTokenRepo, userID, email, s.opts.DeletedEmailHold)
+}
+
+// emailAvailable returns ErrEmailTaken if email belongs to another active
+// account, is held after another account's deletion, or is reserved for
+// reverting another account's email change
+func emailAvailable(
+	ctx context.Context,
+	userRepo repository.UserRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	userID uuid.UUID,
+	email string,
+	hold time.Duration,
+) error {
+	existing, err := userRepo.FindByEmail(ctx, email)
+	if err == nil && existing.ID != userID {
+		return ErrEmailTaken
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	held, err := emailHeld(ctx, userRepo, email, hold, userID)
+	if err != nil {
+		return err
+	}
+	reserved, err := emailReserved(ctx, actionTokenRepo, email, userID)
+	if err != nil {
+		return err
+	}
+	if held || reserved {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// switchEmail moves the user to a confirmed address. The unique index on
+// active addresses settles a race with a registration for the same one.
+func (s *emailChangeService) switchEmail(ctx context.Context, user *model.User, email string) error {
+	now := time.Now()
+	user.Email = email
+	user.EmailVerifiedAt = &now
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *emailChangeService) createToken(ctx context.Context, userID uuid.UUID, purpose, email string, ttl time.Duration) (string, error) {
+	raw, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.actionTokenRepo.Create(ctx, &model.ActionToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: s.tokenHasher.Hash(raw),
+		ExpiresAt: time.Now().Add(ttl),
+		Email:     &email,
+	}); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (s *emailChangeService) appLink(path, token string) string {
+	return s.opts.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (s *emailChangeService) sendMail(ctx context.Context, msg mail.Message) {
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "Failed to send email", "error", err, "subject", msg.Subject)
+	}
+}
+
+// emailReserved reports whether email can still be restored by the revert
+// link of another account's email change, so nobody may claim it meanwhile.
+// Pass uuid.Nil for a new account.
+func emailReserved(ctx context.Context, actionTokenRepo repository.ActionTokenRepository, email string, userID uuid.UUID) (bool, error) {
+	token, err := actionTokenRepo.FindByEmail(ctx, model.ActionTokenEmailChangeRevert, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return token.UserID != userID, nil
+}
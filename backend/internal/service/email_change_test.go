@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+var emailChangeURL = regexp.MustCompile(`https://\S+/(confirm|revert)-email-change\?token=\S+`)
+
+// mailedToken returns the token in the last email, which must go to "to"
+func (e *testEnv) mailedToken(t *testing.T, to string) string {
+	t.Helper()
+	msg, ok := e.mailer.Last()
+	if !ok || msg.To != to {
+		t.Fatalf("last email = %+v, want one to %s", msg, to)
+	}
+	link, err := url.Parse(emailChangeURL.FindString(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.Query().Get("token")
+}
+
+func TestEmailChange(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	session, _ := env.login(t, user.Email, "correct horse battery")
+
+	req := ChangeEmailRequest{NewEmail: "alice@wonderland.example", Password: "correct horse battery"}
+	if err := env.emailChanges.Request(ctx, user.ID, req); err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	confirm := env.mailedToken(t, req.NewEmail)
+
+	// Nothing changes until the new address confirms
+	if _, err := env.users.FindByEmail(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("address changed before confirmation: %v", err)
+	}
+
+	if err := env.emailChanges.Confirm(ctx, ConfirmEmailChangeRequest{Token: confirm}); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	changed, err := env.users.FindByID(ctx, user.ID)
+	if err != nil || changed.Email != req.NewEmail || !changed.IsEmailVerified() {
+		t.Fatalf("user after Confirm = %+v, %v", changed, err)
+	}
+	if !env.revocations.revokedUser(user.ID) {
+		t.Error("Confirm did not sign the user out")
+	}
+	if _, err := env.auth.Refresh(ctx, session.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("session survived the email change")
+	}
+	if err := env.emailChanges.Confirm(ctx, ConfirmEmailChangeRequest{Token: confirm}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second Confirm: err = %v, want ErrInvalidToken", err)
+	}
+	env.login(t, req.NewEmail, "correct horse battery")
+
+	// The previous address stays reserved for the revert link
+	register := RegisterRequest{Email: "alice@example.com", Password: "tea party at four", Name: "Mallory"}
+	if _, err := env.auth.Register(ctx, register, ClientInfo{}); !errors.Is(err, ErrUserAlreadyExists) {
+		t.Errorf("Register with the previous address: err = %v, want ErrUserAlreadyExists", err)
+	}
+
+	revert := env.mailedToken(t, "alice@example.com")
+	if err := env.emailChanges.Revert(ctx, RevertEmailChangeRequest{Token: revert}); err != nil {
+		t.Fatalf("Revert: %v", err)
+	}
+	reverted, err := env.users.FindByID(ctx, user.ID)
+	if err != nil || reverted.Email != "alice@example.com" {
+		t.Fatalf("user after Revert = %+v, %v", reverted, err)
+	}
+	if err := env.emailChanges.Revert(ctx, RevertEmailChangeRequest{Token: revert}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second Revert: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestEmailChangeRevertKeepsTypedAddress(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "Alice@Example.com", "correct horse battery")
+
+	req := ChangeEmailRequest{NewEmail: "alice@wonderland.example", Password: "correct horse battery"}
+	if err := env.emailChanges.Request(ctx, user.ID, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.emailChanges.Confirm(ctx, ConfirmEmailChangeRequest{Token: env.mailedToken(t, req.NewEmail)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reservation holds under any spelling of the previous address
+	register := RegisterRequest{Email: "alice@EXAMPLE.com", Password: "tea party at four", Name: "Mallory"}
+	if _, err := env.auth.Register(ctx, register, ClientInfo{}); !errors.Is(err, ErrUserAlreadyExists) {
+		t.Errorf("Register with the previous address: err = %v, want ErrUserAlreadyExists", err)
+	}
+
+	if err := env.emailChanges.Revert(ctx, RevertEmailChangeRequest{Token: env.mailedToken(t, user.Email)}); err != nil {
+		t.Fatalf("Revert: %v", err)
+	}
+	reverted, err := env.users.FindByID(ctx, user.ID)
+	if err != nil || reverted.Email != "Alice@Example.com" {
+		t.Fatalf("user after Revert = %+v, %v; want the address as typed", reverted, err)
+	}
+}
+
+func TestEmailChangeRejected(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	alice := env.createUser(t, "alice@example.com", "correct horse battery")
+	env.createUser(t, "bob@example.com", "correct horse battery")
+
+	tests := []struct {
+		name string
+		req  ChangeEmailRequest
+		want error
+	}{
+		{"wrong password", ChangeEmailRequest{NewEmail: "alice@wonderland.example", Password: "wrong"}, ErrInvalidCredentials},
+		{"same address", ChangeEmailRequest{NewEmail: "Alice@Example.com", Password: "correct horse battery"}, ErrEmailUnchanged},
+		{"taken address", ChangeEmailRequest{NewEmail: "bob@example.com", Password: "correct horse battery"}, ErrEmailTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := env.emailChanges.Request(ctx, alice.ID, tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("Request: err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailChangeTakenBeforeConfirm(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	req := ChangeEmailRequest{NewEmail: "alice@wonderland.example", Password: "correct horse battery"}
+	if err := env.emailChanges.Request(ctx, user.ID, req); err != nil {
+		t.Fatal(err)
+	}
+	confirm := env.mailedToken(t, req.NewEmail)
+	env.createUser(t, req.NewEmail, "tea party at four")
+
+	if err := env.emailChanges.Confirm(ctx, ConfirmEmailChangeRequest{Token: confirm}); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("Confirm: err = %v, want ErrEmailTaken", err)
+	}
+	unchanged, err := env.users.FindByID(ctx, user.ID)
+	if err != nil || unchanged.Email != "alice@example.com" {
+		t.Errorf("user after failed Confirm = %+v, %v", unchanged, err)
+	}
+	if env.revocations.revokedUser(user.ID) {
+		t.Error("failed Confirm signed the user out")
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEmailIdentityIsCanonical(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	req := RegisterRequest{Email: "Alice@Bücher.example", Password: "correct horse battery", Name: "Alice"}
+	res, err := env.auth.Register(ctx, req, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if res.User.Email != req.Email {
+		t.Errorf("Email = %q, want it kept as typed", res.User.Email)
+	}
+
+	// The same address in another case, Unicode form or domain encoding
+	for _, email := range []string{"alice@bücher.example", "ALICE@BÜCHER.EXAMPLE", "alice@xn--bcher-kva.example"} {
+		req.Email = email
+		if _, err := env.auth.Register(ctx, req, ClientInfo{}); !errors.Is(err, ErrUserAlreadyExists) {
+			t.Errorf("Register(%q): err = %v, want ErrUserAlreadyExists", email, err)
+		}
+		login, err := env.auth.Login(ctx, LoginRequest{Email: email, Password: "correct horse battery"}, ClientInfo{})
+		if err != nil || login.User.ID != res.User.ID {
+			t.Errorf("Login(%q) = %v, want the registered user", email, err)
+		}
+	}
+}
+
+func TestLoginThrottleIgnoresEmailCase(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	stopClock(env)
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	// Spelling the address differently does not reset the count
+	emails := []string{"alice@example.com", "Alice@example.com", "ALICE@EXAMPLE.COM", "alice@Example.com"}
+	for i := 0; i < testThrottleOptions.FreeAttempts+1; i++ {
+		_, err := env.auth.Login(ctx, LoginRequest{Email: emails[i%len(emails)], Password: "wrong"}, ClientInfo{IPAddress: "192.0.2.1"})
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	var rateErr *RateLimitError
+	_, err := env.auth.Login(ctx, LoginRequest{Email: " ALICE@example.com", Password: "correct horse battery"}, ClientInfo{IPAddress: "192.0.2.2"})
+	if !errors.As(err, &rateErr) {
+		t.Fatalf("Login for %s after failures under other spellings: err = %v, want RateLimitError", user.Email, err)
+	}
+}
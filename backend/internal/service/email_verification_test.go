@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+var verifyEmailURL = regexp.MustCompile(`https://\S+/verify-email\?token=\S+`)
+
+// verificationToken returns the token of the last verification link, which
+// must have been mailed to "to"
+func (e *testEnv) verificationToken(t *testing.T, to string) string {
+	t.Helper()
+	msg, ok := e.mailer.Last()
+	if !ok || msg.To != to {
+		t.Fatalf("last email = %+v, want one to %s", msg, to)
+	}
+	link, err := url.Parse(verifyEmailURL.FindString(msg.Body))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("no verification link in %q", msg.Body)
+	}
+	return link.Query().Get("token")
+}
+
+func TestEmailVerification(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	req := RegisterRequest{Email: "alice@example.com", Password: "correct horse battery", Name: "Alice"}
+	res, err := env.auth.Register(ctx, req, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if res.User.IsEmailVerified() {
+		t.Fatal("new user is already verified")
+	}
+	first := env.verificationToken(t, req.Email)
+
+	if err := env.auth.ResendVerification(ctx, ResendVerificationRequest{Email: req.Email}); err != nil {
+		t.Fatalf("ResendVerification: %v", err)
+	}
+	second := env.verificationToken(t, req.Email)
+
+	// Either outstanding link works
+	if err := env.auth.VerifyEmail(ctx, VerifyEmailRequest{Token: first}); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	user, err := env.users.FindByEmail(ctx, req.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsEmailVerified() {
+		t.Error("VerifyEmail did not set EmailVerifiedAt")
+	}
+
+	// The used link, and the other one mailed for the same address, are gone
+	for name, token := range map[string]string{"used": first, "resent": second} {
+		if err := env.auth.VerifyEmail(ctx, VerifyEmailRequest{Token: token}); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s link: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestResendVerificationSendsNothing(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	verified := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	for _, email := range []string{verified.Email, "nobody@example.com"} {
+		if err := env.auth.ResendVerification(ctx, ResendVerificationRequest{Email: email}); err != nil {
+			t.Errorf("ResendVerification(%s): %v", email, err)
+		}
+	}
+	if msg, ok := env.mailer.Last(); ok {
+		t.Errorf("sent %+v, want no email", msg)
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.auth.(*authService).opts.RequireVerifiedEmail = true
+
+	req := RegisterRequest{Email: "alice@example.com", Password: "correct horse battery", Name: "Alice"}
+	res, err := env.auth.Register(ctx, req, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if !res.EmailVerificationRequired || res.AccessToken != "" {
+		t.Errorf("Register = %+v, want no session until verified", res)
+	}
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: req.Email, Password: req.Password}, ClientInfo{}); !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("Login before verifying: err = %v, want ErrEmailNotVerified", err)
+	}
+
+	if err := env.auth.VerifyEmail(ctx, VerifyEmailRequest{Token: env.verificationToken(t, req.Email)}); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	env.login(t, req.Email, req.Password)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// AccountLockedError is returned when an account has been locked after
+// too many failed logins
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account locked, retry after %s", e.RetryAfter)
+}
+
+// LoginThrottleService slows down password guessing. Failures are counted
+// per account and per client IP; after a few free attempts each further
+// failure doubles the wait before the next attempt, and reaching the
+// lockout threshold blocks the account or IP for a fixed duration.
+type LoginThrottleService interface {
+	// Check returns *AccountLockedError for a locked account and
+	// *RateLimitError while the account or IP has to wait
+	Check(ctx context.Context, email, ip string) error
+	RecordFailure(ctx context.Context, email, ip string) error
+	// RecordSuccess forgets the account's failures. The IP's failures
+	// stay, so one valid account does not reset guessing on others.
+	RecordSuccess(ctx context.Context, email string) error
+	Unlock(ctx context.Context, email string) error
+	UnlockIP(ctx context.Context, ip string) error
+}
+
+// LoginThrottleOptions configures LoginThrottleService
+type LoginThrottleOptions struct {
+	// FreeAttempts is the number of failures allowed without any wait
+	FreeAttempts int
+	// BackoffBase is the wait after the first failure past FreeAttempts;
+	// it doubles with every further failure up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// LockoutThreshold failures lock an account for LockoutDuration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// IPLockoutThreshold failures from one IP block it for LockoutDuration
+	IPLockoutThreshold int
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+type loginThrottleService struct {
+	attemptRepo repository.LoginAttemptRepository
+	opts        LoginThrottleOptions
+	now         func() time.Time
+}
+
+func NewLoginThrottleService(attemptRepo repository.LoginAttemptRepository, opts LoginThrottleOptions) LoginThrottleService {
+	return &loginThrottleService{
+		attemptRepo: attemptRepo,
+		opts:        opts,
+		now:         time.Now,
+	}
+}
+
+// accountKey is derived from the submitted email whether or not an account
+// exists, so responses do not reveal which emails are registered
+func accountKey(email string) string {
+	return "account:" + auth.CanonicalEmail(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (s *loginThrottleService) Check(ctx context.Context, email, ip string) error {
+	attempt, err := s.find(ctx, accountKey(email))
+	if err != nil {
+		return err
+	}
+	if wait, locked := s.wait(attempt, s.opts.LockoutThreshold); wait > 0 {
+		if locked {
+			return &AccountLockedError{RetryAfter: wait}
+		}
+		return &RateLimitError{RetryAfter: wait}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	attempt, err = s.find(ctx, ipKey(ip))
+	if err != nil {
+		return err
+	}
+	if wait, _ := s.wait(attempt, s.opts.IPLockoutThreshold); wait > 0 {
+		return &RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (s *loginThrottleService) RecordFailure(ctx context.Context, email, ip string) error {
+	now := s.now()
+	since := now.Add(-s.opts.Window)
+
+	attempt, err := s.attemptRepo.RecordFailure(ctx, accountKey(email), now, since)
+	if err != nil {
+		return err
+	}
+	if attempt.Failures == s.opts.LockoutThreshold {
+		slog.InfoContext(ctx, "Account locked after failed logins",
+			"event", "account_locked",
+			"email", email,
+			"ip", ip,
+			"failures", attempt.Failures,
+		)
+	}
+
+	if ip == "" {
+		return nil
+	}
+	attempt, err = s.attemptRepo.RecordFailure(ctx, ipKey(ip), now, since)
+	if err != nil {
+		return err
+	}
+	if attempt.Failures == s.opts.IPLockoutThreshold {
+		slog.InfoContext(ctx, "Login IP blocked after failed logins",
+			"event", "login_ip_blocked",
+			"ip", ip,
+			"failures", attempt.Failures,
+		)
+	}
+	return nil
+}
+
+func (s *loginThrottleService) RecordSuccess(ctx context.Context, email string) error {
+	return s.attemptRepo.Delete(ctx, accountKey(email))
+}
+
+func (s *loginThrottleService) Unlock(ctx context.Context, email string) error {
+	if err := s.attemptRepo.Delete(ctx, accountKey(email)); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Account unlocked", "event", "account_unlocked", "email", email)
+	return nil
+}
+
+func (s *loginThrottleService) UnlockIP(ctx context.Context, ip string) error {
+	if err := s.attemptRepo.Delete(ctx, ipKey(ip)); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Login IP unblocked", "event", "login_ip_unblocked", "ip", ip)
+	return nil
+}
+
+func (s *loginThrottleService) find(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	attempt, err := s.attemptRepo.Find(ctx, key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// wait returns how long the key has to wait before the next attempt, and
+// whether that is because it reached lockThreshold. A key that fails again
+// after its lockout ends is locked again straight away.
+func (s *loginThrottleService) wait(attempt *model.LoginAttempt, lockThreshold int) (time.Duration, bool) {
+	now := s.now()
+	if attempt == nil || attempt.LastFailureAt.Before(now.Add(-s.opts.Window)) {
+		return 0, false
+	}
+
+	var delay time.Duration
+	locked := lockThreshold > 0 && attempt.Failures >= lockThreshold
+	switch {
+	case locked:
+		delay = s.opts.LockoutDuration
+	case attempt.Failures > s.opts.FreeAttempts:
+		// Cap the shift so the doubling cannot overflow
+		shift := min(attempt.Failures-s.opts.FreeAttempts-1, 30)
+		delay = min(s.opts.BackoffBase<<shift, s.opts.BackoffMax)
+	}
+
+	wait := attempt.LastFailureAt.Add(delay).Sub(now)
+	if wait <= 0 {
+		return 0, false
+	}
+	return wait, locked
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// stopClock freezes the throttle's clock; the returned function moves it on
+func stopClock(env *testEnv) func(time.Duration) {
+	now := time.Now()
+	env.throttle.(*loginThrottleService).now = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLoginBackoffAndLockout(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	advance := stopClock(env)
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	client := ClientInfo{IPAddress: "192.0.2.1"}
+	wrong := LoginRequest{Email: user.Email, Password: "wrong"}
+	right := LoginRequest{Email: user.Email, Password: "correct horse battery"}
+
+	// The free attempts and the first failure past them are not delayed
+	for i := 0; i <= testThrottleOptions.FreeAttempts; i++ {
+		if _, err := env.auth.Login(ctx, wrong, client); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	// Even the right password has to wait out the backoff
+	var rateErr *RateLimitError
+	if _, err := env.auth.Login(ctx, right, client); !errors.As(err, &rateErr) || rateErr.RetryAfter != time.Second {
+		t.Fatalf("err = %v, want RateLimitError after 1s", err)
+	}
+
+	advance(time.Second)
+	if _, err := env.auth.Login(ctx, wrong, client); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+
+	var lockedErr *AccountLockedError
+	advance(time.Minute)
+	if _, err := env.auth.Login(ctx, right, client); !errors.As(err, &lockedErr) {
+		t.Fatalf("err = %v, want AccountLockedError", err)
+	}
+	if want := testThrottleOptions.LockoutDuration - time.Minute; lockedErr.RetryAfter != want {
+		t.Errorf("RetryAfter = %s, want %s", lockedErr.RetryAfter, want)
+	}
+
+	if err := env.throttle.Unlock(ctx, "Alice@Example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.auth.Login(ctx, right, client); err != nil {
+		t.Fatalf("Login after unlock: %v", err)
+	}
+}
+
+func TestLoginSuccessResetsFailures(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	stopClock(env)
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	// Each round comes from its own IP, whose failures are not reset
+	for round := 0; round < 2; round++ {
+		client := ClientInfo{IPAddress: fmt.Sprintf("192.0.2.%d", round+1)}
+		for i := 0; i < testThrottleOptions.FreeAttempts; i++ {
+			if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "wrong"}, client); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("round %d: err = %v, want ErrInvalidCredentials", round, err)
+			}
+		}
+		if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, client); err != nil {
+			t.Fatalf("round %d: Login = %v", round, err)
+		}
+	}
+}
+
+func TestLoginThrottleUnknownEmail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	advance := stopClock(env)
+	req := LoginRequest{Email: "nobody@example.com", Password: "wrong"}
+
+	for i := 0; i < testThrottleOptions.LockoutThreshold; i++ {
+		if _, err := env.auth.Login(ctx, req, ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+		advance(time.Minute)
+	}
+
+	// Unknown addresses lock like real ones, so lockouts do not reveal
+	// which emails are registered
+	var lockedErr *AccountLockedError
+	if _, err := env.auth.Login(ctx, req, ClientInfo{}); !errors.As(err, &lockedErr) {
+		t.Fatalf("err = %v, want AccountLockedError", err)
+	}
+}
+
+func TestLoginThrottlePerIP(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	advance := stopClock(env)
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	client := ClientInfo{IPAddress: "192.0.2.1"}
+
+	// One failure each on many accounts stays under every account limit
+	for i := 0; i < testThrottleOptions.IPLockoutThreshold; i++ {
+		req := LoginRequest{Email: fmt.Sprintf("user%d@example.com", i), Password: "wrong"}
+		if _, err := env.auth.Login(ctx, req, client); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+		advance(time.Minute)
+	}
+
+	var rateErr *RateLimitError
+	right := LoginRequest{Email: user.Email, Password: "correct horse battery"}
+	if _, err := env.auth.Login(ctx, right, client); !errors.As(err, &rateErr) {
+		t.Fatalf("err = %v, want RateLimitError", err)
+	}
+	if _, err := env.auth.Login(ctx, right, ClientInfo{IPAddress: "192.0.2.2"}); err != nil {
+		t.Fatalf("Login from another IP: %v", err)
+	}
+
+	if err := env.throttle.UnlockIP(ctx, client.IPAddress); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.auth.Login(ctx, right, client); err != nil {
+		t.Fatalf("Login after unblocking: %v", err)
+	}
+}
+
+const testMFACode = "123456"
+
+// codeMFA is an MFAService under which every user has a second factor
+// whose only right code is testMFACode
+type codeMFA struct {
+	MFAService
+	mu         sync.Mutex
+	challenges map[string]uuid.UUID
+}
+
+func (*codeMFA) IsEnabled(context.Context, uuid.UUID) (bool, error) { return true, nil }
+
+func (m *codeMFA) CreateChallenge(ctx context.Context, userID uuid.UUID) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token := uuid.NewString()
+	m.challenges[token] = userID
+	return token, nil
+}
+
+func (m *codeMFA) ChallengeUser(ctx context.Context, token string) (uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	userID, ok := m.challenges[token]
+	if !ok {
+		return uuid.Nil, ErrInvalidMFAChallenge
+	}
+	return userID, nil
+}
+
+func (m *codeMFA) VerifyChallenge(ctx context.Context, token, code string) (uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	userID, ok := m.challenges[token]
+	if !ok {
+		return uuid.Nil, ErrInvalidMFAChallenge
+	}
+	if code != testMFACode {
+		return uuid.Nil, ErrInvalidMFACode
+	}
+	delete(m.challenges, token)
+	return userID, nil
+}
+
+func TestWrongMFACodesLockAccount(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	advance := stopClock(env)
+	env.auth.(*authService).mfa = &codeMFA{challenges: make(map[string]uuid.UUID)}
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	client := ClientInfo{IPAddress: "192.0.2.1"}
+	login := func() string {
+		t.Helper()
+		res, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, client)
+		if err != nil || res.MFAToken == "" {
+			t.Fatalf("Login = %+v, %v; want an MFA challenge", res, err)
+		}
+		return res.MFAToken
+	}
+
+	// Opened before the lockout, then tried with the right code after it
+	early := login()
+
+	// A fresh challenge per password login must not reset the count
+	for i := 0; i < testThrottleOptions.LockoutThreshold; i++ {
+		_, err := env.auth.VerifyMFA(ctx, VerifyMFARequest{MFAToken: login(), Code: "000000"}, client)
+		if !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidMFACode", i+1, err)
+		}
+		advance(time.Minute)
+	}
+
+	var lockedErr *AccountLockedError
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, client); !errors.As(err, &lockedErr) {
+		t.Fatalf("Login err = %v, want AccountLockedError", err)
+	}
+	if _, err := env.auth.VerifyMFA(ctx, VerifyMFARequest{MFAToken: early, Code: testMFACode}, client); !errors.As(err, &lockedErr) {
+		t.Fatalf("VerifyMFA err = %v, want AccountLockedError", err)
+	}
+
+	// The refused attempt did not use up the challenge
+	advance(testThrottleOptions.LockoutDuration)
+	res, err := env.auth.VerifyMFA(ctx, VerifyMFARequest{MFAToken: early, Code: testMFACode}, client)
+	if err != nil || res.AccessToken == "" {
+		t.Fatalf("VerifyMFA after the lockout = %+v, %v", res, err)
+	}
+	if attempt, _ := env.throttle.(*loginThrottleService).find(ctx, accountKey(user.Email)); attempt != nil {
+		t.Errorf("failures after a completed login = %+v, want none", attempt)
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/mail"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/ratelimit"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidMagicLink = errors.New("invalid magic link")
+)
+
+// RateLimitError is returned when a caller has to wait before trying again
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// MagicLinkService signs users in with single-use links sent to their
+// email address. Consume only identifies the user; AuthService.LoginExternal
+// starts the session, so a second factor still applies.
+//
+// A link carries a random token whose hash is stored as an action token
+// rather than a signed payload: a signature alone cannot make a link work
+// only once or cancel the user's other links when one is used, and both
+// need server-side state anyway.
+type MagicLinkService interface {
+	Send(ctx context.Context, req MagicLinkRequest, client ClientInfo) (string, error)
+	Consume(ctx context.Context, req ConsumeMagicLinkRequest, binding string) (uuid.UUID, error)
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// MagicLinkOptions configures MagicLinkService
+type MagicLinkOptions struct {
+	// AppURL is the frontend base URL; links open AppURL/magic-link
+	AppURL string
+	Expiry time.Duration
+	// BindBrowser makes a link work only in the browser that requested it
+	BindBrowser bool
+}
+
+type magicLinkService struct {
+	userRepo        repository.UserRepository
+	actionTokenRepo repository.ActionTokenRepository
+	tokenHasher     *auth.TokenHasher
+	mailer          mail.Mailer
+	emailLimiter    ratelimit.Limiter
+	ipLimiter       ratelimit.Limiter
+	opts            MagicLinkOptions
+}
+
+func NewMagicLinkService(
+	userRepo repository.UserRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	tokenHasher *auth.TokenHasher,
+	mailer mail.Mailer,
+	emailLimiter ratelimit.Limiter,
+	ipLimiter ratelimit.Limiter,
+	opts MagicLinkOptions,
+) MagicLinkService {
+	return &magicLinkService{
+		userRepo:        userRepo,
+		actionTokenRepo: actionTokenRepo,
+		tokenHasher:     tokenHasher,
+		mailer:          mailer,
+		emailLimiter:    emailLimiter,
+		ipLimiter:       ipLimiter,
+		opts:            opts,
+	}
+}
+
+// Send emails a login link. Like ForgotPassword it behaves the same
+// whether or not the address belongs to an account, and the mailer queues
+// the message so the response time does not reveal it either. With BindBrowser it
+// returns the value the caller must keep in the browser for Consume.
+func (s *magicLinkService) Send(ctx context.Context, req MagicLinkRequest, client ClientInfo) (string, error) {
+	if err := s.allow(ctx, s.ipLimiter, "ip:"+client.IPAddress); err != nil {
+		return "", err
+	}
+	if err := s.allow(ctx, s.emailLimiter, "email:"+auth.CanonicalEmail(req.Email)); err != nil {
+		return "", err
+	}
+
+	var binding string
+	var bindingHash *string
+	if s.opts.BindBrowser {
+		var err error
+		if binding, err = auth.GenerateOpaqueToken(); err != nil {
+			return "", err
+		}
+		hash := s.tokenHasher.Hash(binding)
+		bindingHash = &hash
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return binding, nil
+		}
+		return "", err
+	}
+
+	raw, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.actionTokenRepo.Create(ctx, &model.ActionToken{
+		UserID:      user.ID,
+		Purpose:     model.ActionTokenMagicLink,
+		TokenHash:   s.tokenHasher.Hash(raw),
+		ExpiresAt:   time.Now().Add(s.opts.Expiry),
+		BindingHash: bindingHash,
+	}); err != nil {
+		return "", err
+	}
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to sign in. It expires in %s and works only once.\n\n%s\n\n"+
+				"If you did not try to sign in, you can ignore this email.\n",
+			user.Name, s.opts.Expiry, s.opts.AppURL+"/magic-link?token="+url.QueryEscape(raw),
+		),
+	}
+	if s.opts.BindBrowser {
+		msg.Body += "The link only works in the browser you requested it from.\n"
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "Failed to send email", "error", err, "subject", msg.Subject)
+	}
+	return binding, nil
+}
+
+// Consume redeems a link and returns the user it was sent to. The link is
+// used up even if the browser binding does not match.
+func (s *magicLinkService) Consume(ctx context.Context, req ConsumeMagicLinkRequest, binding string) (uuid.UUID, error) {
+	token, err := s.actionTokenRepo.Consume(ctx, model.ActionTokenMagicLink, s.tokenHasher.Hash(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidMagicLink
+		}
+		return uuid.Nil, err
+	}
+	if token.BindingHash != nil {
+		if binding == "" || subtle.ConstantTimeCompare([]byte(s.tokenHasher.Hash(binding)), []byte(*token.BindingHash)) != 1 {
+			slog.WarnContext(ctx, "Magic link opened in another browser",
+				"event", "magic_link_binding_mismatch",
+				"user_id", token.UserID,
+			)
+			return uuid.Nil, ErrInvalidMagicLink
+		}
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidMagicLink
+		}
+		return uuid.Nil, err
+	}
+
+	// Receiving the link proves the user controls the address
+	if !user.IsEmailVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	if err := s.actionTokenRepo.DeleteByUserID(ctx, user.ID, model.ActionTokenMagicLink); err != nil {
+		return uuid.Nil, err
+	}
+	return user.ID, nil
+}
+
+func (s *magicLinkService) allow(ctx context.Context, limiter ratelimit.Limiter, key string) error {
+	ok, retryAfter, err := limiter.Allow(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
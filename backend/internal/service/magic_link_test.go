@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/mail"
+	"github.com/ablaze/gonexttemp-backend/internal/ratelimit"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+)
+
+var magicLinkURL = regexp.MustCompile(`https://\S+/magic-link\?token=\S+`)
+
+func newMagicLinkService(t *testing.T, env *testEnv, bind bool) (MagicLinkService, *mail.MemoryMailer) {
+	t.Helper()
+	return newMagicLinkReplica(env, repositorytest.NewRateLimits(), bind)
+}
+
+// newMagicLinkReplica builds a service counting requests in limits, as a
+// replica sharing the database would
+func newMagicLinkReplica(env *testEnv, limits *repositorytest.RateLimits, bind bool) (MagicLinkService, *mail.MemoryMailer) {
+	mailer := mail.NewMemoryMailer()
+	return NewMagicLinkService(
+		env.users,
+		repositorytest.NewActionTokens(),
+		env.hasher,
+		mailer,
+		ratelimit.NewStore(limits, "magic_link:", 2, time.Minute),
+		ratelimit.NewStore(limits, "magic_link:", 100, time.Minute),
+		MagicLinkOptions{AppURL: testOrigin, Expiry: 15 * time.Minute, BindBrowser: bind},
+	), mailer
+}
+
+// sentMagicLinkToken returns the token of the last link emailed
+func sentMagicLinkToken(t *testing.T, mailer *mail.MemoryMailer) string {
+	t.Helper()
+	msg, ok := mailer.Last()
+	if !ok {
+		t.Fatal("no email was sent")
+	}
+	link, err := url.Parse(magicLinkURL.FindString(msg.Body))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("no magic link in %q", msg.Body)
+	}
+	return link.Query().Get("token")
+}
+
+func TestMagicLinkIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	links, mailer := newMagicLinkService(t, env, false)
+
+	binding, err := links.Send(ctx, MagicLinkRequest{Email: user.Email}, ClientInfo{IPAddress: "192.0.2.1"})
+	if err != nil || binding != "" {
+		t.Fatalf("Send = %q, %v", binding, err)
+	}
+	token := sentMagicLinkToken(t, mailer)
+
+	userID, err := links.Consume(ctx, ConsumeMagicLinkRequest{Token: token}, "")
+	if err != nil || userID != user.ID {
+		t.Fatalf("Consume = %s, %v; want %s", userID, err, user.ID)
+	}
+	if _, err := links.Consume(ctx, ConsumeMagicLinkRequest{Token: token}, ""); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Fatalf("second Consume err = %v, want ErrInvalidMagicLink", err)
+	}
+}
+
+func TestMagicLinkBrowserBinding(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	links, mailer := newMagicLinkService(t, env, true)
+
+	binding, err := links.Send(ctx, MagicLinkRequest{Email: user.Email}, ClientInfo{IPAddress: "192.0.2.1"})
+	if err != nil || binding == "" {
+		t.Fatalf("Send = %q, %v", binding, err)
+	}
+	if _, err := links.Consume(ctx, ConsumeMagicLinkRequest{Token: sentMagicLinkToken(t, mailer)}, "other-browser"); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Fatalf("Consume from another browser err = %v, want ErrInvalidMagicLink", err)
+	}
+
+	binding, err = links.Send(ctx, MagicLinkRequest{Email: user.Email}, ClientInfo{IPAddress: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := links.Consume(ctx, ConsumeMagicLinkRequest{Token: sentMagicLinkToken(t, mailer)}, binding); err != nil || userID != user.ID {
+		t.Fatalf("Consume = %s, %v; want %s", userID, err, user.ID)
+	}
+
+	// Unknown addresses get a binding too, so the response reveals nothing
+	if binding, err := links.Send(ctx, MagicLinkRequest{Email: "nobody@example.com"}, ClientInfo{IPAddress: "192.0.2.1"}); err != nil || binding == "" {
+		t.Fatalf("Send to unknown address = %q, %v", binding, err)
+	}
+}
+
+func TestMagicLinkRateLimitPerEmail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	limits := repositorytest.NewRateLimits()
+	links, _ := newMagicLinkReplica(env, limits, false)
+	other, _ := newMagicLinkReplica(env, limits, false)
+
+	for i := 0; i < 2; i++ {
+		if _, err := links.Send(ctx, MagicLinkRequest{Email: "Alice@example.com"}, ClientInfo{IPAddress: "192.0.2.1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The count is shared with the other replica
+	_, err := other.Send(ctx, MagicLinkRequest{Email: "alice@example.com"}, ClientInfo{IPAddress: "192.0.2.2"})
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) || rateErr.RetryAfter <= 0 || rateErr.RetryAfter > time.Minute {
+		t.Fatalf("third Send err = %v, want a RateLimitError", err)
+	}
+}
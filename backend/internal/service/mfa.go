@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication not enabled")
+	ErrMFANotEnrolled      = errors.New("two-factor authentication enrollment not started")
+	ErrInvalidMFACode      = errors.New("invalid two-factor authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired MFA challenge")
+)
+
+type MFAService interface {
+	EnrollTOTP(ctx context.Context, userID uuid.UUID) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, req MFACodeRequest) (*RecoveryCodes, error)
+	DisableTOTP(ctx context.Context, userID uuid.UUID, req MFACodeRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req MFACodeRequest) (*RecoveryCodes, error)
+	IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error)
+	Status(ctx context.Context, userID uuid.UUID) (*MFAStatus, error)
+	CreateChallenge(ctx context.Context, userID uuid.UUID) (string, error)
+	// ChallengeUser returns the user an open challenge was issued for,
+	// without using up an attempt
+	ChallengeUser(ctx context.Context, challengeToken string) (uuid.UUID, error)
+	VerifyChallenge(ctx context.Context, challengeToken, code string) (uuid.UUID, error)
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// TOTPEnrollment is returned once, when enrollment starts. The secret is
+// never shown again.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes is returned once, when a set is generated. The codes are
+// never shown again.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// MFAStatus summarizes a user's second factor setup
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+	// LowRecoveryCodes is set when the user should regenerate their codes
+	LowRecoveryCodes bool `json:"low_recovery_codes"`
+}
+
+// MFAOptions configures MFAService
+type MFAOptions struct {
+	// Issuer is the account issuer shown in authenticator apps
+	Issuer            string
+	ChallengeExpiry   time.Duration
+	ChallengeAttempts int
+	// RecoveryCodeCount is the size of each generated set of recovery codes
+	RecoveryCodeCount int
+	// RecoveryCodeWarningThreshold is the number of remaining codes at or
+	// below which MFAStatus reports LowRecoveryCodes
+	RecoveryCodeWarningThreshold int
+}
+
+type mfaService struct {
+	mfaRepo     repository.MFARepository
+	userRepo    repository.UserRepository
+	cipher      *auth.Cipher
+	tokenHasher *auth.TokenHasher
+	opts        MFAOptions
+}
+
+func NewMFAService(
+	mfaRepo repository.MFARepository,
+	userRepo repository.UserRepository,
+	cipher *auth.Cipher,
+	tokenHasher *auth.TokenHasher,
+	opts MFAOptions,
+) MFAService {
+	return &mfaService{
+		mfaRepo:     mfaRepo,
+		userRepo:    userRepo,
+		cipher:      cipher,
+		tokenHasher: tokenHasher,
+		opts:        opts,
+	}
+}
+
+// EnrollTOTP starts (or restarts) enrollment with a fresh secret. The
+// credential stays disabled until ConfirmTOTP proves the app was set up.
+func (s *mfaService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (*TOTPEnrollment, error) {
+	credential, err := s.mfaRepo.FindTOTP(ctx, userID)
+	if err == nil && credential.IsEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.cipher.Encrypt([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SaveTOTP(ctx, &model.TOTPCredential{
+		UserID:          userID,
+		SecretEncrypted: encrypted,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(s.opts.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables the credential and returns the user's first set of
+// recovery codes
+func (s *mfaService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, req MFACodeRequest) (*RecoveryCodes, error) {
+	credential, err := s.mfaRepo.FindTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	if credential.IsEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, err := s.checkTOTP(credential, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store the codes first so an enabled credential always has a set
+	codes, err := s.generateRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	credential.EnabledAt = &now
+	credential.LastUsedStep = step
+	if err := s.mfaRepo.SaveTOTP(ctx, credential); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP accepts a TOTP or recovery code, so a user who lost their
+// device can still turn two-factor authentication off
+func (s *mfaService) DisableTOTP(ctx context.Context, userID uuid.UUID, req MFACodeRequest) error {
+	credential, err := s.findEnabledTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifySecondFactor(ctx, credential, req.Code); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+	return s.mfaRepo.DeleteRecoveryCodes(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces all of the user's recovery codes,
+// including unused ones
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req MFACodeRequest) (*RecoveryCodes, error) {
+	credential, err := s.findEnabledTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, credential, req.Code); err != nil {
+		return nil, err
+	}
+	return s.generateRecoveryCodes(ctx, userID)
+}
+
+func (s *mfaService) IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	_, err := s.findEnabledTOTP(ctx, userID)
+	if errors.Is(err, ErrMFANotEnabled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *mfaService) Status(ctx context.Context, userID uuid.UUID) (*MFAStatus, error) {
+	enabled, err := s.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return &MFAStatus{}, nil
+	}
+
+	remaining, err := s.mfaRepo.CountUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAStatus{
+		Enabled:                true,
+		RecoveryCodesRemaining: int(remaining),
+		LowRecoveryCodes:       int(remaining) <= s.opts.RecoveryCodeWarningThreshold,
+	}, nil
+}
+
+// CreateChallenge starts the second login step for a user whose password
+// was correct and returns the opaque challenge token
+func (s *mfaService) CreateChallenge(ctx context.Context, userID uuid.UUID) (string, error) {
+	raw, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.mfaRepo.CreateChallenge(ctx, &model.MFAChallenge{
+		UserID:    userID,
+		TokenHash: s.tokenHasher.Hash(raw),
+		ExpiresAt: time.Now().Add(s.opts.ChallengeExpiry),
+	}); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (s *mfaService) ChallengeUser(ctx context.Context, challengeToken string) (uuid.UUID, error) {
+	challenge, err := s.mfaRepo.FindChallenge(ctx, s.tokenHasher.Hash(challengeToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidMFAChallenge
+		}
+		return uuid.Nil, err
+	}
+	return challenge.UserID, nil
+}
+
+// VerifyChallenge completes a challenge with a TOTP or recovery code and
+// returns the user it was issued for. Each challenge allows a limited
+// number of attempts.
+func (s *mfaService) VerifyChallenge(ctx context.Context, challengeToken, code string) (uuid.UUID, error) {
+	challenge, err := s.mfaRepo.FindChallenge(ctx, s.tokenHasher.Hash(challengeToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidMFAChallenge
+		}
+		return uuid.Nil, err
+	}
+
+	if err := s.mfaRepo.IncrementChallengeAttempts(ctx, challenge.ID, s.opts.ChallengeAttempts); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = s.mfaRepo.DeleteChallenge(ctx, challenge.ID)
+			return uuid.Nil, ErrInvalidMFAChallenge
+		}
+		return uuid.Nil, err
+	}
+
+	credential, err := s.findEnabledTOTP(ctx, challenge.UserID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := s.verifySecondFactor(ctx, credential, code); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := s.mfaRepo.DeleteChallenge(ctx, challenge.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidMFAChallenge
+		}
+		return uuid.Nil, err
+	}
+	return challenge.UserID, nil
+}
+
+func (s *mfaService) findEnabledTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPCredential, error) {
+	credential, err := s.mfaRepo.FindTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if !credential.IsEnabled() {
+		return nil, ErrMFANotEnabled
+	}
+	return credential, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or one of the user's
+// recovery codes, telling them apart by shape
+func (s *mfaService) verifySecondFactor(ctx context.Context, credential *model.TOTPCredential, code string) error {
+	if auth.IsRecoveryCode(code) {
+		return s.useRecoveryCode(ctx, credential.UserID, code)
+	}
+	return s.verifyTOTP(ctx, credential, code)
+}
+
+// useRecoveryCode checks code against the user's unused recovery codes and
+// marks the match as used
+func (s *mfaService) useRecoveryCode(ctx context.Context, userID uuid.UUID, code string) error {
+	codes, err := s.mfaRepo.FindUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	normalized := auth.NormalizeRecoveryCode(code)
+	for _, candidate := range codes {
+		if !auth.CheckRecoveryCode(normalized, candidate.CodeHash) {
+			continue
+		}
+		if err := s.mfaRepo.UseRecoveryCode(ctx, candidate.ID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidMFACode
+			}
+			return err
+		}
+		slog.InfoContext(ctx, "MFA recovery code used",
+			"event", "mfa_recovery_code_used",
+			"user_id", userID,
+			"remaining", len(codes)-1,
+		)
+		return nil
+	}
+	return ErrInvalidMFACode
+}
+
+// generateRecoveryCodes replaces the user's recovery codes with a new set
+// and returns the plaintext codes
+func (s *mfaService) generateRecoveryCodes(ctx context.Context, userID uuid.UUID) (*RecoveryCodes, error) {
+	plain := make([]string, s.opts.RecoveryCodeCount)
+	codes := make([]model.RecoveryCode, s.opts.RecoveryCodeCount)
+	for i := range plain {
+		code, err := auth.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := auth.HashRecoveryCode(auth.NormalizeRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+		plain[i] = code
+		codes[i] = model.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, codes); err != nil {
+		return nil, err
+	}
+	return &RecoveryCodes{Codes: plain}, nil
+}
+
+// verifyTOTP checks a code and consumes its time step
+func (s *mfaService) verifyTOTP(ctx context.Context, credential *model.TOTPCredential, code string) error {
+	step, err := s.checkTOTP(credential, code)
+	if err != nil {
+		return err
+	}
+	if err := s.mfaRepo.UseTOTPStep(ctx, credential.UserID, step); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *mfaService) checkTOTP(credential *model.TOTPCredential, code string) (int64, error) {
+	secret, err := s.cipher.Decrypt(credential.SecretEncrypted)
+	if err != nil {
+		return 0, err
+	}
+	step, ok := auth.ValidateTOTP(string(secret), code, time.Now())
+	if !ok {
+		return 0, ErrInvalidMFACode
+	}
+	return step, nil
+}
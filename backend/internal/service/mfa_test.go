@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+const testMFAIssuer = "Test"
+
+func newMFAService(t *testing.T, env *testEnv) (MFAService, *repositorytest.MFA) {
+	t.Helper()
+	repo := repositorytest.NewMFA()
+	return NewMFAService(repo, env.users, newTestCipher(t, "0123456789abcdef0123456789abcdef"), env.hasher, MFAOptions{
+		Issuer:                       testMFAIssuer,
+		ChallengeExpiry:              time.Minute,
+		ChallengeAttempts:            3,
+		RecoveryCodeCount:            4,
+		RecoveryCodeWarningThreshold: 2,
+	}), repo
+}
+
+// totpAt computes the code an authenticator app shows for secret at t
+func totpAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1_000_000)
+}
+
+// enrollTOTP enrolls and confirms an authenticator app for userID and
+// returns its secret and the recovery codes
+func enrollTOTP(t *testing.T, mfa MFAService, userID uuid.UUID) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enrollment, err := mfa.EnrollTOTP(ctx, userID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	codes, err := mfa.ConfirmTOTP(ctx, userID, MFACodeRequest{Code: totpAt(t, enrollment.Secret, time.Now())})
+	if err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	return enrollment.Secret, codes.Codes
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	mfa, repo := newMFAService(t, env)
+
+	enrollment, err := mfa.EnrollTOTP(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	if !strings.Contains(enrollment.URI, "secret="+enrollment.Secret) || !strings.HasPrefix(enrollment.URI, "otpauth://totp/"+testMFAIssuer+":") {
+		t.Errorf("URI = %q", enrollment.URI)
+	}
+	stored, err := repo.FindTOTP(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored.SecretEncrypted, enrollment.Secret) {
+		t.Error("secret is stored in plaintext")
+	}
+
+	// Nothing counts until the app proves it was set up
+	if enabled, _ := mfa.IsEnabled(ctx, user.ID); enabled {
+		t.Error("IsEnabled before ConfirmTOTP = true")
+	}
+	if _, err := mfa.ConfirmTOTP(ctx, user.ID, MFACodeRequest{Code: "000000"}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("ConfirmTOTP with a wrong code: err = %v, want ErrInvalidMFACode", err)
+	}
+	codes, err := mfa.ConfirmTOTP(ctx, user.ID, MFACodeRequest{Code: totpAt(t, enrollment.Secret, time.Now())})
+	if err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	if len(codes.Codes) != 4 {
+		t.Errorf("got %d recovery codes, want 4", len(codes.Codes))
+	}
+	if enabled, _ := mfa.IsEnabled(ctx, user.ID); !enabled {
+		t.Error("IsEnabled after ConfirmTOTP = false")
+	}
+
+	if _, err := mfa.EnrollTOTP(ctx, user.ID); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Errorf("EnrollTOTP when enabled: err = %v, want ErrMFAAlreadyEnabled", err)
+	}
+	if _, err := mfa.ConfirmTOTP(ctx, uuid.New(), MFACodeRequest{Code: "000000"}); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Errorf("ConfirmTOTP without enrolling: err = %v, want ErrMFANotEnrolled", err)
+	}
+}
+
+func TestTOTPCodesAreSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	mfa, repo := newMFAService(t, env)
+	secret, _ := enrollTOTP(t, mfa, user.ID)
+
+	credential, err := repo.FindTOTP(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := totpAt(t, secret, time.Unix(credential.LastUsedStep*30, 0))
+	next := totpAt(t, secret, time.Unix((credential.LastUsedStep+1)*30, 0))
+	verify := func(code string) error {
+		t.Helper()
+		challenge, err := mfa.CreateChallenge(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = mfa.VerifyChallenge(ctx, challenge, code)
+		return err
+	}
+
+	// Confirming enrollment used up the current code
+	if err := verify(used); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("code used for enrollment: err = %v, want ErrInvalidMFACode", err)
+	}
+	// The next step is within the allowed clock skew
+	if err := verify(next); err != nil {
+		t.Fatalf("next code: %v", err)
+	}
+	if err := verify(next); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replayed code: err = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestMFAChallenge(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	mfa, _ := newMFAService(t, env)
+	secret, _ := enrollTOTP(t, mfa, user.ID)
+
+	challenge, err := mfa.CreateChallenge(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := mfa.ChallengeUser(ctx, challenge); err != nil || got != user.ID {
+		t.Errorf("ChallengeUser = %v, %v; want %v", got, err, user.ID)
+	}
+
+	// Wrong codes use up attempts; the last one is refused even if right
+	for i := 0; i < 2; i++ {
+		if _, err := mfa.VerifyChallenge(ctx, challenge, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+	code := totpAt(t, secret, time.Now().Add(30*time.Second))
+	got, err := mfa.VerifyChallenge(ctx, challenge, code)
+	if err != nil || got != user.ID {
+		t.Fatalf("VerifyChallenge = %v, %v; want %v", got, err, user.ID)
+	}
+	if _, err := mfa.VerifyChallenge(ctx, challenge, code); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Errorf("completed challenge: err = %v, want ErrInvalidMFAChallenge", err)
+	}
+
+	exhausted, err := mfa.CreateChallenge(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = mfa.VerifyChallenge(ctx, exhausted, "000000")
+	}
+	if _, err := mfa.VerifyChallenge(ctx, exhausted, "000000"); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Errorf("challenge out of attempts: err = %v, want ErrInvalidMFAChallenge", err)
+	}
+}
+
+func TestDisableTOTP(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	mfa, repo := newMFAService(t, env)
+	secret, _ := enrollTOTP(t, mfa, user.ID)
+
+	if err := mfa.DisableTOTP(ctx, user.ID, MFACodeRequest{Code: "000000"}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("wrong code: err = %v, want ErrInvalidMFACode", err)
+	}
+	code := totpAt(t, secret, time.Now().Add(30*time.Second))
+	if err := mfa.DisableTOTP(ctx, user.ID, MFACodeRequest{Code: code}); err != nil {
+		t.Fatalf("DisableTOTP: %v", err)
+	}
+	if enabled, _ := mfa.IsEnabled(ctx, user.ID); enabled {
+		t.Error("IsEnabled after DisableTOTP = true")
+	}
+	if n, _ := repo.CountUnusedRecoveryCodes(ctx, user.ID); n != 0 {
+		t.Errorf("%d recovery codes left after DisableTOTP", n)
+	}
+	if err := mfa.DisableTOTP(ctx, user.ID, MFACodeRequest{Code: code}); !errors.Is(err, ErrMFANotEnabled) {
+		t.Errorf("second DisableTOTP: err = %v, want ErrMFANotEnabled", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	mfa, _ := newMFAService(t, env)
+	env.auth.(*authService).mfa = mfa
+	_, codes := enrollTOTP(t, mfa, user.ID)
+
+	status := func() MFAStatus {
+		t.Helper()
+		status, err := mfa.Status(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *status
+	}
+	verify := func(code string) error {
+		t.Helper()
+		challenge, err := mfa.CreateChallenge(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = mfa.VerifyChallenge(ctx, challenge, code)
+		return err
+	}
+
+	if got := status(); got != (MFAStatus{Enabled: true, RecoveryCodesRemaining: 4}) {
+		t.Errorf("Status = %+v after enrolling", got)
+	}
+
+	// Codes may be typed in upper case and with a space for the hyphen
+	if err := verify(strings.ToUpper(strings.Replace(codes[0], "-", " ", 1))); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := verify(codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("reused recovery code: err = %v, want ErrInvalidMFACode", err)
+	}
+
+	// At the warning threshold the user is told to make new ones
+	if err := verify(codes[1]); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if got := status(); got != (MFAStatus{Enabled: true, RecoveryCodesRemaining: 2, LowRecoveryCodes: true}) {
+		t.Errorf("Status = %+v with two codes left", got)
+	}
+	current, err := env.auth.GetCurrentUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current.Warnings) != 1 || current.Warnings[0] != WarningLowRecoveryCodes {
+		t.Errorf("Warnings = %v, want [%s]", current.Warnings, WarningLowRecoveryCodes)
+	}
+
+	// Regenerating replaces the unused codes as well
+	fresh, err := mfa.RegenerateRecoveryCodes(ctx, user.ID, MFACodeRequest{Code: codes[2]})
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if err := verify(codes[3]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("code from the old set: err = %v, want ErrInvalidMFACode", err)
+	}
+	if got := status(); got != (MFAStatus{Enabled: true, RecoveryCodesRemaining: 4}) {
+		t.Errorf("Status = %+v after regenerating", got)
+	}
+	if err := verify(fresh.Codes[0]); err != nil {
+		t.Errorf("code from the new set: %v", err)
+	}
+}
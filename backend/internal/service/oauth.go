@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/oidc"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidOAuthState     = errors.New("invalid or expired OAuth state")
+	ErrOAuthFailed           = errors.New("identity provider login failed")
+	ErrOAuthEmailRequired    = errors.New("identity provider did not report a verified email")
+	ErrOAuthAccountExists    = errors.New("an account with this email already exists")
+	ErrIdentityAlreadyLinked = errors.New("identity is linked to another user")
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrLastLoginMethod       = errors.New("cannot remove the last login method")
+)
+
+type OAuthService interface {
+	Providers() []string
+	Start(ctx context.Context, provider string, linkUserID *uuid.UUID) (*OAuthStart, error)
+	Complete(ctx context.Context, provider, state, code string) (*OAuthResult, error)
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]model.Identity, error)
+	Unlink(ctx context.Context, userID, id uuid.UUID) error
+}
+
+// OAuthStart is an authorization request to send the browser on. State must
+// also be bound to the browser, e.g. in a cookie, to prevent login CSRF.
+type OAuthStart struct {
+	URL   string `json:"authorization_url"`
+	State string `json:"-"`
+}
+
+// OAuthResult is the outcome of a provider callback
+type OAuthResult struct {
+	UserID uuid.UUID
+	// Linked is set when the callback completed linking an identity to a
+	// logged in user rather than a login
+	Linked bool
+}
+
+// OAuthOptions configures OAuthService
+type OAuthOptions struct {
+	StateExpiry time.Duration
+	// LinkVerifiedEmail allows a login whose verified provider email matches
+	// an existing account with a verified email to link to that account.
+	// Otherwise such logins are refused and users link identities from
+	// their account.
+	LinkVerifiedEmail bool
+	// DeletedEmailHold is AuthOptions.DeletedEmailHold for accounts
+	// created through a provider
+	DeletedEmailHold time.Duration
+}
+
+type oauthService struct {
+	identityRepo repository.IdentityRepository
+	userRepo     repository.UserRepository
+	providers    map[string]*oidc.Provider
+	tokenHasher  *auth.TokenHasher
+	opts         OAuthOptions
+}
+
+func NewOAuthService(
+	identityRepo repository.IdentityRepository,
+	userRepo repository.UserRepository,
+	providers []*oidc.Provider,
+	tokenHasher *auth.TokenHasher,
+	opts OAuthOptions,
+) OAuthService {
+	byName := make(map[string]*oidc.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	return &oauthService{
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		providers:    byName,
+		tokenHasher:  tokenHasher,
+		opts:         opts,
+	}
+}
+
+func (s *oauthService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start begins an authorization code flow. With linkUserID set, the
+// resulting identity is linked to that user instead of logging in.
+func (s *oauthService) Start(ctx context.Context, provider string, linkUserID *uuid.UUID) (*OAuthStart, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := oidc.GenerateRandom()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.GenerateRandom()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.GenerateRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identityRepo.CreateState(ctx, &model.OAuthState{
+		StateHash:    s.tokenHasher.Hash(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(s.opts.StateExpiry),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &OAuthStart{URL: authURL, State: state}, nil
+}
+
+// Complete handles the provider callback and resolves the user to log in
+func (s *oauthService) Complete(ctx context.Context, provider, state, code string) (*OAuthResult, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	pending, err := s.identityRepo.ConsumeState(ctx, s.tokenHasher.Hash(state))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidOAuthState
+		}
+		return nil, err
+	}
+	if pending.Provider != provider {
+		return nil, ErrInvalidOAuthState
+	}
+
+	external, err := p.Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		slog.WarnContext(ctx, "Identity provider login failed",
+			"event", "oauth_login_failed",
+			"provider", provider,
+			"error", err,
+		)
+		return nil, ErrOAuthFailed
+	}
+
+	identity, err := s.identityRepo.FindByProviderSubject(ctx, provider, external.Subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if pending.LinkUserID != nil {
+		return s.link(ctx, *pending.LinkUserID, provider, external, identity)
+	}
+
+	if identity != nil {
+		if err := s.identityRepo.RecordLogin(ctx, identity.ID, external.Email); err != nil {
+			return nil, err
+		}
+		return &OAuthResult{UserID: identity.UserID}, nil
+	}
+	return s.signUp(ctx, provider, external)
+}
+
+func (s *oauthService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]model.Identity, error) {
+	return s.identityRepo.FindByUserID(ctx, userID)
+}
+
+// Unlink removes an identity unless the user would be left without a
+// password or another identity to log in with
+func (s *oauthService) Unlink(ctx context.Context, userID, id uuid.UUID) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	identities, err := s.identityRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Password == "" && len(identities) <= 1 {
+		return ErrLastLoginMethod
+	}
+
+	if err := s.identityRepo.Delete(ctx, userID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrIdentityNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// link attaches an identity to a logged in user
+func (s *oauthService) link(ctx context.Context, userID uuid.UUID, provider string, external *oidc.Identity, existing *model.Identity) (*OAuthResult, error) {
+	if existing != nil {
+		if existing.UserID != userID {
+			return nil, ErrIdentityAlreadyLinked
+		}
+		return &OAuthResult{UserID: userID, Linked: true}, nil
+	}
+
+	if err := s.identityRepo.Create(ctx, &model.Identity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  external.Subject,
+		Email:    external.Email,
+	}); err != nil {
+		return nil, err
+	}
+	return &OAuthResult{UserID: userID, Linked: true}, nil
+}
+
+// signUp handles the first login with an identity. An account that already
+// uses the email is only linked when the policy allows it; otherwise a new
+// account is created.
+func (s *oauthService) signUp(ctx context.Context, provider string, external *oidc.Identity) (*OAuthResult, error) {
+	if external.Email == "" || !external.EmailVerified {
+		return nil, ErrOAuthEmailRequired
+	}
+
+	identity := &model.Identity{
+		Provider: provider,
+		Subject:  external.Subject,
+		Email:    external.Email,
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, external.Email)
+	if err == nil {
+		if !s.opts.LinkVerifiedEmail || !user.IsEmailVerified() {
+			return nil, ErrOAuthAccountExists
+		}
+		identity.UserID = user.ID
+		if err := s.identityRepo.Create(ctx, identity); err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "Identity linked by verified email",
+			"event", "identity_linked",
+			"user_id", user.ID,
+			"provider", provider,
+		)
+		return &OAuthResult{UserID: user.ID}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	held, err := emailHeld(ctx, s.userRepo, external.Email, s.opts.DeletedEmailHold, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	if held {
+		return nil, ErrOAuthAccountExists
+	}
+
+	// Accounts created through a provider have no password until the user
+	// sets one through a password reset
+	now := time.Now()
+	user = &model.User{
+		Email:           external.Email,
+		Name:            external.Name,
+		EmailVerifiedAt: &now,
+	}
+	if err := s.identityRepo.CreateWithUser(ctx, user, identity); err != nil {
+		return nil, err
+	}
+	return &OAuthResult{UserID: user.ID}, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"slices"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOAuthClientNotFound   = errors.New("OAuth client not found")
+	ErrInvalidClientMetadata = errors.New("invalid OAuth client metadata")
+)
+
+// OAuthClientService manages the applications allowed to use this server
+// as their authorization server
+type OAuthClientService interface {
+	Create(ctx context.Context, reg OAuthClientRegistration) (*model.OAuthClient, string, error)
+	List(ctx context.Context) ([]model.OAuthClient, error)
+	Delete(ctx context.Context, clientID string) error
+	RotateSecret(ctx context.Context, clientID string) (string, error)
+	Authenticate(ctx context.Context, clientID, secret string) (*model.OAuthClient, error)
+}
+
+// OAuthClientRegistration describes a new client. Public clients get no
+// secret and must use PKCE.
+type OAuthClientRegistration struct {
+	Name         string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+	Public       bool
+	SkipConsent  bool
+}
+
+type oauthClientService struct {
+	clientRepo  repository.OAuthClientRepository
+	tokenHasher *auth.TokenHasher
+}
+
+func NewOAuthClientService(clientRepo repository.OAuthClientRepository, tokenHasher *auth.TokenHasher) OAuthClientService {
+	return &oauthClientService{
+		clientRepo:  clientRepo,
+		tokenHasher: tokenHasher,
+	}
+}
+
+// Create registers a client and returns its secret, which is not stored
+// and cannot be shown again
+func (s *oauthClientService) Create(ctx context.Context, reg OAuthClientRegistration) (*model.OAuthClient, string, error) {
+	if err := validateClientRegistration(reg); err != nil {
+		return nil, "", err
+	}
+
+	clientID, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	// The JSON serializer stores nil slices as an empty string, which is
+	// not valid jsonb
+	client := &model.OAuthClient{
+		ClientID:     clientID,
+		Name:         reg.Name,
+		RedirectURIs: append([]string{}, reg.RedirectURIs...),
+		GrantTypes:   append([]string{}, reg.GrantTypes...),
+		Scopes:       append([]string{}, reg.Scopes...),
+		SkipConsent:  reg.SkipConsent,
+	}
+
+	var secret string
+	if !reg.Public {
+		if secret, err = auth.GenerateOpaqueToken(); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = s.tokenHasher.Hash(secret)
+	}
+
+	if err := s.clientRepo.Create(ctx, client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (s *oauthClientService) List(ctx context.Context) ([]model.OAuthClient, error) {
+	return s.clientRepo.FindAll(ctx)
+}
+
+// Delete removes a client together with its consents and refresh tokens
+func (s *oauthClientService) Delete(ctx context.Context, clientID string) error {
+	if err := s.clientRepo.Delete(ctx, clientID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOAuthClientNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RotateSecret replaces a confidential client's secret. The old secret
+// stops working immediately.
+func (s *oauthClientService) RotateSecret(ctx context.Context, clientID string) (string, error) {
+	client, err := s.clientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrOAuthClientNotFound
+		}
+		return "", err
+	}
+	if client.IsPublic() {
+		return "", ErrInvalidClientMetadata
+	}
+
+	secret, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.clientRepo.UpdateSecretHash(ctx, clientID, s.tokenHasher.Hash(secret)); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Authenticate resolves the client making a token request. Public clients
+// must not present a secret and confidential clients must present theirs.
+func (s *oauthClientService) Authenticate(ctx context.Context, clientID, secret string) (*model.OAuthClient, error) {
+	if clientID == "" {
+		return nil, ErrOAuthClientNotFound
+	}
+	client, err := s.clientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, err
+	}
+
+	if client.IsPublic() {
+		if secret != "" {
+			return nil, ErrOAuthClientNotFound
+		}
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(s.tokenHasher.Hash(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, ErrOAuthClientNotFound
+	}
+	return client, nil
+}
+
+func validateClientRegistration(reg OAuthClientRegistration) error {
+	if reg.Name == "" || len(reg.GrantTypes) == 0 {
+		return ErrInvalidClientMetadata
+	}
+	for _, grant := range reg.GrantTypes {
+		switch grant {
+		case model.GrantAuthorizationCode, model.GrantRefreshToken:
+		case model.GrantClientCredentials:
+			// Anyone can read a public client's ID, so it cannot act on
+			// its own behalf
+			if reg.Public {
+				return ErrInvalidClientMetadata
+			}
+		default:
+			return ErrInvalidClientMetadata
+		}
+	}
+	if slices.Contains(reg.GrantTypes, model.GrantAuthorizationCode) && len(reg.RedirectURIs) == 0 {
+		return ErrInvalidClientMetadata
+	}
+	for _, uri := range reg.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return ErrInvalidClientMetadata
+		}
+	}
+	return nil
+}
@@ -0,0 +1,783 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/oidc"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOAuthRequestNotFound = errors.New("authorization request not found")
+	ErrOAuthConsentNotFound = errors.New("consent not found")
+)
+
+// OpenID Connect scopes understood by /userinfo and ID tokens
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Error codes defined by RFC 6749 and OpenID Connect
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrUnauthorizedClient      = "unauthorized_client"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+	OAuthErrInvalidToken            = "invalid_token"
+	OAuthErrServerError             = "server_error"
+)
+
+// OAuthError is an error response returned to OAuth clients as is
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthServerService lets registered clients sign users in through this
+// server (authorization code with PKCE) and obtain tokens for themselves
+// (client credentials)
+type OAuthServerService interface {
+	Authorize(ctx context.Context, req AuthorizeRequest) (string, error)
+	GetRequest(ctx context.Context, userID, id uuid.UUID) (*OAuthConsentRequest, error)
+	Approve(ctx context.Context, userID, id uuid.UUID) (string, error)
+	Deny(ctx context.Context, userID, id uuid.UUID) (string, error)
+	Token(ctx context.Context, req TokenRequest) (*TokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error)
+	ListConsents(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error)
+	RevokeConsent(ctx context.Context, userID uuid.UUID, clientID string) error
+	Configuration() *OpenIDConfiguration
+}
+
+// AuthorizeRequest holds the parameters of an authorization endpoint
+// request
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// OAuthConsentRequest is what the consent page shows the user.
+// ConsentRequired is false when the client is trusted or the user has
+// already granted every scope, in which case the page can approve at once.
+type OAuthConsentRequest struct {
+	ID              uuid.UUID `json:"id"`
+	ClientID        string    `json:"client_id"`
+	ClientName      string    `json:"client_name"`
+	Scopes          []string  `json:"scopes"`
+	ConsentRequired bool      `json:"consent_required"`
+}
+
+// TokenRequest holds the parameters of a token endpoint request. The
+// client credentials come from HTTP Basic auth or the form body.
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+// TokenResponse is a successful token endpoint response (RFC 6749 §5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OpenIDConfiguration is the OpenID Provider metadata served at
+// /.well-known/openid-configuration
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// idTokenClaims are the claims of an OpenID Connect ID token. ID tokens
+// have no jti, so ValidateAccessToken never accepts them.
+type idTokenClaims struct {
+	AuthTime      int64  `json:"auth_time"`
+	Nonce         string `json:"nonce,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// OAuthServerOptions configures OAuthServerService
+type OAuthServerOptions struct {
+	// Issuer is the public URL of this server
+	Issuer string
+	// ConsentURL is the frontend page that asks the user to approve a
+	// request; the request ID is appended as ?request=
+	ConsentURL string
+	// RequestExpiry bounds how long the user may take to log in and decide
+	RequestExpiry time.Duration
+	// CodeExpiry bounds how long the client may take to redeem a code
+	CodeExpiry time.Duration
+}
+
+type oauthServerService struct {
+	clients     OAuthClientService
+	clientRepo  repository.OAuthClientRepository
+	grantRepo   repository.OAuthGrantRepository
+	userRepo    repository.UserRepository
+	revocations RevocationService
+	jwt         *auth.JWTManager
+	tokenHasher *auth.TokenHasher
+	opts        OAuthServerOptions
+}
+
+func NewOAuthServerService(
+	clients OAuthClientService,
+	clientRepo repository.OAuthClientRepository,
+	grantRepo repository.OAuthGrantRepository,
+	userRepo repository.UserRepository,
+	revocations RevocationService,
+	jwt *auth.JWTManager,
+	tokenHasher *auth.TokenHasher,
+	opts OAuthServerOptions,
+) OAuthServerService {
+	return &oauthServerService{
+		clients:     clients,
+		clientRepo:  clientRepo,
+		grantRepo:   grantRepo,
+		userRepo:    userRepo,
+		revocations: revocations,
+		jwt:         jwt,
+		tokenHasher: tokenHasher,
+		opts:        opts,
+	}
+}
+
+// Authorize validates an authorization request, stores it and returns the
+// URL to send the browser to: the consent page, or the client's redirect
+// URI with an error. An unknown client or redirect URI is returned as an
+// *OAuthError instead, since redirecting there would be an open redirect.
+func (s *oauthServerService) Authorize(ctx context.Context, req AuthorizeRequest) (string, error) {
+	client, err := s.clientRepo.FindByClientID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", oauthError(OAuthErrInvalidClient, "unknown client_id")
+		}
+		return "", err
+	}
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return "", oauthError(OAuthErrInvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	fail := func(code, description string) (string, error) {
+		return redirectURL(req.RedirectURI, url.Values{
+			"error":             {code},
+			"error_description": {description},
+			"state":             {req.State},
+		}), nil
+	}
+
+	if req.ResponseType != "code" {
+		return fail(OAuthErrUnsupportedResponseType, "only response_type=code is supported")
+	}
+	if !client.AllowsGrant(model.GrantAuthorizationCode) {
+		return fail(OAuthErrUnauthorizedClient, "client may not use the authorization code grant")
+	}
+	scopes := parseScope(req.Scope)
+	if len(scopes) == 0 || !client.AllowsScopes(scopes) {
+		return fail(OAuthErrInvalidScope, "requested scope is not allowed for this client")
+	}
+	// PKCE is required of every client, confidential ones included
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return fail(OAuthErrInvalidRequest, "code_challenge with code_challenge_method=S256 is required")
+	}
+
+	authorization := &model.OAuthAuthorization{
+		ClientID:      client.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		State:         req.State,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.opts.RequestExpiry),
+	}
+	if err := s.grantRepo.CreateAuthorization(ctx, authorization); err != nil {
+		return "", err
+	}
+
+	return redirectURL(s.opts.ConsentURL, url.Values{"request": {authorization.ID.String()}}), nil
+}
+
+// GetRequest returns a pending request for the consent page
+func (s *oauthServerService) GetRequest(ctx context.Context, userID, id uuid.UUID) (*OAuthConsentRequest, error) {
+	authorization, client, err := s.findPending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	consentRequired, err := s.consentRequired(ctx, userID, client, authorization.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthConsentRequest{
+		ID:              authorization.ID,
+		ClientID:        client.ClientID,
+		ClientName:      client.Name,
+		Scopes:          authorization.Scopes,
+		ConsentRequired: consentRequired,
+	}, nil
+}
+
+// Approve issues an authorization code for the user, records their consent
+// and returns the client redirect carrying the code
+func (s *oauthServerService) Approve(ctx context.Context, userID, id uuid.UUID) (string, error) {
+	authorization, client, err := s.findPending(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.grantRepo.ApproveAuthorization(ctx, id, userID, s.tokenHasher.Hash(code), time.Now().Add(s.opts.CodeExpiry)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrOAuthRequestNotFound
+		}
+		return "", err
+	}
+
+	if !client.SkipConsent {
+		scopes := authorization.Scopes
+		if existing, err := s.grantRepo.FindConsent(ctx, userID, client.ClientID); err == nil {
+			scopes = mergeScopes(existing.Scopes, scopes)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+		if err := s.grantRepo.SaveConsent(ctx, &model.OAuthConsent{
+			UserID:   userID,
+			ClientID: client.ClientID,
+			Scopes:   scopes,
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	return redirectURL(authorization.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {authorization.State},
+	}), nil
+}
+
+// Deny drops the request and returns the client redirect carrying
+// access_denied
+func (s *oauthServerService) Deny(ctx context.Context, userID, id uuid.UUID) (string, error) {
+	authorization, _, err := s.findPending(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if err := s.grantRepo.DeleteAuthorization(ctx, id); err != nil {
+		return "", err
+	}
+
+	return redirectURL(authorization.RedirectURI, url.Values{
+		"error":             {OAuthErrAccessDenied},
+		"error_description": {"the user denied the request"},
+		"state":             {authorization.State},
+	}), nil
+}
+
+// Token serves the token endpoint. Failures are returned as *OAuthError.
+func (s *oauthServerService) Token(ctx context.Context, req TokenRequest) (*TokenResponse, error) {
+	client, err := s.clients.Authenticate(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		if errors.Is(err, ErrOAuthClientNotFound) {
+			return nil, oauthError(OAuthErrInvalidClient, "client authentication failed")
+		}
+		return nil, err
+	}
+	if !client.AllowsGrant(req.GrantType) {
+		switch req.GrantType {
+		case model.GrantAuthorizationCode, model.GrantRefreshToken, model.GrantClientCredentials:
+			return nil, oauthError(OAuthErrUnauthorizedClient, "client may not use this grant type")
+		}
+		return nil, oauthError(OAuthErrUnsupportedGrantType, "")
+	}
+
+	switch req.GrantType {
+	case model.GrantAuthorizationCode:
+		return s.exchangeCode(ctx, client, req)
+	case model.GrantRefreshToken:
+		return s.refresh(ctx, client, req)
+	default:
+		return s.clientCredentials(client, req)
+	}
+}
+
+func (s *oauthServerService) exchangeCode(ctx context.Context, client *model.OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, oauthError(OAuthErrInvalidRequest, "code and code_verifier are required")
+	}
+
+	// The code is consumed before it is checked, so a failed attempt
+	// burns it. It is kept as long as the refresh tokens issued for it may
+	// be used, so that presenting it again can revoke them.
+	codeHash := s.tokenHasher.Hash(req.Code)
+	familyID := uuid.New()
+	authorization, err := s.grantRepo.ConsumeCode(ctx, codeHash, familyID, time.Now().Add(s.jwt.GetRefreshExpiry()))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, s.revokeReusedCode(ctx, codeHash)
+		}
+		return nil, err
+	}
+	if authorization.ClientID != client.ClientID || authorization.RedirectURI != req.RedirectURI {
+		return nil, oauthError(OAuthErrInvalidGrant, "code was not issued for this client and redirect_uri")
+	}
+	challenge := oidc.CodeChallenge(req.CodeVerifier)
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(authorization.CodeChallenge)) != 1 {
+		return nil, oauthError(OAuthErrInvalidGrant, "code_verifier does not match")
+	}
+	if authorization.UserID == nil || authorization.AuthTime == nil {
+		return nil, oauthError(OAuthErrInvalidGrant, "invalid or expired code")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, *authorization.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError(OAuthErrInvalidGrant, "user no longer exists")
+		}
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, oauthError(OAuthErrInvalidGrant, "user is disabled")
+	}
+
+	res, jti, err := s.issueUserTokens(ctx, client, user, authorization.Scopes, *authorization.AuthTime, authorization.Nonce, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.grantRepo.SetCodeAccessToken(ctx, authorization.ID, jti); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// revokeReusedCode revokes the tokens issued for a code presented again
+// (RFC 6749 §4.1.2), since either the client or whoever replays its code
+// has been compromised. Unknown and expired codes are simply rejected.
+func (s *oauthServerService) revokeReusedCode(ctx context.Context, codeHash string) error {
+	authorization, err := s.grantRepo.FindConsumedCode(ctx, codeHash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return oauthError(OAuthErrInvalidGrant, "invalid or expired code")
+		}
+		return err
+	}
+
+	slog.WarnContext(ctx, "OAuth authorization code reuse detected",
+		"event", "oauth_code_reuse",
+		"client_id", authorization.ClientID,
+		"user_id", authorization.UserID,
+		"family_id", authorization.FamilyID,
+	)
+
+	if authorization.FamilyID != nil {
+		if err := s.grantRepo.DeleteRefreshTokenFamily(ctx, *authorization.FamilyID); err != nil {
+			return err
+		}
+	}
+	if authorization.AccessTokenJTI != nil && authorization.UserID != nil && authorization.ConsumedAt != nil {
+		if err := s.revocations.RevokeAccessToken(ctx, *authorization.AccessTokenJTI, *authorization.UserID, *authorization.ConsumedAt); err != nil {
+			return err
+		}
+	}
+	return oauthError(OAuthErrInvalidGrant, "code was already used")
+}
+
+// refresh rotates an OAuth refresh token. Presenting a consumed token
+// revokes the whole family, as with first-party refresh tokens.
+func (s *oauthServerService) refresh(ctx context.Context, client *model.OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, oauthError(OAuthErrInvalidRequest, "refresh_token is required")
+	}
+
+	token, err := s.grantRepo.FindRefreshToken(ctx, s.tokenHasher.Hash(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError(OAuthErrInvalidGrant, "invalid refresh token")
+		}
+		return nil, err
+	}
+	if token.ClientID != client.ClientID {
+		return nil, oauthError(OAuthErrInvalidGrant, "invalid refresh token")
+	}
+	if token.ConsumedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, token)
+	}
+	if token.IsExpired() {
+		return nil, oauthError(OAuthErrInvalidGrant, "refresh token has expired")
+	}
+
+	scopes, err := narrowScope(token.Scopes, req.Scope, "scope exceeds the original grant")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.grantRepo.MarkRefreshTokenConsumed(ctx, token.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, s.revokeReusedFamily(ctx, token)
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError(OAuthErrInvalidGrant, "user no longer exists")
+		}
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, oauthError(OAuthErrInvalidGrant, "user is disabled")
+	}
+
+	res, _, err := s.issueUserTokens(ctx, client, user, scopes, token.AuthTime, "", token.FamilyID)
+	return res, err
+}
+
+func (s *oauthServerService) revokeReusedFamily(ctx context.Context, token *model.OAuthRefreshToken) error {
+	slog.WarnContext(ctx, "OAuth refresh token reuse detected",
+		"event", "oauth_refresh_token_reuse",
+		"client_id", token.ClientID,
+		"user_id", token.UserID,
+		"family_id", token.FamilyID,
+	)
+
+	if err := s.grantRepo.DeleteRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return oauthError(OAuthErrInvalidGrant, "refresh token was already used")
+}
+
+// clientCredentials issues a token to the client itself, with no user
+func (s *oauthServerService) clientCredentials(client *model.OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	scopes, err := narrowScope(client.Scopes, req.Scope, "requested scope is not allowed for this client")
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, _, err := s.jwt.GenerateAccessToken(auth.Claims{
+		ClientID: client.ClientID,
+		Scope:    strings.Join(scopes, " "),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.jwt.GetAccessExpiry().Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// issueUserTokens issues an access token for the user, plus a refresh
+// token in familyID if the client may refresh and an ID token if openid
+// was granted. It also returns the access token's jti.
+func (s *oauthServerService) issueUserTokens(
+	ctx context.Context,
+	client *model.OAuthClient,
+	user *model.User,
+	scopes []string,
+	authTime time.Time,
+	nonce string,
+	familyID uuid.UUID,
+) (*TokenResponse, string, error) {
+	scope := strings.Join(scopes, " ")
+	accessToken, jti, err := s.jwt.GenerateAccessToken(auth.Claims{
+		UserID:        user.ID.String(),
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		ClientID:      client.ClientID,
+		Scope:         scope,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	res := &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.jwt.GetAccessExpiry().Seconds()),
+		Scope:       scope,
+	}
+
+	if client.AllowsGrant(model.GrantRefreshToken) {
+		refreshToken, err := s.jwt.GenerateRefreshToken()
+		if err != nil {
+			return nil, "", err
+		}
+		if err := s.grantRepo.CreateRefreshToken(ctx, &model.OAuthRefreshToken{
+			TokenHash: s.tokenHasher.Hash(refreshToken),
+			FamilyID:  familyID,
+			ClientID:  client.ClientID,
+			UserID:    user.ID,
+			Scopes:    scopes,
+			AuthTime:  authTime,
+			ExpiresAt: time.Now().Add(s.jwt.GetRefreshExpiry()),
+		}); err != nil {
+			return nil, "", err
+		}
+		res.RefreshToken = refreshToken
+	}
+
+	if slices.Contains(scopes, ScopeOpenID) {
+		now := time.Now()
+		claims := idTokenClaims{
+			AuthTime: authTime.Unix(),
+			Nonce:    nonce,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    s.opts.Issuer,
+				Subject:   user.ID.String(),
+				Audience:  jwt.ClaimStrings{client.ClientID},
+				ExpiresAt: jwt.NewNumericDate(now.Add(s.jwt.GetAccessExpiry())),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+		}
+		if slices.Contains(scopes, ScopeEmail) {
+			verified := user.IsEmailVerified()
+			claims.Email = user.Email
+			claims.EmailVerified = &verified
+		}
+		if slices.Contains(scopes, ScopeProfile) {
+			claims.Name = user.Name
+		}
+		if res.IDToken, err = s.jwt.Sign(claims); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return res, jti, nil
+}
+
+// UserInfo returns the claims about the user the access token's scopes
+// allow. Only tokens issued to OAuth clients with openid are accepted.
+func (s *oauthServerService) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	claims, err := s.jwt.ValidateAccessToken(accessToken)
+	if err != nil || claims.ClientID == "" || claims.UserID == "" {
+		return nil, oauthError(OAuthErrInvalidToken, "invalid access token")
+	}
+	revoked, err := s.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, oauthError(OAuthErrInvalidToken, "access token has been revoked")
+	}
+	scopes := parseScope(claims.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return nil, oauthError(OAuthErrInvalidToken, "access token lacks the openid scope")
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, oauthError(OAuthErrInvalidToken, "invalid access token")
+	}
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError(OAuthErrInvalidToken, "user no longer exists")
+		}
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, oauthError(OAuthErrInvalidToken, "user is disabled")
+	}
+
+	info := map[string]interface{}{"sub": user.ID.String()}
+	if slices.Contains(scopes, ScopeEmail) {
+		info["email"] = user.Email
+		info["email_verified"] = user.IsEmailVerified()
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		info["name"] = user.Name
+	}
+	return info, nil
+}
+
+func (s *oauthServerService) ListConsents(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error) {
+	return s.grantRepo.FindConsentsByUserID(ctx, userID)
+}
+
+// RevokeConsent withdraws the user's consent for a client and deletes the
+// refresh tokens issued under it
+func (s *oauthServerService) RevokeConsent(ctx context.Context, userID uuid.UUID, clientID string) error {
+	if err := s.grantRepo.DeleteConsent(ctx, userID, clientID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOAuthConsentNotFound
+		}
+		return err
+	}
+	return s.grantRepo.DeleteRefreshTokensByUserAndClient(ctx, userID, clientID)
+}
+
+func (s *oauthServerService) Configuration() *OpenIDConfiguration {
+	return &OpenIDConfiguration{
+		Issuer:                 s.opts.Issuer,
+		AuthorizationEndpoint:  s.opts.Issuer + "/oauth/authorize",
+		TokenEndpoint:          s.opts.Issuer + "/oauth/token",
+		UserinfoEndpoint:       s.opts.Issuer + "/userinfo",
+		JWKSURI:                s.opts.Issuer + "/.well-known/jwks.json",
+		ScopesSupported:        []string{ScopeOpenID, ScopeProfile, ScopeEmail},
+		ResponseTypesSupported: []string{"code"},
+		GrantTypesSupported: []string{
+			model.GrantAuthorizationCode,
+			model.GrantRefreshToken,
+			model.GrantClientCredentials,
+		},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.jwt.SigningAlgorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "email_verified", "name"},
+	}
+}
+
+// findPending loads an undecided request and its client
+func (s *oauthServerService) findPending(ctx context.Context, id uuid.UUID) (*model.OAuthAuthorization, *model.OAuthClient, error) {
+	authorization, err := s.grantRepo.FindPendingAuthorization(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrOAuthRequestNotFound
+		}
+		return nil, nil, err
+	}
+	client, err := s.clientRepo.FindByClientID(ctx, authorization.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrOAuthRequestNotFound
+		}
+		return nil, nil, err
+	}
+	return authorization, client, nil
+}
+
+func (s *oauthServerService) consentRequired(ctx context.Context, userID uuid.UUID, client *model.OAuthClient, scopes []string) (bool, error) {
+	if client.SkipConsent {
+		return false, nil
+	}
+	consent, err := s.grantRepo.FindConsent(ctx, userID, client.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	return !consent.Covers(scopes), nil
+}
+
+// parseScope splits a space separated scope parameter, dropping duplicates
+func parseScope(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// narrowScope returns the scopes a token request asks for out of those
+// granted, or all of them if it asks for none. The scope may be narrowed
+// but never widened; anything beyond the grant fails with invalid_scope
+// and the given description.
+func narrowScope(granted []string, scope, description string) ([]string, error) {
+	if scope == "" {
+		return granted, nil
+	}
+	scopes := parseScope(scope)
+	for _, s := range scopes {
+		if !slices.Contains(granted, s) {
+			return nil, oauthError(OAuthErrInvalidScope, description)
+		}
+	}
+	return scopes, nil
+}
+
+func mergeScopes(a, b []string) []string {
+	merged := slices.Clone(a)
+	for _, scope := range b {
+		if !slices.Contains(merged, scope) {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
+
+// redirectURL adds params to base, keeping any query it already has.
+// Empty values are left out.
+func redirectURL(base string, params url.Values) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	query := u.Query()
+	for key, values := range params {
+		for _, v := range values {
+			if v != "" {
+				query.Add(key, v)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/oidc"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+const (
+	testRedirectURI  = "https://client.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// oauthServerEnv runs OAuthServerService against in-memory repositories
+// with one confidential client that may use every grant
+type oauthServerEnv struct {
+	*testEnv
+	grants  *repositorytest.OAuthGrants
+	server  OAuthServerService
+	client  *model.OAuthClient
+	secret  string
+	user    *model.User
+	clients OAuthClientService
+}
+
+func newOAuthServerEnv(t *testing.T) *oauthServerEnv {
+	t.Helper()
+
+	env := &oauthServerEnv{testEnv: newTestEnv(t), grants: repositorytest.NewOAuthGrants()}
+	clientRepo := repositorytest.NewOAuthClients()
+	env.clients = NewOAuthClientService(clientRepo, env.hasher)
+	env.server = NewOAuthServerService(env.clients, clientRepo, env.grants, env.users, env.revocations, env.jwt, env.hasher, OAuthServerOptions{
+		Issuer:        "https://api.example.com",
+		ConsentURL:    testOrigin + "/oauth/consent",
+		RequestExpiry: 10 * time.Minute,
+		CodeExpiry:    time.Minute,
+	})
+
+	var err error
+	env.client, env.secret, err = env.clients.Create(context.Background(), OAuthClientRegistration{
+		Name:         "Client",
+		RedirectURIs: []string{testRedirectURI},
+		GrantTypes:   []string{model.GrantAuthorizationCode, model.GrantRefreshToken, model.GrantClientCredentials},
+		Scopes:       []string{ScopeOpenID, ScopeProfile, ScopeEmail},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.user = env.createUser(t, "alice@example.com", "correct horse battery")
+	return env
+}
+
+// authorizeRequest is a valid authorization request for the client
+func (e *oauthServerEnv) authorizeRequest(scope string) AuthorizeRequest {
+	return AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            e.client.ClientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               scope,
+		State:               "xyz",
+		Nonce:               "n-0S6",
+		CodeChallenge:       oidc.CodeChallenge(testCodeVerifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+// authorize starts a request and returns its ID from the consent page URL
+func (e *oauthServerEnv) authorize(t *testing.T, scope string) uuid.UUID {
+	t.Helper()
+	consentURL, err := e.server.Authorize(context.Background(), e.authorizeRequest(scope))
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	u, err := url.Parse(consentURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := uuid.Parse(u.Query().Get("request"))
+	if err != nil {
+		t.Fatalf("Authorize redirected to %s, want the consent page", consentURL)
+	}
+	return id
+}
+
+// code has the user approve a request and returns the authorization code
+func (e *oauthServerEnv) code(t *testing.T, scope string) string {
+	t.Helper()
+	redirect, err := e.server.Approve(context.Background(), e.user.ID, e.authorize(t, scope))
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	query := redirectQuery(t, redirect)
+	if query.Get("state") != "xyz" || query.Get("code") == "" {
+		t.Fatalf("Approve redirected to %s", redirect)
+	}
+	return query.Get("code")
+}
+
+func (e *oauthServerEnv) exchange(code, redirectURI, verifier string) (*TokenResponse, error) {
+	return e.server.Token(context.Background(), TokenRequest{
+		GrantType:    model.GrantAuthorizationCode,
+		ClientID:     e.client.ClientID,
+		ClientSecret: e.secret,
+		Code:         code,
+		RedirectURI:  redirectURI,
+		CodeVerifier: verifier,
+	})
+}
+
+func (e *oauthServerEnv) refresh(refreshToken, scope string) (*TokenResponse, error) {
+	return e.server.Token(context.Background(), TokenRequest{
+		GrantType:    model.GrantRefreshToken,
+		ClientID:     e.client.ClientID,
+		ClientSecret: e.secret,
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	})
+}
+
+// tokens runs the authorization code flow for scope
+func (e *oauthServerEnv) tokens(t *testing.T, scope string) *TokenResponse {
+	t.Helper()
+	res, err := e.exchange(e.code(t, scope), testRedirectURI, testCodeVerifier)
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	return res
+}
+
+// redirectQuery returns the query of a redirect to the client
+func redirectQuery(t *testing.T, redirect string) url.Values {
+	t.Helper()
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme+"://"+u.Host+u.Path != testRedirectURI {
+		t.Fatalf("redirected to %s, want %s", redirect, testRedirectURI)
+	}
+	return u.Query()
+}
+
+func TestAuthorizeRejectsUnregisteredRedirectURI(t *testing.T) {
+	env := newOAuthServerEnv(t)
+	req := env.authorizeRequest(ScopeOpenID)
+	req.RedirectURI = "https://attacker.example.com/callback"
+
+	// Errors cannot be sent to an unregistered URI
+	_, err := env.server.Authorize(context.Background(), req)
+	requireOAuthError(t, err, OAuthErrInvalidRequest)
+}
+
+func TestAuthorizeRequiresS256Challenge(t *testing.T) {
+	env := newOAuthServerEnv(t)
+	tests := []struct {
+		name      string
+		challenge string
+		method    string
+	}{
+		{"missing", "", ""},
+		{"missing method", oidc.CodeChallenge(testCodeVerifier), ""},
+		{"plain", testCodeVerifier, "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := env.authorizeRequest(ScopeOpenID)
+			req.CodeChallenge, req.CodeChallengeMethod = tt.challenge, tt.method
+			redirect, err := env.server.Authorize(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+			query := redirectQuery(t, redirect)
+			if query.Get("error") != OAuthErrInvalidRequest || query.Get("state") != "xyz" {
+				t.Errorf("Authorize redirected to %s, want invalid_request", redirect)
+			}
+		})
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	env := newOAuthServerEnv(t)
+	ctx := context.Background()
+
+	id := env.authorize(t, "openid email")
+	req, err := env.server.GetRequest(ctx, env.user.ID, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.ConsentRequired || req.ClientID != env.client.ClientID || !slices.Equal(req.Scopes, []string{ScopeOpenID, ScopeEmail}) {
+		t.Fatalf("GetRequest = %+v", req)
+	}
+	redirect, err := env.server.Approve(ctx, env.user.ID, id)
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	res, err := env.exchange(redirectQuery(t, redirect).Get("code"), testRedirectURI, testCodeVerifier)
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if res.AccessToken == "" || res.RefreshToken == "" || res.IDToken == "" || res.Scope != "openid email" {
+		t.Fatalf("Token = %+v", res)
+	}
+	claims, err := env.jwt.ValidateAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != env.user.ID.String() || claims.ClientID != env.client.ClientID {
+		t.Errorf("access token claims = %+v", claims)
+	}
+
+	// The consent covers a later request for the same scopes
+	req, err = env.server.GetRequest(ctx, env.user.ID, env.authorize(t, "openid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ConsentRequired {
+		t.Error("consent is asked again for granted scopes")
+	}
+}
+
+func TestDenyAuthorization(t *testing.T) {
+	env := newOAuthServerEnv(t)
+	ctx := context.Background()
+	id := env.authorize(t, ScopeOpenID)
+
+	redirect, err := env.server.Deny(ctx, env.user.ID, id)
+	if err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+	query := redirectQuery(t, redirect)
+	if query.Get("error") != OAuthErrAccessDenied || query.Get("state") != "xyz" || query.Has("code") {
+		t.Errorf("Deny redirected to %s", redirect)
+	}
+	if _, err := env.server.Approve(ctx, env.user.ID, id); !errors.Is(err, ErrOAuthRequestNotFound) {
+		t.Errorf("Approve after Deny: err = %v, want ErrOAuthRequestNotFound", err)
+	}
+	if consents, _ := env.server.ListConsents(ctx, env.user.ID); len(consents) != 0 {
+		t.Errorf("consents = %v, want none", consents)
+	}
+}
+
+func TestExchangeCodeRejectsMismatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		redirectURI string
+		verifier    string
+	}{
+		{"wrong verifier", testRedirectURI, "another-verifier-of-sufficient-length-0123456789"},
+		{"redirect mismatch", testRedirectURI + "/other", testCodeVerifier},
+		{"missing redirect", "", testCodeVerifier},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOAuthServerEnv(t)
+			code := env.code(t, ScopeOpenID)
+
+			_, err := env.exchange(code, tt.redirectURI, tt.verifier)
+			requireOAuthError(t, err, OAuthErrInvalidGrant)
+
+			// A failed attempt burns the code
+			_, err = env.exchange(code, testRedirectURI, testCodeVerifier)
+			requireOAuthError(t, err, OAuthErrInvalidGrant)
+		})
+	}
+}
+
+func TestExchangeCodeReuseRevokesTokens(t *testing.T) {
+	env := newOAuthServerEnv(t)
+	ctx := context.Background()
+	code := env.code(t, ScopeOpenID)
+	res, err := env.exchange(code, testRedirectURI, testCodeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.server.UserInfo(ctx, res.AccessToken); err != nil {
+		t.Fatalf("UserInfo: %v", err)
+	}
+
+	_, err = env.exchange(code, testRedirectURI, testCodeVerifier)
+	requireOAuthError(t, err, OAuthErrInvalidGrant)
+
+	_, err = env.refresh(res.RefreshToken, "")
+	requireOAuthError(t, err, OAuthErrInvalidGrant)
+	_, err = env.server.UserInfo(ctx, res.AccessToken)
+	requireOAuthError(t, err, OAuthErrInvalidToken)
+
+	// Unknown codes are rejected without side effects
+	_, err = env.exchange("unknown", testRedirectURI, testCodeVerifier)
+	requireOAuthError(t, err, OAuthErrInvalidGrant)
+}
+
+func TestOAuthRefreshRotation(t *testing.T) {
+	env := newOAuthServerEnv(t)
+	first := env.tokens(t, "openid email")
+
+	second, err := env.refresh(first.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken || second.Scope != "openid email" {
+		t.Fatalf("refresh = %+v", second)
+	}
+
+	// Replaying the rotated token revokes the whole family
+	_, err = env.refresh(first.RefreshToken, "")
+	requireOAuthError(t, err, OAuthErrInvalidGrant)
+	_, err = env.refresh(second.RefreshToken, "")
+	requireOAuthError(t, err, OAuthErrInvalidGrant)
+
+	// Other grants are untouched
+	if _, err := env.refresh(env.tokens(t, ScopeOpenID).RefreshToken, ""); err != nil {
+		t.Errorf("refresh of another grant: %v", err)
+	}
+}
+
+func TestOAuthScopeCanOnlyNarrow(t *testing.T) {
+	env := newOAuthServerEnv(t)
+	ctx := context.Background()
+	res := env.tokens(t, "openid email")
+
+	_, err := env.refresh(res.RefreshToken, "openid profile")
+	requireOAuthError(t, err, OAuthErrInvalidScope)
+	narrowed, err := env.refresh(res.RefreshToken, ScopeOpenID)
+	if err != nil {
+		t.Fatalf("narrowing refresh: %v", err)
+	}
+	if narrowed.Scope != ScopeOpenID {
+		t.Errorf("narrowed scope = %q", narrowed.Scope)
+	}
+	// The narrowed grant cannot be widened back
+	_, err = env.refresh(narrowed.RefreshToken, "openid email")
+	requireOAuthError(t, err, OAuthErrInvalidScope)
+
+	credentials := func(scope string) (*TokenResponse, error) {
+		return env.server.Token(ctx, TokenRequest{
+			GrantType:    model.GrantClientCredentials,
+			ClientID:     env.client.ClientID,
+			ClientSecret: env.secret,
+			Scope:        scope,
+		})
+	}
+	if res, err := credentials(""); err != nil || res.Scope != "openid profile email" {
+		t.Errorf("client_credentials without scope = %+v, %v", res, err)
+	}
+	if res, err := credentials(ScopeEmail); err != nil || res.Scope != ScopeEmail || res.RefreshToken != "" {
+		t.Errorf("narrowed client_credentials = %+v, %v", res, err)
+	}
+	_, err = credentials("openid admin")
+	requireOAuthError(t, err, OAuthErrInvalidScope)
+}
+
+func TestUserInfoFiltersClaimsByScope(t *testing.T) {
+	env := newOAuthServerEnv(t)
+	ctx := context.Background()
+	sub := env.user.ID.String()
+
+	tests := []struct {
+		scope string
+		want  map[string]interface{}
+	}{
+		{"openid", map[string]interface{}{"sub": sub}},
+		{"openid email", map[string]interface{}{"sub": sub, "email": env.user.Email, "email_verified": true}},
+		{"openid profile", map[string]interface{}{"sub": sub, "name": env.user.Name}},
+	}
+	for _, tt := range tests {
+		info, err := env.server.UserInfo(ctx, env.tokens(t, tt.scope).AccessToken)
+		if err != nil {
+			t.Fatalf("UserInfo(%s): %v", tt.scope, err)
+		}
+		if !maps.Equal(info, tt.want) {
+			t.Errorf("UserInfo(%s) = %v, want %v", tt.scope, info, tt.want)
+		}
+	}
+
+	_, err := env.server.UserInfo(ctx, env.tokens(t, ScopeEmail).AccessToken)
+	requireOAuthError(t, err, OAuthErrInvalidToken)
+
+	// First-party access tokens are not accepted
+	session, _ := env.login(t, env.user.Email, "correct horse battery")
+	_, err = env.server.UserInfo(ctx, session.AccessToken)
+	requireOAuthError(t, err, OAuthErrInvalidToken)
+}
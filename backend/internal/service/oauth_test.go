@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/oidc"
+	"github.com/ablaze/gonexttemp-backend/internal/oidc/oidctest"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+)
+
+const testProvider = "test"
+
+// oauthEnv runs OAuthService against an oidctest provider
+type oauthEnv struct {
+	*testEnv
+	idp        *oidctest.Server
+	identities *repositorytest.Identities
+	oauth      OAuthService
+}
+
+func newOAuthEnv(t *testing.T, opts OAuthOptions) *oauthEnv {
+	t.Helper()
+
+	env := &oauthEnv{testEnv: newTestEnv(t)}
+	env.idp = oidctest.NewServer("client-id", "client-secret")
+	t.Cleanup(env.idp.Close)
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Name:         testProvider,
+		Issuer:       env.idp.Issuer(),
+		ClientID:     env.idp.ClientID,
+		ClientSecret: env.idp.ClientSecret,
+		RedirectURL:  testOrigin + "/api/v1/auth/oauth/" + testProvider + "/callback",
+	}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.StateExpiry == 0 {
+		opts.StateExpiry = 10 * time.Minute
+	}
+	env.identities = repositorytest.NewIdentities(env.users)
+	env.oauth = NewOAuthService(env.identities, env.users, []*oidc.Provider{provider}, env.hasher, opts)
+	return env
+}
+
+// authorize starts a flow and returns its state and the callback the
+// provider redirected to
+func (e *oauthEnv) authorize(t *testing.T) (string, *url.URL) {
+	t.Helper()
+
+	start, err := e.oauth.Start(context.Background(), testProvider, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback, err := e.idp.Authorize(start.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if callback.Query().Get("state") != start.State {
+		t.Fatalf("callback state = %q, want %q", callback.Query().Get("state"), start.State)
+	}
+	return start.State, callback
+}
+
+func (e *oauthEnv) complete(t *testing.T) (*OAuthResult, error) {
+	t.Helper()
+	state, callback := e.authorize(t)
+	return e.oauth.Complete(context.Background(), testProvider, state, callback.Query().Get("code"))
+}
+
+func (e *oauthEnv) userCount(t *testing.T) int {
+	t.Helper()
+	users, err := e.users.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(users)
+}
+
+func TestOAuthCompleteSignsUpAndLogsIn(t *testing.T) {
+	env := newOAuthEnv(t, OAuthOptions{})
+	ctx := context.Background()
+	env.idp.SetUser(oidctest.User{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"})
+
+	first, err := env.complete(t)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	user, err := env.users.FindByID(ctx, first.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "alice@example.com" || !user.IsEmailVerified() || user.Password != "" {
+		t.Fatalf("signed up user = %+v", user)
+	}
+
+	second, err := env.complete(t)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if second.UserID != first.UserID || second.Linked {
+		t.Fatalf("second login = %+v, want user %s", second, first.UserID)
+	}
+	if n := env.userCount(t); n != 1 {
+		t.Fatalf("%d users after two logins with one identity", n)
+	}
+}
+
+func TestOAuthCompleteRejectsReusedState(t *testing.T) {
+	env := newOAuthEnv(t, OAuthOptions{})
+	ctx := context.Background()
+
+	state, callback := env.authorize(t)
+	if _, err := env.oauth.Complete(ctx, testProvider, state, callback.Query().Get("code")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := env.oauth.Complete(ctx, testProvider, state, callback.Query().Get("code"))
+	if !errors.Is(err, ErrInvalidOAuthState) {
+		t.Fatalf("reused state error = %v, want %v", err, ErrInvalidOAuthState)
+	}
+}
+
+func TestOAuthCompleteRejectsUnknownState(t *testing.T) {
+	env := newOAuthEnv(t, OAuthOptions{})
+
+	_, callback := env.authorize(t)
+	_, err := env.oauth.Complete(context.Background(), testProvider, "forged-state", callback.Query().Get("code"))
+	if !errors.Is(err, ErrInvalidOAuthState) {
+		t.Fatalf("Complete error = %v, want %v", err, ErrInvalidOAuthState)
+	}
+}
+
+func TestOAuthCompleteRejectsWrongNonce(t *testing.T) {
+	env := newOAuthEnv(t, OAuthOptions{})
+	env.idp.SetNonce("replayed-nonce")
+
+	if _, err := env.complete(t); !errors.Is(err, ErrOAuthFailed) {
+		t.Fatalf("Complete error = %v, want %v", err, ErrOAuthFailed)
+	}
+	if n := env.userCount(t); n != 0 {
+		t.Fatalf("%d users created by a rejected login", n)
+	}
+}
+
+func TestOAuthCompleteRejectsCodeOfAnotherFlow(t *testing.T) {
+	env := newOAuthEnv(t, OAuthOptions{})
+
+	// The code was issued for the first flow's PKCE challenge, so the
+	// second flow's verifier does not match it
+	_, first := env.authorize(t)
+	secondState, _ := env.authorize(t)
+
+	_, err := env.oauth.Complete(context.Background(), testProvider, secondState, first.Query().Get("code"))
+	if !errors.Is(err, ErrOAuthFailed) {
+		t.Fatalf("Complete error = %v, want %v", err, ErrOAuthFailed)
+	}
+	if n := env.userCount(t); n != 0 {
+		t.Fatalf("%d users created by a rejected login", n)
+	}
+}
+
+func TestOAuthCompleteRequiresVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		user oidctest.User
+	}{
+		{"unverified", oidctest.User{Subject: "sub-1", Email: "alice@example.com", EmailVerified: false}},
+		{"missing", oidctest.User{Subject: "sub-1", EmailVerified: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOAuthEnv(t, OAuthOptions{LinkVerifiedEmail: true})
+			existing := env.createUser(t, "alice@example.com", "correct horse battery")
+			env.idp.SetUser(tt.user)
+
+			if _, err := env.complete(t); !errors.Is(err, ErrOAuthEmailRequired) {
+				t.Fatalf("Complete error = %v, want %v", err, ErrOAuthEmailRequired)
+			}
+			if n := env.userCount(t); n != 1 {
+				t.Fatalf("%d users after a rejected login, want 1", n)
+			}
+			if identities, _ := env.identities.FindByUserID(context.Background(), existing.ID); len(identities) != 0 {
+				t.Fatalf("unverified email linked %d identities", len(identities))
+			}
+		})
+	}
+}
+
+func TestOAuthCompleteWithEmailOfExistingAccount(t *testing.T) {
+	tests := []struct {
+		name              string
+		linkVerifiedEmail bool
+		accountVerified   bool
+		wantErr           error
+	}{
+		{name: "linking disabled", linkVerifiedEmail: false, accountVerified: true, wantErr: ErrOAuthAccountExists},
+		{name: "account email unverified", linkVerifiedEmail: true, accountVerified: false, wantErr: ErrOAuthAccountExists},
+		{name: "linking enabled", linkVerifiedEmail: true, accountVerified: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOAuthEnv(t, OAuthOptions{LinkVerifiedEmail: tt.linkVerifiedEmail})
+			ctx := context.Background()
+			existing := env.createUser(t, "alice@example.com", "correct horse battery")
+			if !tt.accountVerified {
+				existing.EmailVerifiedAt = nil
+				if err := env.users.Update(ctx, existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			env.idp.SetUser(oidctest.User{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true})
+
+			result, err := env.complete(t)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Complete error = %v, want %v", err, tt.wantErr)
+			}
+			if n := env.userCount(t); n != 1 {
+				t.Fatalf("%d users after logging in with an existing email, want 1", n)
+			}
+
+			identities, err := env.identities.FindByUserID(ctx, existing.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != nil {
+				if len(identities) != 0 {
+					t.Fatalf("refused login linked %d identities", len(identities))
+				}
+				return
+			}
+			if result.UserID != existing.ID || result.Linked {
+				t.Fatalf("Complete = %+v, want login as %s", result, existing.ID)
+			}
+			if len(identities) != 1 || identities[0].Subject != "sub-1" {
+				t.Fatalf("identities of existing user = %+v", identities)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/ablaze/gonexttemp-backend/internal/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPasskeyNotFound          = errors.New("passkey not found")
+	ErrPasskeyAlreadyRegistered = errors.New("passkey already registered")
+	ErrInvalidPasskey           = errors.New("invalid passkey response")
+	ErrInvalidPasskeyChallenge  = errors.New("invalid or expired passkey challenge")
+)
+
+const defaultPasskeyName = "Passkey"
+
+type PasskeyService interface {
+	BeginRegistration(ctx context.Context, userID uuid.UUID) (*webauthn.CreationOptions, error)
+	FinishRegistration(ctx context.Context, userID uuid.UUID, req PasskeyRegistrationRequest) (*model.WebAuthnCredential, error)
+	List(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	BeginLogin(ctx context.Context) (*webauthn.RequestOptions, error)
+	FinishLogin(ctx context.Context, credential *webauthn.AssertionResponse) (uuid.UUID, error)
+}
+
+type PasskeyRegistrationRequest struct {
+	Name       string                        `json:"name" validate:"max=100"`
+	Credential webauthn.RegistrationResponse `json:"credential"`
+}
+
+type PasskeyLoginRequest struct {
+	Credential webauthn.AssertionResponse `json:"credential"`
+}
+
+// PasskeyOptions configures PasskeyService
+type PasskeyOptions struct {
+	ChallengeExpiry time.Duration
+}
+
+type passkeyService struct {
+	webauthnRepo repository.WebAuthnRepository
+	userRepo     repository.UserRepository
+	rp           *webauthn.RelyingParty
+	tokenHasher  *auth.TokenHasher
+	opts         PasskeyOptions
+}
+
+func NewPasskeyService(
+	webauthnRepo repository.WebAuthnRepository,
+	userRepo repository.UserRepository,
+	rp *webauthn.RelyingParty,
+	tokenHasher *auth.TokenHasher,
+	opts PasskeyOptions,
+) PasskeyService {
+	return &passkeyService{
+		webauthnRepo: webauthnRepo,
+		userRepo:     userRepo,
+		rp:           rp,
+		tokenHasher:  tokenHasher,
+		opts:         opts,
+	}
+}
+
+// BeginRegistration starts adding a passkey to the user's account. The
+// user handle stored on the authenticator is the user ID.
+func (s *passkeyService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*webauthn.CreationOptions, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	existing, err := s.webauthnRepo.FindCredentialsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(existing))
+	for _, credential := range existing {
+		exclude = append(exclude, webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.CredentialID,
+			Transports: credential.Transports,
+		})
+	}
+
+	challenge, err := s.createChallenge(ctx, model.WebAuthnRegistration, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rp.CreationOptions(webauthn.UserEntity{
+		ID:          user.ID[:],
+		Name:        user.Email,
+		DisplayName: user.Name,
+	}, challenge, exclude), nil
+}
+
+func (s *passkeyService) FinishRegistration(ctx context.Context, userID uuid.UUID, req PasskeyRegistrationRequest) (*model.WebAuthnCredential, error) {
+	challenge, err := s.consumeChallenge(ctx, model.WebAuthnRegistration, req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID == nil || *challenge.UserID != userID {
+		return nil, ErrInvalidPasskeyChallenge
+	}
+
+	verified, err := s.rp.VerifyRegistration(&req.Credential, challenge.raw)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	if _, err := s.webauthnRepo.FindCredentialByCredentialID(ctx, verified.ID); err == nil {
+		return nil, ErrPasskeyAlreadyRegistered
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	name := req.Name
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	transports := verified.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+
+	credential := &model.WebAuthnCredential{
+		UserID:         userID,
+		CredentialID:   verified.ID,
+		PublicKey:      verified.PublicKey,
+		SignCount:      int64(verified.SignCount),
+		Transports:     transports,
+		AAGUID:         verified.AAGUID,
+		Name:           name,
+		BackupEligible: verified.BackupEligible,
+		BackupState:    verified.BackupState,
+	}
+	if err := s.webauthnRepo.CreateCredential(ctx, credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (s *passkeyService) List(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error) {
+	return s.webauthnRepo.FindCredentialsByUserID(ctx, userID)
+}
+
+func (s *passkeyService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.webauthnRepo.DeleteCredential(ctx, userID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPasskeyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// BeginLogin starts a passkey login. No user is named up front; the
+// browser offers the user's discoverable credentials.
+func (s *passkeyService) BeginLogin(ctx context.Context) (*webauthn.RequestOptions, error) {
+	challenge, err := s.createChallenge(ctx, model.WebAuthnLogin, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.rp.RequestOptions(challenge, nil), nil
+}
+
+// FinishLogin verifies a passkey assertion and returns the user it belongs
+// to
+func (s *passkeyService) FinishLogin(ctx context.Context, assertion *webauthn.AssertionResponse) (uuid.UUID, error) {
+	challenge, err := s.consumeChallenge(ctx, model.WebAuthnLogin, assertion.Response.ClientDataJSON)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	credential, err := s.webauthnRepo.FindCredentialByCredentialID(ctx, assertion.RawID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidPasskey
+		}
+		return uuid.Nil, err
+	}
+	if len(assertion.Response.UserHandle) > 0 && !bytes.Equal(assertion.Response.UserHandle, credential.UserID[:]) {
+		return uuid.Nil, ErrInvalidPasskey
+	}
+
+	result, err := s.rp.VerifyAssertion(assertion, challenge.raw, credential.PublicKey, uint32(credential.SignCount))
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCountRegressed) {
+			slog.WarnContext(ctx, "Passkey signature counter regressed",
+				"event", "passkey_sign_count_regressed",
+				"user_id", credential.UserID,
+				"credential_id", credential.ID,
+			)
+		}
+		return uuid.Nil, ErrInvalidPasskey
+	}
+
+	if err := s.webauthnRepo.UpdateCredentialUsage(ctx, credential.ID, credential.SignCount, int64(result.SignCount), result.BackupState); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidPasskey
+		}
+		return uuid.Nil, err
+	}
+	return credential.UserID, nil
+}
+
+// pendingChallenge is a consumed ceremony together with its raw challenge
+type pendingChallenge struct {
+	*model.WebAuthnChallenge
+	raw []byte
+}
+
+func (s *passkeyService) createChallenge(ctx context.Context, purpose string, userID *uuid.UUID) ([]byte, error) {
+	challenge, err := webauthn.GenerateChallenge()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.webauthnRepo.CreateChallenge(ctx, &model.WebAuthnChallenge{
+		ChallengeHash: s.hashChallenge(challenge),
+		Purpose:       purpose,
+		UserID:        userID,
+		ExpiresAt:     time.Now().Add(s.opts.ChallengeExpiry),
+	}); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// consumeChallenge finds the ceremony a response belongs to through the
+// challenge in its client data
+func (s *passkeyService) consumeChallenge(ctx context.Context, purpose string, clientDataJSON []byte) (*pendingChallenge, error) {
+	raw, err := webauthn.ClientChallenge(clientDataJSON)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	challenge, err := s.webauthnRepo.ConsumeChallenge(ctx, purpose, s.hashChallenge(raw))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPasskeyChallenge
+		}
+		return nil, err
+	}
+	return &pendingChallenge{WebAuthnChallenge: challenge, raw: raw}, nil
+}
+
+func (s *passkeyService) hashChallenge(challenge []byte) string {
+	return s.tokenHasher.Hash(base64.RawURLEncoding.EncodeToString(challenge))
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/webauthn"
+	"github.com/ablaze/gonexttemp-backend/internal/webauthn/webauthntest"
+)
+
+func registerPasskey(t *testing.T, env *testEnv, authenticator *webauthntest.Authenticator, user *model.User) *model.WebAuthnCredential {
+	t.Helper()
+	ctx := context.Background()
+
+	options, err := env.passkeys.BeginRegistration(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := env.passkeys.FinishRegistration(ctx, user.ID, PasskeyRegistrationRequest{Credential: *resp})
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	return credential
+}
+
+// passkeyAssertion runs the authenticator on fresh login options
+func passkeyAssertion(t *testing.T, env *testEnv, authenticator *webauthntest.Authenticator) *webauthn.AssertionResponse {
+	t.Helper()
+
+	options, err := env.passkeys.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion, err := authenticator.Login(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assertion
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	authenticator := webauthntest.NewAuthenticator(testOrigin)
+
+	credential := registerPasskey(t, env, authenticator, user)
+	if credential.UserID != user.ID || credential.Name != defaultPasskeyName {
+		t.Fatalf("registered credential = %+v", credential)
+	}
+
+	for i := 0; i < 2; i++ {
+		userID, err := env.passkeys.FinishLogin(ctx, passkeyAssertion(t, env, authenticator))
+		if err != nil {
+			t.Fatalf("login %d: %v", i+1, err)
+		}
+		if userID != user.ID {
+			t.Fatalf("login %d: user = %s, want %s", i+1, userID, user.ID)
+		}
+	}
+
+	stored, err := env.webauthn.FindCredentialByCredentialID(ctx, credential.CredentialID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SignCount != 2 || stored.LastUsedAt == nil {
+		t.Fatalf("after two logins sign count = %d, last used = %v", stored.SignCount, stored.LastUsedAt)
+	}
+}
+
+func TestPasskeyRegistrationRejectsInvalidResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(*webauthntest.Authenticator, *webauthn.CreationOptions)
+		want    error
+	}{
+		{
+			name: "wrong origin",
+			prepare: func(a *webauthntest.Authenticator, _ *webauthn.CreationOptions) {
+				a.Origin = "https://evil.example.com"
+			},
+			want: ErrInvalidPasskey,
+		},
+		{
+			name:    "user not present",
+			prepare: func(a *webauthntest.Authenticator, _ *webauthn.CreationOptions) { a.OmitUserPresence = true },
+			want:    ErrInvalidPasskey,
+		},
+		{
+			name:    "user not verified",
+			prepare: func(a *webauthntest.Authenticator, _ *webauthn.CreationOptions) { a.OmitUserVerification = true },
+			want:    ErrInvalidPasskey,
+		},
+		{
+			name: "challenge not issued",
+			prepare: func(_ *webauthntest.Authenticator, o *webauthn.CreationOptions) {
+				o.Challenge = mustChallenge(t)
+			},
+			want: ErrInvalidPasskeyChallenge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := context.Background()
+			user := env.createUser(t, "alice@example.com", "correct horse battery")
+			authenticator := webauthntest.NewAuthenticator(testOrigin)
+
+			options, err := env.passkeys.BeginRegistration(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.prepare(authenticator, options)
+			resp, err := authenticator.Register(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = env.passkeys.FinishRegistration(ctx, user.ID, PasskeyRegistrationRequest{Credential: *resp})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("FinishRegistration error = %v, want %v", err, tt.want)
+			}
+			if credentials, _ := env.passkeys.List(ctx, user.ID); len(credentials) != 0 {
+				t.Fatalf("stored %d credentials for a rejected registration", len(credentials))
+			}
+		})
+	}
+}
+
+func TestPasskeyRegistrationRejectsChallengeOfAnotherUser(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	alice := env.createUser(t, "alice@example.com", "correct horse battery")
+	bob := env.createUser(t, "bob@example.com", "correct horse battery")
+
+	options, err := env.passkeys.BeginRegistration(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := webauthntest.NewAuthenticator(testOrigin).Register(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = env.passkeys.FinishRegistration(ctx, bob.ID, PasskeyRegistrationRequest{Credential: *resp})
+	if !errors.Is(err, ErrInvalidPasskeyChallenge) {
+		t.Fatalf("FinishRegistration error = %v, want %v", err, ErrInvalidPasskeyChallenge)
+	}
+}
+
+func TestPasskeyLoginRejectsInvalidAssertions(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare runs before the authenticator signs
+		prepare func(*webauthntest.Authenticator, *webauthn.RequestOptions)
+		// tamper runs on the signed assertion
+		tamper func(*webauthn.AssertionResponse, *model.User)
+		want   error
+	}{
+		{
+			name:    "wrong origin",
+			prepare: func(a *webauthntest.Authenticator, _ *webauthn.RequestOptions) { a.Origin = "https://evil.example.com" },
+			want:    ErrInvalidPasskey,
+		},
+		{
+			name: "challenge not issued",
+			prepare: func(_ *webauthntest.Authenticator, o *webauthn.RequestOptions) {
+				o.Challenge = mustChallenge(t)
+			},
+			want: ErrInvalidPasskeyChallenge,
+		},
+		{
+			name:    "user not present",
+			prepare: func(a *webauthntest.Authenticator, _ *webauthn.RequestOptions) { a.OmitUserPresence = true },
+			want:    ErrInvalidPasskey,
+		},
+		{
+			name:    "user not verified",
+			prepare: func(a *webauthntest.Authenticator, _ *webauthn.RequestOptions) { a.OmitUserVerification = true },
+			want:    ErrInvalidPasskey,
+		},
+		{
+			name: "user handle of another user",
+			tamper: func(a *webauthn.AssertionResponse, other *model.User) {
+				a.Response.UserHandle = other.ID[:]
+			},
+			want: ErrInvalidPasskey,
+		},
+		{
+			name: "signature over other data",
+			tamper: func(a *webauthn.AssertionResponse, _ *model.User) {
+				a.Response.AuthenticatorData[len(a.Response.AuthenticatorData)-1]++
+			},
+			want: ErrInvalidPasskey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := context.Background()
+			alice := env.createUser(t, "alice@example.com", "correct horse battery")
+			bob := env.createUser(t, "bob@example.com", "correct horse battery")
+			authenticator := webauthntest.NewAuthenticator(testOrigin)
+			credential := registerPasskey(t, env, authenticator, alice)
+
+			options, err := env.passkeys.BeginLogin(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(authenticator, options)
+			}
+			assertion, err := authenticator.Login(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(assertion, bob)
+			}
+
+			userID, err := env.passkeys.FinishLogin(ctx, assertion)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("FinishLogin = %s, %v; want error %v", userID, err, tt.want)
+			}
+			stored, err := env.webauthn.FindCredentialByCredentialID(ctx, credential.CredentialID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.SignCount != 0 {
+				t.Fatalf("rejected login advanced the sign count to %d", stored.SignCount)
+			}
+		})
+	}
+}
+
+func TestPasskeyLoginRejectsReusedChallenge(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	authenticator := webauthntest.NewAuthenticator(testOrigin)
+	registerPasskey(t, env, authenticator, user)
+
+	assertion := passkeyAssertion(t, env, authenticator)
+	if _, err := env.passkeys.FinishLogin(ctx, assertion); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.passkeys.FinishLogin(ctx, assertion); !errors.Is(err, ErrInvalidPasskeyChallenge) {
+		t.Fatalf("replayed assertion error = %v, want %v", err, ErrInvalidPasskeyChallenge)
+	}
+}
+
+func TestPasskeyLoginRejectsRegistrationChallenge(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	authenticator := webauthntest.NewAuthenticator(testOrigin)
+	registerPasskey(t, env, authenticator, user)
+
+	creation, err := env.passkeys.BeginRegistration(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := env.passkeys.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Challenge = creation.Challenge
+	assertion, err := authenticator.Login(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := env.passkeys.FinishLogin(ctx, assertion); !errors.Is(err, ErrInvalidPasskeyChallenge) {
+		t.Fatalf("FinishLogin error = %v, want %v", err, ErrInvalidPasskeyChallenge)
+	}
+}
+
+func TestPasskeyLoginRejectsSignCountRegression(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	authenticator := webauthntest.NewAuthenticator(testOrigin)
+	credential := registerPasskey(t, env, authenticator, user)
+	clone := authenticator.Clone()
+
+	for i := 0; i < 2; i++ {
+		if _, err := env.passkeys.FinishLogin(ctx, passkeyAssertion(t, env, authenticator)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The clone's counter is behind the original's
+	if _, err := env.passkeys.FinishLogin(ctx, passkeyAssertion(t, env, clone)); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("cloned authenticator error = %v, want %v", err, ErrInvalidPasskey)
+	}
+	stored, err := env.webauthn.FindCredentialByCredentialID(ctx, credential.CredentialID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SignCount != 2 {
+		t.Fatalf("sign count = %d after a regressed login, want 2", stored.SignCount)
+	}
+}
+
+func TestLoginWithPasskeyMatchesPasswordLogin(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	authenticator := webauthntest.NewAuthenticator(testOrigin)
+	registerPasskey(t, env, authenticator, user)
+	client := ClientInfo{UserAgent: "test", IPAddress: "192.0.2.1"}
+
+	passwordRes, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, client)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	passkeyRes, err := env.auth.LoginWithPasskey(ctx, PasskeyLoginRequest{Credential: *passkeyAssertion(t, env, authenticator)}, client)
+	if err != nil {
+		t.Fatalf("LoginWithPasskey: %v", err)
+	}
+
+	for name, res := range map[string]*AuthResponse{"password": passwordRes, "passkey": passkeyRes} {
+		if res.User == nil || res.User.ID != user.ID {
+			t.Fatalf("%s login user = %+v", name, res.User)
+		}
+		if res.MFAToken != "" || res.EmailVerificationRequired {
+			t.Fatalf("%s login did not complete: %+v", name, res)
+		}
+		if res.ExpiresIn != passwordRes.ExpiresIn {
+			t.Fatalf("%s login expires in %d, password login in %d", name, res.ExpiresIn, passwordRes.ExpiresIn)
+		}
+
+		claims, err := env.jwt.ValidateAccessToken(res.AccessToken)
+		if err != nil {
+			t.Fatalf("%s login access token: %v", name, err)
+		}
+		if claims.UserID != user.ID.String() || claims.Email != user.Email || !claims.EmailVerified || claims.SessionID == "" {
+			t.Fatalf("%s login claims = %+v", name, claims)
+		}
+
+		stored, err := env.tokens.FindByToken(ctx, env.hasher.Hash(res.RefreshToken))
+		if err != nil {
+			t.Fatalf("%s login refresh token not stored: %v", name, err)
+		}
+		if stored.UserID != user.ID || stored.FamilyID.String() != claims.SessionID || stored.AccessTokenJTI != claims.ID {
+			t.Fatalf("%s login refresh token = %+v", name, stored)
+		}
+	}
+
+	if passkeyRes.RefreshToken == passwordRes.RefreshToken {
+		t.Fatal("password and passkey logins share a refresh token")
+	}
+}
+
+func mustChallenge(t *testing.T) []byte {
+	t.Helper()
+	challenge, err := webauthn.GenerateChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ablaze/gonexttemp-backend/internal/passwordpolicy"
+)
+
+var resetPasswordURL = regexp.MustCompile(`https://\S+/reset-password\?token=\S+`)
+
+// requireViolations checks that err is a policy error breaking exactly rules
+func requireViolations(t *testing.T, err error, rules ...string) {
+	t.Helper()
+	var policyErr *passwordpolicy.Error
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("err = %v, want password policy error", err)
+	}
+	got := make([]string, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		got[i] = v.Rule
+	}
+	if strings.Join(got, ",") != strings.Join(rules, ",") {
+		t.Fatalf("violations = %v, want %v", got, rules)
+	}
+}
+
+func TestRegisterPasswordPolicy(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		password string
+		rules    []string
+	}{
+		{"short", []string{passwordpolicy.RuleTooShort}},
+		{strings.Repeat("long enough ", 11), []string{passwordpolicy.RuleTooLong}},
+		{"Password123", []string{passwordpolicy.RuleCommon}},
+		{"alice.liddell-1865", []string{passwordpolicy.RulePersonalInfo}},
+		{"wonderland rabbit hole", []string{passwordpolicy.RulePersonalInfo}},
+		{"alice", []string{passwordpolicy.RuleTooShort, passwordpolicy.RulePersonalInfo}},
+	}
+	for _, tt := range tests {
+		req := RegisterRequest{Email: "alice.liddell@example.com", Password: tt.password, Name: "Alice Wonderland"}
+		_, err := env.auth.Register(ctx, req, ClientInfo{})
+		requireViolations(t, err, tt.rules...)
+	}
+
+	req := RegisterRequest{Email: "alice.liddell@example.com", Password: "correct horse battery", Name: "Alice Wonderland"}
+	if _, err := env.auth.Register(ctx, req, ClientInfo{}); err != nil {
+		t.Fatalf("Register with a good password: %v", err)
+	}
+}
+
+func TestResetPasswordPolicyKeepsLink(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	if err := env.auth.ForgotPassword(ctx, ForgotPasswordRequest{Email: user.Email}); err != nil {
+		t.Fatal(err)
+	}
+	msg, ok := env.mailer.Last()
+	if !ok {
+		t.Fatal("no email was sent")
+	}
+	link, err := url.Parse(resetPasswordURL.FindString(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := link.Query().Get("token")
+
+	err = env.auth.ResetPassword(ctx, ResetPasswordRequest{Token: token, Password: "iloveyou"})
+	requireViolations(t, err, passwordpolicy.RuleCommon)
+	if env.revocations.revokedUser(user.ID) {
+		t.Error("rejected reset signed the user out")
+	}
+
+	// The link was not used up by the rejected attempt
+	if err := env.auth.ResetPassword(ctx, ResetPasswordRequest{Token: token, Password: "tea party at four"}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if !env.revocations.revokedUser(user.ID) {
+		t.Error("reset did not sign the user out")
+	}
+	if err := env.auth.ResetPassword(ctx, ResetPasswordRequest{Token: token, Password: "tea party at five"}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second reset: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "tea party at four"}, ClientInfo{}); err != nil {
+		t.Errorf("Login with the new password: %v", err)
+	}
+}
+
+func TestBreachedPasswordRangeDir(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("correct horse battery staple"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	sum = sha1.Sum([]byte("rarely leaked passphrase"))
+	rare := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// Range files as the downloader writes them, one with an extension
+	files := map[string]string{
+		hash[:5]:          "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + hash[5:] + ":3645804\r\n",
+		rare[:5] + ".txt": rare[5:] + ":2\r\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy := passwordpolicy.New(passwordpolicy.Options{
+		MinLength: 8,
+		MaxLength: 128,
+		Breaches:  passwordpolicy.NewRangeDir(dir, 10),
+	})
+	requireViolations(t, policy.Check("correct horse battery staple", "bob@example.com", "Bob"), passwordpolicy.RuleBreached)
+	if err := policy.Check("rarely leaked passphrase", "bob@example.com", "Bob"); err != nil {
+		t.Errorf("password seen fewer than the minimum count: %v", err)
+	}
+	if err := policy.Check("no range file for this one", "bob@example.com", "Bob"); err != nil {
+		t.Errorf("password without a range file: %v", err)
+	}
+}
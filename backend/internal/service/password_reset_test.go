@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// resetToken asks for a reset link for email and returns its token
+func (e *testEnv) resetToken(t *testing.T, email string) string {
+	t.Helper()
+	if err := e.auth.ForgotPassword(context.Background(), ForgotPasswordRequest{Email: email}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	msg, ok := e.mailer.Last()
+	if !ok || msg.To != email {
+		t.Fatalf("last email = %+v, want one to %s", msg, email)
+	}
+	link, err := url.Parse(resetPasswordURL.FindString(msg.Body))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("no reset link in %q", msg.Body)
+	}
+	return link.Query().Get("token")
+}
+
+func TestPasswordReset(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	session, _ := env.login(t, user.Email, "correct horse battery")
+
+	first := env.resetToken(t, user.Email)
+	second := env.resetToken(t, user.Email)
+	if first == second {
+		t.Fatal("two reset requests mailed the same token")
+	}
+
+	if err := env.auth.ResetPassword(ctx, ResetPasswordRequest{Token: second, Password: "tea party at four"}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	// The link is single use, and the one mailed before it is gone too
+	for name, token := range map[string]string{"used": second, "earlier": first} {
+		err := env.auth.ResetPassword(ctx, ResetPasswordRequest{Token: token, Password: "tea party at five"})
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s link: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with the old password: err = %v, want ErrInvalidCredentials", err)
+	}
+	env.login(t, user.Email, "tea party at four")
+
+	// Every session that existed before the reset is signed out
+	if !env.revocations.revokedUser(user.ID) {
+		t.Error("reset did not revoke the user's access tokens")
+	}
+	if _, err := env.auth.Refresh(ctx, session.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("refresh token from before the reset still works")
+	}
+}
+
+func TestPasswordResetLinkExpires(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	env.auth.(*authService).opts.PasswordResetExpiry = -time.Minute
+
+	token := env.resetToken(t, user.Email)
+	err := env.auth.ResetPassword(ctx, ResetPasswordRequest{Token: token, Password: "tea party at four"})
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired link: err = %v, want ErrInvalidToken", err)
+	}
+	if env.revocations.revokedUser(user.ID) {
+		t.Error("expired link signed the user out")
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.auth.ForgotPassword(ctx, ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	if msg, ok := env.mailer.Last(); ok {
+		t.Errorf("sent %+v for an unknown address", msg)
+	}
+	if err := env.auth.ResetPassword(ctx, ResetPasswordRequest{Token: "not-a-token", Password: "tea party at four"}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("made-up token: err = %v, want ErrInvalidToken", err)
+	}
+}
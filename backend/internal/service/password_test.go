@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// storedPassword returns the password hash currently saved for a user
+func (e *testEnv) storedPassword(t *testing.T, email string) string {
+	t.Helper()
+	user, err := e.users.FindByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.Password
+}
+
+func TestLoginRehashesBcryptPassword(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "x")
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Password = string(legacy)
+	if err := env.users.Update(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, ClientInfo{}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	rehashed := env.storedPassword(t, user.Email)
+	if !strings.HasPrefix(rehashed, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("password hash = %q, want argon2id", rehashed)
+	}
+
+	// The new hash works and is current
+	ok, rehash, err := env.passwords.Verify("correct horse battery", rehashed)
+	if err != nil || !ok || rehash {
+		t.Errorf("Verify = %v, %v, %v; want true, false, nil", ok, rehash, err)
+	}
+}
+
+// resetDuringLogin is a UserRepository whose user changes their password
+// right after a login has loaded the account
+type resetDuringLogin struct {
+	*repositorytest.Users
+	password string
+}
+
+func (r *resetDuringLogin) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	user, err := r.Users.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	stored := *user
+	stored.Password = r.password
+	return user, r.Users.Update(ctx, &stored)
+}
+
+func TestLoginRehashKeepsConcurrentlyChangedPassword(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "x")
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Password = string(legacy)
+	if err := env.users.Update(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	reset, err := env.passwords.Hash("new password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.auth.(*authService).userRepo = &resetDuringLogin{Users: env.users, password: reset}
+
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, ClientInfo{}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if got := env.storedPassword(t, user.Email); got != reset {
+		t.Errorf("password hash = %q, want the reset one %q", got, reset)
+	}
+}
+
+func TestLoginRehashesOutdatedParameters(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	before := env.storedPassword(t, user.Email)
+
+	// Raise the cost and add a pepper, as an operator would
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	env.auth.(*authService).passwords = auth.NewPasswordHasher(auth.PasswordOptions{
+		Argon2:   stronger,
+		Pepper:   "test-pepper",
+		PepperID: "1",
+	})
+
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, ClientInfo{}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	after := env.storedPassword(t, user.Email)
+	if after == before || !strings.HasPrefix(after, "$argon2id$v=19$m=64,t=2,p=1,keyid=1$") {
+		t.Fatalf("password hash = %q, want rehashed with t=2 and the pepper", after)
+	}
+
+	// Without the pepper the new hash cannot be checked at all
+	if _, _, err := env.passwords.Verify("correct horse battery", after); !errors.Is(err, auth.ErrUnknownPepper) {
+		t.Errorf("Verify without pepper: err = %v, want ErrUnknownPepper", err)
+	}
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "wrong"}, ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLoginRehashesRotatedPepper(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	peppered := func(opts auth.PasswordOptions) {
+		opts.Argon2 = testArgon2Params
+		env.auth.(*authService).passwords = auth.NewPasswordHasher(opts)
+	}
+	peppered(auth.PasswordOptions{Pepper: "old-pepper", PepperID: "1"})
+	login := func() error {
+		_, err := env.auth.Login(ctx, LoginRequest{Email: "alice@example.com", Password: "correct horse battery"}, ClientInfo{})
+		return err
+	}
+
+	hash, err := env.auth.(*authService).passwords.Hash("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := env.createUser(t, "alice@example.com", "unused")
+	user.Password = hash
+	if err := env.users.Update(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotated without keeping the old pepper, the hash cannot be checked
+	peppered(auth.PasswordOptions{Pepper: "new-pepper", PepperID: "2"})
+	if err := login(); !errors.Is(err, auth.ErrUnknownPepper) {
+		t.Fatalf("Login without the old pepper: err = %v, want ErrUnknownPepper", err)
+	}
+
+	peppered(auth.PasswordOptions{Pepper: "new-pepper", PepperID: "2", PreviousPeppers: map[string]string{"1": "old-pepper"}})
+	if err := login(); err != nil {
+		t.Fatalf("Login with the old pepper kept: %v", err)
+	}
+	if after := env.storedPassword(t, user.Email); !strings.Contains(after, ",keyid=2$") {
+		t.Fatalf("password hash = %q, want rehashed with pepper 2", after)
+	}
+
+	// The old pepper is no longer needed
+	peppered(auth.PasswordOptions{Pepper: "new-pepper", PepperID: "2"})
+	if err := login(); err != nil {
+		t.Fatalf("Login after dropping the old pepper: %v", err)
+	}
+}
+
+func TestPasswordHasherBeyondBcryptLimit(t *testing.T) {
+	env := newTestEnv(t)
+	long := strings.Repeat("a", 72)
+
+	hash, err := env.passwords.Hash(long + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bcrypt would accept this, as it only looks at the first 72 bytes
+	if ok, _, err := env.passwords.Verify(long+"2", hash); err != nil || ok {
+		t.Errorf("Verify(other long password) = %v, %v; want false", ok, err)
+	}
+	if ok, _, err := env.passwords.Verify(long+"1", hash); err != nil || !ok {
+		t.Errorf("Verify(password) = %v, %v; want true", ok, err)
+	}
+}
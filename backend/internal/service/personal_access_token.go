@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+	ErrInvalidTokenScope           = errors.New("unknown scope")
+	ErrInvalidTokenExpiry          = errors.New("invalid token expiry")
+)
+
+// patLastUsedResolution limits how often last_used_at is written for a
+// token that is used continuously
+const patLastUsedResolution = time.Minute
+
+// patHintLength is how many trailing characters of a token are kept to
+// identify it in listings
+const patHintLength = 4
+
+type PersonalAccessTokenService interface {
+	Create(ctx context.Context, userID uuid.UUID, req CreatePersonalAccessTokenRequest) (*CreatedPersonalAccessToken, error)
+	List(ctx context.Context, userID uuid.UUID) ([]model.PersonalAccessToken, error)
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	AuthenticatePersonalAccessToken(ctx context.Context, token string) (*auth.Claims, error)
+}
+
+type CreatePersonalAccessTokenRequest struct {
+	Name   string   `json:"name" validate:"required,min=1,max=255"`
+	Scopes []string `json:"scopes" validate:"required,min=1"`
+	// ExpiresAt is optional; without it the token lives until revoked
+	// unless PAT_MAX_LIFETIME is set
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedPersonalAccessToken carries the token itself, which is shown only
+// once
+type CreatedPersonalAccessToken struct {
+	*model.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// PersonalAccessTokenOptions configures PersonalAccessTokenService
+type PersonalAccessTokenOptions struct {
+	// MaxLifetime, if non-zero, makes an expiry mandatory and caps it
+	MaxLifetime time.Duration
+}
+
+type personalAccessTokenService struct {
+	patRepo     repository.PersonalAccessTokenRepository
+	userRepo    repository.UserRepository
+	tokenHasher *auth.TokenHasher
+	opts        PersonalAccessTokenOptions
+}
+
+func NewPersonalAccessTokenService(
+	patRepo repository.PersonalAccessTokenRepository,
+	userRepo repository.UserRepository,
+	tokenHasher *auth.TokenHasher,
+	opts PersonalAccessTokenOptions,
+) PersonalAccessTokenService {
+	return &personalAccessTokenService{
+		patRepo:     patRepo,
+		userRepo:    userRepo,
+		tokenHasher: tokenHasher,
+		opts:        opts,
+	}
+}
+
+func (s *personalAccessTokenService) Create(ctx context.Context, userID uuid.UUID, req CreatePersonalAccessTokenRequest) (*CreatedPersonalAccessToken, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !slices.Contains(auth.PersonalAccessTokenScopes, scope) {
+			return nil, ErrInvalidTokenScope
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidTokenExpiry
+	}
+	if s.opts.MaxLifetime > 0 {
+		if req.ExpiresAt == nil || req.ExpiresAt.After(time.Now().Add(s.opts.MaxLifetime)) {
+			return nil, ErrInvalidTokenExpiry
+		}
+	}
+
+	token, err := auth.GeneratePersonalAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	pat := &model.PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		TokenHash: s.tokenHasher.Hash(token),
+		Hint:      token[len(token)-patHintLength:],
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.patRepo.Create(ctx, pat); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Personal access token created",
+		"event", "pat_created",
+		"user_id", userID,
+		"token_id", pat.ID,
+		"scopes", scopes,
+	)
+
+	return &CreatedPersonalAccessToken{PersonalAccessToken: pat, Token: token}, nil
+}
+
+func (s *personalAccessTokenService) List(ctx context.Context, userID uuid.UUID) ([]model.PersonalAccessToken, error) {
+	return s.patRepo.FindByUserID(ctx, userID)
+}
+
+func (s *personalAccessTokenService) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.patRepo.Delete(ctx, userID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPersonalAccessTokenNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// AuthenticatePersonalAccessToken resolves a bearer token to the claims
+// the auth middleware exposes for JWTs, with the granted scopes in Scope.
+// It returns auth.ErrInvalidToken or auth.ErrExpiredToken on failure.
+func (s *personalAccessTokenService) AuthenticatePersonalAccessToken(ctx context.Context, token string) (*auth.Claims, error) {
+	pat, err := s.patRepo.FindByHash(ctx, s.tokenHasher.Hash(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if pat.IsExpired() {
+		return nil, auth.ErrExpiredToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, pat.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, auth.ErrInvalidToken
+	}
+
+	now := time.Now()
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) > patLastUsedResolution {
+		if err := s.patRepo.UpdateLastUsed(ctx, pat.ID, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to record personal access token use", "error", err, "token_id", pat.ID)
+		}
+	}
+
+	return &auth.Claims{
+		UserID:        user.ID.String(),
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		Scope:         strings.Join(pat.Scopes, " "),
+	}, nil
+}
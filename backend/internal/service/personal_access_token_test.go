@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+)
+
+func newPersonalAccessTokenService(env *testEnv, maxLifetime time.Duration) (PersonalAccessTokenService, *repositorytest.PersonalAccessTokens) {
+	pats := repositorytest.NewPersonalAccessTokens()
+	return NewPersonalAccessTokenService(pats, env.users, env.hasher, PersonalAccessTokenOptions{MaxLifetime: maxLifetime}), pats
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	pats, _ := newPersonalAccessTokenService(env, 30*24*time.Hour)
+
+	inWeek := time.Now().Add(7 * 24 * time.Hour)
+	created, err := pats.Create(ctx, user.ID, CreatePersonalAccessTokenRequest{
+		Name:      "CI",
+		Scopes:    []string{auth.ScopeUserRead, auth.ScopeUserRead},
+		ExpiresAt: &inWeek,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !auth.IsPersonalAccessToken(created.Token) || len(created.Scopes) != 1 || created.Hint != created.Token[len(created.Token)-4:] {
+		t.Errorf("Create = %+v", created)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	beyondCap := time.Now().Add(31 * 24 * time.Hour)
+	tests := []struct {
+		name string
+		req  CreatePersonalAccessTokenRequest
+		want error
+	}{
+		{"unknown scope", CreatePersonalAccessTokenRequest{Name: "x", Scopes: []string{"admin"}, ExpiresAt: &inWeek}, ErrInvalidTokenScope},
+		{"expired", CreatePersonalAccessTokenRequest{Name: "x", Scopes: []string{auth.ScopeUserRead}, ExpiresAt: &past}, ErrInvalidTokenExpiry},
+		{"beyond max lifetime", CreatePersonalAccessTokenRequest{Name: "x", Scopes: []string{auth.ScopeUserRead}, ExpiresAt: &beyondCap}, ErrInvalidTokenExpiry},
+		{"no expiry with max lifetime", CreatePersonalAccessTokenRequest{Name: "x", Scopes: []string{auth.ScopeUserRead}}, ErrInvalidTokenExpiry},
+	}
+	for _, tt := range tests {
+		if _, err := pats.Create(ctx, user.ID, tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// Without a cap the expiry is optional
+	unlimited, _ := newPersonalAccessTokenService(env, 0)
+	if _, err := unlimited.Create(ctx, user.ID, CreatePersonalAccessTokenRequest{Name: "x", Scopes: []string{auth.ScopeUserRead}}); err != nil {
+		t.Errorf("Create without expiry: %v", err)
+	}
+}
+
+func TestAuthenticatePersonalAccessToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	pats, repo := newPersonalAccessTokenService(env, 0)
+	create := func() *CreatedPersonalAccessToken {
+		t.Helper()
+		created, err := pats.Create(ctx, user.ID, CreatePersonalAccessTokenRequest{Name: "CLI", Scopes: []string{auth.ScopeUserRead}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created
+	}
+
+	created := create()
+	claims, err := pats.AuthenticatePersonalAccessToken(ctx, created.Token)
+	if err != nil {
+		t.Fatalf("AuthenticatePersonalAccessToken: %v", err)
+	}
+	if claims.UserID != user.ID.String() || claims.Scope != auth.ScopeUserRead || claims.SessionID != "" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if stored, _ := repo.FindByHash(ctx, env.hasher.Hash(created.Token)); stored.LastUsedAt == nil {
+		t.Error("last use was not recorded")
+	}
+
+	// A token differing in one character has another hash
+	tampered := created.Token[:len(created.Token)-1] + "x"
+	if tampered == created.Token {
+		tampered = created.Token[:len(created.Token)-1] + "y"
+	}
+	if _, err := pats.AuthenticatePersonalAccessToken(ctx, tampered); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("wrong token: err = %v, want ErrInvalidToken", err)
+	}
+
+	expiredToken, err := auth.GeneratePersonalAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+	if err := repo.Create(ctx, &model.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      "old",
+		TokenHash: env.hasher.Hash(expiredToken),
+		Scopes:    []string{auth.ScopeUserRead},
+		ExpiresAt: &past,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pats.AuthenticatePersonalAccessToken(ctx, expiredToken); !errors.Is(err, auth.ErrExpiredToken) {
+		t.Errorf("expired token: err = %v, want ErrExpiredToken", err)
+	}
+
+	revoked := create()
+	if err := pats.Revoke(ctx, user.ID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pats.AuthenticatePersonalAccessToken(ctx, revoked.Token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("revoked token: err = %v, want ErrInvalidToken", err)
+	}
+
+	if err := env.users.SetDisabled(ctx, user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pats.AuthenticatePersonalAccessToken(ctx, created.Token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("token of a disabled user: err = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+)
+
+// PurgeService deletes what has expired and can no longer be used:
+// refresh tokens, denylisted access tokens, emailed links, MFA and passkey
+// challenges, OAuth codes, refresh tokens and login states, rate limit
+// windows and forgotten login failures
+type PurgeService interface {
+	// Purge deletes everything expired once. A failing table does not
+	// stop the others.
+	Purge(ctx context.Context) error
+	// Run purges every interval until ctx is done
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// PurgeOptions are the windows after which rate limit counts and login
+// failures are forgotten
+type PurgeOptions struct {
+	RateLimitWindow    time.Duration
+	LoginAttemptWindow time.Duration
+}
+
+type purgeService struct {
+	tokenRepo        repository.TokenRepository
+	revokedTokenRepo repository.RevokedTokenRepository
+	actionTokenRepo  repository.ActionTokenRepository
+	mfaRepo          repository.MFARepository
+	webauthnRepo     repository.WebAuthnRepository
+	oauthGrantRepo   repository.OAuthGrantRepository
+	identityRepo     repository.IdentityRepository
+	rateLimitRepo    repository.RateLimitRepository
+	loginAttemptRepo repository.LoginAttemptRepository
+	opts             PurgeOptions
+	now              func() time.Time
+}
+
+func NewPurgeService(
+	tokenRepo repository.TokenRepository,
+	revokedTokenRepo repository.RevokedTokenRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	mfaRepo repository.MFARepository,
+	webauthnRepo repository.WebAuthnRepository,
+	oauthGrantRepo repository.OAuthGrantRepository,
+	identityRepo repository.IdentityRepository,
+	rateLimitRepo repository.RateLimitRepository,
+	loginAttemptRepo repository.LoginAttemptRepository,
+	opts PurgeOptions,
+) PurgeService {
+	return &purgeService{
+		tokenRepo:        tokenRepo,
+		revokedTokenRepo: revokedTokenRepo,
+		actionTokenRepo:  actionTokenRepo,
+		mfaRepo:          mfaRepo,
+		webauthnRepo:     webauthnRepo,
+		oauthGrantRepo:   oauthGrantRepo,
+		identityRepo:     identityRepo,
+		rateLimitRepo:    rateLimitRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		opts:             opts,
+		now:              time.Now,
+	}
+}
+
+func (s *purgeService) Purge(ctx context.Context) error {
+	now := s.now()
+	purges := []struct {
+		table string
+		purge func(context.Context) error
+	}{
+		{"refresh_tokens", s.tokenRepo.DeleteExpired},
+		{"revoked_tokens", s.revokedTokenRepo.DeleteExpired},
+		{"action_tokens", s.actionTokenRepo.DeleteExpired},
+		{"mfa_challenges", s.mfaRepo.DeleteExpiredChallenges},
+		{"webauthn_challenges", s.webauthnRepo.DeleteExpiredChallenges},
+		{"oauth_authorizations", s.oauthGrantRepo.DeleteExpiredAuthorizations},
+		{"oauth_refresh_tokens", s.oauthGrantRepo.DeleteExpiredRefreshTokens},
+		{"oauth_states", s.identityRepo.DeleteExpiredStates},
+		{"rate_limits", func(ctx context.Context) error {
+			return s.rateLimitRepo.DeleteStale(ctx, now.Add(-s.opts.RateLimitWindow))
+		}},
+		{"login_attempts", func(ctx context.Context) error {
+			return s.loginAttemptRepo.DeleteStale(ctx, now.Add(-s.opts.LoginAttemptWindow))
+		}},
+	}
+
+	var errs []error
+	for _, p := range purges {
+		if err := p.purge(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to purge expired rows", "table", p.table, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *purgeService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.Purge(ctx)
+		}
+	}
+}
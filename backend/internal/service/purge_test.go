@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+)
+
+// failingRevocations is a RevokedTokenRepository whose purge fails
+type failingRevocations struct {
+	repository.RevokedTokenRepository
+}
+
+func (failingRevocations) DeleteExpired(ctx context.Context) error {
+	return errors.New("connection reset")
+}
+
+// purgedChallenges records that MFA challenges were purged
+type purgedChallenges struct {
+	repository.MFARepository
+	purged bool
+}
+
+func (r *purgedChallenges) DeleteExpiredChallenges(ctx context.Context) error {
+	r.purged = true
+	return nil
+}
+
+// staleLoginAttempts records the cutoff login failures were purged with
+type staleLoginAttempts struct {
+	repository.LoginAttemptRepository
+	before time.Time
+}
+
+func (r *staleLoginAttempts) DeleteStale(ctx context.Context, before time.Time) error {
+	r.before = before
+	return nil
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	users := repositorytest.NewUsers()
+	rateLimits := repositorytest.NewRateLimits()
+	challenges := &purgedChallenges{}
+	loginAttempts := &staleLoginAttempts{}
+	purge := NewPurgeService(
+		repositorytest.NewTokens(users),
+		failingRevocations{},
+		repositorytest.NewActionTokens(),
+		challenges,
+		repositorytest.NewWebAuthn(),
+		repositorytest.NewOAuthGrants(),
+		repositorytest.NewIdentities(users),
+		rateLimits,
+		loginAttempts,
+		PurgeOptions{RateLimitWindow: 15 * time.Minute, LoginAttemptWindow: 24 * time.Hour},
+	)
+	purge.(*purgeService).now = func() time.Time { return now }
+
+	for key, start := range map[string]time.Time{
+		"ended":   now.Add(-20 * time.Minute),
+		"current": now.Add(-10 * time.Minute),
+	} {
+		if _, err := rateLimits.Hit(ctx, key, start, start); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A failing table is reported without stopping the others
+	if err := purge.Purge(ctx); err == nil {
+		t.Error("Purge: err = nil, want the revoked_tokens failure")
+	}
+	if !challenges.purged {
+		t.Error("MFA challenges were not purged")
+	}
+	if want := now.Add(-24 * time.Hour); !loginAttempts.before.Equal(want) {
+		t.Errorf("login attempts purged before %v, want %v", loginAttempts.before, want)
+	}
+	for key, hits := range map[string]int{"ended": 1, "current": 2} {
+		limit, err := rateLimits.Hit(ctx, key, now, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limit.Hits != hits {
+			t.Errorf("rate limit %q: hits = %d, want %d", key, limit.Hits, hits)
+		}
+	}
+}
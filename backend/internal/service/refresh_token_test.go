@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	first, sessionID := env.login(t, user.Email, "correct horse battery")
+
+	second, err := env.auth.Refresh(ctx, first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Error("Refresh returned the same tokens, want new ones")
+	}
+	claims, err := env.jwt.ValidateAccessToken(second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SessionID != sessionID.String() {
+		t.Errorf("session = %s, want the rotated token to stay in session %s", claims.SessionID, sessionID)
+	}
+
+	// Only the keyed hash of a refresh token is stored
+	if _, err := env.tokens.FindByToken(ctx, second.RefreshToken); err == nil {
+		t.Error("the refresh token is found by its plain text")
+	}
+	if _, err := env.tokens.FindByToken(ctx, env.hasher.Hash(second.RefreshToken)); err != nil {
+		t.Errorf("the refresh token is not found by its hash: %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	tests := []struct {
+		name string
+		// rotations is how often the session's holder refreshed before the
+		// first token is presented again
+		rotations int
+	}{
+		{"replayed after one rotation", 1},
+		{"replayed after several rotations", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := context.Background()
+			user := env.createUser(t, "alice@example.com", "correct horse battery")
+			other, otherSessionID := env.login(t, user.Email, "correct horse battery")
+			first, sessionID := env.login(t, user.Email, "correct horse battery")
+
+			latest := first
+			for range tt.rotations {
+				var err error
+				if latest, err = env.auth.Refresh(ctx, latest.RefreshToken, ClientInfo{}); err != nil {
+					t.Fatalf("Refresh: %v", err)
+				}
+			}
+
+			if _, err := env.auth.Refresh(ctx, first.RefreshToken, ClientInfo{}); !errors.Is(err, ErrTokenReused) {
+				t.Fatalf("reused token: err = %v, want ErrTokenReused", err)
+			}
+			// The whole session is signed out, whoever holds its newest token
+			if !env.revocations.revokedSession(sessionID) {
+				t.Error("the session's access tokens were not revoked")
+			}
+			if _, err := env.auth.Refresh(ctx, latest.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("newest token of the session: err = %v, want ErrInvalidToken", err)
+			}
+
+			// Other sessions of the user are left alone
+			if env.revocations.revokedSession(otherSessionID) {
+				t.Error("another session was revoked")
+			}
+			if _, err := env.auth.Refresh(ctx, other.RefreshToken, ClientInfo{}); err != nil {
+				t.Errorf("token of another session: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshRejectsUnknownTokens(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	session, _ := env.login(t, user.Email, "correct horse battery")
+
+	if err := env.auth.Logout(ctx, session.RefreshToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	for name, token := range map[string]string{
+		"logged out": session.RefreshToken,
+		"made up":    "not-a-refresh-token",
+	} {
+		if _, err := env.auth.Refresh(ctx, token, ClientInfo{}); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
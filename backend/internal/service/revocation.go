@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/cache"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// RevocationService maintains the access token denylist. Access tokens are
+// found through the refresh tokens they were issued with, so revoking a
+// session or a user covers every access token that may still be valid;
+// this must happen before the refresh tokens are deleted.
+type RevocationService interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeSession(ctx context.Context, sessionID uuid.UUID) error
+	RevokeUser(ctx context.Context, userID uuid.UUID) error
+	// RevokeAccessToken denylists a single access token issued at issuedAt
+	RevokeAccessToken(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) error
+}
+
+type revocationService struct {
+	revokedRepo  repository.RevokedTokenRepository
+	tokenRepo    repository.TokenRepository
+	accessExpiry time.Duration
+	cache        *cache.LRU[string, bool]
+	negativeTTL  time.Duration
+}
+
+// NewRevocationService creates a revocation service with an in-memory LRU
+// in front of the database. Revocations made on this replica take effect
+// at once; those made on other replicas within negativeTTL.
+func NewRevocationService(
+	revokedRepo repository.RevokedTokenRepository,
+	tokenRepo repository.TokenRepository,
+	accessExpiry time.Duration,
+	cacheSize int,
+	negativeTTL time.Duration,
+) RevocationService {
+	return &revocationService{
+		revokedRepo:  revokedRepo,
+		tokenRepo:    tokenRepo,
+		accessExpiry: accessExpiry,
+		cache:        cache.NewLRU[string, bool](cacheSize),
+		negativeTTL:  negativeTTL,
+	}
+}
+
+func (s *revocationService) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	if revoked, ok := s.cache.Get(jti); ok {
+		return revoked, nil
+	}
+
+	revoked, err := s.revokedRepo.Exists(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	// A revoked jti stays revoked for the token's whole lifetime
+	ttl := s.negativeTTL
+	if revoked {
+		ttl = s.accessExpiry
+	}
+	s.cache.Set(jti, revoked, time.Now().Add(ttl))
+	return revoked, nil
+}
+
+func (s *revocationService) RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	tokens, err := s.tokenRepo.FindByFamilyIDSince(ctx, sessionID, time.Now().Add(-s.accessExpiry))
+	if err != nil {
+		return err
+	}
+	return s.revoke(ctx, tokens)
+}
+
+func (s *revocationService) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	tokens, err := s.tokenRepo.FindByUserIDSince(ctx, userID, time.Now().Add(-s.accessExpiry))
+	if err != nil {
+		return err
+	}
+	return s.revoke(ctx, tokens)
+}
+
+func (s *revocationService) RevokeAccessToken(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) error {
+	return s.deny(ctx, []model.RevokedToken{{JTI: jti, UserID: userID, ExpiresAt: issuedAt.Add(s.accessExpiry)}})
+}
+
+// revoke denylists the access tokens issued alongside the given refresh
+// tokens
+func (s *revocationService) revoke(ctx context.Context, tokens []model.RefreshToken) error {
+	revoked := make([]model.RevokedToken, 0, len(tokens))
+	for _, token := range tokens {
+		if token.AccessTokenJTI == "" {
+			continue
+		}
+		revoked = append(revoked, model.RevokedToken{
+			JTI:       token.AccessTokenJTI,
+			UserID:    token.UserID,
+			ExpiresAt: token.CreatedAt.Add(s.accessExpiry),
+		})
+	}
+	return s.deny(ctx, revoked)
+}
+
+func (s *revocationService) deny(ctx context.Context, revoked []model.RevokedToken) error {
+	if err := s.revokedRepo.Create(ctx, revoked); err != nil {
+		return err
+	}
+	for _, r := range revoked {
+		s.cache.Set(r.JTI, true, r.ExpiresAt)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+// countedRevocations counts denylist lookups that reach the database
+type countedRevocations struct {
+	*repositorytest.RevokedTokens
+	lookups int
+}
+
+func (r *countedRevocations) Exists(ctx context.Context, jti string) (bool, error) {
+	r.lookups++
+	return r.RevokedTokens.Exists(ctx, jti)
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	ctx := context.Background()
+	revoked := &countedRevocations{RevokedTokens: repositorytest.NewRevokedTokens()}
+	tokens := repositorytest.NewTokens(repositorytest.NewUsers())
+	svc := NewRevocationService(revoked, tokens, 15*time.Minute, 100, time.Minute)
+
+	if err := svc.RevokeAccessToken(ctx, "jti-1", uuid.New(), time.Now()); err != nil {
+		t.Fatalf("RevokeAccessToken: %v", err)
+	}
+	tests := []struct {
+		jti     string
+		revoked bool
+	}{
+		{"jti-1", true},
+		{"jti-2", false},
+		// Answered from the cache this time
+		{"jti-1", true},
+		{"jti-2", false},
+	}
+	for _, tt := range tests {
+		got, err := svc.IsRevoked(ctx, tt.jti)
+		if err != nil || got != tt.revoked {
+			t.Errorf("IsRevoked(%s) = %v, %v; want %v", tt.jti, got, err, tt.revoked)
+		}
+	}
+	// The revocation made here is cached; the unknown jti is looked up once
+	if revoked.lookups != 1 {
+		t.Errorf("database lookups = %d, want 1", revoked.lookups)
+	}
+}
+
+func TestRevocationsReachOtherReplicas(t *testing.T) {
+	ctx := context.Background()
+	revoked := repositorytest.NewRevokedTokens()
+	tokens := repositorytest.NewTokens(repositorytest.NewUsers())
+	here := NewRevocationService(revoked, tokens, 15*time.Minute, 100, time.Minute)
+	cached := NewRevocationService(revoked, tokens, 15*time.Minute, 100, time.Minute)
+	uncached := NewRevocationService(revoked, tokens, 15*time.Minute, 100, 0)
+
+	// Both replicas have seen the token as valid
+	for _, svc := range []RevocationService{cached, uncached} {
+		if got, _ := svc.IsRevoked(ctx, "jti-1"); got {
+			t.Fatal("IsRevoked before the revocation = true")
+		}
+	}
+	if err := here.RevokeAccessToken(ctx, "jti-1", uuid.New(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// One still trusts its cache until the negative TTL passes
+	if got, _ := cached.IsRevoked(ctx, "jti-1"); got {
+		t.Error("replica with a cached answer: IsRevoked = true before its TTL")
+	}
+	if got, _ := uncached.IsRevoked(ctx, "jti-1"); !got {
+		t.Error("replica without a cache: IsRevoked = false, want true")
+	}
+}
+
+func TestRevokeSessionDenylistsLiveAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	revoked := repositorytest.NewRevokedTokens()
+	tokens := repositorytest.NewTokens(repositorytest.NewUsers())
+	accessExpiry := 15 * time.Minute
+	svc := NewRevocationService(revoked, tokens, accessExpiry, 100, time.Minute)
+
+	userID := uuid.New()
+	session, other := uuid.New(), uuid.New()
+	for _, token := range []model.RefreshToken{
+		{UserID: userID, FamilyID: session, AccessTokenJTI: "session-1"},
+		{UserID: userID, FamilyID: session, AccessTokenJTI: "session-2"},
+		{UserID: userID, FamilyID: other, AccessTokenJTI: "other-1"},
+	} {
+		if err := tokens.Create(ctx, &token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := svc.RevokeSession(ctx, session); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	for jti, want := range map[string]bool{"session-1": true, "session-2": true, "other-1": false} {
+		if got, _ := revoked.Exists(ctx, jti); got != want {
+			t.Errorf("%s denylisted = %v, want %v", jti, got, want)
+		}
+	}
+
+	if err := svc.RevokeUser(ctx, userID); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+	if got, _ := svc.IsRevoked(ctx, "other-1"); !got {
+		t.Error("RevokeUser left other-1 valid")
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/cache"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleNotAssigned = errors.New("role not assigned")
+	ErrLastAdmin       = errors.New("cannot remove the last admin")
+)
+
+// RoleService manages role assignments and resolves the permissions they
+// grant. Access tokens carry the permissions together with the user's
+// authorization version, which changes with every assignment, so checks
+// only reach the database when a token is out of date.
+type RoleService interface {
+	List(ctx context.Context) ([]model.Role, error)
+	UserRoles(ctx context.Context, userID uuid.UUID) ([]model.Role, error)
+	// Assign gives a user a role. actorID is the admin making the change,
+	// or uuid.Nil for the CLI.
+	Assign(ctx context.Context, actorID, userID uuid.UUID, role string) error
+	Unassign(ctx context.Context, actorID, userID uuid.UUID, role string) error
+	// Authorization returns what an access token issued now should carry
+	Authorization(ctx context.Context, userID uuid.UUID) (*Authorization, error)
+	// AuthzVersion returns the user's current authorization version, or 0
+	// for users that do not exist
+	AuthzVersion(ctx context.Context, userID uuid.UUID) (int, error)
+	Permissions(ctx context.Context, userID uuid.UUID) ([]string, error)
+}
+
+// Authorization is what a user's roles allow at one authorization version
+type Authorization struct {
+	Version     int
+	Roles       []string
+	Permissions []string
+}
+
+type roleService struct {
+	roleRepo repository.RoleRepository
+	userRepo repository.UserRepository
+	versions *cache.LRU[uuid.UUID, int]
+	cacheTTL time.Duration
+}
+
+// NewRoleService creates a role service that caches authorization
+// versions in memory. Changes made on this replica take effect at once;
+// those made on other replicas within cacheTTL.
+func NewRoleService(
+	roleRepo repository.RoleRepository,
+	userRepo repository.UserRepository,
+	cacheSize int,
+	cacheTTL time.Duration,
+) RoleService {
+	return &roleService{
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+		versions: cache.NewLRU[uuid.UUID, int](cacheSize),
+		cacheTTL: cacheTTL,
+	}
+}
+
+func (s *roleService) List(ctx context.Context) ([]model.Role, error) {
+	return s.roleRepo.List(ctx)
+}
+
+func (s *roleService) UserRoles(ctx context.Context, userID uuid.UUID) ([]model.Role, error) {
+	if _, err := s.findUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.roleRepo.FindByUserID(ctx, userID)
+}
+
+func (s *roleService) Assign(ctx context.Context, actorID, userID uuid.UUID, role string) error {
+	if _, err := s.findUser(ctx, userID); err != nil {
+		return err
+	}
+	r, err := s.findRole(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	assignment := &model.UserRole{UserID: userID, RoleID: r.ID}
+	if actorID != uuid.Nil {
+		assignment.AssignedBy = &actorID
+	}
+	if err := s.roleRepo.Assign(ctx, assignment); err != nil {
+		return err
+	}
+	s.versions.Delete(userID)
+
+	slog.InfoContext(ctx, "Role assigned",
+		"event", "role_assigned",
+		"actor_id", actorID,
+		"user_id", userID,
+		"role", r.Name,
+	)
+	return nil
+}
+
+func (s *roleService) Unassign(ctx context.Context, actorID, userID uuid.UUID, role string) error {
+	r, err := s.findRole(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	// Someone has to be able to manage roles
+	if r.Name == model.RoleAdmin {
+		roles, err := s.roleRepo.FindByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(roles, func(role model.Role) bool { return role.ID == r.ID }) {
+			return ErrRoleNotAssigned
+		}
+		admins, err := s.roleRepo.CountUsers(ctx, r.ID)
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	if err := s.roleRepo.Unassign(ctx, userID, r.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleNotAssigned
+		}
+		return err
+	}
+	s.versions.Delete(userID)
+
+	slog.InfoContext(ctx, "Role unassigned",
+		"event", "role_unassigned",
+		"actor_id", actorID,
+		"user_id", userID,
+		"role", r.Name,
+	)
+	return nil
+}
+
+func (s *roleService) Authorization(ctx context.Context, userID uuid.UUID) (*Authorization, error) {
+	// The version is read first: an assignment racing with this call then
+	// leaves the token with an older version, which is checked again,
+	// rather than with old permissions under the new version
+	version, err := s.roleRepo.AuthzVersion(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	roles, err := s.roleRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	authz := &Authorization{Version: version, Roles: []string{}, Permissions: []string{}}
+	for _, role := range roles {
+		authz.Roles = append(authz.Roles, role.Name)
+		for _, name := range role.PermissionNames() {
+			if !slices.Contains(authz.Permissions, name) {
+				authz.Permissions = append(authz.Permissions, name)
+			}
+		}
+	}
+	slices.Sort(authz.Permissions)
+	return authz, nil
+}
+
+func (s *roleService) AuthzVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	if version, ok := s.versions.Get(userID); ok {
+		return version, nil
+	}
+
+	version, err := s.roleRepo.AuthzVersion(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	s.versions.Set(userID, version, time.Now().Add(s.cacheTTL))
+	return version, nil
+}
+
+func (s *roleService) Permissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return s.roleRepo.Permissions(ctx, userID)
+}
+
+func (s *roleService) findUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *roleService) findRole(ctx context.Context, name string) (*model.Role, error) {
+	role, err := s.roleRepo.FindByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return role, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestAssignRoleOutdatesTokens(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	session, _ := env.login(t, user.Email, "correct horse battery")
+
+	claims, err := env.jwt.ValidateAccessToken(session.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims.Permissions) != 0 || claims.AuthzVersion == 0 {
+		t.Fatalf("claims = %v at version %d, want no permissions", claims.Permissions, claims.AuthzVersion)
+	}
+
+	if err := env.roles.Assign(ctx, uuid.Nil, user.ID, model.RoleAdmin); err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+	version, err := env.roles.AuthzVersion(ctx, user.ID)
+	if err != nil || version == claims.AuthzVersion {
+		t.Fatalf("AuthzVersion = %d, %v; want it to move on from %d", version, err, claims.AuthzVersion)
+	}
+	// Assigning a role twice changes nothing
+	if err := env.roles.Assign(ctx, uuid.Nil, user.ID, model.RoleAdmin); err != nil {
+		t.Fatalf("second Assign: %v", err)
+	}
+	if again, _ := env.roles.AuthzVersion(ctx, user.ID); again != version {
+		t.Errorf("second Assign moved the version from %d to %d", version, again)
+	}
+
+	refreshed, err := env.auth.Refresh(ctx, session.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = env.jwt.ValidateAccessToken(refreshed.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.AuthzVersion != version || !slices.Contains(claims.Permissions, model.PermissionRolesAssign) {
+		t.Errorf("refreshed claims = %v at version %d, want admin permissions at %d", claims.Permissions, claims.AuthzVersion, version)
+	}
+
+	current, err := env.auth.GetCurrentUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(current.Roles, []string{model.RoleAdmin}) || !slices.Equal(current.Permissions, claims.Permissions) {
+		t.Errorf("current user roles = %v, permissions = %v", current.Roles, current.Permissions)
+	}
+}
+
+func TestUnassignRole(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	alice := env.createUser(t, "alice@example.com", "correct horse battery")
+	bob := env.createUser(t, "bob@example.com", "correct horse battery")
+	for _, user := range []*model.User{alice, bob} {
+		if err := env.roles.Assign(ctx, uuid.Nil, user.ID, model.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := env.roles.Unassign(ctx, alice.ID, bob.ID, model.RoleAdmin); err != nil {
+		t.Fatalf("Unassign: %v", err)
+	}
+	if permissions, err := env.roles.Permissions(ctx, bob.ID); err != nil || len(permissions) != 0 {
+		t.Errorf("Permissions after Unassign = %v, %v", permissions, err)
+	}
+
+	if err := env.roles.Unassign(ctx, alice.ID, bob.ID, model.RoleAdmin); !errors.Is(err, ErrRoleNotAssigned) {
+		t.Errorf("Unassign twice: err = %v, want ErrRoleNotAssigned", err)
+	}
+	if err := env.roles.Unassign(ctx, alice.ID, alice.ID, model.RoleAdmin); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Unassign the last admin: err = %v, want ErrLastAdmin", err)
+	}
+	if err := env.roles.Assign(ctx, alice.ID, bob.ID, "owner"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("Assign an unknown role: err = %v, want ErrRoleNotFound", err)
+	}
+	if err := env.roles.Assign(ctx, alice.ID, uuid.New(), model.RoleAdmin); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Assign to an unknown user: err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestAuthzVersionOfMissingUser(t *testing.T) {
+	env := newTestEnv(t)
+
+	version, err := env.roles.AuthzVersion(context.Background(), uuid.New())
+	if err != nil || version != 0 {
+		t.Errorf("AuthzVersion = %d, %v; want 0", version, err)
+	}
+}
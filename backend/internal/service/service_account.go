@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrInvalidServiceAccount  = errors.New("invalid service account")
+)
+
+// serviceAccountHintLength is how many trailing characters of a secret are
+// kept to identify it in listings
+const serviceAccountHintLength = 4
+
+// ServiceAccountService manages machine-to-machine principals and issues
+// their access tokens through the client credentials grant
+type ServiceAccountService interface {
+	Create(ctx context.Context, name string, scopes []string) (*model.ServiceAccount, string, error)
+	List(ctx context.Context) ([]model.ServiceAccount, error)
+	Delete(ctx context.Context, clientID string) error
+	RotateSecret(ctx context.Context, clientID string, overlap time.Duration) (string, error)
+	Token(ctx context.Context, req TokenRequest) (*TokenResponse, error)
+}
+
+type serviceAccountService struct {
+	accountRepo repository.ServiceAccountRepository
+	jwt         *auth.JWTManager
+	tokenHasher *auth.TokenHasher
+}
+
+func NewServiceAccountService(
+	accountRepo repository.ServiceAccountRepository,
+	jwt *auth.JWTManager,
+	tokenHasher *auth.TokenHasher,
+) ServiceAccountService {
+	return &serviceAccountService{
+		accountRepo: accountRepo,
+		jwt:         jwt,
+		tokenHasher: tokenHasher,
+	}
+}
+
+// Create adds a service account allowed the given scopes and returns its
+// secret, which is not stored and cannot be shown again
+func (s *serviceAccountService) Create(ctx context.Context, name string, scopes []string) (*model.ServiceAccount, string, error) {
+	scopes = parseScope(strings.Join(scopes, " "))
+	if name == "" || len(scopes) == 0 {
+		return nil, "", ErrInvalidServiceAccount
+	}
+
+	clientID, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, secretRecord, err := s.newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	account := &model.ServiceAccount{
+		ClientID: clientID,
+		Name:     name,
+		Scopes:   scopes,
+	}
+	if err := s.accountRepo.Create(ctx, account, secretRecord); err != nil {
+		return nil, "", err
+	}
+	account.Secrets = []model.ServiceAccountSecret{*secretRecord}
+
+	slog.InfoContext(ctx, "Service account created",
+		"event", "service_account_created",
+		"service_account_id", account.ID,
+		"scopes", scopes,
+	)
+	return account, secret, nil
+}
+
+func (s *serviceAccountService) List(ctx context.Context) ([]model.ServiceAccount, error) {
+	return s.accountRepo.FindAll(ctx)
+}
+
+// Delete removes a service account. Access tokens already issued to it
+// stay valid until they expire.
+func (s *serviceAccountService) Delete(ctx context.Context, clientID string) error {
+	if err := s.accountRepo.Delete(ctx, clientID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrServiceAccountNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RotateSecret issues a new secret. The previous secrets keep working for
+// overlap so callers can be redeployed; a zero overlap cuts them off now.
+func (s *serviceAccountService) RotateSecret(ctx context.Context, clientID string, overlap time.Duration) (string, error) {
+	account, err := s.accountRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrServiceAccountNotFound
+		}
+		return "", err
+	}
+
+	secret, secretRecord, err := s.newSecret()
+	if err != nil {
+		return "", err
+	}
+	secretRecord.ServiceAccountID = account.ID
+	if err := s.accountRepo.RotateSecret(ctx, secretRecord, time.Now().Add(overlap)); err != nil {
+		return "", err
+	}
+
+	slog.InfoContext(ctx, "Service account secret rotated",
+		"event", "service_account_secret_rotated",
+		"service_account_id", account.ID,
+		"overlap", overlap,
+	)
+	return secret, nil
+}
+
+// Token serves the client credentials grant. Failures are returned as
+// *OAuthError.
+func (s *serviceAccountService) Token(ctx context.Context, req TokenRequest) (*TokenResponse, error) {
+	if req.GrantType != model.GrantClientCredentials {
+		return nil, oauthError(OAuthErrUnsupportedGrantType, "only client_credentials is supported")
+	}
+
+	account, secret, err := s.authenticate(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := narrowScope(account.Scopes, req.Scope, "requested scope is not allowed for this service account")
+	if err != nil {
+		return nil, err
+	}
+
+	scope := strings.Join(scopes, " ")
+	accessToken, _, err := s.jwt.GenerateAccessToken(auth.Claims{
+		PrincipalType:    auth.PrincipalService,
+		ServiceAccountID: account.ID.String(),
+		Scope:            scope,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.accountRepo.UpdateSecretLastUsed(ctx, secret.ID, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "Failed to record service account secret use", "error", err, "service_account_id", account.ID)
+	}
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.jwt.GetAccessExpiry().Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// authenticate resolves a client ID and secret to the service account and
+// the secret that matched
+func (s *serviceAccountService) authenticate(ctx context.Context, clientID, secret string) (*model.ServiceAccount, *model.ServiceAccountSecret, error) {
+	if clientID == "" || secret == "" {
+		return nil, nil, oauthError(OAuthErrInvalidClient, "client authentication failed")
+	}
+	account, err := s.accountRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, oauthError(OAuthErrInvalidClient, "client authentication failed")
+		}
+		return nil, nil, err
+	}
+	secretRecord, err := s.accountRepo.FindActiveSecret(ctx, account.ID, s.tokenHasher.Hash(secret))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, oauthError(OAuthErrInvalidClient, "client authentication failed")
+		}
+		return nil, nil, err
+	}
+	return account, secretRecord, nil
+}
+
+// newSecret generates a secret and the record that stores its hash
+func (s *serviceAccountService) newSecret() (string, *model.ServiceAccountSecret, error) {
+	secret, err := auth.GenerateServiceAccountSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	return secret, &model.ServiceAccountSecret{
+		SecretHash: s.tokenHasher.Hash(secret),
+		Hint:       secret[len(secret)-serviceAccountHintLength:],
+	}, nil
+}
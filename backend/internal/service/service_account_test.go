@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+)
+
+func newServiceAccountService(t *testing.T) (ServiceAccountService, *auth.JWTManager) {
+	t.Helper()
+	env := newTestEnv(t)
+	return NewServiceAccountService(repositorytest.NewServiceAccounts(), env.jwt, env.hasher), env.jwt
+}
+
+func requireOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+		t.Fatalf("err = %v, want %s", err, code)
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	accounts, jwt := newServiceAccountService(t)
+	ctx := context.Background()
+
+	account, secret, err := accounts.Create(ctx, "Worker", []string{"reports:read", "reports:write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := accounts.Token(ctx, TokenRequest{
+		GrantType:    model.GrantClientCredentials,
+		ClientID:     account.ClientID,
+		ClientSecret: secret,
+		Scope:        "reports:read",
+	})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	claims, err := jwt.ValidateAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Principal() != auth.PrincipalService || claims.ServiceAccountID != account.ID.String() ||
+		claims.UserID != "" || claims.Scope != "reports:read" {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	_, err = accounts.Token(ctx, TokenRequest{
+		GrantType:    model.GrantClientCredentials,
+		ClientID:     account.ClientID,
+		ClientSecret: secret,
+		Scope:        "admin",
+	})
+	requireOAuthError(t, err, OAuthErrInvalidScope)
+
+	_, err = accounts.Token(ctx, TokenRequest{
+		GrantType:    model.GrantClientCredentials,
+		ClientID:     account.ClientID,
+		ClientSecret: secret + "x",
+	})
+	requireOAuthError(t, err, OAuthErrInvalidClient)
+
+	_, err = accounts.Token(ctx, TokenRequest{
+		GrantType:    model.GrantAuthorizationCode,
+		ClientID:     account.ClientID,
+		ClientSecret: secret,
+	})
+	requireOAuthError(t, err, OAuthErrUnsupportedGrantType)
+}
+
+func TestServiceAccountSecretRotation(t *testing.T) {
+	accounts, _ := newServiceAccountService(t)
+	ctx := context.Background()
+
+	account, oldSecret, err := accounts.Create(ctx, "Worker", []string{"reports:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(secret string) error {
+		_, err := accounts.Token(ctx, TokenRequest{
+			GrantType:    model.GrantClientCredentials,
+			ClientID:     account.ClientID,
+			ClientSecret: secret,
+		})
+		return err
+	}
+
+	// Both secrets work during the overlap
+	newSecret, err := accounts.RotateSecret(ctx, account.ClientID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := token(oldSecret); err != nil {
+		t.Fatalf("old secret during overlap: %v", err)
+	}
+	if err := token(newSecret); err != nil {
+		t.Fatalf("new secret: %v", err)
+	}
+
+	// A rotation without overlap cuts off every earlier secret
+	latestSecret, err := accounts.RotateSecret(ctx, account.ClientID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireOAuthError(t, token(oldSecret), OAuthErrInvalidClient)
+	requireOAuthError(t, token(newSecret), OAuthErrInvalidClient)
+	if err := token(latestSecret); err != nil {
+		t.Fatalf("latest secret: %v", err)
+	}
+}
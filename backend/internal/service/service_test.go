@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/mail"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/pagination"
+	"github.com/ablaze/gonexttemp-backend/internal/passwordpolicy"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+	"github.com/ablaze/gonexttemp-backend/internal/webauthn"
+	"github.com/google/uuid"
+)
+
+const (
+	testRPID   = "app.example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// testEnv wires AuthService and PasskeyService to in-memory repositories
+type testEnv struct {
+	users        *repositorytest.Users
+	webauthn     *repositorytest.WebAuthn
+	tokens       *repositorytest.Tokens
+	actionTokens *repositorytest.ActionTokens
+	revocations  *recordedRevocations
+	mailer       *mail.MemoryMailer
+	hasher       *auth.TokenHasher
+	passwords    auth.PasswordHasher
+	jwt          *auth.JWTManager
+	passkeys     PasskeyService
+	throttle     LoginThrottleService
+	roles        RoleService
+	auth         AuthService
+	accounts     UserService
+	emailChanges EmailChangeService
+	admin        AdminUserService
+}
+
+// testArgon2Params keep password hashing cheap in tests
+var testArgon2Params = auth.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+var testThrottleOptions = LoginThrottleOptions{
+	FreeAttempts:       3,
+	BackoffBase:        time.Second,
+	BackoffMax:         time.Minute,
+	LockoutThreshold:   5,
+	LockoutDuration:    15 * time.Minute,
+	IPLockoutThreshold: 8,
+	Window:             24 * time.Hour,
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		users:        repositorytest.NewUsers(),
+		webauthn:     repositorytest.NewWebAuthn(),
+		actionTokens: repositorytest.NewActionTokens(),
+		revocations:  &recordedRevocations{},
+		mailer:       mail.NewMemoryMailer(),
+		hasher:       auth.NewTokenHasher("test-token-hash-key"),
+		passwords:    auth.NewPasswordHasher(auth.PasswordOptions{Argon2: testArgon2Params}),
+		jwt:          auth.NewJWTManager(auth.NewKeyRing(auth.NewHMACKey("test-jwt-secret")), 15*time.Minute, 7*24*time.Hour),
+	}
+	env.tokens = repositorytest.NewTokens(env.users)
+	env.passkeys = NewPasskeyService(
+		env.webauthn,
+		env.users,
+		webauthn.New(webauthn.Config{
+			RPID:    testRPID,
+			RPName:  "Test",
+			Origins: []string{testOrigin},
+			Timeout: time.Minute,
+		}),
+		env.hasher,
+		PasskeyOptions{ChallengeExpiry: time.Minute},
+	)
+	policy := passwordpolicy.New(passwordpolicy.Options{MinLength: 8, MaxLength: 128})
+	env.throttle = NewLoginThrottleService(repository.NewMemoryLoginAttemptRepository(), testThrottleOptions)
+	roleRepo := repositorytest.NewRoles(env.users)
+	env.roles = NewRoleService(roleRepo, env.users, 100, time.Minute)
+	env.auth = NewAuthService(
+		env.users,
+		env.tokens,
+		env.actionTokens,
+		env.revocations,
+		noMFA{},
+		env.passkeys,
+		env.throttle,
+		env.roles,
+		env.jwt,
+		env.hasher,
+		env.passwords,
+		policy,
+		env.mailer,
+		AuthOptions{AppURL: "https://app.example.com", PasswordResetExpiry: time.Hour, EmailVerificationExpiry: time.Hour},
+	)
+	sessions := NewSessionService(env.tokens, env.revocations)
+	env.accounts = NewUserService(
+		env.users,
+		env.actionTokens,
+		sessions,
+		env.revocations,
+		env.passwords,
+		policy,
+	)
+	env.emailChanges = NewEmailChangeService(
+		env.users,
+		env.actionTokens,
+		sessions,
+		env.passwords,
+		env.hasher,
+		env.mailer,
+		EmailChangeOptions{AppURL: "https://app.example.com", Expiry: time.Hour, RevertExpiry: 7 * 24 * time.Hour},
+	)
+	env.admin = NewAdminUserService(
+		env.users,
+		roleRepo,
+		repositorytest.NewAdminActions(),
+		env.actionTokens,
+		sessions,
+		env.revocations,
+		env.auth,
+		env.throttle,
+		pagination.NewSigner("test-cursor-key"),
+		AdminUserOptions{},
+	)
+	return env
+}
+
+// createUser adds a user with a verified email address
+func (e *testEnv) createUser(t *testing.T, email, plaintext string) *model.User {
+	t.Helper()
+
+	hash, err := e.passwords.Hash(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user := &model.User{Email: email, Password: hash, Name: "Test User", EmailVerifiedAt: &now}
+	if err := e.users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// recordedRevocations is a RevocationService that remembers which users,
+// sessions and single access tokens were signed out
+type recordedRevocations struct {
+	RevocationService
+	mu       sync.Mutex
+	users    []uuid.UUID
+	sessions []uuid.UUID
+	jtis     []string
+}
+
+func (r *recordedRevocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Contains(r.jtis, jti), nil
+}
+
+func (r *recordedRevocations) RevokeAccessToken(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jtis = append(r.jtis, jti)
+	return nil
+}
+
+func (r *recordedRevocations) RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions = append(r.sessions, sessionID)
+	return nil
+}
+
+func (r *recordedRevocations) revokedSession(sessionID uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Contains(r.sessions, sessionID)
+}
+
+func (r *recordedRevocations) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users = append(r.users, userID)
+	return nil
+}
+
+func (r *recordedRevocations) revokedUser(userID uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Contains(r.users, userID)
+}
+
+// noMFA is an MFAService for users without a second factor
+type noMFA struct{ MFAService }
+
+func (noMFA) IsEnabled(context.Context, uuid.UUID) (bool, error)    { return false, nil }
+func (noMFA) Status(context.Context, uuid.UUID) (*MFAStatus, error) { return &MFAStatus{}, nil }
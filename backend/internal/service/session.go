@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxUserAgentLength = 512
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+type SessionService interface {
+	List(ctx context.Context, userID, currentSessionID uuid.UUID) ([]Session, error)
+	Revoke(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeAll(ctx context.Context, userID uuid.UUID) error
+	// RevokeOthers signs the user out of every session but keepSessionID
+	RevokeOthers(ctx context.Context, userID, keepSessionID uuid.UUID) error
+}
+
+// Session is a login of a user, backed by a refresh token family
+type Session struct {
+	ID              uuid.UUID `json:"id"`
+	DeviceLabel     string    `json:"device_label"`
+	UserAgent       string    `json:"user_agent"`
+	IPAddress       string    `json:"ip_address"`
+	AuthenticatedAt time.Time `json:"created_at"`
+	LastUsedAt      time.Time `json:"last_used_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	Current         bool      `json:"current"`
+}
+
+type sessionService struct {
+	tokenRepo   repository.TokenRepository
+	revocations RevocationService
+}
+
+func NewSessionService(tokenRepo repository.TokenRepository, revocations RevocationService) SessionService {
+	return &sessionService{
+		tokenRepo:   tokenRepo,
+		revocations: revocations,
+	}
+}
+
+func (s *sessionService) List(ctx context.Context, userID, currentSessionID uuid.UUID) ([]Session, error) {
+	tokens, err := s.tokenRepo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, Session{
+			ID:              token.FamilyID,
+			DeviceLabel:     token.DeviceLabel,
+			UserAgent:       token.UserAgent,
+			IPAddress:       token.IPAddress,
+			AuthenticatedAt: token.AuthenticatedAt,
+			LastUsedAt:      token.LastUsedAt,
+			ExpiresAt:       token.ExpiresAt,
+			Current:         token.FamilyID == currentSessionID,
+		})
+	}
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, userID, sessionID uuid.UUID) error {
+	// Make sure the session belongs to the user before revoking its tokens
+	tokens, err := s.tokenRepo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	owned := false
+	for _, token := range tokens {
+		if token.FamilyID == sessionID {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		return ErrSessionNotFound
+	}
+
+	if err := s.revocations.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeleteByUserIDAndFamilyID(ctx, userID, sessionID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *sessionService) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	if err := s.revocations.RevokeUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.tokenRepo.DeleteByUserID(ctx, userID)
+}
+
+func (s *sessionService) RevokeOthers(ctx context.Context, userID, keepSessionID uuid.UUID) error {
+	tokens, err := s.tokenRepo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.FamilyID == keepSessionID {
+			continue
+		}
+		if err := s.revocations.RevokeSession(ctx, token.FamilyID); err != nil {
+			return err
+		}
+		if err := s.tokenRepo.DeleteByUserIDAndFamilyID(ctx, userID, token.FamilyID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deviceLabel derives a human readable label such as "Chrome on macOS"
+// from a User-Agent header
+func deviceLabel(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	os := ""
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const testUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+
+// loginFrom logs in from a client and returns the session
+func (e *testEnv) loginFrom(t *testing.T, email, password string, client ClientInfo) (*AuthResponse, uuid.UUID) {
+	t.Helper()
+	res, err := e.auth.Login(context.Background(), LoginRequest{Email: email, Password: password}, client)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims, err := e.jwt.ValidateAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, uuid.MustParse(claims.SessionID)
+}
+
+func TestListSessions(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	sessions := NewSessionService(env.tokens, env.revocations)
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	laptop := ClientInfo{UserAgent: testUserAgent, IPAddress: "192.0.2.1"}
+	_, current := env.loginFrom(t, user.Email, "correct horse battery", laptop)
+	phone, other := env.loginFrom(t, user.Email, "correct horse battery", ClientInfo{})
+
+	// A refreshed session is still listed once
+	if _, err := env.auth.Refresh(ctx, phone.RefreshToken, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := sessions.List(ctx, user.ID, current)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	byID := make(map[uuid.UUID]Session)
+	for _, s := range list {
+		byID[s.ID] = s
+	}
+	if len(list) != 2 || len(byID) != 2 {
+		t.Fatalf("List = %+v, want the two sessions", list)
+	}
+	if s := byID[current]; !s.Current || s.DeviceLabel != "Chrome on macOS" || s.IPAddress != "192.0.2.1" {
+		t.Errorf("current session = %+v, want it marked current, labelled Chrome on macOS", s)
+	}
+	if s := byID[other]; s.Current || s.DeviceLabel != "Unknown device" {
+		t.Errorf("other session = %+v", s)
+	}
+
+	// Another user sees none of them
+	stranger := env.createUser(t, "mallory@example.com", "correct horse battery")
+	if list, _ := sessions.List(ctx, stranger.ID, uuid.Nil); len(list) != 0 {
+		t.Errorf("List for another user = %+v, want none", list)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	sessions := NewSessionService(env.tokens, env.revocations)
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	stranger := env.createUser(t, "mallory@example.com", "correct horse battery")
+	kept, keptID := env.login(t, user.Email, "correct horse battery")
+	revoked, revokedID := env.login(t, user.Email, "correct horse battery")
+
+	// Sessions of other users cannot be revoked, nor made-up ones
+	for _, id := range []uuid.UUID{keptID, uuid.New()} {
+		if err := sessions.Revoke(ctx, stranger.ID, id); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("Revoke(%s) by another user: err = %v, want ErrSessionNotFound", id, err)
+		}
+	}
+
+	if err := sessions.Revoke(ctx, user.ID, revokedID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if !env.revocations.revokedSession(revokedID) || env.revocations.revokedSession(keptID) {
+		t.Error("want exactly the revoked session's access tokens denylisted")
+	}
+	if _, err := env.auth.Refresh(ctx, revoked.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh of the revoked session: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := env.auth.Refresh(ctx, kept.RefreshToken, ClientInfo{}); err != nil {
+		t.Errorf("refresh of the other session: %v", err)
+	}
+	if err := sessions.Revoke(ctx, user.ID, revokedID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second Revoke: err = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestRevokeOtherAndAllSessions(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	sessions := NewSessionService(env.tokens, env.revocations)
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	current, currentID := env.login(t, user.Email, "correct horse battery")
+	other, otherID := env.login(t, user.Email, "correct horse battery")
+
+	if err := sessions.RevokeOthers(ctx, user.ID, currentID); err != nil {
+		t.Fatalf("RevokeOthers: %v", err)
+	}
+	if !env.revocations.revokedSession(otherID) || env.revocations.revokedSession(currentID) {
+		t.Error("RevokeOthers: want only the other session revoked")
+	}
+	if _, err := env.auth.Refresh(ctx, other.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh of the other session: err = %v, want ErrInvalidToken", err)
+	}
+	current, err := env.auth.Refresh(ctx, current.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("refresh of the current session: %v", err)
+	}
+
+	if err := sessions.RevokeAll(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeAll: %v", err)
+	}
+	if !env.revocations.revokedUser(user.ID) {
+		t.Error("RevokeAll did not revoke the user's access tokens")
+	}
+	if _, err := env.auth.Refresh(ctx, current.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh after RevokeAll: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestDeviceLabel(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		label     string
+	}{
+		{"", "Unknown device"},
+		{testUserAgent, "Chrome on macOS"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0", "Edge on Windows"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1", "Safari on iOS"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", "Firefox on Linux"},
+		{"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36", "Chrome on Android"},
+		{"curl/8.4.0", "curl"},
+		{"SomeBot/1.0", "Unknown browser"},
+	}
+	for _, tt := range tests {
+		if got := deviceLabel(tt.userAgent); got != tt.label {
+			t.Errorf("deviceLabel(%q) = %q, want %q", tt.userAgent, got, tt.label)
+		}
+	}
+
+	// User agents are cut at a character boundary
+	long := strings.Repeat("a", maxUserAgentLength-1) + "é"
+	if got := truncate(long, maxUserAgentLength); got != strings.Repeat("a", maxUserAgentLength-1) {
+		t.Errorf("truncate split a character: %q", got[len(got)-2:])
+	}
+}
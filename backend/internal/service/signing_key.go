@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+	ErrNoActiveSigningKey = errors.New("no active signing key")
+)
+
+// SigningKeyService manages the access token key ring stored in the
+// database. Every replica reloads the ring periodically, so a key should
+// be generated (and thereby published in the JWKS) at least one reload
+// interval plus the JWKS cache lifetime before it is promoted.
+type SigningKeyService interface {
+	Bootstrap(ctx context.Context, fallback *auth.SigningKey) error
+	Reload(ctx context.Context) error
+	Watch(ctx context.Context, interval time.Duration)
+	List(ctx context.Context) ([]model.SigningKey, error)
+	Generate(ctx context.Context, alg string) (*model.SigningKey, error)
+	Promote(ctx context.Context, kid string) error
+	Retire(ctx context.Context) (int64, error)
+}
+
+type signingKeyService struct {
+	keyRepo      repository.SigningKeyRepository
+	ring         *auth.KeyRing
+	cipher       *auth.Cipher
+	accessExpiry time.Duration
+}
+
+func NewSigningKeyService(
+	keyRepo repository.SigningKeyRepository,
+	ring *auth.KeyRing,
+	cipher *auth.Cipher,
+	accessExpiry time.Duration,
+) SigningKeyService {
+	return &signingKeyService{
+		keyRepo:      keyRepo,
+		ring:         ring,
+		cipher:       cipher,
+		accessExpiry: accessExpiry,
+	}
+}
+
+// Bootstrap stores the configured key as the active key when the database
+// holds none yet, so existing deployments keep their tokens valid
+func (s *signingKeyService) Bootstrap(ctx context.Context, fallback *auth.SigningKey) error {
+	keys, err := s.keyRepo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.Status == model.SigningKeyStatusActive {
+			return nil
+		}
+	}
+
+	record, err := s.encode(fallback, model.SigningKeyStatusNext)
+	if err != nil {
+		return err
+	}
+	if err := s.keyRepo.Create(ctx, record); err != nil {
+		// Another replica may have stored the same key concurrently
+		if _, findErr := s.keyRepo.FindByID(ctx, record.ID); findErr != nil {
+			return err
+		}
+	}
+	return s.keyRepo.Activate(ctx, record.ID)
+}
+
+// Reload replaces the in-memory ring with the keys in the database
+func (s *signingKeyService) Reload(ctx context.Context) error {
+	records, err := s.keyRepo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	var active *auth.SigningKey
+	verifyOnly := make([]*auth.SigningKey, 0, len(records))
+	for _, record := range records {
+		key, err := s.decode(&record)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", record.ID, err)
+		}
+		if record.Status == model.SigningKeyStatusActive {
+			active = key
+		} else {
+			verifyOnly = append(verifyOnly, key)
+		}
+	}
+	if active == nil {
+		return ErrNoActiveSigningKey
+	}
+
+	s.ring.Replace(active, verifyOnly...)
+	return nil
+}
+
+// Watch reloads the ring every interval until ctx is done
+func (s *signingKeyService) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to reload signing keys", "error", err)
+			}
+		}
+	}
+}
+
+func (s *signingKeyService) List(ctx context.Context) ([]model.SigningKey, error) {
+	return s.keyRepo.FindAll(ctx)
+}
+
+// Generate creates a new key in the next state: published for verification
+// but not yet used for signing
+func (s *signingKeyService) Generate(ctx context.Context, alg string) (*model.SigningKey, error) {
+	key, err := auth.GenerateSigningKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.encode(key, model.SigningKeyStatusNext)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.keyRepo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Promote makes kid the active key; the previously active key stays
+// verify-only until it is retired
+func (s *signingKeyService) Promote(ctx context.Context, kid string) error {
+	if err := s.keyRepo.Activate(ctx, kid); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSigningKeyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Retire deletes previous keys that were deactivated longer ago than the
+// access token TTL, i.e. that can no longer verify an unexpired token
+func (s *signingKeyService) Retire(ctx context.Context) (int64, error) {
+	return s.keyRepo.DeletePreviousBefore(ctx, time.Now().Add(-s.accessExpiry))
+}
+
+func (s *signingKeyService) encode(key *auth.SigningKey, status string) (*model.SigningKey, error) {
+	material, err := key.MarshalPrivate()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.cipher.Encrypt(material)
+	if err != nil {
+		return nil, err
+	}
+	return &model.SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Method.Alg(),
+		PrivateKey: encrypted,
+		Status:     status,
+	}, nil
+}
+
+func (s *signingKeyService) decode(record *model.SigningKey) (*auth.SigningKey, error) {
+	material, err := s.cipher.Decrypt(record.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return auth.ParseSigningKey(record.Algorithm, material, record.ID)
+}
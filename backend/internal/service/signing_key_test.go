@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/repository/repositorytest"
+)
+
+func newTestCipher(t *testing.T, key string) *auth.Cipher {
+	t.Helper()
+	c, err := auth.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSigningKeyBootstrap(t *testing.T) {
+	ctx := context.Background()
+	keys := repositorytest.NewSigningKeys()
+	configured := auth.NewHMACKey("configured-secret")
+	ring := auth.NewKeyRing(configured)
+	svc := NewSigningKeyService(keys, ring, newTestCipher(t, "0123456789abcdef0123456789abcdef"), time.Minute)
+
+	// The configured key becomes the first active key, once
+	for _, fallback := range []*auth.SigningKey{configured, auth.NewHMACKey("changed-secret")} {
+		if err := svc.Bootstrap(ctx, fallback); err != nil {
+			t.Fatalf("Bootstrap: %v", err)
+		}
+	}
+	records, _ := keys.FindAll(ctx)
+	if len(records) != 1 || records[0].ID != configured.ID || records[0].Status != model.SigningKeyStatusActive {
+		t.Fatalf("keys = %+v, want only the configured key, active", records)
+	}
+
+	// The key material is stored encrypted
+	if records[0].PrivateKey == "configured-secret" {
+		t.Error("the secret is stored in plain text")
+	}
+	if err := NewSigningKeyService(keys, ring, newTestCipher(t, "fedcba9876543210fedcba9876543210"), time.Minute).Reload(ctx); !errors.Is(err, auth.ErrDecryptionFailed) {
+		t.Errorf("Reload under another ENCRYPTION_KEY: err = %v, want ErrDecryptionFailed", err)
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	keys := repositorytest.NewSigningKeys()
+	initial, err := auth.GenerateSigningKey(auth.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := auth.NewKeyRing(initial)
+	// With no access token lifetime a previous key can be retired at once
+	svc := NewSigningKeyService(keys, ring, newTestCipher(t, "0123456789abcdef0123456789abcdef"), 0)
+	jwt := auth.NewJWTManager(ring, time.Minute, time.Hour)
+	if err := svc.Bootstrap(ctx, initial); err != nil {
+		t.Fatal(err)
+	}
+	reload := func() {
+		t.Helper()
+		if err := svc.Reload(ctx); err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+	}
+	published := func(kid string) bool {
+		for _, jwk := range jwt.JWKS().Keys {
+			if jwk.Kid == kid {
+				return true
+			}
+		}
+		return false
+	}
+	issue := func() string {
+		t.Helper()
+		token, _, err := jwt.GenerateAccessToken(auth.Claims{UserID: "user-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	// Generated, the next key is published but does not sign yet
+	next, err := svc.Generate(ctx, auth.AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reload()
+	if !published(next.ID) || ring.Active().ID != initial.ID {
+		t.Fatalf("after Generate: active %s, next published %v; want %s active and the next key published", ring.Active().ID, published(next.ID), initial.ID)
+	}
+	before := issue()
+
+	// Promoted, it signs and the old key still verifies
+	if err := svc.Promote(ctx, next.ID); err != nil {
+		t.Fatal(err)
+	}
+	reload()
+	if ring.Active().ID != next.ID || jwt.SigningAlgorithm() != auth.AlgEdDSA {
+		t.Fatalf("after Promote: active %s (%s), want %s", ring.Active().ID, jwt.SigningAlgorithm(), next.ID)
+	}
+	if _, err := jwt.ValidateAccessToken(before); err != nil {
+		t.Errorf("token of the previous key: %v", err)
+	}
+	if _, err := jwt.ValidateAccessToken(issue()); err != nil {
+		t.Errorf("token of the new key: %v", err)
+	}
+
+	// Retired, the old key is gone
+	retired, err := svc.Retire(ctx)
+	if err != nil || retired != 1 {
+		t.Fatalf("Retire = %d, %v; want 1 key", retired, err)
+	}
+	reload()
+	if published(initial.ID) {
+		t.Error("the retired key is still published")
+	}
+	if _, err := jwt.ValidateAccessToken(before); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("token of the retired key: err = %v, want ErrInvalidToken", err)
+	}
+
+	if err := svc.Promote(ctx, "unknown"); !errors.Is(err, ErrSigningKeyNotFound) {
+		t.Errorf("Promote(unknown): err = %v, want ErrSigningKeyNotFound", err)
+	}
+}
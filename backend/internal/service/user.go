@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/auth"
+	"github.com/ablaze/gonexttemp-backend/internal/model"
+	"github.com/ablaze/gonexttemp-backend/internal/passwordpolicy"
+	"github.com/ablaze/gonexttemp-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserService lets users manage their own account
+type UserService interface {
+	UpdateProfile(ctx context.Context, userID uuid.UUID, req UpdateProfileRequest) (*model.User, error)
+	// ChangePassword signs the user out of every session but the current one
+	ChangePassword(ctx context.Context, userID, currentSessionID uuid.UUID, req ChangePasswordRequest) error
+	// Delete soft-deletes the account and revokes every token issued for it
+	Delete(ctx context.Context, userID uuid.UUID, req DeleteAccountRequest) error
+}
+
+// UpdateProfileRequest changes only the fields that are present
+type UpdateProfileRequest struct {
+	Name *string `json:"name" validate:"omitempty,min=1,max=255"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// DeleteAccountRequest confirms a deletion with the current password.
+// Accounts without a password (created through a provider) leave it empty.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type userService struct {
+	userRepo        repository.UserRepository
+	actionTokenRepo repository.ActionTokenRepository
+	sessions        SessionService
+	revocations     RevocationService
+	passwords       auth.PasswordHasher
+	passwordPolicy  *passwordpolicy.Policy
+}
+
+func NewUserService(
+	userRepo repository.UserRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	sessions SessionService,
+	revocations RevocationService,
+	passwords auth.PasswordHasher,
+	passwordPolicy *passwordpolicy.Policy,
+) UserService {
+	return &userService{
+		userRepo:        userRepo,
+		actionTokenRepo: actionTokenRepo,
+		sessions:        sessions,
+		revocations:     revocations,
+		passwords:       passwords,
+		passwordPolicy:  passwordPolicy,
+	}
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, userID uuid.UUID, req UpdateProfileRequest) (*model.User, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) ChangePassword(ctx context.Context, userID, currentSessionID uuid.UUID, req ChangePasswordRequest) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Accounts without a password set one through a password reset
+	ok, _, err := s.passwords.Verify(req.CurrentPassword, user.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	if err := s.passwordPolicy.Check(req.NewPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+	hashedPassword, err := s.passwords.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	if user.PasswordResetRequired {
+		if err := s.userRepo.SetPasswordResetRequired(ctx, user.ID, false); err != nil {
+			return err
+		}
+	}
+
+	// Reset links sent before the change must not undo it
+	if err := s.actionTokenRepo.DeleteByUserID(ctx, user.ID, model.ActionTokenPasswordReset); err != nil {
+		return err
+	}
+	if err := s.sessions.RevokeOthers(ctx, user.ID, currentSessionID); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Password changed", "event", "password_changed", "user_id", user.ID)
+	return nil
+}
+
+func (s *userService) Delete(ctx context.Context, userID uuid.UUID, req DeleteAccountRequest) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Password != "" {
+		ok, _, err := s.passwords.Verify(req.Password, user.Password)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidCredentials
+		}
+	}
+
+	// Access tokens are denylisted first, as that needs the refresh tokens
+	// that the deletion removes
+	if err := s.revocations.RevokeUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.userRepo.Delete(ctx, user.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	slog.InfoContext(ctx, "Account deleted", "event", "account_deleted", "user_id", user.ID)
+	return nil
+}
+
+func (s *userService) findUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// emailHeld reports whether email still belongs to a deleted account other
+// than userID. A hold of zero frees addresses at once and a negative hold
+// keeps them for good. Pass uuid.Nil for a new account.
+func emailHeld(ctx context.Context, userRepo repository.UserRepository, email string, hold time.Duration, userID uuid.UUID) (bool, error) {
+	if hold == 0 {
+		return false, nil
+	}
+	user, err := userRepo.FindDeletedByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if user.ID == userID {
+		return false, nil
+	}
+	return hold < 0 || time.Since(user.DeletedAt.Time) < hold, nil
+}
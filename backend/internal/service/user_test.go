@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ablaze/gonexttemp-backend/internal/passwordpolicy"
+	"github.com/google/uuid"
+)
+
+// login signs in with a password and returns the response and its session
+func (e *testEnv) login(t *testing.T, email, password string) (*AuthResponse, uuid.UUID) {
+	t.Helper()
+	res, err := e.auth.Login(context.Background(), LoginRequest{Email: email, Password: password}, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims, err := e.jwt.ValidateAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, sessionID
+}
+
+func TestUpdateProfile(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+
+	name := "Alice Liddell"
+	updated, err := env.accounts.UpdateProfile(ctx, user.ID, UpdateProfileRequest{Name: &name})
+	if err != nil || updated.Name != name {
+		t.Fatalf("UpdateProfile = %+v, %v", updated, err)
+	}
+
+	// Absent fields are left alone
+	updated, err = env.accounts.UpdateProfile(ctx, user.ID, UpdateProfileRequest{})
+	if err != nil || updated.Name != name {
+		t.Fatalf("UpdateProfile without fields = %+v, %v", updated, err)
+	}
+}
+
+func TestChangePasswordKeepsCurrentSession(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	current, currentID := env.login(t, user.Email, "correct horse battery")
+	other, otherID := env.login(t, user.Email, "correct horse battery")
+
+	err := env.accounts.ChangePassword(ctx, user.ID, currentID, ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "tea party at four"})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong current password: err = %v, want ErrInvalidCredentials", err)
+	}
+	err = env.accounts.ChangePassword(ctx, user.ID, currentID, ChangePasswordRequest{CurrentPassword: "correct horse battery", NewPassword: "alice in chains"})
+	requireViolations(t, err, passwordpolicy.RulePersonalInfo)
+
+	err = env.accounts.ChangePassword(ctx, user.ID, currentID, ChangePasswordRequest{CurrentPassword: "correct horse battery", NewPassword: "tea party at four"})
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	if !env.revocations.revokedSession(otherID) || env.revocations.revokedSession(currentID) {
+		t.Errorf("revoked sessions = %v, want only %s", env.revocations.sessions, otherID)
+	}
+	if _, err := env.auth.Refresh(ctx, other.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("the other session can still refresh")
+	}
+	if _, err := env.auth.Refresh(ctx, current.RefreshToken, ClientInfo{}); err != nil {
+		t.Errorf("the current session cannot refresh: %v", err)
+	}
+	if _, err := env.auth.Login(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"}, ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with the old password: err = %v, want ErrInvalidCredentials", err)
+	}
+	env.login(t, user.Email, "tea party at four")
+}
+
+func TestDeleteAccount(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t, "alice@example.com", "correct horse battery")
+	session, _ := env.login(t, user.Email, "correct horse battery")
+
+	if err := env.accounts.Delete(ctx, user.ID, DeleteAccountRequest{Password: "wrong"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Delete with wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+	if err := env.accounts.Delete(ctx, user.ID, DeleteAccountRequest{Password: "correct horse battery"}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if !env.revocations.revokedUser(user.ID) {
+		t.Error("access tokens were not revoked")
+	}
+	if _, err := env.users.FindByID(ctx, user.ID); err == nil {
+		t.Error("deleted user is still found")
+	}
+	if _, err := env.users.FindDeletedByEmail(ctx, user.Email); err != nil {
+		t.Errorf("deleted user was not kept: %v", err)
+	}
+	if _, err := env.auth.Refresh(ctx, session.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("deleted user can still refresh")
+	}
+	if err := env.accounts.Delete(ctx, user.ID, DeleteAccountRequest{Password: "correct horse battery"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("second Delete: err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestDeletedEmailHold(t *testing.T) {
+	ctx := context.Background()
+	register := RegisterRequest{Email: "alice@example.com", Password: "correct horse battery", Name: "Alice"}
+
+	tests := []struct {
+		name string
+		hold time.Duration
+		want error
+	}{
+		{"release", 0, nil},
+		{"hold", time.Hour, ErrUserAlreadyExists},
+		{"never", -1, ErrUserAlreadyExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.auth.(*authService).opts.DeletedEmailHold = tt.hold
+			user := env.createUser(t, register.Email, register.Password)
+			if err := env.accounts.Delete(ctx, user.ID, DeleteAccountRequest{Password: register.Password}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := env.auth.Register(ctx, register, ClientInfo{}); !errors.Is(err, tt.want) {
+				t.Errorf("Register: err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) accepted for credentials
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// COSE key parameters (RFC 9052, RFC 9053)
+const (
+	coseKeyType    int64 = 1
+	coseKeyAlg     int64 = 3
+	coseKeyCrv     int64 = -1 // EC2 and OKP
+	coseKeyX       int64 = -2 // EC2 and OKP
+	coseKeyY       int64 = -3 // EC2
+	coseKeyRSAN    int64 = -1
+	coseKeyRSAE    int64 = -2
+	coseKtyOKP     int64 = 1
+	coseKtyEC2     int64 = 2
+	coseKtyRSA     int64 = 3
+	coseCrvP256    int64 = 1
+	coseCrvEd25519 int64 = 6
+)
+
+const minRSAKeyBits = 2048
+
+// PublicKey is a credential public key decoded from its COSE form
+type PublicKey struct {
+	Algorithm int64
+	key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key as stored for a credential
+func ParsePublicKey(coseKey []byte) (*PublicKey, error) {
+	v, rest, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("webauthn: trailing data after COSE key")
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, errors.New("webauthn: COSE key is not a map")
+	}
+
+	kty, _ := m[coseKeyType].(int64)
+	alg, _ := m[coseKeyAlg].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		crv, _ := m[coseKeyCrv].(int64)
+		x, _ := m[coseKeyX].([]byte)
+		y, _ := m[coseKeyY].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("webauthn: invalid P-256 COSE key")
+		}
+		// crypto/ecdh rejects points that are not on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, errors.New("webauthn: invalid P-256 COSE key")
+		}
+		return &PublicKey{Algorithm: alg, key: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}}, nil
+
+	case kty == coseKtyOKP && alg == AlgEdDSA:
+		crv, _ := m[coseKeyCrv].(int64)
+		x, _ := m[coseKeyX].([]byte)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("webauthn: invalid Ed25519 COSE key")
+		}
+		return &PublicKey{Algorithm: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, _ := m[coseKeyRSAN].([]byte)
+		e, _ := m[coseKeyRSAE].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("webauthn: invalid RSA COSE key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		modulus := new(big.Int).SetBytes(n)
+		if modulus.BitLen() < minRSAKeyBits || exponent < 3 {
+			return nil, errors.New("webauthn: invalid RSA COSE key")
+		}
+		return &PublicKey{Algorithm: alg, key: &rsa.PublicKey{N: modulus, E: exponent}}, nil
+	}
+
+	return nil, fmt.Errorf("webauthn: unsupported COSE key type %d with algorithm %d", kty, alg)
+}
+
+// Verify checks a WebAuthn signature over data. ES256 signatures are ASN.1
+// DER encoded, as authenticators produce them.
+func (k *PublicKey) Verify(data, signature []byte) error {
+	var ok bool
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		ok = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+// Package webauthn implements the relying party side of WebAuthn
+// registration and authentication ceremonies for passkeys.
+//
+// Only "none" attestation is requested, so attestation statements are not
+// verified and authenticator models (AAGUIDs) are informational.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidResponse  = errors.New("webauthn: invalid credential response")
+	ErrInvalidSignature = errors.New("webauthn: invalid signature")
+	// ErrSignCountRegressed means the authenticator's signature counter did
+	// not increase, which indicates a cloned authenticator
+	ErrSignCountRegressed = errors.New("webauthn: signature counter did not increase")
+)
+
+const (
+	credentialType = "public-key"
+	challengeBytes = 32
+)
+
+// Config describes the relying party
+type Config struct {
+	// RPID is the registrable domain credentials are scoped to, e.g.
+	// "example.com" (or "localhost" in development)
+	RPID   string
+	RPName string
+	// Origins lists the exact origins ceremonies may run on
+	Origins []string
+	Timeout time.Duration
+}
+
+type RelyingParty struct {
+	cfg      Config
+	rpIDHash [32]byte
+}
+
+func New(cfg Config) *RelyingParty {
+	return &RelyingParty{
+		cfg:      cfg,
+		rpIDHash: sha256.Sum256([]byte(cfg.RPID)),
+	}
+}
+
+// GenerateChallenge creates a random ceremony challenge
+func GenerateChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeBytes)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// Credential is a verified new credential to be stored for the user
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	SignCount      uint32
+	Transports     []string
+	AAGUID         []byte
+	BackupEligible bool
+	BackupState    bool
+}
+
+// Assertion is the result of a verified login
+type Assertion struct {
+	SignCount   uint32
+	BackupState bool
+}
+
+// CreationOptions builds options for registering a discoverable, user
+// verified credential. Existing credentials are excluded so the same
+// authenticator is not registered twice.
+func (rp *RelyingParty) CreationOptions(user UserEntity, challenge []byte, exclude []CredentialDescriptor) *CreationOptions {
+	return &CreationOptions{
+		RP:        RelyingPartyEntity{ID: rp.cfg.RPID, Name: rp.cfg.RPName},
+		User:      user,
+		Challenge: challenge,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: credentialType, Alg: AlgES256},
+			{Type: credentialType, Alg: AlgEdDSA},
+			{Type: credentialType, Alg: AlgRS256},
+		},
+		Timeout:            rp.cfg.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions builds options for a login. With no allowed credentials
+// the browser offers every passkey it has for the relying party.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.cfg.Timeout.Milliseconds(),
+		RPID:             rp.cfg.RPID,
+		AllowCredentials: allow,
+		UserVerification: "required",
+	}
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// that was issued for it and returns the new credential
+func (rp *RelyingParty) VerifyRegistration(resp *RegistrationResponse, challenge []byte) (*Credential, error) {
+	if resp.Type != credentialType {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, ClientDataCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	v, rest, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrInvalidResponse
+	}
+	attestation, ok := v.(map[any]any)
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+	if authData.Flags&flagAttestedCredential == 0 {
+		return nil, ErrInvalidResponse
+	}
+	if len(resp.RawID) > 0 && !bytes.Equal(resp.RawID, authData.CredentialID) {
+		return nil, ErrInvalidResponse
+	}
+	if _, err := ParsePublicKey(authData.CredentialPublicKey); err != nil {
+		return nil, ErrInvalidResponse
+	}
+
+	return &Credential{
+		ID:             bytes.Clone(authData.CredentialID),
+		PublicKey:      bytes.Clone(authData.CredentialPublicKey),
+		SignCount:      authData.SignCount,
+		Transports:     resp.Response.Transports,
+		AAGUID:         bytes.Clone(authData.AAGUID),
+		BackupEligible: authData.Flags&flagBackupEligible != 0,
+		BackupState:    authData.Flags&flagBackupState != 0,
+	}, nil
+}
+
+// VerifyAssertion checks a login response against the issued challenge and
+// the stored credential public key and signature counter
+func (rp *RelyingParty) VerifyAssertion(resp *AssertionResponse, challenge, publicKey []byte, storedSignCount uint32) (*Assertion, error) {
+	if resp.Type != credentialType {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, ClientDataGet, challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := parseAuthenticatorData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(bytes.Clone(resp.Response.AuthenticatorData), clientDataHash[:]...)
+	if err := key.Verify(signed, resp.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	// Authenticators that do not implement a counter always report zero
+	if (authData.SignCount != 0 || storedSignCount != 0) && authData.SignCount <= storedSignCount {
+		return nil, ErrSignCountRegressed
+	}
+
+	return &Assertion{
+		SignCount:   authData.SignCount,
+		BackupState: authData.Flags&flagBackupState != 0,
+	}, nil
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data ClientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ErrInvalidResponse
+	}
+	if data.Type != ceremony || data.CrossOrigin {
+		return ErrInvalidResponse
+	}
+	expected := base64.RawURLEncoding.EncodeToString(challenge)
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(expected)) != 1 {
+		return ErrInvalidResponse
+	}
+	if !slices.Contains(rp.cfg.Origins, data.Origin) {
+		return ErrInvalidResponse
+	}
+	return nil
+}
+
+// verifyAuthenticatorData checks the relying party and that the user was
+// both present and verified, which makes a passkey a multi-factor login
+func (rp *RelyingParty) verifyAuthenticatorData(authData *authenticatorData) error {
+	if subtle.ConstantTimeCompare(authData.RPIDHash, rp.rpIDHash[:]) != 1 {
+		return ErrInvalidResponse
+	}
+	if authData.Flags&flagUserPresent == 0 || authData.Flags&flagUserVerified == 0 {
+		return ErrInvalidResponse
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+// Package webauthntest provides a software authenticator for exercising
+// passkey registration and login in tests without a browser.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/ablaze/gonexttemp-backend/internal/webauthn"
+)
+
+const (
+	flagUserPresent        = 0x01
+	flagUserVerified       = 0x04
+	flagAttestedCredential = 0x40
+)
+
+// Authenticator is a platform authenticator holding ES256 passkeys. It
+// reports the user as present and verified unless told otherwise.
+type Authenticator struct {
+	Origin string
+	// OmitUserPresence and OmitUserVerification clear the UP and UV flags,
+	// like an authenticator that skipped the user gesture or PIN
+	OmitUserPresence     bool
+	OmitUserVerification bool
+
+	credentials []*credential
+}
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// NewAuthenticator creates an authenticator that runs ceremonies as if in
+// a browser on origin
+func NewAuthenticator(origin string) *Authenticator {
+	return &Authenticator{Origin: origin}
+}
+
+// Register creates a passkey for the given options, like
+// navigator.credentials.create()
+func (a *Authenticator) Register(options *webauthn.CreationOptions) (*webauthn.RegistrationResponse, error) {
+	for _, excluded := range options.ExcludeCredentials {
+		if a.find(options.RP.ID, excluded.ID) != nil {
+			return nil, errors.New("webauthntest: credential already registered")
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	cred := &credential{id: id, rpID: options.RP.ID, userHandle: options.User.ID, key: key}
+	a.credentials = append(a.credentials, cred)
+
+	clientDataJSON, err := a.clientData(webauthn.ClientDataCreate, options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := cred.authenticatorData(a.flags() | flagAttestedCredential)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, cred.coseKey()...)
+
+	attestationObject := encodeCBOR(cborMap{
+		"fmt":      "none",
+		"attStmt":  cborMap{},
+		"authData": authData,
+	})
+
+	return &webauthn.RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(id),
+		RawID: id,
+		Type:  "public-key",
+		Response: webauthn.AttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: attestationObject,
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Login signs in with a passkey for the given options, like
+// navigator.credentials.get(). With no allowed credentials the most
+// recently registered passkey for the relying party is used.
+func (a *Authenticator) Login(options *webauthn.RequestOptions) (*webauthn.AssertionResponse, error) {
+	var cred *credential
+	if len(options.AllowCredentials) == 0 {
+		for i := len(a.credentials) - 1; i >= 0 && cred == nil; i-- {
+			if a.credentials[i].rpID == options.RPID {
+				cred = a.credentials[i]
+			}
+		}
+	}
+	for _, allowed := range options.AllowCredentials {
+		if cred = a.find(options.RPID, allowed.ID); cred != nil {
+			break
+		}
+	}
+	if cred == nil {
+		return nil, errors.New("webauthntest: no matching credential")
+	}
+
+	clientDataJSON, err := a.clientData(webauthn.ClientDataGet, options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	cred.signCount++
+	authData := cred.authenticatorData(a.flags())
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(bytes.Clone(authData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(cred.id),
+		RawID: cred.id,
+		Type:  "public-key",
+		Response: webauthn.AssertionResponseData{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         signature,
+			UserHandle:        cred.userHandle,
+		},
+	}, nil
+}
+
+// Clone copies the authenticator with its passkeys and their signature
+// counters, like an attacker who extracted the keys. Logins with the
+// original and the clone make the counters run backwards.
+func (a *Authenticator) Clone() *Authenticator {
+	clone := *a
+	clone.credentials = make([]*credential, len(a.credentials))
+	for i, cred := range a.credentials {
+		copied := *cred
+		clone.credentials[i] = &copied
+	}
+	return &clone
+}
+
+func (a *Authenticator) flags() byte {
+	var flags byte
+	if !a.OmitUserPresence {
+		flags |= flagUserPresent
+	}
+	if !a.OmitUserVerification {
+		flags |= flagUserVerified
+	}
+	return flags
+}
+
+func (a *Authenticator) find(rpID string, id []byte) *credential {
+	for _, cred := range a.credentials {
+		if cred.rpID == rpID && bytes.Equal(cred.id, id) {
+			return cred
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(webauthn.ClientData{
+		Type:      ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    a.Origin,
+	})
+}
+
+func (c *credential) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, c.signCount)
+}
+
+func (c *credential) coseKey() []byte {
+	point := c.key.PublicKey
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	point.X.FillBytes(x)
+	point.Y.FillBytes(y)
+	return encodeCBOR(cborMap{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: x,
+		-3: y,
+	})
+}
@@ -0,0 +1,66 @@
+package webauthntest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// cborMap keeps encoding deterministic (keys sorted, as CTAP2 requires)
+type cborMap map[any]any
+
+// encodeCBOR encodes the small set of types an authenticator emits
+func encodeCBOR(v any) []byte {
+	switch v := v.(type) {
+	case int:
+		return encodeCBORInt(int64(v))
+	case int64:
+		return encodeCBORInt(v)
+	case []byte:
+		return append(cborHeader(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHeader(3, uint64(len(v))), v...)
+	case cborMap:
+		keys := make([][]byte, 0, len(v))
+		values := make(map[string][]byte, len(v))
+		for key, value := range v {
+			encoded := encodeCBOR(key)
+			keys = append(keys, encoded)
+			values[string(encoded)] = encodeCBOR(value)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return string(keys[i]) < string(keys[j])
+		})
+		out := cborHeader(5, uint64(len(v)))
+		for _, key := range keys {
+			out = append(out, key...)
+			out = append(out, values[string(key)]...)
+		}
+		return out
+	}
+	panic(fmt.Sprintf("webauthntest: cannot encode %T as CBOR", v))
+}
+
+func encodeCBORInt(v int64) []byte {
+	if v < 0 {
+		return cborHeader(1, uint64(-1-v))
+	}
+	return cborHeader(0, uint64(v))
+}
+
+func cborHeader(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+}
@@ -0,0 +1,6 @@
+-- Consumed tokens were never valid before families existed
+DELETE FROM refresh_tokens WHERE consumed_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS consumed_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id;
@@ -0,0 +1,10 @@
+-- Group refresh tokens into families (one per login lineage)
+ALTER TABLE refresh_tokens ADD COLUMN family_id UUID;
+UPDATE refresh_tokens SET family_id = id WHERE family_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+-- Rotated tokens are kept as consumed so that replays can be detected
+ALTER TABLE refresh_tokens ADD COLUMN consumed_at TIMESTAMP WITH TIME ZONE;
+
+-- Create indexes
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
@@ -0,0 +1,6 @@
+-- Hashes cannot be turned back into tokens
+DELETE FROM refresh_tokens;
+
+ALTER INDEX idx_refresh_tokens_token_hash RENAME TO idx_refresh_tokens_token;
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash TYPE VARCHAR(255);
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
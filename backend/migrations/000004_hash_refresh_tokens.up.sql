@@ -0,0 +1,8 @@
+-- Raw refresh tokens cannot be converted without the server key,
+-- so existing sessions are invalidated and users must log in again
+DELETE FROM refresh_tokens;
+
+-- Store only the HMAC-SHA256 (hex) of each refresh token
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash TYPE VARCHAR(64);
+ALTER INDEX idx_refresh_tokens_token RENAME TO idx_refresh_tokens_token_hash;
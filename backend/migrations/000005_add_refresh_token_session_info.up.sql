@@ -0,0 +1,9 @@
+-- Record where and when each session (token family) is used
+ALTER TABLE refresh_tokens ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ip_address VARCHAR(45) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN device_label VARCHAR(255) NOT NULL DEFAULT '';
+
+-- authenticated_at is the login time of the family, carried over on rotation
+ALTER TABLE refresh_tokens ADD COLUMN authenticated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+ALTER TABLE refresh_tokens ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+UPDATE refresh_tokens SET authenticated_at = created_at, last_used_at = created_at WHERE created_at IS NOT NULL;
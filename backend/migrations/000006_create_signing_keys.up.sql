@@ -0,0 +1,14 @@
+-- Create signing_keys table (access token key ring)
+CREATE TABLE signing_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('next', 'active', 'previous')),
+    activated_at TIMESTAMP WITH TIME ZONE,
+    deactivated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_signing_keys_status ON signing_keys(status);
+CREATE UNIQUE INDEX idx_signing_keys_single_active ON signing_keys(status) WHERE status = 'active';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS revoked_tokens;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS access_token_jti;
@@ -0,0 +1,14 @@
+-- Remember the access token issued alongside each refresh token
+ALTER TABLE refresh_tokens ADD COLUMN access_token_jti VARCHAR(64) NOT NULL DEFAULT '';
+
+-- Create revoked_tokens table (access token jti denylist)
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_revoked_tokens_user_id ON revoked_tokens(user_id);
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
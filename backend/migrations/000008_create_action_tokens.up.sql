@@ -0,0 +1,14 @@
+-- Create action_tokens table (single-use emailed tokens, e.g. password reset)
+CREATE TABLE action_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_action_tokens_user_id_purpose ON action_tokens(user_id, purpose);
+CREATE INDEX idx_action_tokens_expires_at ON action_tokens(expires_at);
@@ -0,0 +1,60 @@
+-- Create oauth_clients table (applications using this server as IdP)
+CREATE TABLE oauth_clients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id VARCHAR(64) UNIQUE NOT NULL,
+    secret_hash VARCHAR(64),
+    name VARCHAR(255) NOT NULL,
+    redirect_uris JSONB NOT NULL DEFAULT '[]',
+    grant_types JSONB NOT NULL DEFAULT '[]',
+    scopes JSONB NOT NULL DEFAULT '[]',
+    skip_consent BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create oauth_authorizations table (pending requests and authorization codes)
+CREATE TABLE oauth_authorizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    state TEXT,
+    nonce TEXT,
+    code_challenge TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) UNIQUE,
+    auth_time TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create oauth_consents table
+CREATE TABLE oauth_consents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create oauth_refresh_tokens table
+CREATE TABLE oauth_refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    family_id UUID NOT NULL,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    consumed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_oauth_authorizations_client_id ON oauth_authorizations(client_id);
+CREATE INDEX idx_oauth_authorizations_expires_at ON oauth_authorizations(expires_at);
+CREATE UNIQUE INDEX idx_oauth_consents_user_client ON oauth_consents(user_id, client_id);
+CREATE INDEX idx_oauth_refresh_tokens_family_id ON oauth_refresh_tokens(family_id);
+CREATE INDEX idx_oauth_refresh_tokens_user_id ON oauth_refresh_tokens(user_id);
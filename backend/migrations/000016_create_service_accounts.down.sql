@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS service_account_secrets;
+DROP TABLE IF EXISTS service_accounts;
@@ -0,0 +1 @@
+ALTER TABLE action_tokens ADD COLUMN binding_hash VARCHAR(64);
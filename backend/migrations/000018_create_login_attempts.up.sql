@@ -0,0 +1,9 @@
+-- Create login_attempts table (failed logins per account and per IP)
+CREATE TABLE login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create indexes
+CREATE INDEX idx_login_attempts_last_failure_at ON login_attempts(last_failure_at);
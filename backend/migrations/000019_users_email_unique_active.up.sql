@@ -0,0 +1,4 @@
+-- Only active accounts need unique email addresses, so a deleted account
+-- can free its address for a new registration
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX idx_users_email_active ON users(email) WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_action_tokens_email;
+ALTER TABLE action_tokens DROP COLUMN IF EXISTS email;
@@ -0,0 +1,2 @@
+ALTER TABLE action_tokens ADD COLUMN email VARCHAR(255);
+CREATE INDEX idx_action_tokens_email ON action_tokens(email) WHERE email IS NOT NULL;
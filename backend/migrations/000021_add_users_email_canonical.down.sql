@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_email_canonical_active;
+ALTER TABLE users DROP COLUMN IF EXISTS email_canonical;
+CREATE UNIQUE INDEX idx_users_email_active ON users(email) WHERE deleted_at IS NULL;
@@ -0,0 +1,47 @@
+-- Accounts are identified by a canonical form of their email address:
+-- trimmed, NFC-normalized and lowercased, with internationalized domains
+-- in punycode. SQL cannot convert to punycode, so addresses with non-ASCII
+-- characters only get an approximation here. Completing this migration
+-- takes a second, Go-side step:
+--
+--   userctl canonicalize-emails   (task user:canonicalize-emails)
+--
+-- which recomputes them and refuses to change anything if two active users
+-- would collide. The server checks at startup and refuses to start while
+-- any user still needs it.
+ALTER TABLE users ADD COLUMN email_canonical VARCHAR(255);
+UPDATE users SET email_canonical = lower(normalize(btrim(email, E' \t\r\n'), NFC));
+
+-- Addresses that differed only in case or normalization now collide.
+-- Stop with a list of them instead of failing on the unique index, so the
+-- duplicate accounts can be merged, renamed or deleted first.
+DO $$
+DECLARE
+    collisions TEXT;
+    pending    BIGINT;
+BEGIN
+    SELECT string_agg(format('  %s: %s', email_canonical, accounts), E'\n')
+    INTO collisions
+    FROM (
+        SELECT email_canonical,
+               string_agg(format('%s (id %s)', email, id), ', ' ORDER BY created_at) AS accounts
+        FROM users
+        WHERE deleted_at IS NULL
+        GROUP BY email_canonical
+        HAVING count(*) > 1
+    ) AS duplicates;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION E'active users share an email address once case and normalization are ignored:\n%', collisions
+            USING HINT = 'Keep one account per address (change or delete the others), then run the migration again.';
+    END IF;
+
+    SELECT count(*) INTO pending FROM users WHERE octet_length(email) <> char_length(email);
+    IF pending > 0 THEN
+        RAISE WARNING '% users have non-ASCII email addresses; the server will not start until `userctl canonicalize-emails` has finished them', pending;
+    END IF;
+END $$;
+
+ALTER TABLE users ALTER COLUMN email_canonical SET NOT NULL;
+DROP INDEX idx_users_email_active;
+CREATE UNIQUE INDEX idx_users_email_canonical_active ON users(email_canonical) WHERE deleted_at IS NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS authz_version;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
@@ -0,0 +1,2 @@
+DELETE FROM roles WHERE name IN ('admin', 'member');
+DELETE FROM permissions WHERE name IN ('users:read', 'users:write', 'roles:read', 'roles:assign');
@@ -0,0 +1,22 @@
+-- Seed the built-in permissions and roles. Migrations that change what a
+-- role grants must also bump users.authz_version of its holders.
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'View user accounts'),
+    ('users:write', 'Manage user accounts'),
+    ('roles:read', 'View roles and role assignments'),
+    ('roles:assign', 'Assign roles to users and remove them');
+
+INSERT INTO roles (name, description, is_default) VALUES
+    ('admin', 'Full access to user and role management', FALSE),
+    ('member', 'Regular user, assigned on sign-up', TRUE);
+
+INSERT INTO role_permissions (role_id, permission_name)
+SELECT roles.id, permissions.name
+FROM roles CROSS JOIN permissions
+WHERE roles.name = 'admin';
+
+-- Existing users become members; admins are appointed with userctl
+INSERT INTO user_roles (user_id, role_id)
+SELECT users.id, roles.id
+FROM users CROSS JOIN roles
+WHERE roles.name = 'member';
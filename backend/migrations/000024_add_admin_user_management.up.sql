@@ -0,0 +1,16 @@
+-- Account state managed by admins
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Who did what to which account. No foreign keys, so the record survives
+-- the accounts involved.
+CREATE TABLE admin_actions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_admin_actions_actor_id ON admin_actions(actor_id);
+CREATE INDEX idx_admin_actions_user_id ON admin_actions(user_id);
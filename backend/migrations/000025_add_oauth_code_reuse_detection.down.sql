@@ -0,0 +1,3 @@
+ALTER TABLE oauth_authorizations DROP COLUMN IF EXISTS access_token_jti;
+ALTER TABLE oauth_authorizations DROP COLUMN IF EXISTS family_id;
+ALTER TABLE oauth_authorizations DROP COLUMN IF EXISTS consumed_at;
@@ -0,0 +1,5 @@
+-- Redeemed authorization codes are kept with the tokens issued for them,
+-- so presenting a code again revokes those tokens
+ALTER TABLE oauth_authorizations ADD COLUMN consumed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE oauth_authorizations ADD COLUMN family_id UUID;
+ALTER TABLE oauth_authorizations ADD COLUMN access_token_jti VARCHAR(64);
@@ -0,0 +1,9 @@
+-- Create rate_limits table (requests per key in fixed windows)
+CREATE TABLE rate_limits (
+    key VARCHAR(400) PRIMARY KEY,
+    hits INTEGER NOT NULL,
+    window_start TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create indexes
+CREATE INDEX idx_rate_limits_window_start ON rate_limits(window_start);
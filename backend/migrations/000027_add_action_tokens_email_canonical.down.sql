@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_action_tokens_email_canonical;
+ALTER TABLE action_tokens DROP COLUMN IF EXISTS email_canonical;
+CREATE INDEX idx_action_tokens_email ON action_tokens(email) WHERE email IS NOT NULL;
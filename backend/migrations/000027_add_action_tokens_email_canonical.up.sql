@@ -0,0 +1,8 @@
+-- Email change tokens keep the address as typed and look it up by its
+-- canonical form, like users do. Existing rows get the SQL approximation
+-- of migration 000021; revert tokens already hold a canonical address.
+ALTER TABLE action_tokens ADD COLUMN email_canonical VARCHAR(255);
+UPDATE action_tokens SET email_canonical = lower(normalize(btrim(email, E' \t\r\n'), NFC)) WHERE email IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_action_tokens_email;
+CREATE INDEX idx_action_tokens_email_canonical ON action_tokens(email_canonical) WHERE email_canonical IS NOT NULL;
@@ -4,16 +4,32 @@ package response
 type SuccessResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
+	Meta    *Meta       `json:"meta,omitempty"`
+}
+
+// Meta describes the page of a list response. NextCursor is passed as the
+// cursor query parameter to read the next page.
+type Meta struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
 }
 
 // ErrorResponse represents an error API response
 type ErrorResponse struct {
-	Success bool       `json:"success"`
+	Success bool        `json:"success"`
 	Error   ErrorDetail `json:"error"`
 }
 
 // ErrorDetail contains error information
 type ErrorDetail struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError describes one problem with one request field
+type FieldError struct {
+	Field   string `json:"field"`
 	Code    string `json:"code"`
 	Message string `json:"message"`
 }
@@ -26,6 +42,13 @@ func Success(data interface{}) SuccessResponse {
 	}
 }
 
+// SuccessWithMeta creates a successful response for a page of a list
+func SuccessWithMeta(data interface{}, meta Meta) SuccessResponse {
+	res := Success(data)
+	res.Meta = &meta
+	return res
+}
+
 // Error creates an error response
 func Error(code, message string) ErrorResponse {
 	return ErrorResponse{
@@ -37,15 +60,31 @@ func Error(code, message string) ErrorResponse {
 	}
 }
 
+// ErrorWithDetails creates an error response with field-level details
+func ErrorWithDetails(code, message string, details []FieldError) ErrorResponse {
+	res := Error(code, message)
+	res.Error.Details = details
+	return res
+}
+
 // Common error codes
 const (
-	CodeValidationError   = "VALIDATION_ERROR"
-	CodeUnauthorized      = "UNAUTHORIZED"
-	CodeForbidden         = "FORBIDDEN"
-	CodeNotFound          = "NOT_FOUND"
-	CodeConflict          = "CONFLICT"
-	CodeInternalError     = "INTERNAL_ERROR"
-	CodeInvalidCredentials = "INVALID_CREDENTIALS"
-	CodeTokenExpired      = "TOKEN_EXPIRED"
-	CodeTokenInvalid      = "TOKEN_INVALID"
+	CodeValidationError       = "VALIDATION_ERROR"
+	CodeUnauthorized          = "UNAUTHORIZED"
+	CodeForbidden             = "FORBIDDEN"
+	CodeNotFound              = "NOT_FOUND"
+	CodeConflict              = "CONFLICT"
+	CodeInternalError         = "INTERNAL_ERROR"
+	CodeInvalidCredentials    = "INVALID_CREDENTIALS"
+	CodeTokenExpired          = "TOKEN_EXPIRED"
+	CodeTokenInvalid          = "TOKEN_INVALID"
+	CodeTokenReused           = "TOKEN_REUSED"
+	CodeTokenRevoked          = "TOKEN_REVOKED"
+	CodeEmailNotVerified      = "EMAIL_NOT_VERIFIED"
+	CodeMFAInvalidCode        = "MFA_INVALID_CODE"
+	CodeInsufficientScope     = "INSUFFICIENT_SCOPE"
+	CodeRateLimited           = "RATE_LIMITED"
+	CodeAccountLocked         = "ACCOUNT_LOCKED"
+	CodeAccountDisabled       = "ACCOUNT_DISABLED"
+	CodePasswordResetRequired = "PASSWORD_RESET_REQUIRED"
 )
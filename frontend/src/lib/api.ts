@@ -1,4 +1,10 @@
-import type { ApiError, AuthResponse, LoginRequest, RegisterRequest, User } from "@/types/auth";
+import type {
+  ApiError,
+  AuthResponse,
+  CurrentUser,
+  LoginRequest,
+  RegisterRequest,
+} from "@/types/auth";
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
 
@@ -74,7 +80,7 @@ class ApiClient {
     this.setAccessToken(null);
   }
 
-  async getMe(): Promise<{ success: boolean; data: User }> {
+  async getMe(): Promise<{ success: boolean; data: CurrentUser }> {
     return this.request("/auth/me");
   }
 }
@@ -2,10 +2,22 @@ export interface User {
   id: string;
   email: string;
   name: string;
+  email_verified_at: string | null;
   created_at: string;
   updated_at: string;
 }
 
+export interface MFAStatus {
+  enabled: boolean;
+  recovery_codes_remaining: number;
+  low_recovery_codes: boolean;
+}
+
+export interface CurrentUser extends User {
+  mfa: MFAStatus;
+  warnings?: string[];
+}
+
 export interface AuthResponse {
   success: boolean;
   data: {
@@ -26,10 +38,17 @@ export interface RegisterRequest {
   name: string;
 }
 
+export interface FieldError {
+  field: string;
+  code: string;
+  message: string;
+}
+
 export interface ApiError {
   success: false;
   error: {
     code: string;
     message: string;
+    details?: FieldError[];
   };
 }